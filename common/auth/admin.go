@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CreateTokenRequest is the body of a token creation request
+type CreateTokenRequest struct {
+	Label     string   `json:"label"`
+	ExpiresIn string   `json:"expires_in,omitempty"` // Go duration, e.g. "720h"; empty means no expiry
+	Scopes    []string `json:"scopes,omitempty"`     // Empty means full access
+	TenantID  string   `json:"tenant_id,omitempty"`
+}
+
+// TokenIDRequest identifies a token for revoke and expire requests
+type TokenIDRequest struct {
+	ID        string `json:"id"`
+	ExpiresIn string `json:"expires_in,omitempty"` // Expire only: grace period before expiry; empty expires now
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"status": "error", "error": msg})
+}
+
+// adminStore returns the token store if token management is possible
+func (a *AuthMiddleware) adminStore(w http.ResponseWriter) *TokenStore {
+	if !a.IsEnabled() {
+		// Without authentication anyone could mint tokens
+		writeError(w, http.StatusForbidden, "token management requires authentication to be enabled (-api-key, -auth-tokens or -jwt-jwks)")
+		return nil
+	}
+	store := a.TokenStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "token store not configured")
+		return nil
+	}
+	return store
+}
+
+// validScopes reports whether all scopes are known
+func validScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if s != ScopeReadPages && s != ScopeWriteWAL && s != ScopeReplicate && s != ScopeAdmin {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleTokens lists tokens (GET) or creates a token (POST)
+func (a *AuthMiddleware) HandleTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.handleListTokens(w, r)
+	case http.MethodPost:
+		a.handleCreateToken(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AuthMiddleware) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	store := a.adminStore(w)
+	if store == nil {
+		return
+	}
+
+	tokens := append(a.staticTokenRecords(), store.List()...)
+	now := time.Now()
+	list := make([]map[string]interface{}, 0, len(tokens))
+	for _, t := range tokens {
+		var createdAt *time.Time // Unknown for static tokens
+		if !t.CreatedAt.IsZero() {
+			createdAt = &t.CreatedAt
+		}
+		list = append(list, map[string]interface{}{
+			"id":         t.ID,
+			"token":      t.Prefix + "****", // Masked
+			"label":      t.Label,
+			"scopes":     t.Scopes,
+			"tenant_id":  t.TenantID,
+			"source":     t.Source,
+			"created_at": createdAt,
+			"expires_at": t.ExpiresAt,
+			"last_used":  t.LastUsed,
+			"expired":    t.Expired(now),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"tokens": list,
+	})
+}
+
+func (a *AuthMiddleware) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	store := a.adminStore(w)
+	if store == nil {
+		return
+	}
+
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !validScopes(req.Scopes) {
+		writeError(w, http.StatusBadRequest, "unknown scope (supported: read_pages, write_wal, replicate, admin)")
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid expires_in")
+			return
+		}
+	}
+
+	token, record, err := store.Create(strings.TrimSpace(req.Label), ttl, req.Scopes, req.TenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status":     "ok",
+		"id":         record.ID,
+		"token":      token, // Only returned once
+		"label":      record.Label,
+		"scopes":     record.Scopes,
+		"tenant_id":  record.TenantID,
+		"expires_at": record.ExpiresAt,
+	})
+}
+
+// HandleRevokeToken deletes a token immediately
+func (a *AuthMiddleware) HandleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store := a.adminStore(w)
+	if store == nil {
+		return
+	}
+
+	var req TokenIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "Invalid JSON: id is required", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if strings.HasPrefix(req.ID, "static-") {
+		err = a.revokeStatic(req.ID)
+	} else {
+		err = store.Revoke(req.ID)
+	}
+	if errors.Is(err, ErrTokenNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "id": req.ID})
+}
+
+// HandleExpireToken sets a token's expiry, optionally after a grace period
+// so clients can switch to a replacement token first
+func (a *AuthMiddleware) HandleExpireToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store := a.adminStore(w)
+	if store == nil {
+		return
+	}
+
+	var req TokenIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "Invalid JSON: id is required", http.StatusBadRequest)
+		return
+	}
+
+	at := time.Now()
+	if req.ExpiresIn != "" {
+		grace, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || grace < 0 {
+			writeError(w, http.StatusBadRequest, "invalid expires_in")
+			return
+		}
+		at = at.Add(grace)
+	}
+
+	if strings.HasPrefix(req.ID, "static-") {
+		writeError(w, http.StatusBadRequest, "static tokens cannot expire; revoke them instead")
+		return
+	}
+
+	record, err := store.Expire(req.ID, at)
+	if errors.Is(err, ErrTokenNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"id":         record.ID,
+		"expires_at": record.ExpiresAt,
+	})
+}
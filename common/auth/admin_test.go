@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// call sends body to handler and decodes the JSON response
+func call(t *testing.T, handler http.HandlerFunc, method, body string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestAdminTokens(t *testing.T) {
+	store, err := NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	a := NewAuthMiddleware("key", "")
+	a.SetTokenStore(store)
+
+	code, resp := call(t, a.HandleTokens, http.MethodPost, `{"label":"ci","scopes":["read_pages"],"tenant_id":"t1","expires_in":"1h"}`)
+	if code != http.StatusCreated || resp["token"] == "" || resp["expires_at"] == nil {
+		t.Fatalf("create: %d %v", code, resp)
+	}
+	token, id := resp["token"].(string), resp["id"].(string)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		want    int
+	}{
+		{"unknown scope", a.HandleTokens, http.MethodPost, `{"scopes":["delete"]}`, http.StatusBadRequest},
+		{"invalid expires_in", a.HandleTokens, http.MethodPost, `{"expires_in":"-1h"}`, http.StatusBadRequest},
+		{"invalid JSON", a.HandleTokens, http.MethodPost, `{`, http.StatusBadRequest},
+		{"wrong method", a.HandleTokens, http.MethodDelete, ``, http.StatusMethodNotAllowed},
+		{"revoke without id", a.HandleRevokeToken, http.MethodPost, `{}`, http.StatusBadRequest},
+		{"revoke unknown", a.HandleRevokeToken, http.MethodPost, `{"id":"missing"}`, http.StatusNotFound},
+		{"expire unknown", a.HandleExpireToken, http.MethodPost, `{"id":"missing"}`, http.StatusNotFound},
+		{"expire static", a.HandleExpireToken, http.MethodPost, `{"id":"static-00000000"}`, http.StatusBadRequest},
+		{"expire with grace", a.HandleExpireToken, http.MethodPost, `{"id":"` + id + `","expires_in":"10m"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, resp := call(t, tt.handler, tt.method, tt.body); code != tt.want {
+				t.Fatalf("status %d, want %d: %v", code, tt.want, resp)
+			}
+		})
+	}
+
+	// Listing masks the token
+	code, resp = call(t, a.HandleTokens, http.MethodGet, ``)
+	tokens, _ := resp["tokens"].([]interface{})
+	if code != http.StatusOK || len(tokens) != 1 {
+		t.Fatalf("list: %d %v", code, resp)
+	}
+	listed := tokens[0].(map[string]interface{})
+	if masked := listed["token"].(string); !strings.HasSuffix(masked, "****") || strings.Contains(masked, token) {
+		t.Fatalf("listed token not masked: %v", listed)
+	}
+
+	if _, ok := store.Lookup(token); !ok {
+		t.Fatal("token in its grace period rejected")
+	}
+	if code, resp := call(t, a.HandleRevokeToken, http.MethodPost, `{"id":"`+id+`"}`); code != http.StatusOK {
+		t.Fatalf("revoke: %d %v", code, resp)
+	}
+	if _, ok := store.Lookup(token); ok {
+		t.Fatal("revoked token accepted")
+	}
+}
+
+func TestAdminTokensRequireAuthentication(t *testing.T) {
+	store, err := NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+
+	// Without credentials anyone could mint a token
+	open := NewAuthMiddleware("", "")
+	open.SetTokenStore(store)
+	if code, _ := call(t, open.HandleTokens, http.MethodPost, `{}`); code != http.StatusForbidden {
+		t.Fatalf("create without authentication: %d, want 403", code)
+	}
+	if store.Len() != 0 {
+		t.Fatalf("token created without authentication")
+	}
+
+	noStore := NewAuthMiddleware("key", "")
+	if code, _ := call(t, noStore.HandleTokens, http.MethodGet, ``); code != http.StatusServiceUnavailable {
+		t.Fatalf("list without a store: %d, want 503", code)
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Roles assigned to client certificate identities
+const (
+	RoleCompute = "compute" // Compute nodes: read pages, write WAL
+	RolePeer    = "peer"    // Other storage nodes: replication and internal calls
+	RoleAdmin   = "admin"   // Control plane and operators
+)
+
+// ScopeReplicate allows internal replication and consensus calls between peers
+const ScopeReplicate = "replicate"
+
+// roleScopes maps roles to the scopes they grant
+var roleScopes = map[string][]string{
+	RoleCompute: {ScopeReadPages, ScopeWriteWAL},
+	RolePeer:    {ScopeReadPages, ScopeWriteWAL, ScopeReplicate},
+	RoleAdmin:   {ScopeAdmin},
+}
+
+// CertIdentity is a verified client certificate matched by a role mapping.
+// Certificate identities are service identities and are not tied to a
+// tenant. They are kept apart from the claims so no token can pose as one.
+type CertIdentity struct {
+	SAN  string
+	Role string
+}
+
+// CertRoleMapping maps certificate SANs matching Pattern to Role
+type CertRoleMapping struct {
+	Pattern string // path.Match pattern, e.g. "spiffe://cluster/safekeeper/*" or "*.compute.internal"
+	Role    string
+}
+
+// ParseCertRoles parses "pattern=role,pattern=role"
+func ParseCertRoles(spec string) ([]CertRoleMapping, error) {
+	var mappings []CertRoleMapping
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid certificate role mapping %q (expected pattern=role)", entry)
+		}
+		pattern, role := entry[:i], entry[i+1:]
+		if _, ok := roleScopes[role]; !ok {
+			return nil, fmt.Errorf("unknown role %q (supported: compute, peer, admin)", role)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		mappings = append(mappings, CertRoleMapping{Pattern: pattern, Role: role})
+	}
+	return mappings, nil
+}
+
+// certSANs returns the identities in a certificate: URIs, DNS names, emails and IPs
+func certSANs(cert *x509.Certificate) []string {
+	var sans []string
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// certClaims maps the verified client certificate of r to an identity and
+// the claims of its role, or nil if there is none or no mapping matches.
+// The first matching mapping wins.
+func certClaims(r *http.Request, mappings []CertRoleMapping) (*CertIdentity, *Claims) {
+	if len(mappings) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	for _, m := range mappings {
+		for _, san := range certSANs(leaf) {
+			if ok, _ := path.Match(m.Pattern, san); ok {
+				return &CertIdentity{SAN: san, Role: m.Role}, &Claims{
+					Subject: san,
+					Scopes:  roleScopes[m.Role],
+				}
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCertRoles(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []CertRoleMapping
+		err  string
+	}{
+		{"", nil, ""},
+		{"spiffe://cluster/safekeeper/*=peer, *.compute.internal=compute",
+			[]CertRoleMapping{{"spiffe://cluster/safekeeper/*", RolePeer}, {"*.compute.internal", RoleCompute}}, ""},
+		{"a=b=admin", []CertRoleMapping{{"a=b", RoleAdmin}}, ""},
+		{"ops.internal", nil, "expected pattern=role"},
+		{"=admin", nil, "expected pattern=role"},
+		{"ops.internal=root", nil, `unknown role "root"`},
+		{"[ops=admin", nil, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseCertRoles(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseCertRoles = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertRoles(t *testing.T) {
+	mappings, err := ParseCertRoles("spiffe://cluster/safekeeper/*=peer,*.compute.internal=compute,ops@example.com=admin")
+	if err != nil {
+		t.Fatalf("ParseCertRoles: %v", err)
+	}
+	a := NewAuthMiddleware("", "")
+	a.SetCertRoles(mappings)
+	a.SetServedTenant("t1", "")
+
+	peer, _ := url.Parse("spiffe://cluster/safekeeper/sk-1")
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		verified bool
+		scope    string
+		want     int
+	}{
+		{"peer URI", &x509.Certificate{URIs: []*url.URL{peer}}, true, ScopeReplicate, http.StatusOK},
+		{"compute DNS name", &x509.Certificate{DNSNames: []string{"c1.compute.internal"}}, true, ScopeWriteWAL, http.StatusOK},
+		{"compute cannot replicate", &x509.Certificate{DNSNames: []string{"c1.compute.internal"}}, true, ScopeReplicate, http.StatusForbidden},
+		{"admin email", &x509.Certificate{EmailAddresses: []string{"ops@example.com"}}, true, ScopeAdmin, http.StatusOK},
+		{"unmapped", &x509.Certificate{DNSNames: []string{"c1.other.internal"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}, true, ScopeReadPages, http.StatusUnauthorized},
+		{"not verified", &x509.Certificate{DNSNames: []string{"c1.compute.internal"}}, false, ScopeReadPages, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			if tt.verified {
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{tt.cert}}
+			}
+			w := httptest.NewRecorder()
+			a.Middleware(a.Require(tt.scope, func(w http.ResponseWriter, r *http.Request) {
+				cert := CertIdentityFromContext(r.Context())
+				if claims := ClaimsFromContext(r.Context()); claims == nil || cert == nil || cert.SAN != claims.Subject {
+					t.Errorf("claims %+v, certificate %+v", claims, cert)
+				}
+			}))(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
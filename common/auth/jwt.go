@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token scopes
+const (
+	ScopeReadPages = "read_pages" // Read pages and metadata
+	ScopeWriteWAL  = "write_wal"  // Stream WAL
+	ScopeAdmin     = "admin"      // Administrative operations; implies all other scopes
+)
+
+// clockSkew is the leeway allowed when checking exp and nbf
+const clockSkew = time.Minute
+
+// Claims are the JWT claims understood by the service
+type Claims struct {
+	Subject    string   `json:"sub,omitempty"`
+	Issuer     string   `json:"iss,omitempty"`
+	Audience   Audience `json:"aud,omitempty"`
+	TenantID   string   `json:"tenant_id,omitempty"`
+	TimelineID string   `json:"timeline_id,omitempty"`
+	Scopes     []string `json:"scopes,omitempty"`
+	ExpiresAt  int64    `json:"exp"`
+	NotBefore  int64    `json:"nbf,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+}
+
+// Audience is the aud claim, which may be a single string or an array
+type Audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether aud names audience
+func (a Audience) Contains(audience string) bool {
+	for _, s := range a {
+		if s == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the claims grant scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// JWK is a JSON Web Key (only the fields used for EdDSA and RS256)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// verificationKey is a parsed public key
+type verificationKey struct {
+	kid string
+	alg string // "EdDSA" or "RS256"
+	ed  ed25519.PublicKey
+	rsa *rsa.PublicKey
+}
+
+// reservedIssuer is rejected in tokens: it names client certificate
+// identities, which never come from a token
+const reservedIssuer = "mtls"
+
+// JWTVerifier verifies EdDSA and RS256 tokens against keys from a JWKS file
+type JWTVerifier struct {
+	path     string
+	issuer   string // Required iss, if set
+	audience string // Required entry in aud, if set
+	mu       sync.RWMutex
+	keys     []verificationKey
+}
+
+// NewJWTVerifier loads the JWKS file at path. Tokens must be issued by issuer
+// and name audience in their aud claim; empty values skip the check.
+func NewJWTVerifier(path, issuer, audience string) (*JWTVerifier, error) {
+	v := &JWTVerifier{path: path, issuer: issuer, audience: audience}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Path returns the JWKS file path
+func (v *JWTVerifier) Path() string {
+	return v.path
+}
+
+// SameKeys reports whether both verifiers accept tokens signed by the same keys
+func (v *JWTVerifier) SameKeys(other *JWTVerifier) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	if len(v.keys) != len(other.keys) {
+		return false
+	}
+	for i, key := range v.keys {
+		o := other.keys[i]
+		if key.kid != o.kid || key.alg != o.alg || !key.ed.Equal(o.ed) {
+			return false
+		}
+		if (key.rsa == nil) != (o.rsa == nil) || key.rsa != nil && !key.rsa.Equal(o.rsa) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reload re-reads the JWKS file (for key rotation)
+func (v *JWTVerifier) Reload() error {
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return fmt.Errorf("invalid key %q in JWKS file: %w", jwk.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS file %s contains no keys", v.path)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+// parseJWK converts a JWK to a verification key
+func parseJWK(jwk JWK) (verificationKey, error) {
+	switch jwk.Kty {
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return verificationKey{}, fmt.Errorf("unsupported OKP curve: %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return verificationKey{}, fmt.Errorf("invalid Ed25519 public key")
+		}
+		return verificationKey{kid: jwk.Kid, alg: "EdDSA", ed: ed25519.PublicKey(x)}, nil
+
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, fmt.Errorf("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < 2048 {
+			return verificationKey{}, fmt.Errorf("RSA key too small: %d bits", pub.N.BitLen())
+		}
+		return verificationKey{kid: jwk.Kid, alg: "RS256", rsa: pub}, nil
+
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+	}
+}
+
+// LooksLikeJWT reports whether a bearer token has the JWT compact form
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the signature, validity period, issuer and audience of a
+// token and returns its claims
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+
+	if !v.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if claims.Issuer == reservedIssuer {
+		return nil, fmt.Errorf("token issuer %q is reserved", reservedIssuer)
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("token issuer %q is not accepted", claims.Issuer)
+	}
+	if v.audience != "" && !claims.Audience.Contains(v.audience) {
+		return nil, fmt.Errorf("token is not intended for %q", v.audience)
+	}
+
+	return &claims, nil
+}
+
+// verifySignature checks signature with the key matching kid and alg.
+// Tokens without a kid are tried against every key of the right type.
+func (v *JWTVerifier) verifySignature(alg, kid string, signed, signature []byte) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, key := range v.keys {
+		if key.alg != alg || (kid != "" && key.kid != kid) {
+			continue
+		}
+		switch alg {
+		case "EdDSA":
+			if ed25519.Verify(key.ed, signed, signature) {
+				return true
+			}
+		case "RS256":
+			digest := sha256.Sum256(signed)
+			if rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
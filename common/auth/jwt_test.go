@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve sends a bearer token through Middleware and Require(scope)
+func serve(a *AuthMiddleware, scope, token string) int {
+	handler := a.Middleware(a.Require(scope, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w.Code
+}
+
+// writeJWKS writes a JWKS file with the given keys and returns its path
+func writeJWKS(t *testing.T, keys ...JWK) string {
+	t.Helper()
+	data, err := json.Marshal(JWKS{Keys: keys})
+	if err != nil {
+		t.Fatalf("marshal JWKS: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write JWKS: %v", err)
+	}
+	return path
+}
+
+func edJWK(kid string, pub ed25519.PublicKey) JWK {
+	return JWK{Kty: "OKP", Crv: "Ed25519", Kid: kid, X: base64.RawURLEncoding.EncodeToString(pub)}
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// signJWT builds a compact token; sign receives the signing input
+func signJWT(t *testing.T, alg, kid string, claims Claims, sign func([]byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func TestJWTVerify(t *testing.T) {
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	v, err := NewJWTVerifier(writeJWKS(t, edJWK("ed", edPub), rsaJWK("rsa", &rsaKey.PublicKey)), "", "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	edSign := func(b []byte) []byte { return ed25519.Sign(edKey, b) }
+	rsaSign := func(b []byte) []byte {
+		digest := sha256.Sum256(b)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+		return sig
+	}
+	hmacSign := func(b []byte) []byte {
+		mac := hmac.New(sha256.New, edPub) // Public key used as the HMAC secret
+		mac.Write(b)
+		return mac.Sum(nil)
+	}
+
+	now := time.Now()
+	valid := Claims{Subject: "compute-1", TenantID: "t1", Scopes: []string{ScopeReadPages}, ExpiresAt: now.Add(time.Hour).Unix()}
+	with := func(f func(*Claims)) Claims {
+		c := valid
+		f(&c)
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"EdDSA", signJWT(t, "EdDSA", "ed", valid, edSign), ""},
+		{"RS256", signJWT(t, "RS256", "rsa", valid, rsaSign), ""},
+		{"no kid", signJWT(t, "EdDSA", "", valid, edSign), ""},
+		{"within clock skew", signJWT(t, "EdDSA", "ed", with(func(c *Claims) { c.ExpiresAt = now.Add(-30 * time.Second).Unix() }), edSign), ""},
+		{"expired", signJWT(t, "EdDSA", "ed", with(func(c *Claims) { c.ExpiresAt = now.Add(-2 * time.Minute).Unix() }), edSign), "token expired"},
+		{"no expiry", signJWT(t, "EdDSA", "ed", with(func(c *Claims) { c.ExpiresAt = 0 }), edSign), "token has no expiry"},
+		{"not yet valid", signJWT(t, "EdDSA", "ed", with(func(c *Claims) { c.NotBefore = now.Add(2 * time.Minute).Unix() }), edSign), "token not yet valid"},
+		{"unknown kid", signJWT(t, "EdDSA", "other", valid, edSign), "invalid token signature"},
+		{"kid of another key type", signJWT(t, "EdDSA", "rsa", valid, edSign), "invalid token signature"},
+		{"alg of another key", signJWT(t, "RS256", "ed", valid, edSign), "invalid token signature"},
+		{"HS256", signJWT(t, "HS256", "ed", valid, hmacSign), "invalid token signature"},
+		{"none", signJWT(t, "none", "", valid, func([]byte) []byte { return nil }), "invalid token signature"},
+		{"wrong key", signJWT(t, "EdDSA", "ed", valid, func(b []byte) []byte {
+			_, other, _ := ed25519.GenerateKey(rand.Reader)
+			return ed25519.Sign(other, b)
+		}), "invalid token signature"},
+		{"malformed", "a.b", "malformed token"},
+		{"reserved issuer", signJWT(t, "EdDSA", "ed", with(func(c *Claims) { c.Issuer = "mtls" }), edSign), `token issuer "mtls" is reserved`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(tt.token)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Subject != "compute-1" || claims.TenantID != "t1" {
+					t.Fatalf("claims: %+v", claims)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("Verify error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// A payload swapped after signing fails verification
+	token := signJWT(t, "EdDSA", "ed", valid, edSign)
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(with(func(c *Claims) { c.Scopes = []string{ScopeAdmin} }))
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+	if _, err := v.Verify(strings.Join(parts, ".")); err == nil {
+		t.Fatal("Verify accepted a modified payload")
+	}
+}
+
+func TestJWTIssuerAndAudience(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	v, err := NewJWTVerifier(writeJWKS(t, edJWK("ed", pub)), "control-plane", "page-server")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		payload string
+		err     string
+	}{
+		{"audience string", `{"iss":"control-plane","aud":"page-server","exp":%d}`, ""},
+		{"audience list", `{"iss":"control-plane","aud":["safekeeper","page-server"],"exp":%d}`, ""},
+		{"other issuer", `{"iss":"someone-else","aud":"page-server","exp":%d}`, `token issuer "someone-else" is not accepted`},
+		{"no issuer", `{"aud":"page-server","exp":%d}`, `token issuer "" is not accepted`},
+		{"other audience", `{"iss":"control-plane","aud":["safekeeper"],"exp":%d}`, `token is not intended for "page-server"`},
+		{"no audience", `{"iss":"control-plane","exp":%d}`, `token is not intended for "page-server"`},
+		{"invalid audience", `{"iss":"control-plane","aud":7,"exp":%d}`, "malformed token claims"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","kid":"ed"}`))
+			payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(tt.payload, exp)))
+			signed := header + "." + payload
+			token := signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+
+			_, err := v.Verify(token)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("Verify error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestJWKSKeys(t *testing.T) {
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		keys []JWK
+		err  string
+	}{
+		{"Ed25519", []JWK{edJWK("ed", edPub)}, ""},
+		{"RSA under 2048 bits", []JWK{rsaJWK("small", &small.PublicKey)}, "RSA key too small: 1024 bits"},
+		{"unsupported curve", []JWK{{Kty: "OKP", Crv: "X25519", X: base64.RawURLEncoding.EncodeToString(edPub)}}, "unsupported OKP curve"},
+		{"short Ed25519 key", []JWK{{Kty: "OKP", Crv: "Ed25519", X: "AAAA"}}, "invalid Ed25519 public key"},
+		{"symmetric key", []JWK{{Kty: "oct"}}, "unsupported key type: oct"},
+		{"empty", nil, "contains no keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJWTVerifier(writeJWKS(t, tt.keys...), "", "")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("NewJWTVerifier: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("NewJWTVerifier error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestJWTRequire(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	v, err := NewJWTVerifier(writeJWKS(t, edJWK("ed", pub)), "", "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	a := NewAuthMiddleware("", "")
+	a.SetJWTVerifier(v)
+	a.SetServedTenant("t1", "tl1")
+
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name   string
+		claims Claims
+		scope  string
+		want   int
+	}{
+		{"tenant and scope", Claims{TenantID: "t1", Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeReadPages, http.StatusOK},
+		{"timeline", Claims{TenantID: "t1", TimelineID: "tl1", Scopes: []string{ScopeWriteWAL}, ExpiresAt: exp}, ScopeWriteWAL, http.StatusOK},
+		{"missing scope", Claims{TenantID: "t1", Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeWriteWAL, http.StatusForbidden},
+		{"no scopes", Claims{TenantID: "t1", ExpiresAt: exp}, ScopeReadPages, http.StatusForbidden},
+		{"other tenant", Claims{TenantID: "t2", Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeReadPages, http.StatusForbidden},
+		{"no tenant", Claims{Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeReadPages, http.StatusForbidden},
+		{"other timeline", Claims{TenantID: "t1", TimelineID: "tl2", Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeReadPages, http.StatusForbidden},
+		{"admin of any tenant", Claims{TenantID: "t2", Scopes: []string{ScopeAdmin}, ExpiresAt: exp}, ScopeWriteWAL, http.StatusOK},
+		{"certificate issuer", Claims{Issuer: "mtls", TenantID: "t2", Scopes: []string{ScopeReadPages}, ExpiresAt: exp}, ScopeReadPages, http.StatusUnauthorized},
+		{"expired", Claims{TenantID: "t1", Scopes: []string{ScopeReadPages}, ExpiresAt: time.Now().Add(-time.Hour).Unix()}, ScopeReadPages, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signJWT(t, "EdDSA", "ed", tt.claims, func(b []byte) []byte { return ed25519.Sign(key, b) })
+			if code := serve(a, tt.scope, token); code != tt.want {
+				t.Fatalf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
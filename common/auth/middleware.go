@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	claimsKey
+	certKey
+)
+
+// PrincipalFromContext returns the principal stored by Middleware, or "" if none
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey).(string)
+	return principal
+}
+
+// ClaimsFromContext returns the JWT claims stored by Middleware, or nil if
+// the request was not authenticated with a JWT
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey).(*Claims)
+	return claims
+}
+
+// CertIdentityFromContext returns the client certificate identity stored by
+// Middleware, or nil if the request was not authenticated with a certificate
+func CertIdentityFromContext(ctx context.Context) *CertIdentity {
+	cert, _ := ctx.Value(certKey).(*CertIdentity)
+	return cert
+}
+
+// claimsPrincipal names a JWT principal by tenant, falling back to the subject
+func claimsPrincipal(claims *Claims) string {
+	if claims.TenantID != "" {
+		return "tenant:" + claims.TenantID
+	}
+	return "sub:" + claims.Subject
+}
+
+// tokenPrincipal names a token principal without exposing the token itself
+func tokenPrincipal(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// anonymousPrincipal names an unauthenticated client by its remote IP
+func anonymousPrincipal(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// AuthMiddleware handles authentication
+type AuthMiddleware struct {
+	apiKey     string
+	authTokens map[string]bool
+	tokensMu   sync.RWMutex
+	enabled    bool
+
+	// Persistent tokens managed through the admin API
+	store *TokenStore
+
+	// Roles for verified client certificates (mTLS)
+	certRoles []CertRoleMapping
+
+	// JWT verification and the tenant/timeline served by this instance
+	jwt        *JWTVerifier
+	tenantID   string
+	timelineID string
+}
+
+// NewAuthMiddleware creates a new authentication middleware
+func NewAuthMiddleware(apiKey string, authTokens string) *AuthMiddleware {
+	auth := &AuthMiddleware{
+		authTokens: make(map[string]bool),
+	}
+	
+	// Set API key if provided
+	if apiKey != "" {
+		auth.apiKey = apiKey
+		auth.enabled = true
+	}
+	
+	// Parse auth tokens if provided
+	if authTokens != "" {
+		tokens := strings.Split(authTokens, ",")
+		for _, token := range tokens {
+			token = strings.TrimSpace(token)
+			if token != "" {
+				auth.authTokens[token] = true
+			}
+		}
+		auth.enabled = true
+	}
+	
+	return auth
+}
+
+// IsEnabled returns true if authentication is enabled
+func (a *AuthMiddleware) IsEnabled() bool {
+	a.tokensMu.RLock()
+	defer a.tokensMu.RUnlock()
+	return a.enabled
+}
+
+// Authenticate validates the request
+func (a *AuthMiddleware) Authenticate(r *http.Request) bool {
+	_, ok := a.AuthenticatePrincipal(r)
+	return ok
+}
+
+// AuthenticatePrincipal validates the request and returns who made it:
+// "tenant:<id>" or "sub:<subject>" for JWTs, "api-key", "token:<hash prefix>"
+// or, with auth disabled, "ip:<remote ip>"
+func (a *AuthMiddleware) AuthenticatePrincipal(r *http.Request) (string, bool) {
+	principal, _, _, ok := a.authenticate(r)
+	return principal, ok
+}
+
+// authenticate validates the request and returns the principal, the claims
+// of JWTs, stored tokens and certificates, and the certificate identity
+func (a *AuthMiddleware) authenticate(r *http.Request) (string, *Claims, *CertIdentity, bool) {
+	// Credentials can be replaced at runtime (SetCredentials, SetJWTVerifier)
+	a.tokensMu.RLock()
+	enabled := a.enabled
+	apiKey := a.apiKey
+	verifier := a.jwt
+	store := a.store
+	certRoles := a.certRoles
+	a.tokensMu.RUnlock()
+	
+	if !enabled {
+		return anonymousPrincipal(r), nil, nil, true // No auth required
+	}
+	
+	// Check verified client certificate (mTLS)
+	if cert, claims := certClaims(r, certRoles); cert != nil {
+		return "cert:" + cert.SAN, claims, cert, true
+	}
+	
+	// Check API key in header
+	if apiKey != "" {
+		providedKey := r.Header.Get("X-API-Key")
+		if providedKey != "" && subtle.ConstantTimeCompare([]byte(providedKey), []byte(apiKey)) == 1 {
+			return "api-key", nil, nil, true
+		}
+	}
+	
+	// Check Bearer token
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			token := parts[1]
+			if verifier != nil && LooksLikeJWT(token) {
+				claims, err := verifier.Verify(token)
+				if err != nil {
+					return "", nil, nil, false
+				}
+				return claimsPrincipal(claims), claims, nil, true
+			}
+			a.tokensMu.RLock()
+			valid := a.authTokens[token]
+			a.tokensMu.RUnlock()
+			if valid {
+				return tokenPrincipal(token), nil, nil, true
+			}
+			if store != nil {
+				if record, ok := store.Lookup(token); ok {
+					return "token:" + record.ID, record.claims(), nil, true
+				}
+			}
+		}
+	}
+	
+	// Check Basic Auth (username:password or token:)
+	if authHeader != "" {
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "basic" {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err == nil {
+				credentials := strings.SplitN(string(decoded), ":", 2)
+				if len(credentials) == 2 {
+					// Check if password matches API key
+					if apiKey != "" && subtle.ConstantTimeCompare([]byte(credentials[1]), []byte(apiKey)) == 1 {
+						return "api-key", nil, nil, true
+					}
+					// Check if password is a valid token
+					a.tokensMu.RLock()
+					valid := a.authTokens[credentials[1]]
+					a.tokensMu.RUnlock()
+					if valid {
+						return tokenPrincipal(credentials[1]), nil, nil, true
+					}
+					if store != nil {
+						if record, ok := store.Lookup(credentials[1]); ok {
+							return "token:" + record.ID, record.claims(), nil, true
+						}
+					}
+				}
+			}
+		}
+	}
+	
+	return "", nil, nil, false
+}
+
+// Middleware wraps HTTP handlers with authentication.
+// The authenticated principal is available to next via PrincipalFromContext.
+func (a *AuthMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, claims, cert, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Basic realm="Page Server"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "error",
+				"error":  "Authentication required",
+			})
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalKey, principal)
+		if claims != nil {
+			ctx = context.WithValue(ctx, claimsKey, claims)
+		}
+		if cert != nil {
+			ctx = context.WithValue(ctx, certKey, cert)
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// Require wraps a handler so that JWT-authenticated requests must carry
+// scope and match the tenant and timeline served by this instance.
+// API keys and static tokens are not scoped and keep full access.
+// Must be wrapped by Middleware.
+func (a *AuthMiddleware) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := ClaimsFromContext(r.Context())
+		if claims != nil {
+			if err := a.authorize(claims, scope, CertIdentityFromContext(r.Context()) != nil); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"status": "error",
+					"error":  err.Error(),
+				})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// authorize checks the scope and tenant/timeline of claims. Certificate
+// identities are not tied to a tenant.
+func (a *AuthMiddleware) authorize(claims *Claims, scope string, fromCert bool) error {
+	if !claims.HasScope(scope) {
+		return fmt.Errorf("token lacks scope %q", scope)
+	}
+	if claims.HasScope(ScopeAdmin) || fromCert {
+		return nil
+	}
+	
+	a.tokensMu.RLock()
+	tenantID := a.tenantID
+	timelineID := a.timelineID
+	a.tokensMu.RUnlock()
+	
+	if tenantID != "" && claims.TenantID != tenantID {
+		return fmt.Errorf("token is not valid for tenant %q", tenantID)
+	}
+	if timelineID != "" && claims.TimelineID != "" && claims.TimelineID != timelineID {
+		return fmt.Errorf("token is not valid for timeline %q", timelineID)
+	}
+	return nil
+}
+
+// SetCertRoles sets the roles of client certificate identities.
+// A non-empty mapping enables authentication.
+func (a *AuthMiddleware) SetCertRoles(mappings []CertRoleMapping) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	a.certRoles = mappings
+	if len(mappings) > 0 {
+		a.enabled = true
+	}
+}
+
+// SetJWTVerifier enables JWT authentication (nil disables it).
+// Enabling JWTs also enables authentication.
+func (a *AuthMiddleware) SetJWTVerifier(v *JWTVerifier) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	a.jwt = v
+	if v != nil {
+		a.enabled = true
+	}
+}
+
+// JWTVerifier returns the JWT verifier, or nil if JWTs are not enabled
+func (a *AuthMiddleware) JWTVerifier() *JWTVerifier {
+	a.tokensMu.RLock()
+	defer a.tokensMu.RUnlock()
+	return a.jwt
+}
+
+// SetServedTenant sets the tenant and timeline this instance serves.
+// JWTs without the admin scope must name this tenant; empty disables the check.
+func (a *AuthMiddleware) SetServedTenant(tenantID, timelineID string) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	a.tenantID = tenantID
+	a.timelineID = timelineID
+}
+
+// AddToken adds a new authentication token
+func (a *AuthMiddleware) AddToken(token string) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	a.authTokens[token] = true
+	a.enabled = true
+}
+
+// SetCredentials replaces the API key and the token set.
+// Authentication stays enabled if it was enabled before, so that
+// clearing credentials at runtime never opens up the server.
+func (a *AuthMiddleware) SetCredentials(apiKey string, authTokens string) {
+	tokens := make(map[string]bool)
+	for _, token := range strings.Split(authTokens, ",") {
+		token = strings.TrimSpace(token)
+		if token != "" {
+			tokens[token] = true
+		}
+	}
+	
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	if a.store != nil {
+		for token := range tokens {
+			if a.store.StaticRevoked(token) {
+				delete(tokens, token)
+			}
+		}
+	}
+	a.apiKey = apiKey
+	a.authTokens = tokens
+	if apiKey != "" || len(tokens) > 0 {
+		a.enabled = true
+	}
+}
+
+// RemoveToken removes an authentication token
+func (a *AuthMiddleware) RemoveToken(token string) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	delete(a.authTokens, token)
+}
+
+
+// SetTokenStore attaches the persistent token store. Static tokens revoked
+// through the admin API are dropped, and stored tokens enable authentication.
+func (a *AuthMiddleware) SetTokenStore(store *TokenStore) {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+	a.store = store
+	for token := range a.authTokens {
+		if store.StaticRevoked(token) {
+			delete(a.authTokens, token)
+		}
+	}
+	if store.Len() > 0 {
+		a.enabled = true
+	}
+}
+
+// TokenStore returns the persistent token store, or nil if none is attached
+func (a *AuthMiddleware) TokenStore() *TokenStore {
+	a.tokensMu.RLock()
+	defer a.tokensMu.RUnlock()
+	return a.store
+}
+
+// staticTokenID returns the stable ID under which a static token is listed
+func staticTokenID(token string) string {
+	return "static-" + hashToken(token)[:8]
+}
+
+// staticTokenRecords lists the static tokens in masked form
+func (a *AuthMiddleware) staticTokenRecords() []TokenRecord {
+	a.tokensMu.RLock()
+	defer a.tokensMu.RUnlock()
+
+	records := make([]TokenRecord, 0, len(a.authTokens))
+	for token := range a.authTokens {
+		records = append(records, TokenRecord{
+			ID:     staticTokenID(token),
+			Prefix: tokenPrefix(token),
+			Source: SourceStatic,
+		})
+	}
+	return records
+}
+
+// revokeStatic removes the static token with the given ID, persisting the revocation
+func (a *AuthMiddleware) revokeStatic(id string) error {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
+
+	for token := range a.authTokens {
+		if staticTokenID(token) == id {
+			if err := a.store.RevokeStatic(token); err != nil {
+				return err
+			}
+			delete(a.authTokens, token)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
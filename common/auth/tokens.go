@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lastUsedFlushInterval bounds how often last-used timestamps are written to disk
+const lastUsedFlushInterval = time.Minute
+
+// Token sources
+const (
+	SourceStatic = "static" // From -auth-tokens / config
+	SourceAPI    = "api"    // Created through the admin API
+)
+
+// ErrTokenNotFound is returned for unknown token IDs
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenRecord describes a managed token. The token itself is never stored,
+// only its SHA-256 hash.
+type TokenRecord struct {
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash"`
+	Prefix    string     `json:"prefix"` // First characters of the token, for identification
+	Label     string     `json:"label,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"` // Empty means full access, or all tenant scopes with TenantID
+	TenantID  string     `json:"tenant_id,omitempty"`
+	Source    string     `json:"source"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+}
+
+// Expired reports whether the token is past its expiry
+func (r *TokenRecord) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// tenantScopes are granted to tenant tokens created without scopes. Admin is
+// left out because it is not bound to a tenant.
+var tenantScopes = []string{ScopeReadPages, ScopeWriteWAL, ScopeReplicate}
+
+// claims returns synthetic claims for scoped or tenant tokens, or nil for full access
+func (r *TokenRecord) claims() *Claims {
+	scopes := r.Scopes
+	if len(scopes) == 0 {
+		if r.TenantID == "" {
+			return nil
+		}
+		scopes = tenantScopes
+	}
+	claims := &Claims{Subject: "token:" + r.ID, TenantID: r.TenantID, Scopes: scopes}
+	if r.ExpiresAt != nil {
+		claims.ExpiresAt = r.ExpiresAt.Unix()
+	}
+	return claims
+}
+
+// tokenFile is the on-disk layout of the token store
+type tokenFile struct {
+	Tokens        []*TokenRecord `json:"tokens"`
+	RevokedStatic []string       `json:"revoked_static,omitempty"` // Hashes of revoked static tokens
+}
+
+// TokenStore persists hashed tokens in <dataDir>/auth/tokens.json
+type TokenStore struct {
+	path string
+
+	mu            sync.Mutex
+	byHash        map[string]*TokenRecord
+	revokedStatic map[string]bool
+	lastFlush     time.Time
+	dirty         bool
+}
+
+// NewTokenStore loads the token store from dataDir, creating it if needed
+func NewTokenStore(dataDir string) (*TokenStore, error) {
+	dir := filepath.Join(dataDir, "auth")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create auth directory: %w", err)
+	}
+
+	s := &TokenStore{
+		path:          filepath.Join(dir, "tokens.json"),
+		byHash:        make(map[string]*TokenRecord),
+		revokedStatic: make(map[string]bool),
+		lastFlush:     time.Now(),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+
+	var file tokenFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse token store: %w", err)
+	}
+	for _, record := range file.Tokens {
+		s.byHash[record.Hash] = record
+	}
+	for _, hash := range file.RevokedStatic {
+		s.revokedStatic[hash] = true
+	}
+
+	return s, nil
+}
+
+// hashToken returns the hex SHA-256 of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenPrefix returns the identifying prefix of a token
+func tokenPrefix(token string) string {
+	if len(token) > 4 {
+		return token[:4]
+	}
+	return ""
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Len returns the number of stored tokens
+func (s *TokenStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byHash)
+}
+
+// Create generates a new token. The plaintext token is returned only here.
+func (s *TokenStore) Create(label string, ttl time.Duration, scopes []string, tenantID string) (string, *TokenRecord, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	record := &TokenRecord{
+		ID:        id,
+		Hash:      hashToken(secret),
+		Prefix:    tokenPrefix(secret),
+		Label:     label,
+		Scopes:    scopes,
+		TenantID:  tenantID,
+		Source:    SourceAPI,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		record.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[record.Hash] = record
+	if err := s.saveLocked(); err != nil {
+		delete(s.byHash, record.Hash)
+		return "", nil, err
+	}
+
+	copied := *record
+	return secret, &copied, nil
+}
+
+// Lookup returns the record of a valid (unexpired) token and updates its last-used time
+func (s *TokenStore) Lookup(token string) (*TokenRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.byHash[hashToken(token)]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now().UTC()
+	if record.Expired(now) {
+		return nil, false
+	}
+
+	record.LastUsed = &now
+	s.dirty = true
+	if now.Sub(s.lastFlush) >= lastUsedFlushInterval {
+		// Best effort: last-used times are informational
+		s.saveLocked()
+	}
+
+	copied := *record
+	return &copied, true
+}
+
+// List returns all records sorted by creation time
+func (s *TokenStore) List() []TokenRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]TokenRecord, 0, len(s.byHash))
+	for _, record := range s.byHash {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records
+}
+
+// Revoke deletes a token by ID
+func (s *TokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, record := range s.byHash {
+		if record.ID == id {
+			delete(s.byHash, hash)
+			return s.saveLocked()
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// Expire sets the expiry of a token by ID (use time.Now() to expire immediately)
+func (s *TokenStore) Expire(id string, at time.Time) (*TokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.byHash {
+		if record.ID == id {
+			at = at.UTC()
+			record.ExpiresAt = &at
+			if err := s.saveLocked(); err != nil {
+				return nil, err
+			}
+			copied := *record
+			return &copied, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+// RevokeStatic records that a static token must stay revoked across restarts
+func (s *TokenStore) RevokeStatic(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedStatic[hashToken(token)] = true
+	return s.saveLocked()
+}
+
+// StaticRevoked reports whether a static token was revoked
+func (s *TokenStore) StaticRevoked(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revokedStatic[hashToken(token)]
+}
+
+// Flush writes pending last-used updates
+func (s *TokenStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// saveLocked writes the store atomically (caller holds mu)
+func (s *TokenStore) saveLocked() error {
+	file := tokenFile{Tokens: make([]*TokenRecord, 0, len(s.byHash))}
+	for _, record := range s.byHash {
+		file.Tokens = append(file.Tokens, record)
+	}
+	sort.Slice(file.Tokens, func(i, j int) bool { return file.Tokens[i].CreatedAt.Before(file.Tokens[j].CreatedAt) })
+	for hash := range s.revokedStatic {
+		file.RevokedStatic = append(file.RevokedStatic, hash)
+	}
+	sort.Strings(file.RevokedStatic)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token store: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+
+	s.dirty = false
+	s.lastFlush = time.Now()
+	return nil
+}
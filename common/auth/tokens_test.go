@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantTokenWithoutScopes(t *testing.T) {
+	store, err := NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	token, _, err := store.Create("tenant-a", 0, nil, "tenant-a")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	a := NewAuthMiddleware("", "")
+	a.SetTokenStore(store)
+
+	a.SetServedTenant("tenant-a", "")
+	if code := serve(a, ScopeWriteWAL, token); code != http.StatusOK {
+		t.Fatalf("own tenant: %d, want 200", code)
+	}
+	if code := serve(a, ScopeAdmin, token); code != http.StatusForbidden {
+		t.Fatalf("admin scope: %d, want 403", code)
+	}
+	a.SetServedTenant("tenant-b", "")
+	if code := serve(a, ScopeReadPages, token); code != http.StatusForbidden {
+		t.Fatalf("other tenant: %d, want 403", code)
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewTokenStore(dir)
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	live, liveRecord, err := store.Create("live", time.Hour, []string{ScopeReadPages}, "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	revoked, revokedRecord, _ := store.Create("revoked", 0, nil, "")
+	expired, expiredRecord, _ := store.Create("expired", 0, nil, "")
+	grace, graceRecord, _ := store.Create("grace", 0, nil, "")
+
+	if err := store.Revoke(revokedRecord.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := store.Expire(expiredRecord.ID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	if _, err := store.Expire(graceRecord.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	if err := store.Revoke("missing"); err != ErrTokenNotFound {
+		t.Fatalf("Revoke of an unknown ID: %v", err)
+	}
+
+	// The store on disk holds hashes and prefixes only
+	data, err := os.ReadFile(filepath.Join(dir, "auth", "tokens.json"))
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	for _, token := range []string{live, revoked, expired, grace} {
+		if strings.Contains(string(data), token) {
+			t.Fatalf("token stored in plaintext: %s", data)
+		}
+	}
+	if liveRecord.Hash != hashToken(live) || !strings.HasPrefix(live, liveRecord.Prefix) {
+		t.Fatalf("record: %+v", liveRecord)
+	}
+
+	// Reopening keeps revocations and expiries
+	store, err = NewTokenStore(dir)
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"live", live, true},
+		{"revoked", revoked, false},
+		{"expired", expired, false},
+		{"expiring later", grace, true},
+		{"unknown", "not-a-token", false},
+		{"hash of a token", liveRecord.Hash, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, ok := store.Lookup(tt.token)
+			if ok != tt.ok {
+				t.Fatalf("Lookup ok=%v, want %v", ok, tt.ok)
+			}
+			if ok && record.LastUsed == nil {
+				t.Fatalf("last used not recorded: %+v", record)
+			}
+		})
+	}
+	if store.Len() != 3 {
+		t.Fatalf("Len %d, want 3", store.Len())
+	}
+}
+
+func TestStoredTokenAuthentication(t *testing.T) {
+	store, err := NewTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	full, _, _ := store.Create("full", 0, nil, "")
+	reader, _, _ := store.Create("reader", 0, []string{ScopeReadPages}, "t1")
+	old, oldRecord, _ := store.Create("old", 0, nil, "")
+	store.Expire(oldRecord.ID, time.Now())
+
+	a := NewAuthMiddleware("", "static-token")
+	a.SetTokenStore(store)
+	a.SetServedTenant("t1", "")
+
+	tests := []struct {
+		name  string
+		token string
+		scope string
+		want  int
+	}{
+		{"full access", full, ScopeAdmin, http.StatusOK},
+		{"scoped", reader, ScopeReadPages, http.StatusOK},
+		{"scope not granted", reader, ScopeWriteWAL, http.StatusForbidden},
+		{"expired", old, ScopeReadPages, http.StatusUnauthorized},
+		{"static", "static-token", ScopeAdmin, http.StatusOK},
+		{"none", "", ScopeReadPages, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := serve(a, tt.scope, tt.token); code != tt.want {
+				t.Fatalf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	// Revoked static tokens stay revoked when credentials are reloaded
+	if err := a.revokeStatic(staticTokenID("static-token")); err != nil {
+		t.Fatalf("revokeStatic: %v", err)
+	}
+	a.SetCredentials("", "static-token")
+	if code := serve(a, ScopeReadPages, "static-token"); code != http.StatusUnauthorized {
+		t.Fatalf("revoked static token: %d, want 401", code)
+	}
+}
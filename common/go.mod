@@ -0,0 +1,3 @@
+module github.com/linux/projects/server/common
+
+go 1.23
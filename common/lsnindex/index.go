@@ -0,0 +1,199 @@
+// Package lsnindex keeps a durable, sparse (timestamp, LSN) index of ingested
+// WAL so wall-clock times can be resolved to LSNs.
+//
+// Only the last LSN of each Granularity interval is kept, so a lookup may
+// return an LSN up to one interval older than the exact answer but never an
+// LSN committed after the requested time. The newest interval is written to
+// disk when the next one starts or on Flush, so a crash loses at most that interval.
+package lsnindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Granularity is the index resolution
+const Granularity = time.Second
+
+// entrySize is the on-disk size of an entry: unix nanos + LSN, big-endian
+const entrySize = 16
+
+// ErrBeforeFirst is returned by Lookup for times before the first recorded WAL
+var ErrBeforeFirst = errors.New("no WAL recorded at or before the requested time")
+
+// Entry maps a time to the last LSN committed at or before it
+type Entry struct {
+	Time time.Time `json:"time"`
+	LSN  uint64    `json:"lsn"`
+}
+
+// Index is the timestamp to LSN index
+type Index struct {
+	path    string
+	file    *os.File
+	entries []Entry // Persisted, ascending in both time and LSN
+	pending *Entry  // Latest entry of the current interval, not yet persisted
+	mu      sync.RWMutex
+}
+
+// Open opens or creates the index at <dataDir>/lsn_index
+func Open(dataDir string) (*Index, error) {
+	path := filepath.Join(dataDir, "lsn_index")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LSN index: %w", err)
+	}
+
+	idx := &Index{path: path, file: file}
+	if err := idx.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// load reads all entries and drops a torn trailing entry
+func (idx *Index) load() error {
+	data, err := io.ReadAll(idx.file)
+	if err != nil {
+		return fmt.Errorf("failed to read LSN index: %w", err)
+	}
+
+	valid := len(data) - len(data)%entrySize
+	for off := 0; off < valid; off += entrySize {
+		idx.entries = append(idx.entries, Entry{
+			Time: time.Unix(0, int64(binary.BigEndian.Uint64(data[off:]))),
+			LSN:  binary.BigEndian.Uint64(data[off+8:]),
+		})
+	}
+
+	if valid != len(data) {
+		if err := idx.file.Truncate(int64(valid)); err != nil {
+			return fmt.Errorf("failed to truncate torn LSN index entry: %w", err)
+		}
+	}
+	if _, err := idx.file.Seek(int64(valid), io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek LSN index: %w", err)
+	}
+	return nil
+}
+
+// Record notes that lsn was committed at t. Records that do not advance the
+// LSN are ignored; times that go backwards are clamped to keep the index sorted.
+func (idx *Index) Record(t time.Time, lsn uint64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	last := idx.lastLocked()
+	if last != nil {
+		if lsn <= last.LSN {
+			return nil
+		}
+		if t.Before(last.Time) {
+			t = last.Time
+		}
+	}
+
+	// Same interval: only the newest LSN is kept
+	if idx.pending != nil && t.Truncate(Granularity).Equal(idx.pending.Time.Truncate(Granularity)) {
+		idx.pending = &Entry{Time: t, LSN: lsn}
+		return nil
+	}
+
+	// New interval: persist the previous one
+	if err := idx.flushLocked(); err != nil {
+		return err
+	}
+	idx.pending = &Entry{Time: t, LSN: lsn}
+	return nil
+}
+
+// Lookup returns the entry with the largest LSN committed at or before t
+func (idx *Index) Lookup(t time.Time) (Entry, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.pending != nil && !t.Before(idx.pending.Time) {
+		return *idx.pending, nil
+	}
+
+	i := sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].Time.After(t) })
+	if i == 0 {
+		return Entry{}, ErrBeforeFirst
+	}
+	return idx.entries[i-1], nil
+}
+
+// Range returns the first and last entries; ok is false if the index is empty
+func (idx *Index) Range() (first, last Entry, ok bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	lastEntry := idx.lastLocked()
+	if lastEntry == nil {
+		return Entry{}, Entry{}, false
+	}
+	first = *lastEntry
+	if len(idx.entries) > 0 {
+		first = idx.entries[0]
+	}
+	return first, *lastEntry, true
+}
+
+// Flush persists the entry of the current interval
+func (idx *Index) Flush() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.flushLocked()
+}
+
+// Close flushes and closes the index
+func (idx *Index) Close() error {
+	if err := idx.Flush(); err != nil {
+		return err
+	}
+	return idx.file.Close()
+}
+
+// lastLocked returns the newest entry, pending or persisted
+func (idx *Index) lastLocked() *Entry {
+	if idx.pending != nil {
+		return idx.pending
+	}
+	if len(idx.entries) > 0 {
+		return &idx.entries[len(idx.entries)-1]
+	}
+	return nil
+}
+
+// flushLocked appends the pending entry to the file; idx.mu must be held
+func (idx *Index) flushLocked() error {
+	if idx.pending == nil {
+		return nil
+	}
+
+	var buf [entrySize]byte
+	binary.BigEndian.PutUint64(buf[0:], uint64(idx.pending.Time.UnixNano()))
+	binary.BigEndian.PutUint64(buf[8:], idx.pending.LSN)
+	if _, err := idx.file.Write(buf[:]); err != nil {
+		return fmt.Errorf("failed to append to LSN index: %w", err)
+	}
+	if err := idx.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync LSN index: %w", err)
+	}
+
+	idx.entries = append(idx.entries, *idx.pending)
+	idx.pending = nil
+	return nil
+}
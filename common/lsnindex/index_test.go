@@ -0,0 +1,122 @@
+package lsnindex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := idx.Lookup(time.Now()); !errors.Is(err, ErrBeforeFirst) {
+		t.Fatalf("empty index: %v", err)
+	}
+
+	base := time.Unix(1_700_000_000, 0)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	records := []struct {
+		ms  int
+		lsn uint64
+	}{
+		{0, 100},
+		{400, 150}, // Same second as 100: replaces it
+		{1000, 200},
+		{3500, 300},
+		{3600, 290}, // LSN does not advance: ignored
+		{3200, 310}, // Time goes backwards: clamped to 3500
+	}
+	for _, r := range records {
+		if err := idx.Record(at(r.ms), r.lsn); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		ms   int
+		lsn  uint64
+		err  error
+	}{
+		{"before the first entry", -1, 0, ErrBeforeFirst},
+		{"exact match", 400, 150, nil},
+		{"between entries", 2500, 200, nil},
+		{"exact match on a later entry", 1000, 200, nil},
+		{"just before an entry", 999, 150, nil},
+		{"pending entry", 3500, 310, nil},
+		{"after the last entry", 60_000, 310, nil},
+	}
+	check := func(idx *Index) {
+		t.Helper()
+		for _, tt := range tests {
+			entry, err := idx.Lookup(at(tt.ms))
+			if !errors.Is(err, tt.err) || entry.LSN != tt.lsn {
+				t.Fatalf("%s: Lookup = %d, %v; want %d, %v", tt.name, entry.LSN, err, tt.lsn, tt.err)
+			}
+		}
+	}
+	check(idx)
+
+	first, last, ok := idx.Range()
+	if !ok || first.LSN != 150 || last.LSN != 310 {
+		t.Fatalf("Range = %+v, %+v, %v", first, last, ok)
+	}
+
+	// The pending entry is persisted on close and everything survives a reopen
+	if err := idx.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	idx, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	check(idx)
+	idx.Close()
+}
+
+func TestTornEntry(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	base := time.Unix(1_700_000_000, 0)
+	idx.Record(base, 10)
+	idx.Record(base.Add(time.Second), 20)
+	if err := idx.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A crash in the middle of an append leaves a partial entry
+	path := filepath.Join(dir, "lsn_index")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	idx, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer idx.Close()
+	if info, _ := os.Stat(path); info.Size() != 2*entrySize {
+		t.Fatalf("torn entry not truncated: %d bytes", info.Size())
+	}
+	if entry, err := idx.Lookup(base.Add(time.Hour)); err != nil || entry.LSN != 20 {
+		t.Fatalf("Lookup = %+v, %v", entry, err)
+	}
+
+	// New entries append after the truncated tail
+	idx.Record(base.Add(2*time.Second), 30)
+	idx.Flush()
+	if info, _ := os.Stat(path); info.Size() != 3*entrySize {
+		t.Fatalf("index size %d after append", info.Size())
+	}
+}
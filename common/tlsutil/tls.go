@@ -0,0 +1,382 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certCheckInterval bounds how often certificate files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+// cipherSuites are the TLS 1.2 cipher suites we allow
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+}
+
+// TLSOptions holds server TLS settings
+type TLSOptions struct {
+	Enabled           bool
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string // CA bundle for verifying client certificates (enables mTLS)
+	RequireClientCert bool   // Reject connections without a valid client certificate
+}
+
+// CertReloader serves a certificate (and optional CA bundle) from disk,
+// picking up changed files without a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	caPool    *x509.CertPool
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewCertReloader loads the certificate, key and optional CA bundle
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the files from disk
+func (r *CertReloader) Reload() error {
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		loaded, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		cert = &loaded
+	}
+
+	var caPool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = cert
+	r.caPool = caPool
+	r.modTime = r.latestModTime()
+	r.lastCheck = time.Now()
+	return nil
+}
+
+// latestModTime returns the newest modification time of the watched files
+func (r *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// maybeReload reloads the files if they changed since the last load
+func (r *CertReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < certCheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	changed := r.latestModTime().After(r.modTime)
+	r.mu.Unlock()
+
+	if changed {
+		if err := r.Reload(); err != nil {
+			// Keep serving the old certificate
+			log.Printf("Warning: failed to reload TLS certificate: %v", err)
+		} else {
+			log.Printf("Reloaded TLS certificate from %s", r.certFile)
+		}
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return &tls.Certificate{}, nil // No client certificate configured
+	}
+	return r.cert, nil
+}
+
+// CAPool returns the current CA bundle (nil if none)
+func (r *CertReloader) CAPool() *x509.CertPool {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.caPool
+}
+
+// ConfigureTLS sets up TLS (and mTLS if a client CA is given) on server.
+// Returns the reloader so callers can force a reload (e.g. on SIGHUP);
+// nil if TLS is disabled. Serve with ListenAndServeTLS("", "").
+func ConfigureTLS(server *http.Server, opts TLSOptions) (*CertReloader, error) {
+	if !opts.Enabled {
+		return nil, nil // TLS not enabled
+	}
+
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("TLS enabled but certificate or key file not specified")
+	}
+	if opts.RequireClientCert && opts.ClientCAFile == "" {
+		return nil, fmt.Errorf("client certificates required but no client CA bundle specified")
+	}
+
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile, opts.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if opts.ClientCAFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+		if opts.RequireClientCert {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	base := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12, // Require TLS 1.2 or higher
+		CipherSuites:   cipherSuites,
+		ClientAuth:     clientAuth,
+	}
+	server.TLSConfig = base.Clone()
+	if opts.ClientCAFile != "" {
+		// Build the config per handshake so a reloaded CA bundle takes effect
+		server.TLSConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := base.Clone()
+			cfg.ClientCAs = reloader.CAPool()
+			return cfg, nil
+		}
+	}
+
+	log.Printf("TLS enabled with certificate: %s", opts.CertFile)
+	if opts.ClientCAFile != "" {
+		log.Printf("mTLS enabled with client CA: %s (required: %v)", opts.ClientCAFile, opts.RequireClientCert)
+	}
+	return reloader, nil
+}
+
+// ClientTLSConfig builds a client TLS config presenting certFile/keyFile
+// (optional) and trusting caFile (optional, system roots otherwise).
+// The returned reloader picks up rotated files.
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, *CertReloader, error) {
+	reloader, err := NewCertReloader(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: reloader.GetClientCertificate,
+		RootCAs:              reloader.CAPool(),
+	}
+	if caFile != "" {
+		// Verify against the current CA bundle so rotation does not need a restart
+		cfg.InsecureSkipVerify = true // Replaced by VerifyConnection below
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			opts := x509.VerifyOptions{
+				Roots:         reloader.CAPool(),
+				DNSName:       cs.ServerName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+	return cfg, reloader, nil
+}
+
+// GenerateSelfSignedCert generates a self-signed certificate for testing,
+// valid for localhost and 127.0.0.1
+func GenerateSelfSignedCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	template, err := certTemplate("localhost", []string{"localhost", "127.0.0.1"}, 365*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	template.KeyUsage |= x509.KeyUsageCertSign
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return writeCertAndKey(certFile, keyFile, der, key)
+}
+
+// GenerateDevCA writes a development CA plus server and client certificates into dir:
+// ca.crt/ca.key, server.crt/server.key (for serverSANs) and client.crt/client.key
+// (for clientSANs, e.g. "spiffe://dev/control-plane"). For development only.
+func GenerateDevCA(dir string, serverSANs, clientSANs []string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	caTemplate, err := certTemplate("Development CA", nil, 5*365*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	if err := writeCertAndKey(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"), caDER, caKey); err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	leaves := []struct {
+		name  string
+		sans  []string
+		usage x509.ExtKeyUsage
+	}{
+		{"server", serverSANs, x509.ExtKeyUsageServerAuth},
+		{"client", clientSANs, x509.ExtKeyUsageClientAuth},
+	}
+	for _, leaf := range leaves {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s key: %w", leaf.name, err)
+		}
+		commonName := leaf.name
+		if len(leaf.sans) > 0 {
+			commonName = leaf.sans[0]
+		}
+		template, err := certTemplate(commonName, leaf.sans, 365*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{leaf.usage}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			return fmt.Errorf("failed to create %s certificate: %w", leaf.name, err)
+		}
+		if err := writeCertAndKey(filepath.Join(dir, leaf.name+".crt"), filepath.Join(dir, leaf.name+".key"), der, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// certTemplate returns a leaf certificate template with SANs sorted into
+// DNS names, IP addresses and URIs
+func certTemplate(commonName string, sans []string, validFor time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Page Server"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if u, err := parseURISAN(san); err == nil {
+			template.URIs = append(template.URIs, u)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	return template, nil
+}
+
+// parseURISAN parses a SAN of the form scheme://... (e.g. spiffe://dev/safekeeper)
+func parseURISAN(san string) (*url.URL, error) {
+	if !strings.Contains(san, "://") {
+		return nil, fmt.Errorf("not a URI: %s", san)
+	}
+	return url.Parse(san)
+}
+
+// writeCertAndKey writes PEM files; the key is only readable by the owner
+func writeCertAndKey(certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
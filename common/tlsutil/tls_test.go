@@ -0,0 +1,195 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/common/auth"
+)
+
+// leaf returns the DER of the certificate a reloader serves
+func leaf(t *testing.T, r *CertReloader) []byte {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("GetCertificate: %v, %v", cert, err)
+	}
+	return cert.Certificate[0]
+}
+
+// touch moves the modification time of files forward so a reload sees them as changed
+func touch(t *testing.T, files ...string) {
+	t.Helper()
+	future := time.Now().Add(time.Minute)
+	for _, f := range files {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+}
+
+func TestCertReloaderSwapsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := GenerateSelfSignedCert(certFile, keyFile); err != nil {
+		t.Fatalf("GenerateSelfSignedCert: %v", err)
+	}
+	r, err := NewCertReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("NewCertReloader: %v", err)
+	}
+	first := leaf(t, r)
+
+	// Changed files are not picked up before the check interval
+	if err := GenerateSelfSignedCert(certFile, keyFile); err != nil {
+		t.Fatalf("GenerateSelfSignedCert: %v", err)
+	}
+	touch(t, certFile, keyFile)
+	if !bytes.Equal(leaf(t, r), first) {
+		t.Fatal("certificate reloaded before the check interval")
+	}
+
+	r.mu.Lock()
+	r.lastCheck = time.Time{}
+	r.mu.Unlock()
+	second := leaf(t, r)
+	if bytes.Equal(second, first) {
+		t.Fatal("rotated certificate not picked up")
+	}
+
+	// A broken rotation keeps the old certificate
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	touch(t, certFile)
+	r.mu.Lock()
+	r.lastCheck = time.Time{}
+	r.mu.Unlock()
+	if !bytes.Equal(leaf(t, r), second) {
+		t.Fatal("certificate dropped after a failed reload")
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload accepted an invalid certificate")
+	}
+}
+
+func TestConfigureTLSOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := GenerateSelfSignedCert(certFile, keyFile); err != nil {
+		t.Fatalf("GenerateSelfSignedCert: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+		err  string
+	}{
+		{"disabled", TLSOptions{}, ""},
+		{"server certificate", TLSOptions{Enabled: true, CertFile: certFile, KeyFile: keyFile}, ""},
+		{"no key", TLSOptions{Enabled: true, CertFile: certFile}, "certificate or key file not specified"},
+		{"client certs without CA", TLSOptions{Enabled: true, CertFile: certFile, KeyFile: keyFile, RequireClientCert: true}, "no client CA bundle"},
+		{"missing certificate", TLSOptions{Enabled: true, CertFile: certFile + ".missing", KeyFile: keyFile}, "failed to load TLS certificate"},
+		{"CA without certificates", TLSOptions{Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, "no certificates found in CA bundle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &http.Server{}
+			reloader, err := ConfigureTLS(server, tt.opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigureTLS: %v", err)
+			}
+			if (reloader != nil) != tt.opts.Enabled || (server.TLSConfig != nil) != tt.opts.Enabled {
+				t.Fatalf("reloader %v, TLS config %v", reloader, server.TLSConfig)
+			}
+			if tt.opts.Enabled && server.TLSConfig.MinVersion != tls.VersionTLS12 {
+				t.Fatalf("MinVersion %x", server.TLSConfig.MinVersion)
+			}
+		})
+	}
+}
+
+func TestMutualTLSCertRoles(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateDevCA(dir, []string{"127.0.0.1"}, []string{"spiffe://dev/control-plane"}); err != nil {
+		t.Fatalf("GenerateDevCA: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	mappings, err := auth.ParseCertRoles("spiffe://dev/*=admin")
+	if err != nil {
+		t.Fatalf("ParseCertRoles: %v", err)
+	}
+	a := auth.NewAuthMiddleware("", "")
+	a.SetCertRoles(mappings)
+
+	server := &http.Server{Handler: a.Middleware(a.Require(auth.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, auth.PrincipalFromContext(r.Context()))
+	}))}
+	if _, err := ConfigureTLS(server, TLSOptions{
+		Enabled:      true,
+		CertFile:     path("server.crt"),
+		KeyFile:      path("server.key"),
+		ClientCAFile: path("ca.crt"),
+	}); err != nil {
+		t.Fatalf("ConfigureTLS: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go server.ServeTLS(ln, "", "")
+	defer server.Close()
+	url := "https://" + ln.Addr().String() + "/"
+
+	get := func(certFile, keyFile string) (int, string) {
+		t.Helper()
+		cfg, _, err := ClientTLSConfig(certFile, keyFile, path("ca.crt"))
+		if err != nil {
+			t.Fatalf("ClientTLSConfig: %v", err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get(path("client.crt"), path("client.key")); code != http.StatusOK || body != "cert:spiffe://dev/control-plane" {
+		t.Fatalf("client certificate: %d %q", code, body)
+	}
+	// Certificates are optional without RequireClientCert; the request is then anonymous
+	if code, _ := get("", ""); code != http.StatusUnauthorized {
+		t.Fatalf("no client certificate: %d, want 401", code)
+	}
+	// A certificate the CA did not issue is rejected by the client check
+	other := t.TempDir()
+	if err := GenerateSelfSignedCert(filepath.Join(other, "tls.crt"), filepath.Join(other, "tls.key")); err != nil {
+		t.Fatalf("GenerateSelfSignedCert: %v", err)
+	}
+	cfg, _, err := ClientTLSConfig("", "", filepath.Join(other, "tls.crt"))
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("server certificate accepted against the wrong CA")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/linux/projects/server/control-plane/internal/api"
+	"github.com/linux/projects/server/control-plane/internal/auth"
 	"github.com/linux/projects/server/control-plane/internal/autoscaling"
 	"github.com/linux/projects/server/control-plane/internal/billing"
 	"github.com/linux/projects/server/control-plane/internal/compute"
@@ -23,6 +25,7 @@ import (
 	"github.com/linux/projects/server/control-plane/internal/proxy"
 	"github.com/linux/projects/server/control-plane/internal/scheduler"
 	"github.com/linux/projects/server/control-plane/internal/state"
+	"github.com/linux/projects/server/control-plane/internal/tlsconfig"
 )
 
 func main() {
@@ -38,6 +41,15 @@ func main() {
 		enableProxy       = flag.Bool("enable-proxy", true, "Enable connection proxy (default: true)")
 		enableAutoscaling = flag.Bool("enable-autoscaling", true, "Enable auto-scaling (default: true)")
 		scaleCheckInterval = flag.Duration("scale-check-interval", 1*time.Minute, "Interval for checking scaling metrics")
+		jwtSigningKey      = flag.String("jwt-signing-key", "", "Path to Ed25519 PEM key for minting compute tokens (created if missing; empty disables tokens)")
+		jwtJWKSOut         = flag.String("jwt-jwks-out", "", "Path to write the public JWKS for the page server and safekeeper (-jwt-jwks)")
+		computeTokenTTL    = flag.Duration("compute-token-ttl", 1*time.Hour, "Lifetime of tokens minted for compute nodes")
+		jwtIssuer          = flag.String("jwt-issuer", "control-plane", "iss claim of minted tokens (matches -jwt-issuer of the page server and safekeeper)")
+		jwtAudience        = flag.String("jwt-audience", "page-server,safekeeper", "Comma-separated aud claim of minted tokens")
+		controlPlaneURL    = flag.String("control-plane-url", "", "URL the proxy uses to reach this API (default: http://localhost:<port>)")
+		tlsClientCert      = flag.String("tls-client-cert", "", "Client certificate for outbound calls (mTLS)")
+		tlsClientKey       = flag.String("tls-client-key", "", "Private key for -tls-client-cert")
+		tlsCA              = flag.String("tls-ca", "", "CA bundle for verifying servers on outbound calls (default: system roots)")
 	)
 	flag.Parse()
 
@@ -96,6 +108,28 @@ func main() {
 	// Pass usage tracker to compute manager for automatic tracking
 	computeManager.SetUsageTracker(usageTracker)
 	
+	// Mint short-lived page server/safekeeper tokens for each compute
+	if *jwtSigningKey != "" {
+		var audience []string
+		for _, aud := range strings.Split(*jwtAudience, ",") {
+			if aud = strings.TrimSpace(aud); aud != "" {
+				audience = append(audience, aud)
+			}
+		}
+		tokenIssuer, err := auth.NewTokenIssuer(*jwtSigningKey, *computeTokenTTL, *jwtIssuer, audience)
+		if err != nil {
+			log.Fatalf("Failed to create token issuer: %v", err)
+		}
+		if *jwtJWKSOut != "" {
+			if err := tokenIssuer.WriteJWKS(*jwtJWKSOut); err != nil {
+				log.Fatalf("Failed to write JWKS: %v", err)
+			}
+			log.Printf("Wrote compute token JWKS to %s", *jwtJWKSOut)
+		}
+		computeManager.SetTokenIssuer(tokenIssuer)
+		log.Printf("Compute tokens enabled (ttl %s)", *computeTokenTTL)
+	}
+	
 	// Initialize network policy manager for multi-tenancy isolation
 	k8sClient, err := kubernetes.NewForConfig(k8sConfig)
 	if err != nil {
@@ -128,8 +162,18 @@ func main() {
 
 	// Initialize and start connection proxy (mimics Neon's proxy)
 	if *enableProxy {
-		controlPlaneURL := fmt.Sprintf("http://localhost:%d", *port)
-		proxyRouter := proxy.NewRouter(computeManager, controlPlaneURL, *proxyPort)
+		wakeURL := *controlPlaneURL
+		if wakeURL == "" {
+			wakeURL = fmt.Sprintf("http://localhost:%d", *port)
+		}
+		proxyRouter := proxy.NewRouter(computeManager, wakeURL, *proxyPort)
+		if *tlsClientCert != "" || *tlsCA != "" {
+			clientTLS, err := tlsconfig.ClientConfig(*tlsClientCert, *tlsClientKey, *tlsCA)
+			if err != nil {
+				log.Fatalf("Failed to configure client TLS: %v", err)
+			}
+			proxyRouter.SetTLSConfig(clientTLS)
+		}
 		go func() {
 			log.Printf("Starting connection proxy on port %d", *proxyPort)
 			if err := proxyRouter.Start(); err != nil {
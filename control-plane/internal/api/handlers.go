@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"os"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/linux/projects/server/control-plane/internal/compute"
 	"github.com/linux/projects/server/control-plane/internal/project"
 	"github.com/linux/projects/server/control-plane/internal/scheduler"
+	"github.com/linux/projects/server/control-plane/internal/state"
 	"github.com/linux/projects/server/control-plane/pkg/types"
 )
 
@@ -47,6 +49,7 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 		v1.DELETE("/compute/:id", h.DestroyComputeNode)
 		v1.POST("/compute/:id/suspend", h.SuspendComputeNode)
 		v1.POST("/compute/:id/resume", h.ResumeComputeNode)
+		v1.POST("/compute/:id/token", h.IssueComputeToken)
 
 		// Wake compute endpoint (used by proxy)
 		v1.GET("/wake_compute", h.WakeCompute)
@@ -176,6 +179,24 @@ func (h *Handler) ResumeComputeNode(c *gin.Context) {
 	c.JSON(http.StatusOK, computeNode)
 }
 
+// IssueComputeToken mints a fresh page server/safekeeper token for a compute node
+func (h *Handler) IssueComputeToken(c *gin.Context) {
+	token, expiresAt, err := h.computeManager.IssueComputeToken(c.Param("id"))
+	if errors.Is(err, state.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "compute node not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expiresAt.UTC(),
+	})
+}
+
 // WakeCompute wakes up a compute node (used by proxy)
 func (h *Handler) WakeCompute(c *gin.Context) {
 	endpoint := c.Query("endpointish")
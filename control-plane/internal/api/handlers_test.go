@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/rest"
+
+	"github.com/linux/projects/server/control-plane/internal/auth"
+	"github.com/linux/projects/server/control-plane/internal/compute"
+	"github.com/linux/projects/server/control-plane/internal/state"
+	"github.com/linux/projects/server/control-plane/pkg/types"
+)
+
+// computeStore serves compute nodes from a map; other methods are not used
+type computeStore struct {
+	state.StoreInterface
+	nodes map[string]*types.ComputeNode
+}
+
+func (s *computeStore) GetComputeNode(id string) (*types.ComputeNode, error) {
+	if node, ok := s.nodes[id]; ok {
+		return node, nil
+	}
+	return nil, state.ErrNotFound
+}
+
+func TestIssueComputeToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &computeStore{nodes: map[string]*types.ComputeNode{"c1": {ID: "c1", ProjectID: "p1"}}}
+	manager, err := compute.NewManager(&rest.Config{Host: "http://127.0.0.1:1"}, store, "default")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	router := gin.New()
+	NewHandler(nil, manager, nil).RegisterRoutes(router)
+
+	post := func(id string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/compute/"+id+"/token", nil))
+		return w.Code
+	}
+
+	// Without an issuer the failure is the server's
+	if code := post("c1"); code != http.StatusInternalServerError {
+		t.Fatalf("token without an issuer: %d, want 500", code)
+	}
+
+	issuer, err := auth.NewTokenIssuer(filepath.Join(t.TempDir(), "signing.pem"), time.Hour, "control-plane", nil)
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	manager.SetTokenIssuer(issuer)
+	if code := post("c1"); code != http.StatusOK {
+		t.Fatalf("token for a compute node: %d, want 200", code)
+	}
+	if code := post("missing"); code != http.StatusNotFound {
+		t.Fatalf("token for an unknown compute node: %d, want 404", code)
+	}
+}
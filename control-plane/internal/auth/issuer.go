@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Token scopes understood by the page server and safekeeper
+const (
+	ScopeReadPages = "read_pages"
+	ScopeWriteWAL  = "write_wal"
+	ScopeAdmin     = "admin"
+)
+
+// ComputeScopes are the scopes granted to compute nodes
+var ComputeScopes = []string{ScopeReadPages, ScopeWriteWAL}
+
+// Claims are the JWT claims minted by the control plane
+type Claims struct {
+	Subject    string   `json:"sub"`
+	Issuer     string   `json:"iss"`
+	Audience   []string `json:"aud,omitempty"`
+	TenantID   string   `json:"tenant_id,omitempty"`
+	TimelineID string   `json:"timeline_id,omitempty"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  int64    `json:"exp"`
+	NotBefore  int64    `json:"nbf"`
+	IssuedAt   int64    `json:"iat"`
+}
+
+// TokenIssuer mints short-lived EdDSA JWTs for compute nodes
+type TokenIssuer struct {
+	key      ed25519.PrivateKey
+	kid      string
+	issuer   string
+	audience []string
+	ttl      time.Duration
+}
+
+// NewTokenIssuer loads the Ed25519 signing key (PKCS#8 PEM) from keyPath,
+// generating and saving a new key if the file does not exist. Tokens are
+// issued as issuer for the services in audience.
+func NewTokenIssuer(keyPath string, ttl time.Duration, issuer string, audience []string) (*TokenIssuer, error) {
+	key, err := loadSigningKey(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = createSigningKey(keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pub := key.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(pub)
+
+	return &TokenIssuer{
+		key:      key,
+		kid:      hex.EncodeToString(sum[:8]),
+		issuer:   issuer,
+		audience: audience,
+		ttl:      ttl,
+	}, nil
+}
+
+// loadSigningKey reads a PKCS#8 PEM Ed25519 private key
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode signing key %s: no PEM block", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// createSigningKey generates an Ed25519 key and writes it to path
+func createSigningKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return key, nil
+}
+
+// TTL returns the lifetime of issued tokens
+func (i *TokenIssuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue mints a token for subject scoped to a tenant (and optionally a timeline)
+func (i *TokenIssuer) Issue(subject, tenantID, timelineID string, scopes []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.ttl)
+
+	claims := Claims{
+		Subject:    subject,
+		Issuer:     i.issuer,
+		Audience:   i.audience,
+		TenantID:   tenantID,
+		TimelineID: timelineID,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt.Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": i.kid})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(i.key, []byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), expiresAt, nil
+}
+
+// JWKS returns the public key set that services use to verify issued tokens
+func (i *TokenIssuer) JWKS() ([]byte, error) {
+	pub := i.key.Public().(ed25519.PublicKey)
+	return json.MarshalIndent(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": i.kid,
+				"alg": "EdDSA",
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			},
+		},
+	}, "", "  ")
+}
+
+// WriteJWKS writes the public key set to path for the page server and safekeeper
+func (i *TokenIssuer) WriteJWKS(path string) error {
+	data, err := i.JWKS()
+	if err != nil {
+		return fmt.Errorf("failed to encode JWKS: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JWKS: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write JWKS: %w", err)
+	}
+	return nil
+}
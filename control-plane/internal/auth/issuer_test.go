@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verify checks a token against the issuer's JWKS the way the services do
+// and returns its header and claims
+func verify(t *testing.T, jwks []byte, token string) (map[string]string, Claims) {
+	t.Helper()
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil || len(set.Keys) != 1 {
+		t.Fatalf("JWKS %s: %v", jwks, err)
+	}
+	key := set.Keys[0]
+	if key["kty"] != "OKP" || key["crv"] != "Ed25519" || key["alg"] != "EdDSA" {
+		t.Fatalf("JWK: %v", key)
+	}
+	pub, err := base64.RawURLEncoding.DecodeString(key["x"])
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		t.Fatalf("JWK public key: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q", token)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		t.Fatal("signature does not verify against the JWKS")
+	}
+
+	var header map[string]string
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	if header["kid"] != key["kid"] {
+		t.Fatalf("token kid %q, JWKS kid %q", header["kid"], key["kid"])
+	}
+	var claims Claims
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	return header, claims
+}
+
+func TestIssue(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys", "signing.pem")
+	issuer, err := NewTokenIssuer(keyPath, 15*time.Minute, "control-plane", []string{"page-server", "safekeeper"})
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	jwks, err := issuer.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		timeline string
+		scopes   []string
+	}{
+		{"compute", "tenant-1", "", ComputeScopes},
+		{"timeline", "tenant-1", "timeline-1", []string{ScopeReadPages}},
+		{"admin", "", "", []string{ScopeAdmin}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			token, expiresAt, err := issuer.Issue("compute-1", tt.tenant, tt.timeline, tt.scopes)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			header, claims := verify(t, jwks, token)
+			if header["alg"] != "EdDSA" {
+				t.Fatalf("header: %v", header)
+			}
+			if claims.Subject != "compute-1" || claims.Issuer != "control-plane" || claims.TenantID != tt.tenant ||
+				!reflect.DeepEqual(claims.Audience, []string{"page-server", "safekeeper"}) ||
+				claims.TimelineID != tt.timeline || !reflect.DeepEqual(claims.Scopes, tt.scopes) {
+				t.Fatalf("claims: %+v", claims)
+			}
+			if claims.ExpiresAt != expiresAt.Unix() || expiresAt.Sub(before) < 15*time.Minute || claims.NotBefore > claims.IssuedAt || claims.IssuedAt < before.Unix() {
+				t.Fatalf("validity: %+v, expires %v", claims, expiresAt)
+			}
+		})
+	}
+}
+
+func TestSigningKeyPersistence(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "signing.pem")
+	first, err := NewTokenIssuer(keyPath, time.Minute, "control-plane", nil)
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("signing key file: %v, %v", info, err)
+	}
+
+	// Restarting with the same key keeps tokens verifiable
+	second, err := NewTokenIssuer(keyPath, time.Minute, "control-plane", nil)
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	if second.kid != first.kid {
+		t.Fatalf("kid changed across restarts: %s, %s", first.kid, second.kid)
+	}
+	token, _, _ := first.Issue("c", "t", "", ComputeScopes)
+	jwksPath := filepath.Join(dir, "jwks.json")
+	if err := second.WriteJWKS(jwksPath); err != nil {
+		t.Fatalf("WriteJWKS: %v", err)
+	}
+	jwks, err := os.ReadFile(jwksPath)
+	if err != nil {
+		t.Fatalf("read JWKS: %v", err)
+	}
+	verify(t, jwks, token)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"not PEM", []byte("garbage"), "no PEM block"},
+		{"not PKCS#8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}), "failed to parse signing key"},
+		{"not Ed25519", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}), "is not an Ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".pem")
+			if err := os.WriteFile(path, tt.data, 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := NewTokenIssuer(path, time.Minute, "control-plane", nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/linux/projects/server/control-plane/internal/auth"
 	"github.com/linux/projects/server/control-plane/internal/billing"
 	"github.com/linux/projects/server/control-plane/internal/state"
 	"github.com/linux/projects/server/control-plane/pkg/types"
@@ -24,6 +25,7 @@ type Manager struct {
 	stateStore   state.StoreInterface
 	namespace    string
 	usageTracker *billing.UsageTracker
+	tokenIssuer  *auth.TokenIssuer
 }
 
 // NewManager creates a new compute manager
@@ -46,6 +48,42 @@ func (m *Manager) SetUsageTracker(tracker *billing.UsageTracker) {
 	m.usageTracker = tracker
 }
 
+// SetTokenIssuer enables minting page server/safekeeper tokens for compute nodes
+func (m *Manager) SetTokenIssuer(issuer *auth.TokenIssuer) {
+	m.tokenIssuer = issuer
+}
+
+// IssueComputeToken mints a short-lived token scoped to the compute's project
+func (m *Manager) IssueComputeToken(computeID string) (string, time.Time, error) {
+	if m.tokenIssuer == nil {
+		return "", time.Time{}, fmt.Errorf("token issuing is not enabled")
+	}
+
+	node, err := m.stateStore.GetComputeNode(computeID)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get compute node: %w", err)
+	}
+
+	return m.tokenIssuer.Issue("compute:"+computeID, node.ProjectID, "", auth.ComputeScopes)
+}
+
+// authEnv returns the token environment variables for a compute pod
+func (m *Manager) authEnv(projectID, computeID string) ([]corev1.EnvVar, error) {
+	if m.tokenIssuer == nil {
+		return nil, nil
+	}
+
+	token, expiresAt, err := m.tokenIssuer.Issue("compute:"+computeID, projectID, "", auth.ComputeScopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue compute token: %w", err)
+	}
+
+	return []corev1.EnvVar{
+		{Name: "AUTH_TOKEN", Value: token},
+		{Name: "AUTH_TOKEN_EXPIRES_AT", Value: expiresAt.UTC().Format(time.RFC3339)},
+	}, nil
+}
+
 // CreateComputeNode creates a new MariaDB compute node in Kubernetes
 func (m *Manager) CreateComputeNode(projectID string, config types.ComputeConfig) (*types.ComputeNode, error) {
 	// Generate compute node ID
@@ -79,6 +117,11 @@ func (m *Manager) CreateComputeNode(projectID string, config types.ComputeConfig
 		config.Resources.Memory = "256Mi" // Reduced for k3s/local development
 	}
 
+	authEnv, err := m.authEnv(projectID, computeID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create Kubernetes pod
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -95,14 +138,14 @@ func (m *Manager) CreateComputeNode(projectID string, config types.ComputeConfig
 				{
 					Name:  "mariadb",
 					Image: config.Image,
-					Env: []corev1.EnvVar{
+					Env: append([]corev1.EnvVar{
 						{Name: "PAGE_SERVER_URL", Value: config.PageServerURL},
 						{Name: "SAFEKEEPER_URL", Value: config.SafekeeperURL},
 						{Name: "PROJECT_ID", Value: projectID},
 						{Name: "COMPUTE_ID", Value: computeID},
 						{Name: "MYSQL_ROOT_PASSWORD", Value: "root"},
 						{Name: "MYSQL_DATABASE", Value: "test"},
-					},
+					}, authEnv...),
 					Resources: corev1.ResourceRequirements{
 						Requests: corev1.ResourceList{
 							corev1.ResourceCPU:    resource.MustParse(config.Resources.CPU),
@@ -338,6 +381,12 @@ func (m *Manager) ResumeComputeNode(computeID string) (*types.ComputeNode, error
 	}
 
 createPod:
+	// Mint a fresh token for the recreated pod
+	authEnv, err := m.authEnv(node.ProjectID, computeID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Recreate pod
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -354,14 +403,14 @@ createPod:
 				{
 					Name:  "mariadb",
 					Image: node.Config.Image,
-					Env: []corev1.EnvVar{
+					Env: append([]corev1.EnvVar{
 						{Name: "PAGE_SERVER_URL", Value: node.Config.PageServerURL},
 						{Name: "SAFEKEEPER_URL", Value: node.Config.SafekeeperURL},
 						{Name: "PROJECT_ID", Value: node.ProjectID},
 						{Name: "COMPUTE_ID", Value: computeID},
 						{Name: "MYSQL_ROOT_PASSWORD", Value: "root"},
 						{Name: "MYSQL_DATABASE", Value: "test"},
-					},
+					}, authEnv...),
 					Resources: corev1.ResourceRequirements{
 						Requests: corev1.ResourceList{
 							corev1.ResourceCPU:    resource.MustParse(node.Config.Resources.CPU),
@@ -3,6 +3,7 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -20,6 +21,7 @@ type Router struct {
 	computeManager  *compute.Manager
 	controlPlaneURL string
 	proxyPort       int
+	httpClient      *http.Client
 }
 
 // NewRouter creates a new connection router
@@ -28,6 +30,15 @@ func NewRouter(computeManager *compute.Manager, controlPlaneURL string, proxyPor
 		computeManager:  computeManager,
 		controlPlaneURL: controlPlaneURL,
 		proxyPort:       proxyPort,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetTLSConfig makes control plane calls use TLS (with a client certificate for mTLS)
+func (r *Router) SetTLSConfig(cfg *tls.Config) {
+	r.httpClient = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: cfg},
 	}
 }
 
@@ -163,8 +174,7 @@ func (r *Router) wakeCompute(ctx context.Context, projectID string) (*types.Wake
 	var lastErr error
 	
 	for i := 0; i < maxRetries; i++ {
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
+		resp, err := r.httpClient.Do(req)
 		if err != nil {
 			lastErr = err
 			if i < maxRetries-1 {
@@ -0,0 +1,180 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op names an ObjectStore operation for fault injection
+type Op string
+
+const (
+	OpPut          Op = "put"
+	OpGet          Op = "get"
+	OpGetRange     Op = "get_range"
+	OpList         Op = "list"
+	OpListPrefixes Op = "list_prefixes"
+	OpDelete       Op = "delete"
+)
+
+// Fault decides whether an operation fails. A non-nil error is returned to
+// the caller and the operation has no effect. A Fault may also sleep to
+// simulate latency.
+type Fault func(op Op, key string) error
+
+// MemoryStore is an in-memory ObjectStore for tests
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+	fault   Fault
+	calls   map[Op]int
+}
+
+type memoryObject struct {
+	data     []byte
+	modified time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		objects: make(map[string]memoryObject),
+		calls:   make(map[Op]int),
+	}
+}
+
+// SetFault installs a fault injector; nil removes it
+func (m *MemoryStore) SetFault(fault Fault) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fault = fault
+}
+
+// FailNext makes the next n calls of op fail with err
+func (m *MemoryStore) FailNext(op Op, n int, err error) {
+	var mu sync.Mutex
+	m.SetFault(func(o Op, key string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if o != op || n == 0 {
+			return nil
+		}
+		n--
+		return err
+	})
+}
+
+// Calls returns the number of times op was called, including failed calls
+func (m *MemoryStore) Calls(op Op) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.calls[op]
+}
+
+// begin counts a call and runs the fault injector
+func (m *MemoryStore) begin(ctx context.Context, op Op, key string) error {
+	m.mu.Lock()
+	m.calls[op]++
+	fault := m.fault
+	m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if fault != nil {
+		if err := fault(op, key); err != nil {
+			return fmt.Errorf("injected %s fault on %q: %w", op, key, err)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, key string, body io.Reader) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	if err := m.begin(ctx, OpPut, key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read object body: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, modified: time.Now()}
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.get(ctx, OpGet, key, 0, -1)
+}
+
+func (m *MemoryStore) GetRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	return m.get(ctx, OpGetRange, key, offset, length)
+}
+
+func (m *MemoryStore) get(ctx context.Context, op Op, key string, offset, length int64) ([]byte, error) {
+	if err := m.begin(ctx, op, key); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return clip(object.data, offset, length), nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := m.begin(ctx, OpList, prefix); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var objects []ObjectInfo
+	for key, object := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectInfo{Key: key, Size: int64(len(object.data)), LastModified: object.modified})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *MemoryStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	if err := m.begin(ctx, OpListPrefixes, prefix); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	prefixes := commonPrefixes(keys, prefix)
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := m.begin(ctx, OpDelete, key); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
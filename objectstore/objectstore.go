@@ -0,0 +1,164 @@
+// Package objectstore is the object storage abstraction shared by the page
+// server and the safekeeper. Implementations: S3-compatible services, a local
+// directory for development and offline CI, and an in-memory fake with fault
+// injection for tests.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get and GetRange for keys that do not exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for keys that are empty or escape the store
+var ErrInvalidKey = errors.New("invalid object key")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectStore stores objects under slash-separated keys
+type ObjectStore interface {
+	// Put stores body under key, replacing any existing object.
+	// The object is not visible until it is completely written.
+	Put(ctx context.Context, key string, body io.Reader) error
+
+	// Get returns the contents of an object, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// GetRange returns length bytes of an object starting at offset; a negative
+	// length reads to the end. The result is shorter if the object ends first.
+	GetRange(ctx context.Context, key string, offset, length int64) ([]byte, error)
+
+	// List returns the objects whose keys start with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// ListPrefixes returns the distinct key segments directly below prefix that
+	// have objects under them, each ending in "/", sorted
+	ListPrefixes(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// CredentialUpdater is implemented by stores whose credentials can be rotated at runtime
+type CredentialUpdater interface {
+	UpdateCredentials(accessKey, secretKey string)
+}
+
+// validKey rejects keys that are empty, absolute or contain . or .. segments
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixed is an ObjectStore that keeps its keys below a prefix of another store
+type prefixed struct {
+	store  ObjectStore
+	prefix string // Ends in "/"
+}
+
+// WithPrefix returns a view of store in which every key is below prefix.
+// Keys returned by List and ListPrefixes are relative to prefix.
+func WithPrefix(store ObjectStore, prefix string) ObjectStore {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return store
+	}
+	return &prefixed{store: store, prefix: path.Clean(prefix) + "/"}
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, body io.Reader) error {
+	return p.store.Put(ctx, p.prefix+key, body)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) GetRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	return p.store.GetRange(ctx, p.prefix+key, offset, length)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := p.store.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, nil
+}
+
+func (p *prefixed) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	prefixes, err := p.store.ListPrefixes(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range prefixes {
+		prefixes[i] = strings.TrimPrefix(prefixes[i], p.prefix)
+	}
+	return prefixes, nil
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.store.Delete(ctx, p.prefix+key)
+}
+
+// UpdateCredentials rotates the credentials of the underlying store, if it has any
+func (p *prefixed) UpdateCredentials(accessKey, secretKey string) {
+	if updater, ok := p.store.(CredentialUpdater); ok {
+		updater.UpdateCredentials(accessKey, secretKey)
+	}
+}
+
+// clip returns data[offset:offset+length], clipped to data; a negative length reads to the end
+func clip(data []byte, offset, length int64) []byte {
+	if offset < 0 || offset >= int64(len(data)) {
+		return []byte{}
+	}
+	end := int64(len(data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	return append([]byte(nil), data[offset:end]...)
+}
+
+// commonPrefixes returns the distinct segments directly below prefix among keys
+func commonPrefixes(keys []string, prefix string) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		i := strings.Index(rest, "/")
+		if i < 0 {
+			continue
+		}
+		p := prefix + rest[:i+1]
+		if !seen[p] {
+			seen[p] = true
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
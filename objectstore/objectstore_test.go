@@ -0,0 +1,259 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) ObjectStore { return NewMemoryStore() })
+}
+
+func TestFSStore(t *testing.T) {
+	testStore(t, func(t *testing.T) ObjectStore {
+		store, err := NewFSStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFSStore: %v", err)
+		}
+		return store
+	})
+}
+
+func TestOpenFileEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	testStore(t, func(t *testing.T) ObjectStore {
+		store, err := Open(context.Background(), S3Config{Endpoint: "file://" + dir, Bucket: t.Name()})
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		return store
+	})
+}
+
+func TestWithPrefix(t *testing.T) {
+	testStore(t, func(t *testing.T) ObjectStore { return WithPrefix(NewMemoryStore(), "/tenant/a/") })
+
+	// Objects of another prefix are not visible
+	base := NewMemoryStore()
+	ctx := context.Background()
+	a, b := WithPrefix(base, "a"), WithPrefix(base, "b")
+	if err := a.Put(ctx, "x/1", strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+	if objects, err := b.List(ctx, ""); err != nil || len(objects) != 0 {
+		t.Fatalf("List of another prefix = %v, %v", objects, err)
+	}
+	if objects, err := base.List(ctx, ""); err != nil || len(objects) != 1 || objects[0].Key != "a/x/1" {
+		t.Fatalf("List of the base store = %v, %v; want a/x/1", objects, err)
+	}
+}
+
+// TestS3Store runs against the bucket in OBJECTSTORE_TEST_S3_ENDPOINT, _BUCKET,
+// _REGION, _ACCESS_KEY and _SECRET_KEY, and is skipped without one
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("OBJECTSTORE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("OBJECTSTORE_TEST_S3_ENDPOINT not set")
+	}
+	region := os.Getenv("OBJECTSTORE_TEST_S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	store, err := NewS3Store(context.Background(), S3Config{
+		Endpoint:     endpoint,
+		Bucket:       os.Getenv("OBJECTSTORE_TEST_S3_BUCKET"),
+		Region:       region,
+		AccessKey:    os.Getenv("OBJECTSTORE_TEST_S3_ACCESS_KEY"),
+		SecretKey:    os.Getenv("OBJECTSTORE_TEST_S3_SECRET_KEY"),
+		PathStyle:    true,
+		CreateBucket: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	run := time.Now().UnixNano()
+	testStore(t, func(t *testing.T) ObjectStore {
+		return WithPrefix(store, fmt.Sprintf("objectstore-test/%d/%s", run, t.Name()))
+	})
+}
+
+func TestMemoryStoreFaults(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	errBoom := errors.New("boom")
+
+	store.FailNext(OpPut, 2, errBoom)
+	for i := 0; i < 2; i++ {
+		if err := store.Put(ctx, "k", strings.NewReader("v")); !errors.Is(err, errBoom) {
+			t.Fatalf("Put %d = %v, want injected fault", i, err)
+		}
+	}
+	if _, err := store.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("a failed Put stored the object: %v", err)
+	}
+	if err := store.Put(ctx, "k", strings.NewReader("v")); err != nil {
+		t.Fatalf("Put after the faults: %v", err)
+	}
+	if n := store.Calls(OpPut); n != 3 {
+		t.Fatalf("Calls(OpPut) = %d, want 3", n)
+	}
+
+	store.SetFault(func(op Op, key string) error {
+		if op == OpGet && key == "k" {
+			return errBoom
+		}
+		return nil
+	})
+	if _, err := store.Get(ctx, "k"); !errors.Is(err, errBoom) {
+		t.Fatalf("Get = %v, want injected fault", err)
+	}
+	if _, err := store.GetRange(ctx, "k", 0, 1); err != nil {
+		t.Fatalf("GetRange is not faulted: %v", err)
+	}
+	store.SetFault(nil)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.Get(cancelled, "k"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get with a cancelled context = %v", err)
+	}
+}
+
+// testStore checks the ObjectStore contract against stores made by newStore
+func testStore(t *testing.T, newStore func(t *testing.T) ObjectStore) {
+	ctx := context.Background()
+
+	t.Run("PutGet", func(t *testing.T) {
+		store := newStore(t)
+		if _, err := store.Get(ctx, "a/b"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get of a missing key = %v, want ErrNotFound", err)
+		}
+		for _, data := range [][]byte{[]byte("first"), []byte("second, longer"), {}} {
+			if err := store.Put(ctx, "a/b", bytes.NewReader(data)); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got, err := store.Get(ctx, "a/b"); err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("Get = %q, %v; want %q", got, err, data)
+			}
+		}
+	})
+
+	t.Run("GetRange", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Put(ctx, "r", strings.NewReader("0123456789")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		for _, tt := range []struct {
+			offset, length int64
+			want           string
+		}{{0, 4, "0123"}, {3, 2, "34"}, {8, 10, "89"}, {5, -1, "56789"}, {10, 1, ""}, {20, -1, ""}} {
+			got, err := store.GetRange(ctx, "r", tt.offset, tt.length)
+			if err != nil || string(got) != tt.want {
+				t.Fatalf("GetRange(%d, %d) = %q, %v; want %q", tt.offset, tt.length, got, err, tt.want)
+			}
+		}
+		if _, err := store.GetRange(ctx, "missing", 0, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetRange of a missing key = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		store := newStore(t)
+		for _, key := range []string{"pages/space_2/p1", "pages/space_1/p2", "pages/space_1/p1", "pages/spaces", "wal/w1"} {
+			if err := store.Put(ctx, key, strings.NewReader(key)); err != nil {
+				t.Fatalf("Put(%s): %v", key, err)
+			}
+		}
+
+		objects, err := store.List(ctx, "pages/space_1/")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		var keys []string
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+			if object.Size != int64(len(object.Key)) {
+				t.Fatalf("List reports %d bytes for %s", object.Size, object.Key)
+			}
+		}
+		if got := strings.Join(keys, ","); got != "pages/space_1/p1,pages/space_1/p2" {
+			t.Fatalf("List(pages/space_1/) = %s", got)
+		}
+		if objects, err := store.List(ctx, "pages/space_"); err != nil || len(objects) != 3 {
+			t.Fatalf("List(pages/space_) = %v, %v; want 3 objects", objects, err)
+		}
+		if objects, err := store.List(ctx, "nothing/"); err != nil || len(objects) != 0 {
+			t.Fatalf("List of an empty prefix = %v, %v", objects, err)
+		}
+
+		prefixes, err := store.ListPrefixes(ctx, "pages/")
+		if err != nil {
+			t.Fatalf("ListPrefixes: %v", err)
+		}
+		if got := strings.Join(prefixes, ","); got != "pages/space_1/,pages/space_2/" {
+			t.Fatalf("ListPrefixes(pages/) = %s", got)
+		}
+		if prefixes, err := store.ListPrefixes(ctx, ""); err != nil || strings.Join(prefixes, ",") != "pages/,wal/" {
+			t.Fatalf("ListPrefixes() = %v, %v", prefixes, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Put(ctx, "d/x", strings.NewReader("x")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if err := store.Delete(ctx, "d/x"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := store.Get(ctx, "d/x"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+		}
+		if err := store.Delete(ctx, "d/x"); err != nil {
+			t.Fatalf("Delete of a missing key: %v", err)
+		}
+		if prefixes, err := store.ListPrefixes(ctx, ""); err != nil || len(prefixes) != 0 {
+			t.Fatalf("ListPrefixes after Delete = %v, %v; want none", prefixes, err)
+		}
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		store := newStore(t)
+		for _, key := range []string{"", "a/../../b", "a//b", "a/"} {
+			if err := store.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("Put(%q) = %v, want ErrInvalidKey", key, err)
+			}
+		}
+	})
+
+	t.Run("ConcurrentPut", func(t *testing.T) {
+		store := newStore(t)
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				data := bytes.Repeat([]byte{byte(w)}, 64*1024)
+				for i := 0; i < 10; i++ {
+					if err := store.Put(ctx, "shared", bytes.NewReader(data)); err != nil {
+						t.Errorf("Put: %v", err)
+						return
+					}
+					got, err := store.Get(ctx, "shared")
+					if err != nil || len(got) != len(data) || !bytes.Equal(got, bytes.Repeat(got[:1], len(got))) {
+						t.Errorf("Get returned a torn object: %d bytes, %v", len(got), err)
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+	})
+}
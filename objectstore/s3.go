@@ -0,0 +1,240 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// S3Config holds the settings of an S3-compatible bucket
+type S3Config struct {
+	Endpoint     string // e.g. https://s3.amazonaws.com or http://minio:9000; empty uses AWS
+	Bucket       string
+	Region       string
+	AccessKey    string // Empty uses the default AWS credential chain
+	SecretKey    string
+	PathStyle    bool // Path-style addressing, required by MinIO and some S3-compatible services
+	CreateBucket bool // Create the bucket if it does not exist
+}
+
+// S3Store is an ObjectStore on an S3-compatible bucket
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	creds  *rotatingCredentials
+}
+
+// rotatingCredentials returns static credentials that can be replaced at
+// runtime, and falls back to the default credential chain when none are set
+type rotatingCredentials struct {
+	mu        sync.RWMutex
+	accessKey string
+	secretKey string
+	fallback  aws.CredentialsProvider
+}
+
+// Retrieve implements aws.CredentialsProvider
+func (c *rotatingCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	c.mu.RLock()
+	accessKey, secretKey := c.accessKey, c.secretKey
+	c.mu.RUnlock()
+
+	if accessKey != "" && secretKey != "" {
+		return credentials.NewStaticCredentialsProvider(accessKey, secretKey, "").Retrieve(ctx)
+	}
+	if c.fallback == nil {
+		return aws.Credentials{}, fmt.Errorf("no S3 credentials configured")
+	}
+	return c.fallback.Retrieve(ctx)
+}
+
+// NewS3Store connects to an S3-compatible bucket
+func NewS3Store(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Static credentials can be rotated at runtime with UpdateCredentials
+	creds := &rotatingCredentials{
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		fallback:  awsCfg.Credentials,
+	}
+	awsCfg.Credentials = creds
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = cfg.PathStyle
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	if cfg.CreateBucket {
+		if err := ensureBucketExists(ctx, client, cfg.Bucket); err != nil {
+			return nil, fmt.Errorf("failed to ensure bucket exists: %w", err)
+		}
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket, creds: creds}, nil
+}
+
+// ensureBucketExists creates the bucket if it doesn't exist
+func ensureBucketExists(ctx context.Context, client *s3.Client, bucket string) error {
+	if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err == nil {
+		return nil
+	}
+
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	log.Printf("Created S3 bucket: %s", bucket)
+	return nil
+}
+
+// UpdateCredentials replaces the access key and secret key.
+// Requests issued after the call are signed with the new credentials.
+// Empty keys switch back to the default credential chain.
+func (s *S3Store) UpdateCredentials(accessKey, secretKey string) {
+	s.creds.mu.Lock()
+	defer s.creds.mu.Unlock()
+	s.creds.accessKey = accessKey
+	s.creds.secretKey = secretKey
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to S3: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.get(ctx, key, nil)
+}
+
+func (s *S3Store) GetRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length == 0 {
+		return []byte{}, nil
+	}
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+	data, err := s.get(ctx, key, aws.String(byteRange))
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return []byte{}, nil // Offset past the end of the object
+	}
+	return data, err
+}
+
+func (s *S3Store) get(ctx context.Context, key string, byteRange *string) ([]byte, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  byteRange,
+	})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to download %s from S3: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from S3: %w", key, err)
+	}
+	return data, nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{Key: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size)}
+			if obj.LastModified != nil {
+				info.LastModified = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+	return objects, nil
+}
+
+func (s *S3Store) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	var prefixes []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list prefixes: %w", err)
+		}
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(p.Prefix))
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from S3: %w", key, err)
+	}
+	return nil
+}
+
+// Open returns the store for an S3 configuration. An endpoint of the form
+// file:///path selects a local directory instead, with the bucket as a
+// subdirectory, for development and offline CI.
+func Open(ctx context.Context, cfg S3Config) (ObjectStore, error) {
+	if dir, ok := strings.CutPrefix(cfg.Endpoint, "file://"); ok {
+		if dir == "" {
+			return nil, fmt.Errorf("file:// endpoint needs a directory")
+		}
+		return NewFSStore(filepath.Join(dir, cfg.Bucket))
+	}
+	return NewS3Store(ctx, cfg)
+}
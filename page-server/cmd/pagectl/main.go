@@ -0,0 +1,228 @@
+// Command pagectl inspects page server storage offline: a file backend data
+// directory or the object store prefix of an s3 or hybrid backend. It lists
+// spaces, pages and versions, dumps pages with decoded headers, decodes stored
+// WAL records, verifies checksums and computes storage statistics. It only
+// reads, but a running server may change storage while it looks; for a hybrid
+// backend, pages not yet uploaded by a running server are not seen.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/linux/projects/server/objectstore"
+	"github.com/linux/projects/server/page-server/internal/config"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// errUsage is returned for invalid arguments, after the usage was printed
+var errUsage = errors.New("invalid arguments")
+
+// storageFlags defines the storage settings, shared with the page server
+// (config file and PAGESERVER_* variables apply), and returns the config path
+func storageFlags(fs *flag.FlagSet) *string {
+	configFile := fs.String("config", "", "Path to the page server YAML config file (optional)")
+
+	fs.String("data-dir", "./page-server-data", "Data directory of a file backend")
+	fs.String("storage-backend", "file", "Storage backend: file, s3, or hybrid (hybrid reads its object store)")
+	fs.String("s3-endpoint", "", "S3 endpoint (e.g., https://s3.amazonaws.com or http://minio:9000)")
+	fs.String("s3-bucket", "", "S3 bucket name")
+	fs.String("s3-region", "us-east-1", "AWS region")
+	fs.String("s3-access-key", "", "S3 access key ID")
+	fs.String("s3-secret-key", "", "S3 secret access key")
+	fs.String("s3-prefix", "", "Optional prefix for S3 objects")
+	fs.Bool("s3-use-ssl", true, "Use SSL/TLS for S3 connections")
+	return configFile
+}
+
+// session is a pagectl run: the opened storage and where output goes
+type session struct {
+	backend storage.StorageBackend
+	stdout  io.Writer // Command results
+	stderr  io.Writer // Usage
+}
+
+// command is a pagectl subcommand; run gets the arguments after its name
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, args []string) error
+}
+
+// commands is set in init because the commands' flag usage refers back to it
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"spaces": {"spaces", "List tablespaces with their page and version counts", runSpaces},
+		"pages":  {"pages -space <id> [-page <no>]", "List the pages of a space, or the versions of one page", runPages},
+		"dump":   {"dump -space <id> -page <no> [-lsn <lsn>] [-hex] [-json]", "Show a page version with its headers, records, extents and undo headers decoded", runDump},
+		"wal":    {"wal [-from <lsn>] [-to <lsn>] [-limit <n>] [-preview <bytes>] [-json]", "Decode stored WAL records into redo records", runWAL},
+		"verify": {"verify [-space <id>] [-all-versions]", "Verify InnoDB page checksums", runVerify},
+		"stats":  {"stats [-space <id>]", "Count spaces, pages, versions, WAL records and their bytes", runStats},
+	}
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintln(out, "Usage: pagectl [storage flags] <command> [command flags]")
+	fmt.Fprintln(out, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-50s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(out, "\nStorage flags:")
+	fs.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		log.Fatal(err)
+	}
+}
+
+// run parses the storage flags and runs a command on the storage they name
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("pagectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs) }
+	configFile := storageFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+
+	backend, err := openStorage(*configFile, fs)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer backend.Close()
+
+	s := &session{backend: backend, stdout: stdout, stderr: stderr}
+	if err := cmd.run(s, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return nil
+}
+
+// usageError turns a flag parsing error into errUsage; -h stays flag.ErrHelp
+func usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return errUsage
+}
+
+// openStorage opens the storage named by the flags and config file without
+// creating a data directory or bucket
+func openStorage(configFile string, fs *flag.FlagSet) (storage.StorageBackend, error) {
+	fileCfg, err := config.Load(configFile, fs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	cfg := fileCfg.Config
+
+	switch cfg.StorageType {
+	case "file", "":
+		// NewFileStorage creates missing directories, so check first
+		if _, err := os.Stat(filepath.Join(cfg.DataDir, "pages")); err != nil {
+			return nil, fmt.Errorf("%s is not a page server data directory: %w", cfg.DataDir, err)
+		}
+		return storage.NewFileStorage(cfg.DataDir)
+	case "s3", "hybrid":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("s3-endpoint and s3-bucket are required for the %s backend", cfg.StorageType)
+		}
+		// Unlike the server, never create the bucket
+		store, err := objectstore.Open(context.Background(), objectstore.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open object store: %w", err)
+		}
+		return storage.NewObjectStorage(objectstore.WithPrefix(store, cfg.S3Prefix)), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s (supported: file, s3, hybrid)", cfg.StorageType)
+	}
+}
+
+// versioned returns the backend's page version listing
+func versioned(backend storage.StorageBackend) (storage.VersionedStorage, error) {
+	v, ok := backend.(storage.VersionedStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage backend cannot list page versions")
+	}
+	return v, nil
+}
+
+// sortedPages returns the page numbers of a version listing in ascending order,
+// sorting each page's LSNs too
+func sortedPages(versions map[uint32][]uint64) []uint32 {
+	pages := make([]uint32, 0, len(versions))
+	for pageNo, lsns := range versions {
+		sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+		pages = append(pages, pageNo)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	return pages
+}
+
+// parseFlags parses the flags of a subcommand, printing its usage on errors
+func (s *session) parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(s.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pagectl [storage flags] %s\n", commands[fs.Name()].usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return usageError(err)
+	}
+	return nil
+}
+
+// missingFlag prints the usage of a subcommand and returns errUsage if a
+// required flag is not set
+func missingFlag(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if !isSet(fs, name) {
+			fs.Usage()
+			return errUsage
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linux/projects/server/objectstore"
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// listResult is the ListObjectsV2 response
+type listResult struct {
+	XMLName        xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name           string
+	Prefix         string
+	KeyCount       int
+	MaxKeys        int
+	IsTruncated    bool
+	Contents       []listObject
+	CommonPrefixes []listPrefix
+}
+
+type listObject struct {
+	Key  string
+	Size int64
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+// s3Server serves the objects of store as bucket, with just the GetObject
+// and ListObjectsV2 calls pagectl makes
+func s3Server(t *testing.T, store objectstore.ObjectStore, bucket string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "read only", http.StatusMethodNotAllowed)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/"+bucket)
+		if key := strings.TrimPrefix(path, "/"); key != "" {
+			data, err := store.Get(r.Context(), key)
+			if errors.Is(err, objectstore.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>"))
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// The SDK validates the body against this and warns without it
+			sum := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data))
+			w.Header().Set("x-amz-checksum-crc32", base64.StdEncoding.EncodeToString(sum))
+			w.Write(data)
+			return
+		}
+
+		query := r.URL.Query()
+		result := listResult{Name: bucket, Prefix: query.Get("prefix"), MaxKeys: 1000}
+		if query.Get("delimiter") == "/" {
+			prefixes, err := store.ListPrefixes(r.Context(), result.Prefix)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, p := range prefixes {
+				result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{p})
+			}
+		} else {
+			objects, err := store.List(r.Context(), result.Prefix)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, obj := range objects {
+				result.Contents = append(result.Contents, listObject{obj.Key, obj.Size})
+			}
+		}
+		result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newBucket returns the storage flags of a bucket holding space 5: page 0
+// with a full_crc32 FSP header at LSN 10, and page 3 at LSN 10 and, written
+// by WAL apply, at LSN 20
+func newBucket(t *testing.T) []string {
+	t.Helper()
+	store := objectstore.NewMemoryStore()
+	backend := storage.NewObjectStorage(objectstore.WithPrefix(store, "tenant1"))
+
+	page0 := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint16(page0[innodb.FilPageType:], innodb.PageTypeFSPHdr)
+	binary.BigEndian.PutUint32(page0[innodb.FilPageSpaceID:], 5)
+	innodb.PutFSPHeader(page0, innodb.FSPHeader{SpaceID: 5, Size: 4, Flags: 0x10})
+	page3 := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint32(page3[innodb.FilPageSpaceID:], 5)
+	walData := []byte{0x34, 5, 3, 64, 'x'} // WRITE of one byte at offset 64
+	applied := innodb.StampPage(page3, 3, 10, true)
+	applied[64] = 'x' // The WAL apply does not recompute the checksum
+	for _, v := range []struct {
+		pageNo uint32
+		lsn    uint64
+		data   []byte
+	}{
+		{0, 10, innodb.StampPage(page0, 0, 10, true)},
+		{3, 10, innodb.StampPage(page3, 3, 10, true)},
+		{3, 20, applied},
+	} {
+		if err := backend.StorePage(5, v.pageNo, v.lsn, v.data); err != nil {
+			t.Fatalf("StorePage: %v", err)
+		}
+	}
+	if err := backend.StoreWAL(20, walData); err != nil {
+		t.Fatalf("StoreWAL: %v", err)
+	}
+
+	srv := s3Server(t, store, "pages")
+	return []string{"-storage-backend", "s3", "-s3-endpoint", srv.URL, "-s3-bucket", "pages",
+		"-s3-prefix", "tenant1", "-s3-access-key", "test", "-s3-secret-key", "test"}
+}
+
+// fields collapses the runs of spaces tabwriter pads columns with
+func fields(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCommands(t *testing.T) {
+	storageArgs := newBucket(t)
+	tests := []struct {
+		name string
+		args []string
+		want []string // Lines of the output, with columns separated by one space
+	}{
+		{"spaces", []string{"spaces"}, []string{
+			"SPACE PAGES VERSIONS NEWEST_LSN FSP_SIZE PAGE_SIZE",
+			"5 2 3 20 4 16384",
+		}},
+		{"pages", []string{"pages", "-space", "5"}, []string{
+			"PAGE VERSIONS OLDEST_LSN NEWEST_LSN",
+			"0 1 10 10",
+			"3 2 10 20",
+		}},
+		{"page versions", []string{"pages", "-space", "5", "-page", "3"}, []string{
+			"LSN BYTES TYPE FIL_LSN",
+			"10 16384 ALLOCATED 10",
+		}},
+		{"dump", []string{"dump", "-space", "5", "-page", "0"}, []string{
+			"Space 5 page 0 version 10: 16384 bytes",
+			"lsn 10",
+			"Checksum: valid (full_crc32)",
+			"size 4 pages",
+		}},
+		{"dump at an LSN", []string{"dump", "-space", "5", "-page", "3", "-lsn", "15"}, []string{
+			"Space 5 page 3 version 10: 16384 bytes",
+			"Checksum: valid (full_crc32)",
+		}},
+		{"dump of a version written by WAL apply", []string{"dump", "-space", "5", "-page", "3"}, []string{
+			"Space 5 page 3 version 20: 16384 bytes",
+			"Checksum: not checked (version written by WAL apply)",
+		}},
+		{"wal", []string{"wal"}, []string{
+			"LSN 20: 5 bytes, 1 redo records applied",
+			"@0 WRITE space=5 page=3 offset=64 len=1 data=78",
+			"Decoded 1 WAL records, 0 with errors",
+		}},
+		{"wal limit", []string{"wal", "-to", "15"}, []string{
+			"Decoded 0 WAL records, 0 with errors",
+		}},
+		{"verify", []string{"verify", "-all-versions"}, []string{
+			"Checked 3 versions in 1 spaces: 2 valid, 0 all zeros, 1 written by WAL apply (not checked), 0 failed",
+		}},
+		{"stats", []string{"stats"}, []string{
+			"SPACE PAGES VERSIONS MAX_PER_PAGE BYTES OLDEST_LSN NEWEST_LSN",
+			"5 2 3 2 49152 10 20",
+			"total 2 3 2 49152 10 20",
+			"WAL: 1 records, 5 bytes, LSN 20 to 20 (latest LSN 20)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(append(storageArgs, tt.args...), &stdout, &stderr); err != nil {
+				t.Fatalf("run: %v\n%s", err, stderr.String())
+			}
+			out := fields(stdout.String())
+			for _, line := range tt.want {
+				if !strings.Contains(out, line) {
+					t.Errorf("output has no line %q:\n%s", line, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	storageArgs := newBucket(t)
+
+	var stdout bytes.Buffer
+	if err := run(append(storageArgs, "dump", "-space", "5", "-page", "0", "-json"), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	var page types.DecodePageResponse
+	if err := json.Unmarshal(stdout.Bytes(), &page); err != nil || page.PageLSN != 10 || page.Page.FSP == nil || page.Page.FSP.Size != 4 {
+		t.Fatalf("dump -json: %+v, %v", page, err)
+	}
+
+	stdout.Reset()
+	if err := run(append(storageArgs, "wal", "-json"), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("wal: %v", err)
+	}
+	var record types.DecodedWAL
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil || record.LSN != 20 || len(record.RedoRecords) != 1 {
+		t.Fatalf("wal -json: %+v, %v", record, err)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	storageArgs := newBucket(t)
+	missingDir := []string{"-data-dir", t.TempDir()}
+	tests := []struct {
+		name   string
+		args   []string
+		err    error  // Wrapped by the error, if set
+		errMsg string // Contained in the error, if set
+		usage  string // Printed to stderr
+	}{
+		{"no command", storageArgs, errUsage, "", "Usage: pagectl [storage flags] <command>"},
+		{"unknown command", append(storageArgs, "fsck"), errUsage, "", `Unknown command "fsck"`},
+		{"unknown storage flag", []string{"-s3-bucket-name", "x", "spaces"}, errUsage, "", "flag provided but not defined"},
+		{"help", []string{"-h"}, flag.ErrHelp, "", "Storage flags:"},
+		{"unknown command flag", append(storageArgs, "spaces", "-verbose"), errUsage, "", "Usage: pagectl [storage flags] spaces"},
+		{"invalid flag value", append(storageArgs, "pages", "-space", "five"), errUsage, "", "invalid value"},
+		{"missing space", append(storageArgs, "pages"), errUsage, "", "Usage: pagectl [storage flags] pages -space <id>"},
+		{"missing page", append(storageArgs, "dump", "-space", "5"), errUsage, "", "-page uint"},
+		{"command help", append(storageArgs, "wal", "-h"), flag.ErrHelp, "", "-preview int"},
+		{"no data directory", append(missingDir, "spaces"), nil, "is not a page server data directory", ""},
+		{"s3 without a bucket", []string{"-storage-backend", "s3", "-s3-endpoint", "http://localhost:1", "spaces"}, nil, "s3-endpoint and s3-bucket are required", ""},
+		{"unknown backend", []string{"-storage-backend", "tape", "spaces"}, nil, "unknown storage backend: tape", ""},
+		{"page not stored", append(storageArgs, "pages", "-space", "5", "-page", "9"), storage.ErrPageNotFound, "pages:", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(tt.args, &stdout, &stderr)
+			if err == nil {
+				t.Fatalf("run succeeded:\n%s", stdout.String())
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("error %v, want %v", err, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error %q does not contain %q", err, tt.errMsg)
+			}
+			if !strings.Contains(stderr.String(), tt.usage) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.usage, stderr.String())
+			}
+		})
+	}
+}
+
+// TestVerifyReportsBadChecksums checks the exit error and the line printed
+// for a corrupt version
+func TestVerifyReportsBadChecksums(t *testing.T) {
+	store := objectstore.NewMemoryStore()
+	backend := storage.NewObjectStorage(store)
+	page := innodb.StampPage(make([]byte, innodb.DefaultPageSize), 1, 10, false)
+	page[100] ^= 0xff
+	if err := backend.StorePage(7, 1, 10, page); err != nil {
+		t.Fatalf("StorePage: %v", err)
+	}
+	srv := s3Server(t, store, "pages")
+
+	var stdout bytes.Buffer
+	err := run([]string{"-storage-backend", "hybrid", "-s3-endpoint", srv.URL, "-s3-bucket", "pages",
+		"-s3-access-key", "test", "-s3-secret-key", "test", "verify"}, &stdout, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "1 page versions failed verification") {
+		t.Fatalf("verify: %v", err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "space=7 page=1 lsn=10: ") || !strings.Contains(out, "1 failed") {
+		t.Fatalf("verify output:\n%s", out)
+	}
+}
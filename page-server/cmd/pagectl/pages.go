@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/pagedecode"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// runSpaces lists the stored tablespaces
+func runSpaces(s *session, args []string) error {
+	fs := flag.NewFlagSet("spaces", flag.ContinueOnError)
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	v, err := versioned(s.backend)
+	if err != nil {
+		return err
+	}
+	spaces, err := v.ListSpaces()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SPACE\tPAGES\tVERSIONS\tNEWEST_LSN\tFSP_SIZE\tPAGE_SIZE")
+	for _, spaceID := range spaces {
+		versions, err := v.ListPageVersions(spaceID)
+		if err != nil {
+			return err
+		}
+		count, newest := 0, uint64(0)
+		for _, lsns := range versions {
+			count += len(lsns)
+			for _, lsn := range lsns {
+				newest = max(newest, lsn)
+			}
+		}
+
+		// The FSP header on the newest page 0 gives the size the space had then
+		size, pageSize := "-", "-"
+		if page, _, err := s.backend.LoadPage(spaceID, 0, math.MaxUint64); err == nil {
+			if hdr, err := innodb.ParseFSPHeader(page); err == nil {
+				size, pageSize = fmt.Sprint(hdr.Size), fmt.Sprint(hdr.PageSize())
+			}
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t%s\n", spaceID, len(versions), count, newest, size, pageSize)
+	}
+	return w.Flush()
+}
+
+// runPages lists the pages of a space, or the versions of one page
+func runPages(s *session, args []string) error {
+	fs := flag.NewFlagSet("pages", flag.ContinueOnError)
+	spaceID := fs.Uint("space", 0, "Tablespace ID (required)")
+	pageNo := fs.Uint("page", 0, "List the versions of this page")
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := missingFlag(fs, "space"); err != nil {
+		return err
+	}
+
+	v, err := versioned(s.backend)
+	if err != nil {
+		return err
+	}
+	versions, err := v.ListPageVersions(uint32(*spaceID))
+	if err != nil {
+		return err
+	}
+	pages := sortedPages(versions)
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	if !isSet(fs, "page") {
+		fmt.Fprintln(w, "PAGE\tVERSIONS\tOLDEST_LSN\tNEWEST_LSN")
+		for _, no := range pages {
+			lsns := versions[no]
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\n", no, len(lsns), lsns[0], lsns[len(lsns)-1])
+		}
+		return w.Flush()
+	}
+
+	lsns, ok := versions[uint32(*pageNo)]
+	if !ok {
+		return fmt.Errorf("%w: space=%d page=%d", storage.ErrPageNotFound, *spaceID, *pageNo)
+	}
+	fmt.Fprintln(w, "LSN\tBYTES\tTYPE\tFIL_LSN")
+	for _, lsn := range lsns {
+		page, _, err := s.backend.LoadPage(uint32(*spaceID), uint32(*pageNo), lsn)
+		if err != nil {
+			fmt.Fprintf(w, "%d\t-\t%v\t-\n", lsn, err)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%d\n", lsn, len(page), innodb.PageTypeName(innodb.PageType(page)), innodb.PageLSN(page))
+	}
+	return w.Flush()
+}
+
+// runDump prints one page version with its decoded structures
+func runDump(s *session, args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	spaceID := fs.Uint("space", 0, "Tablespace ID (required)")
+	pageNo := fs.Uint("page", 0, "Page number (required)")
+	lsn := fs.Uint64("lsn", 0, "Show the newest version at or below this LSN (default: the newest version)")
+	hexDump := fs.Bool("hex", false, "Also print a hex dump of the page")
+	asJSON := fs.Bool("json", false, "Print the decoded page as JSON, as /api/v1/debug/page does")
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := missingFlag(fs, "space", "page"); err != nil {
+		return err
+	}
+	if !isSet(fs, "lsn") {
+		*lsn = math.MaxUint64
+	}
+
+	page, pageLSN, err := s.backend.LoadPage(uint32(*spaceID), uint32(*pageNo), *lsn)
+	if err != nil {
+		return err
+	}
+	decoded := pagedecode.Decode(page)
+	if *asJSON {
+		enc := json.NewEncoder(s.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(types.DecodePageResponse{Status: "success", PageLSN: pageLSN, Page: decoded})
+	}
+
+	fmt.Fprintf(s.stdout, "Space %d page %d version %d: %d bytes\n", *spaceID, *pageNo, pageLSN, len(page))
+	if decoded.ZeroPage {
+		fmt.Fprintln(s.stdout, "Page is all zeros")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	if hdr := decoded.FilHeader; hdr != nil {
+		fmt.Fprintln(w, "FIL header:")
+		fmt.Fprintf(w, "  checksum\t0x%08x\n", hdr.Checksum)
+		fmt.Fprintf(w, "  page_no\t%d\n", hdr.PageNo)
+		fmt.Fprintf(w, "  prev\t%s\n", pageRef(hdr.Prev))
+		fmt.Fprintf(w, "  next\t%s\n", pageRef(hdr.Next))
+		fmt.Fprintf(w, "  lsn\t%d\n", hdr.LSN)
+		fmt.Fprintf(w, "  type\t%s (%d)\n", hdr.TypeName, hdr.Type)
+		fmt.Fprintf(w, "  flush_lsn\t%d\n", hdr.FlushLSN)
+		fmt.Fprintf(w, "  space_id\t%d\n", hdr.SpaceID)
+	}
+	if trailer := decoded.FilTrailer; trailer != nil {
+		fmt.Fprintln(w, "FIL trailer:")
+		fmt.Fprintf(w, "  checksum\t0x%08x\n", trailer.Checksum)
+		fmt.Fprintf(w, "  lsn_low32\t0x%08x (matches header: %t)\n", trailer.LSNLow32, trailer.LSNMatches)
+	}
+
+	if fromWAL, err := appliedFromWAL(s.backend, pageLSN); err != nil {
+		fmt.Fprintf(w, "Checksum:\t%v\n", err)
+	} else if fromWAL {
+		fmt.Fprintln(w, "Checksum:\tnot checked (version written by WAL apply)")
+	} else if format, err := verifyPage(s.backend, uint32(*spaceID), page); err != nil {
+		fmt.Fprintf(w, "Checksum:\t%v (%s)\n", err, format)
+	} else {
+		fmt.Fprintf(w, "Checksum:\tvalid (%s)\n", format)
+	}
+
+	if index := decoded.Index; index != nil {
+		fmt.Fprintln(w, "INDEX header:")
+		fmt.Fprintf(w, "  index_id\t%d\n", index.IndexID)
+		fmt.Fprintf(w, "  level\t%d\n", index.Level)
+		fmt.Fprintf(w, "  n_recs\t%d\n", index.NRecs)
+		fmt.Fprintf(w, "  n_heap\t%d\n", index.NHeap)
+		fmt.Fprintf(w, "  compact\t%t\n", index.Compact)
+		fmt.Fprintf(w, "  n_dir_slots\t%d\n", index.NDirSlots)
+		fmt.Fprintf(w, "  heap_top\t%d\n", index.HeapTop)
+		fmt.Fprintf(w, "  free\t%d\n", index.Free)
+		fmt.Fprintf(w, "  garbage\t%d\n", index.Garbage)
+		fmt.Fprintf(w, "  last_insert\t%d\n", index.LastInsert)
+		fmt.Fprintf(w, "  direction\t%d (%d in a row)\n", index.Direction, index.NDirection)
+		fmt.Fprintf(w, "  max_trx_id\t%d\n", index.MaxTrxID)
+		fmt.Fprintf(w, "  directory\t%v\n", index.Directory)
+	}
+	if fsp := decoded.FSP; fsp != nil {
+		fmt.Fprintln(w, "FSP header:")
+		fmt.Fprintf(w, "  space_id\t%d\n", fsp.SpaceID)
+		fmt.Fprintf(w, "  size\t%d pages\n", fsp.Size)
+		fmt.Fprintf(w, "  free_limit\t%d\n", fsp.FreeLimit)
+		fmt.Fprintf(w, "  flags\t0x%x (page size %d, full_crc32 %t)\n", fsp.Flags, fsp.PageSize, fsp.FullCRC32)
+		fmt.Fprintf(w, "  frag_n_used\t%d\n", fsp.FragNUsed)
+		fmt.Fprintf(w, "  next_seg_id\t%d\n", fsp.NextSegID)
+		for _, list := range []struct {
+			name string
+			base types.ListBase
+		}{
+			{"free", fsp.Free}, {"free_frag", fsp.FreeFrag}, {"full_frag", fsp.FullFrag},
+			{"seg_inodes_full", fsp.SegInodesFull}, {"seg_inodes_free", fsp.SegInodesFree},
+		} {
+			fmt.Fprintf(w, "  %s\t%d (first %s, last %s)\n", list.name, list.base.Len, list.base.First, list.base.Last)
+		}
+	}
+	if undo := decoded.Undo; undo != nil {
+		fmt.Fprintln(w, "Undo page header:")
+		fmt.Fprintf(w, "  type\t%d\n", undo.Type)
+		fmt.Fprintf(w, "  start\t%d\n", undo.Start)
+		fmt.Fprintf(w, "  free\t%d\n", undo.Free)
+		if seg := undo.Segment; seg != nil {
+			fmt.Fprintln(w, "Undo segment header:")
+			fmt.Fprintf(w, "  state\t%s\n", seg.State)
+			fmt.Fprintf(w, "  last_log\t%d\n", seg.LastLog)
+			fmt.Fprintf(w, "  page_count\t%d\n", seg.PageCount)
+			for _, log := range seg.Logs {
+				fmt.Fprintf(w, "Undo log header at %d:\n", log.Offset)
+				fmt.Fprintf(w, "  trx_id\t%d\n", log.TrxID)
+				fmt.Fprintf(w, "  trx_no\t%d\n", log.TrxNo)
+				fmt.Fprintf(w, "  log_start\t%d\n", log.LogStart)
+				fmt.Fprintf(w, "  del_marks\t%t\n", log.DelMarks)
+				fmt.Fprintf(w, "  dict_trans\t%t (table %d)\n", log.DictTrans, log.TableID)
+				fmt.Fprintf(w, "  xid_exists\t%t\n", log.XIDExists)
+				fmt.Fprintf(w, "  prev_log\t%d\n", log.PrevLog)
+				fmt.Fprintf(w, "  next_log\t%d\n", log.NextLog)
+			}
+		}
+	}
+	for _, e := range decoded.Errors {
+		fmt.Fprintf(w, "Error:\t%s\n", e)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if index := decoded.Index; index != nil {
+		printRecords(s.stdout, "Records", index.Records)
+		if len(index.FreeRecords) > 0 {
+			printRecords(s.stdout, "Free list", index.FreeRecords)
+		}
+	}
+	if len(decoded.XDES) > 0 {
+		fmt.Fprintln(s.stdout, "\nExtents:")
+		w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FIRST_PAGE\tSTATE\tSEG_ID\tFREE_PAGES")
+		for _, e := range decoded.XDES {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\n", e.FirstPage, e.State, e.SegID, e.FreePages)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if *hexDump {
+		fmt.Fprintln(s.stdout)
+		fmt.Fprint(s.stdout, hex.Dump(page))
+	}
+	return nil
+}
+
+// printRecords prints a list of record headers as a table
+func printRecords(out io.Writer, title string, recs []types.RecordSummary) {
+	fmt.Fprintf(out, "\n%s:\n", title)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OFFSET\tHEAP_NO\tSTATUS\tDELETED\tMIN_REC\tN_OWNED\tNEXT")
+	for _, rec := range recs {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%t\t%t\t%d\t%d\n", rec.Offset, rec.HeapNo, rec.Status, rec.Deleted, rec.MinRec, rec.NOwned, rec.Next)
+	}
+	w.Flush()
+}
+
+// pageRef formats a page number that may be FIL_NULL
+func pageRef(pageNo uint32) string {
+	if pageNo == innodb.FilNull {
+		return "FIL_NULL"
+	}
+	return fmt.Sprint(pageNo)
+}
+
+// isSet reports whether a flag was given on the command line
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
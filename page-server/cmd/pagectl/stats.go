@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// spaceStats summarizes the stored versions of one tablespace
+type spaceStats struct {
+	pages, versions int
+	maxVersions     int // Most versions of one page
+	bytes           int64
+	oldest, newest  uint64
+}
+
+// runStats reads every stored page version and WAL record and reports their counts and sizes
+func runStats(s *session, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	spaceID := fs.Uint("space", 0, "Only count this tablespace (WAL is always counted)")
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	v, err := versioned(s.backend)
+	if err != nil {
+		return err
+	}
+	spaces := []uint32{uint32(*spaceID)}
+	if !isSet(fs, "space") {
+		if spaces, err = v.ListSpaces(); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SPACE\tPAGES\tVERSIONS\tMAX_PER_PAGE\tBYTES\tOLDEST_LSN\tNEWEST_LSN")
+	var total spaceStats
+	for _, space := range spaces {
+		st, err := countSpace(s.backend, v, space)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			space, st.pages, st.versions, st.maxVersions, st.bytes, st.oldest, st.newest)
+		if st.versions > 0 && (total.versions == 0 || st.oldest < total.oldest) {
+			total.oldest = st.oldest
+		}
+		total.pages += st.pages
+		total.versions += st.versions
+		total.maxVersions = max(total.maxVersions, st.maxVersions)
+		total.bytes += st.bytes
+		total.newest = max(total.newest, st.newest)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%d\t%d\t%d\t%d\n",
+		total.pages, total.versions, total.maxVersions, total.bytes, total.oldest, total.newest)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	lister, ok := s.backend.(storage.WALLister)
+	reader, canRead := s.backend.(storage.WALReader)
+	if !ok || !canRead {
+		return nil
+	}
+	lsns, err := lister.ListWAL()
+	if err != nil {
+		return err
+	}
+	var walBytes int64
+	for _, lsn := range lsns {
+		data, err := reader.LoadWAL(lsn)
+		if err != nil {
+			return err
+		}
+		walBytes += int64(len(data))
+	}
+	if len(lsns) == 0 {
+		fmt.Fprintln(s.stdout, "\nWAL: no records")
+		return nil
+	}
+	fmt.Fprintf(s.stdout, "\nWAL: %d records, %d bytes, LSN %d to %d (latest LSN %d)\n",
+		len(lsns), walBytes, lsns[0], lsns[len(lsns)-1], s.backend.GetLatestLSN())
+	return nil
+}
+
+// countSpace loads every stored version of a space's pages
+func countSpace(backend storage.StorageBackend, v storage.VersionedStorage, spaceID uint32) (spaceStats, error) {
+	versions, err := v.ListPageVersions(spaceID)
+	if err != nil {
+		return spaceStats{}, err
+	}
+	st := spaceStats{pages: len(versions)}
+	for _, pageNo := range sortedPages(versions) {
+		lsns := versions[pageNo]
+		if st.versions == 0 || lsns[0] < st.oldest {
+			st.oldest = lsns[0]
+		}
+		st.versions += len(lsns)
+		st.maxVersions = max(st.maxVersions, len(lsns))
+		st.newest = max(st.newest, lsns[len(lsns)-1])
+		for _, lsn := range lsns {
+			page, _, err := backend.LoadPage(spaceID, pageNo, lsn)
+			if err != nil {
+				return spaceStats{}, err
+			}
+			st.bytes += int64(len(page))
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// checksumFormat is the checksum format of a tablespace
+type checksumFormat struct {
+	fullCRC32 bool
+	known     bool // Read from the FSP flags on page 0
+}
+
+// spaceFormat reads the checksum format of a space from its newest page 0
+func spaceFormat(backend storage.StorageBackend, spaceID uint32) checksumFormat {
+	page, _, err := backend.LoadPage(spaceID, 0, math.MaxUint64)
+	if err != nil {
+		return checksumFormat{}
+	}
+	hdr, err := innodb.ParseFSPHeader(page)
+	if err != nil {
+		return checksumFormat{}
+	}
+	return checksumFormat{fullCRC32: innodb.IsFullCRC32(hdr.Flags), known: true}
+}
+
+// verify checks a page's checksum and returns the format it was checked in.
+// Without known flags either format will do, as in the scrubber.
+func (f checksumFormat) verify(page []byte) (string, error) {
+	if f.known {
+		return formatName(f.fullCRC32), innodb.VerifyChecksum(page, f.fullCRC32)
+	}
+	err := innodb.VerifyChecksum(page, false)
+	if err == nil {
+		return formatName(false), nil
+	}
+	if innodb.VerifyChecksum(page, true) == nil {
+		return formatName(true), nil
+	}
+	return "format unknown", err
+}
+
+func formatName(fullCRC32 bool) string {
+	if fullCRC32 {
+		return "full_crc32"
+	}
+	return "original"
+}
+
+// verifyPage checks the checksum of a page of a space
+func verifyPage(backend storage.StorageBackend, spaceID uint32, page []byte) (string, error) {
+	return spaceFormat(backend, spaceID).verify(page)
+}
+
+// appliedFromWAL reports whether a version was written by WAL apply, which
+// does not maintain InnoDB checksums; the scrubber checks those by replay
+func appliedFromWAL(backend storage.StorageBackend, lsn uint64) (bool, error) {
+	reader, ok := backend.(storage.WALReader)
+	if !ok {
+		return false, nil
+	}
+	data, err := reader.LoadWAL(lsn)
+	if errors.Is(err, storage.ErrWALNotFound) {
+		return false, nil
+	}
+	return len(data) > 0, err
+}
+
+// runVerify checks the checksums of stored page versions
+func runVerify(s *session, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	spaceID := fs.Uint("space", 0, "Only verify this tablespace")
+	allVersions := fs.Bool("all-versions", false, "Verify every stored version, not just the newest of each page")
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	v, err := versioned(s.backend)
+	if err != nil {
+		return err
+	}
+	spaces := []uint32{uint32(*spaceID)}
+	if !isSet(fs, "space") {
+		if spaces, err = v.ListSpaces(); err != nil {
+			return err
+		}
+	}
+
+	var checked, valid, zero, applied, bad int
+	for _, space := range spaces {
+		format := spaceFormat(s.backend, space)
+		versions, err := v.ListPageVersions(space)
+		if err != nil {
+			return err
+		}
+		for _, pageNo := range sortedPages(versions) {
+			lsns := versions[pageNo]
+			if !*allVersions {
+				lsns = lsns[len(lsns)-1:]
+			}
+			for _, lsn := range lsns {
+				checked++
+				page, _, err := s.backend.LoadPage(space, pageNo, lsn)
+				if err != nil {
+					bad++
+					fmt.Fprintf(s.stdout, "space=%d page=%d lsn=%d: %v\n", space, pageNo, lsn, err)
+					continue
+				}
+				if innodb.IsZeroPage(page) {
+					zero++
+					continue
+				}
+				if fromWAL, err := appliedFromWAL(s.backend, lsn); err != nil {
+					return err
+				} else if fromWAL {
+					applied++
+					continue
+				}
+				if name, err := format.verify(page); err != nil {
+					bad++
+					fmt.Fprintf(s.stdout, "space=%d page=%d lsn=%d: %v (%s)\n", space, pageNo, lsn, err, name)
+					continue
+				}
+				valid++
+			}
+		}
+	}
+
+	fmt.Fprintf(s.stdout, "Checked %d versions in %d spaces: %d valid, %d all zeros, %d written by WAL apply (not checked), %d failed\n",
+		checked, len(spaces), valid, zero, applied, bad)
+	if bad > 0 {
+		return fmt.Errorf("%d page versions failed verification", bad)
+	}
+	return nil
+}
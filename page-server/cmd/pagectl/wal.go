@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// runWAL decodes stored WAL records with the redo log parser
+func runWAL(s *session, args []string) error {
+	fs := flag.NewFlagSet("wal", flag.ContinueOnError)
+	from := fs.Uint64("from", 0, "First LSN to decode")
+	to := fs.Uint64("to", math.MaxUint64, "Last LSN to decode")
+	limit := fs.Int("limit", 0, "Decode at most this many WAL records (0: no limit)")
+	preview := fs.Int("preview", wal.DefaultPreviewBytes, "Data bytes shown per redo record")
+	asJSON := fs.Bool("json", false, "Print one JSON object per WAL record, as /api/v1/debug/decode_wal does")
+	if err := s.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *limit <= 0 {
+		*limit = math.MaxInt
+	}
+	records, next, err := wal.DecodeStoredWAL(s.backend, *from, *to, *limit, *preview)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	enc := json.NewEncoder(s.stdout)
+	for _, record := range records {
+		if record.Error != "" || record.ErrorOffset != nil {
+			failed++
+		}
+		if *asJSON {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+			continue
+		}
+		printDecodedWAL(s.stdout, record)
+	}
+
+	if *asJSON {
+		return nil
+	}
+	fmt.Fprintf(s.stdout, "Decoded %d WAL records, %d with errors\n", len(records), failed)
+	if next != 0 {
+		fmt.Fprintf(s.stdout, "More records follow; continue with -from %d\n", next)
+	}
+	return nil
+}
+
+// printDecodedWAL prints a decoded WAL record, one line per redo record
+func printDecodedWAL(out io.Writer, record types.DecodedWAL) {
+	if record.Error != "" {
+		fmt.Fprintf(out, "LSN %d: %s\n", record.LSN, record.Error)
+		return
+	}
+	fmt.Fprintf(out, "LSN %d: %d bytes, %d redo records applied\n", record.LSN, record.Bytes, record.Applied)
+
+	for _, redo := range record.RedoRecords {
+		var b strings.Builder
+		fmt.Fprintf(&b, "  @%-5d %-15s", redo.Offset, redo.Type)
+		if redo.Error != "" {
+			fmt.Fprintf(&b, " error: %s", redo.Error)
+		} else {
+			fmt.Fprintf(&b, " space=%d page=%d", redo.SpaceID, redo.PageNo)
+		}
+		if redo.SamePage {
+			b.WriteString(" same_page")
+		}
+		if redo.PageOffset != 0 || redo.DataLen != 0 {
+			fmt.Fprintf(&b, " offset=%d len=%d", redo.PageOffset, redo.DataLen)
+		}
+		if redo.SourceOffset != 0 {
+			fmt.Fprintf(&b, " source=%+d", redo.SourceOffset)
+		}
+		if redo.TypeCode == wal.MREC_EXTENDED {
+			fmt.Fprintf(&b, " subtype=0x%02x", redo.Subtype)
+		}
+		if redo.FileName != "" {
+			fmt.Fprintf(&b, " name=%q", redo.FileName)
+		}
+		if redo.NewName != "" {
+			fmt.Fprintf(&b, " new_name=%q", redo.NewName)
+		}
+		if redo.DataPreview != "" {
+			fmt.Fprintf(&b, " data=%s", redo.DataPreview)
+		}
+		if redo.Warning != "" {
+			fmt.Fprintf(&b, " (warning: %s)", redo.Warning)
+		}
+		if redo.Skipped {
+			b.WriteString(" (skipped by apply)")
+		}
+		fmt.Fprintln(out, b.String())
+	}
+}
@@ -0,0 +1,98 @@
+// Command pageimport loads a stopped MariaDB data directory into page server
+// storage. Run it while the page server is stopped; use POST /api/v1/admin/import
+// to import into a running one. An interrupted import resumes when run again
+// with the same source and base LSN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/config"
+	"github.com/linux/projects/server/page-server/internal/importer"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+var (
+	// Storage settings, shared with the page server (config file and PAGESERVER_* variables apply)
+	configFile = flag.String("config", "", "Path to the page server YAML config file (optional)")
+
+	dataDir        = flag.String("data-dir", "./page-server-data", "Data directory for persistent storage")
+	storageBackend = flag.String("storage-backend", "file", "Storage backend: file, s3, or hybrid")
+	s3Endpoint     = flag.String("s3-endpoint", "", "S3 endpoint (e.g., https://s3.amazonaws.com or http://minio:9000)")
+	s3Bucket       = flag.String("s3-bucket", "", "S3 bucket name")
+	s3Region       = flag.String("s3-region", "us-east-1", "AWS region")
+	s3AccessKey    = flag.String("s3-access-key", "", "S3 access key ID")
+	s3SecretKey    = flag.String("s3-secret-key", "", "S3 secret access key")
+	s3Prefix       = flag.String("s3-prefix", "", "Optional prefix for S3 objects")
+	s3UseSSL       = flag.Bool("s3-use-ssl", true, "Use SSL/TLS for S3 connections")
+
+	// Import settings
+	source          = flag.String("source", "", "Data directory of a stopped MariaDB server (required)")
+	baseLSN         = flag.Uint64("base-lsn", 0, "LSN to import the pages at (default: the flushed LSN of ibdata1)")
+	parallelism     = flag.Int("parallelism", importer.DefaultParallelism, "Concurrent page writers")
+	ignoreChecksums = flag.Bool("ignore-checksums", false, "Import pages with bad checksums instead of failing")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Minute, "Time allowed for flushing uploads at the end")
+)
+
+func main() {
+	flag.Parse()
+
+	if *source == "" {
+		fmt.Fprintln(os.Stderr, "Usage: pageimport -source <mariadb-datadir> [-data-dir <dir>] [-base-lsn <lsn>]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	fileCfg, err := config.Load(*configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	cfg := fileCfg.Config
+	if cfg.DataDir, err = filepath.Abs(cfg.DataDir); err != nil {
+		log.Fatalf("Failed to get absolute path: %v", err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+	sourceDir, err := filepath.Abs(*source)
+	if err != nil {
+		log.Fatalf("Failed to get absolute path: %v", err)
+	}
+
+	pageServer, err := server.NewPageServer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open page server storage: %v", err)
+	}
+
+	// SIGINT/SIGTERM stop the import; running again resumes it
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	status, importErr := pageServer.RunImport(ctx, types.StartImportRequest{
+		SourceDir:       sourceDir,
+		BaseLSN:         *baseLSN,
+		Parallelism:     *parallelism,
+		IgnoreChecksums: *ignoreChecksums,
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	report := pageServer.Shutdown(shutdownCtx)
+	log.Printf("Storage closed: %s", report)
+
+	if importErr != nil {
+		log.Fatalf("Import failed after %d of %d pages: %v", status.PagesDone, status.PagesTotal, importErr)
+	}
+	if !report.Clean() {
+		os.Exit(1)
+	}
+}
@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/linux/projects/server/common/tlsutil"
 	"github.com/linux/projects/server/page-server/internal/api"
+	"github.com/linux/projects/server/page-server/internal/config"
 	"github.com/linux/projects/server/page-server/internal/server"
 )
 
 var (
+	// Config file (flags and PAGESERVER_* environment variables override it)
+	configFile = flag.String("config", "", "Path to YAML config file (optional)")
+
 	port      = flag.Int("port", 8080, "The server port")
 	dataDir   = flag.String("data-dir", "./page-server-data", "Data directory for persistent storage")
 	cacheSize = flag.Int("cache-size", 1000, "Maximum number of pages in cache")
@@ -28,42 +38,90 @@ var (
 	s3UseSSL       = flag.Bool("s3-use-ssl", true, "Use SSL/TLS for S3 connections")
 	
 	// Authentication flags
-	apiKey     = flag.String("api-key", "", "API key for authentication (optional)")
-	authTokens = flag.String("auth-tokens", "", "Comma-separated list of auth tokens")
+	apiKey      = flag.String("api-key", "", "API key for authentication (optional)")
+	authTokens  = flag.String("auth-tokens", "", "Comma-separated list of auth tokens")
+	jwtJWKS     = flag.String("jwt-jwks", "", "Path to JWKS file with EdDSA/RS256 public keys for JWT authentication (optional)")
+	jwtIssuer   = flag.String("jwt-issuer", "", "Required iss claim of JWTs (optional)")
+	jwtAudience = flag.String("jwt-audience", "", "Audience JWTs must name in their aud claim (optional)")
+	tenantID    = flag.String("tenant-id", "", "Tenant served by this instance; JWTs must name it unless they have the admin scope")
+	timelineID  = flag.String("timeline-id", "", "Timeline served by this instance; JWTs scoped to another timeline are rejected")
 	
 	// TLS flags
-	tlsEnabled  = flag.Bool("tls", false, "Enable TLS/HTTPS")
-	tlsCertFile = flag.String("tls-cert", "", "Path to TLS certificate file")
-	tlsKeyFile  = flag.String("tls-key", "", "Path to TLS private key file")
+	tlsEnabled           = flag.Bool("tls", false, "Enable TLS/HTTPS")
+	tlsCertFile          = flag.String("tls-cert", "", "Path to TLS certificate file")
+	tlsKeyFile           = flag.String("tls-key", "", "Path to TLS private key file")
+	tlsClientCA          = flag.String("tls-client-ca", "", "CA bundle for verifying client certificates (enables mTLS)")
+	tlsRequireClientCert = flag.Bool("tls-require-client-cert", false, "Reject TLS clients without a valid client certificate")
+	clientCertRoles      = flag.String("client-cert-roles", "", "Roles for client certificate SANs: pattern=role,... (roles: compute, peer, admin)")
+	generateDevCerts     = flag.String("generate-dev-certs", "", "Write a development CA with server and client certificates to this directory and exit")
+	
+	// Shutdown flags
+	shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "Time allowed for draining requests and flushing uploads on shutdown")
+	
+	// Runtime-tunable flags (hot-applied on SIGHUP or POST /api/v1/admin/reload)
+	logLevel      = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	lfcSizeBytes  = flag.Int64("lfc-size-bytes", 0, "Local file cache size in bytes for hybrid storage (0 = 75% of RAM)")
+	maxBatchPages = flag.Int("max-batch-pages", server.DefaultMaxBatchPages, "Maximum number of pages per get_pages request")
+	
+	// Rate limits (per auth token / API key / client IP; 0 = unlimited) and admission control
+	requestsPerSecond  = flag.Float64("requests-per-second", 0, "Read requests per second per principal (0 = unlimited)")
+	requestBurst       = flag.Int("request-burst", 0, "Request burst per principal (default: one second of requests)")
+	bytesPerSecond     = flag.Int64("bytes-per-second", 0, "Response bytes per second per principal (0 = unlimited)")
+	bytesBurst         = flag.Int64("bytes-burst", 0, "Response byte burst per principal (default: one second of bytes)")
+	maxConcurrentLoads = flag.Int("max-concurrent-loads", server.DefaultMaxConcurrentLoads, "Maximum storage loads in flight across all requests")
+	loadQueueTimeout   = flag.Duration("load-queue-timeout", server.DefaultLoadQueueTimeout, "Maximum time a request waits for a storage load slot")
+
+	// Read replicas sharing the primary's object store (s3 and hybrid backends)
+	role                = flag.String("role", "", "Replication role: primary (publish changes for replicas), replica (read-only, follow a primary) or empty for standalone")
+	replicationInterval = flag.Duration("replication-interval", server.DefaultReplicationInterval, "How often the primary publishes changes and replicas poll for them")
+
+	// Key-space sharding across page servers
+	shardMap   = flag.String("shard-map", "", "JSON shard map assigning pages to page servers (empty = unsharded)")
+	shardIndex = flag.Int("shard-index", 0, "This server's index in the shard map")
+
+	// Ordering and applying incoming WAL records
+	walGapTimeout   = flag.Duration("wal-gap-timeout", server.DefaultWALGapTimeout, "How long WAL records wait for a missing prev_lsn before the range is recorded as a gap")
+	walApplyWorkers = flag.Int("wal-apply-workers", server.DefaultWALApplyWorkers, "Workers applying WAL to pages in parallel (records for one page apply in order)")
 )
 
+// loadConfig builds the configuration from the config file, environment and flags
+func loadConfig() (*config.File, error) {
+	f, err := config.Load(*configFile, flag.CommandLine)
+	if err != nil {
+		return nil, err
+	}
+	
+	absDataDir, err := filepath.Abs(f.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	f.DataDir = absDataDir
+	
+	return f, nil
+}
+
 func main() {
 	flag.Parse()
-
-	// Create data directory if it doesn't exist
-	if err := os.MkdirAll(*dataDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	
+	if *generateDevCerts != "" {
+		if err := tlsutil.GenerateDevCA(*generateDevCerts,
+			[]string{"localhost", "127.0.0.1"},
+			[]string{"spiffe://dev/control-plane"}); err != nil {
+			log.Fatalf("Failed to generate development certificates: %v", err)
+		}
+		log.Printf("Wrote development CA, server and client certificates to %s", *generateDevCerts)
+		return
 	}
 
-	absDataDir, err := filepath.Abs(*dataDir)
+	fileCfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
-
-	// Create Page Server configuration
-	cfg := server.Config{
-		DataDir:     absDataDir,
-		CacheSize:   *cacheSize,
-		StorageType: *storageBackend,
-		S3Endpoint:  *s3Endpoint,
-		S3Bucket:    *s3Bucket,
-		S3Region:    *s3Region,
-		S3AccessKey: *s3AccessKey,
-		S3SecretKey: *s3SecretKey,
-		S3Prefix:    *s3Prefix,
-		S3UseSSL:    *s3UseSSL,
-		APIKey:      *apiKey,
-		AuthTokens:  *authTokens,
+	cfg := fileCfg.Config
+	
+	// Create data directory if it doesn't exist
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
 	// Create Page Server
@@ -71,15 +129,33 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create Page Server: %v", err)
 	}
+	
+	// Reload re-reads the config file and re-applies env and flag overrides
+	pageServer.SetConfigSource(func() (server.Config, error) {
+		f, err := loadConfig()
+		if err != nil {
+			return server.Config{}, err
+		}
+		return f.Config, nil
+	})
 
 	// Create HTTP server
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", *port),
+		Addr:    fmt.Sprintf(":%d", fileCfg.Port),
 		Handler: nil,
 	}
+	// Shutdown waits for in-flight requests, so end long-lived WAL streams
+	httpServer.RegisterOnShutdown(pageServer.StopStreams)
 	
 	// Configure TLS if enabled
-	if err := server.ConfigureTLS(httpServer, *tlsEnabled, *tlsCertFile, *tlsKeyFile); err != nil {
+	certReloader, err := tlsutil.ConfigureTLS(httpServer, tlsutil.TLSOptions{
+		Enabled:           fileCfg.TLS.Enabled,
+		CertFile:          fileCfg.TLS.Cert,
+		KeyFile:           fileCfg.TLS.Key,
+		ClientCAFile:      fileCfg.TLS.ClientCA,
+		RequireClientCert: fileCfg.TLS.RequireClientCert,
+	})
+	if err != nil {
 		log.Fatalf("Failed to configure TLS: %v", err)
 	}
 	
@@ -87,26 +163,45 @@ func main() {
 	api.RegisterHandlers(pageServer)
 
 	log.Printf("Page Server starting...")
-	log.Printf("  Port: %d", *port)
-	log.Printf("  Data Directory: %s", absDataDir)
-	log.Printf("  Cache Size: %d pages", *cacheSize)
+	if *configFile != "" {
+		log.Printf("  Config File: %s", *configFile)
+	}
+	log.Printf("  Port: %d", fileCfg.Port)
+	log.Printf("  Data Directory: %s", cfg.DataDir)
+	log.Printf("  Cache Size: %d pages", cfg.CacheSize)
+	log.Printf("  Log Level: %s", cfg.LogLevel)
+	if cfg.Replication.Role != "" {
+		log.Printf("  Replication Role: %s", cfg.Replication.Role)
+	}
+	if cfg.Sharding.MapFile != "" {
+		log.Printf("  Shard: %d (map: %s)", cfg.Sharding.Index, cfg.Sharding.MapFile)
+	}
 	
 	if pageServer.Auth.IsEnabled() {
 		log.Printf("  Authentication: ENABLED")
-		if *apiKey != "" {
+		if cfg.APIKey != "" {
 			log.Printf("    API Key: configured")
 		}
-		if *authTokens != "" {
+		if cfg.AuthTokens != "" {
 			log.Printf("    Auth Tokens: configured")
 		}
+		if cfg.JWTJWKS != "" {
+			log.Printf("    JWT Keys: %s", cfg.JWTJWKS)
+		}
+		if cfg.TenantID != "" {
+			log.Printf("    Tenant: %s (timeline: %s)", cfg.TenantID, cfg.TimelineID)
+		}
 	} else {
 		log.Printf("  Authentication: DISABLED")
 	}
 	
-	if *tlsEnabled {
+	if fileCfg.TLS.Enabled {
 		log.Printf("  TLS: ENABLED")
-		log.Printf("    Certificate: %s", *tlsCertFile)
-		log.Printf("    Private Key: %s", *tlsKeyFile)
+		log.Printf("    Certificate: %s", fileCfg.TLS.Cert)
+		log.Printf("    Private Key: %s", fileCfg.TLS.Key)
+		if fileCfg.TLS.ClientCA != "" {
+			log.Printf("    Client CA: %s (required: %v)", fileCfg.TLS.ClientCA, fileCfg.TLS.RequireClientCert)
+		}
 	} else {
 		log.Printf("  TLS: DISABLED")
 	}
@@ -115,23 +210,93 @@ func main() {
 	log.Printf("  POST /api/v1/get_page (auth required)")
 	log.Printf("  POST /api/v1/get_pages (auth required, batch)")
 	log.Printf("  POST /api/v1/stream_wal (auth required)")
+	log.Printf("  POST /api/v1/stream_wal/frames (auth required, streaming)")
 	log.Printf("  GET  /api/v1/ping (no auth)")
 	log.Printf("  GET  /api/v1/metrics (auth required)")
+	log.Printf("  GET  /api/v1/replication/status (auth required)")
+	log.Printf("  GET  /api/v1/shard_map (auth required)")
+	log.Printf("  GET  /api/v1/wal/gaps (auth required)")
+	log.Printf("  POST /api/v1/debug/decode_wal (auth required)")
+	log.Printf("  POST /api/v1/debug/page (auth required)")
 	log.Printf("  POST /api/v1/time_travel (auth required)")
+	log.Printf("  GET  /api/v1/lsn_for_timestamp (auth required)")
+	log.Printf("  GET  /api/v1/spaces/list (auth required)")
+	log.Printf("  GET  /api/v1/spaces/size (auth required)")
+	log.Printf("  GET  /api/v1/spaces/diff (auth required)")
+	log.Printf("  GET  /api/v1/basebackup (auth required)")
 	log.Printf("  POST /api/v1/snapshots/create (auth required)")
 	log.Printf("  GET  /api/v1/snapshots/list (auth required)")
 	log.Printf("  GET  /api/v1/snapshots/get (auth required)")
 	log.Printf("  POST /api/v1/snapshots/restore (auth required)")
+	log.Printf("  POST /api/v1/snapshots/delete (auth required)")
+	log.Printf("  POST /api/v1/exports/create (auth required)")
+	log.Printf("  GET  /api/v1/exports/list (auth required)")
+	log.Printf("  GET  /api/v1/exports/get (auth required)")
+	log.Printf("  GET  /api/v1/exports/download (auth required)")
+	log.Printf("  GET  /api/v1/admin/config (auth required)")
+	log.Printf("  POST /api/v1/admin/reload (auth required)")
+	log.Printf("  POST /api/v1/admin/gc (auth required)")
+	log.Printf("  GET  /api/v1/admin/scrub (auth required)")
+	log.Printf("  POST /api/v1/admin/scrub/run (auth required)")
+	log.Printf("  POST /api/v1/admin/import (auth required)")
+	log.Printf("  GET  /api/v1/admin/import/status (auth required)")
 	
 	// Start server with or without TLS
-	if *tlsEnabled {
-		if err := httpServer.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile); err != nil {
-			log.Fatalf("failed to serve: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		if fileCfg.TLS.Enabled {
+			serveErr <- httpServer.ListenAndServeTLS("", "") // Certificates come from the reloader
+		} else {
+			serveErr <- httpServer.ListenAndServe()
 		}
-	} else {
-		if err := httpServer.ListenAndServe(); err != nil {
-			log.Fatalf("failed to serve: %v", err)
+	}()
+	
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	
+	// SIGHUP hot-applies the runtime-safe settings
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	
+	shutdownAfter := fileCfg.ShutdownTimeout
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("failed to serve: %v", err)
+			}
+			break wait
+		case <-hupChan:
+			log.Printf("Received SIGHUP, reloading configuration...")
+			if _, err := pageServer.Reload(); err != nil {
+				log.Printf("Warning: config reload failed: %v", err)
+			}
+			if certReloader != nil {
+				if err := certReloader.Reload(); err != nil {
+					log.Printf("Warning: TLS certificate reload failed: %v", err)
+				}
+			}
+		case sig := <-sigChan:
+			log.Printf("Received %s, shutting down (timeout %s)...", sig, shutdownAfter)
+			break wait
 		}
 	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownAfter)
+	defer cancel()
+	
+	// Stop accepting connections and wait for in-flight handlers
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Warning: HTTP server did not drain cleanly: %v", err)
+	}
+	
+	// Flush queued uploads and close storage
+	report := pageServer.Shutdown(ctx)
+	if !report.Clean() {
+		log.Printf("Page Server stopped with unflushed work: %s", report)
+		os.Exit(1)
+	}
+	log.Printf("Page Server stopped: %s", report)
 }
-
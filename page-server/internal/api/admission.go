@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/page-server/internal/server"
+)
+
+// loadRetryAfter is the retry hint sent when the storage load queue times out
+const loadRetryAfter = time.Second
+
+// admit applies the per-principal rate limits to a read endpoint.
+// Must be wrapped by the auth middleware so the principal is known.
+func admit(pageServer *server.PageServer, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.PrincipalFromContext(r.Context())
+
+		if ok, wait := pageServer.Limiter.Admit(principal); !ok {
+			writeTooManyRequests(w, wait, "Rate limit exceeded")
+			return
+		}
+
+		cw := &countingWriter{ResponseWriter: w}
+		next(cw, r)
+		pageServer.Limiter.ChargeBytes(principal, cw.written)
+	}
+}
+
+// writeTooManyRequests sends a 429 with a Retry-After hint
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "error",
+		"error":          reason,
+		"retry_after_ms": wait.Milliseconds(),
+	})
+}
+
+// countingWriter counts response body bytes for the byte rate limit
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
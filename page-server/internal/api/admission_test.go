@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/server"
+)
+
+func TestAdmitRateLimit(t *testing.T) {
+	ps := &server.PageServer{Limiter: limits.NewLimiter(limits.RateConfig{RequestsPerSecond: 0.5, RequestBurst: 1})}
+	a := auth.NewAuthMiddleware("", "")
+	handler := a.Middleware(admit(ps, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page"))
+	}))
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/get_page", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := get("10.0.0.1:5000"); w.Code != http.StatusOK {
+		t.Fatalf("first request: %d", w.Code)
+	}
+	w := get("10.0.0.1:5001")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: %d, want 429", w.Code)
+	}
+	// One request every two seconds
+	if retry := w.Header().Get("Retry-After"); retry != "2" {
+		t.Fatalf("Retry-After %q, want 2", retry)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["status"] != "error" || body["retry_after_ms"].(float64) <= 1000 {
+		t.Fatalf("body %s: %v", w.Body.String(), err)
+	}
+
+	// Anonymous clients are limited by IP
+	if w := get("10.0.0.2:5000"); w.Code != http.StatusOK {
+		t.Fatalf("other client: %d", w.Code)
+	}
+}
+
+func TestWriteTooManyRequestsRoundsUp(t *testing.T) {
+	for _, tt := range []struct {
+		wait int64 // Milliseconds
+		want string
+	}{
+		{0, "1"},
+		{10, "1"},
+		{1000, "1"},
+		{1001, "2"},
+	} {
+		w := httptest.NewRecorder()
+		writeTooManyRequests(w, time.Duration(tt.wait)*time.Millisecond, "slow down")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != tt.want {
+			t.Fatalf("wait %dms: %d Retry-After %q, want %q", tt.wait, w.Code, w.Header().Get("Retry-After"), tt.want)
+		}
+	}
+}
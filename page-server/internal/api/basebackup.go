@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+func handleBasebackup(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		lsn, ok := queryLSN(w, r, pageServer)
+		if !ok {
+			return
+		}
+
+		// Collect every page before writing, so errors can still be reported as JSON
+		backup, err := pageServer.Basebackup(r.Context(), lsn, r.Header)
+		if err != nil {
+			if errors.Is(err, limits.ErrQueueTimeout) {
+				writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+				return
+			}
+			if errors.Is(err, server.ErrNotReplicated) {
+				writeNotReplicated(w, pageServer, err)
+				return
+			}
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, storage.ErrPageNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, gc.ErrBelowCutoff):
+				status = http.StatusConflict
+			case errors.Is(err, server.ErrShardUnavailable):
+				status = http.StatusBadGateway
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "error",
+				"error":  fmt.Sprintf("Failed to create basebackup: %v", err),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"basebackup_%d.tar\"", backup.Manifest.LSN))
+		w.Header().Set("X-Basebackup-LSN", fmt.Sprintf("%d", backup.Manifest.LSN))
+		if err := backup.WriteTar(w); err != nil {
+			log.Printf("Warning: Basebackup at LSN %d interrupted: %v", backup.Manifest.LSN, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/basebackup"
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// newTestServer returns a page server with file storage in a temporary directory
+func newTestServer(t *testing.T) *server.PageServer {
+	t.Helper()
+	ps, err := server.NewPageServer(server.Config{DataDir: t.TempDir(), CacheSize: 100})
+	if err != nil {
+		t.Fatalf("NewPageServer: %v", err)
+	}
+	t.Cleanup(func() { ps.Shutdown(context.Background()) })
+	return ps
+}
+
+// storePage stores a page whose contents identify its space, number and LSN
+func storePage(t *testing.T, ps *server.PageServer, spaceID, pageNo uint32, lsn uint64, fill func(page []byte)) []byte {
+	t.Helper()
+	page := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint32(page[innodb.FilPageOffset:], pageNo)
+	binary.BigEndian.PutUint64(page[innodb.FilPageLSN:], lsn)
+	binary.BigEndian.PutUint32(page[innodb.FilPageSpaceID:], spaceID)
+	if fill != nil {
+		fill(page)
+	}
+	if err := ps.Storage.StorePage(spaceID, pageNo, lsn, page); err != nil {
+		t.Fatalf("StorePage: %v", err)
+	}
+	return page
+}
+
+func TestBasebackupTar(t *testing.T) {
+	ps := newTestServer(t)
+
+	// System tablespace at LSN 10. Page 4 (the change buffer root) is never
+	// written, the dictionary header names SYS_TABLES at page 8 (missing) and
+	// SYS_TABLE_IDS at page 9, and TRX_SYS has one rollback segment in the
+	// system space and one in undo tablespace 2.
+	want := make(map[string][]byte)
+	for pageNo := uint32(0); pageNo <= innodb.DictHdrPageNo; pageNo++ {
+		var fill func(page []byte)
+		switch pageNo {
+		case 0:
+			fill = func(page []byte) {
+				innodb.PutFSPHeader(page, innodb.FSPHeader{SpaceID: innodb.SystemSpaceID, Size: 768, FreeLimit: 64, Flags: 0x15})
+			}
+		case 4:
+			continue
+		case innodb.TrxSysPageNo:
+			fill = func(page []byte) {
+				slots := page[innodb.FilPageData+18 : innodb.FilPageData+18+128*8]
+				for i := range slots {
+					slots[i] = 0xff
+				}
+				binary.BigEndian.PutUint64(slots, uint64(innodb.SystemSpaceID)<<32|innodb.FirstRsegPageNo)
+				binary.BigEndian.PutUint64(slots[8:], 2<<32|3)
+			}
+		case innodb.DictHdrPageNo:
+			fill = func(page []byte) {
+				roots := page[innodb.FilPageData+32 : innodb.FilPageData+52]
+				for i := range roots {
+					roots[i] = 0xff
+				}
+				binary.BigEndian.PutUint32(roots, 8)
+				binary.BigEndian.PutUint32(roots[4:], 9)
+			}
+		}
+		want[basebackupPath(innodb.SystemSpaceID, pageNo)] = storePage(t, ps, innodb.SystemSpaceID, pageNo, 10, fill)
+	}
+	want[basebackupPath(0, 9)] = storePage(t, ps, 0, 9, 10, nil)
+	want[basebackupPath(2, 0)] = storePage(t, ps, 2, 0, 10, nil)
+	// Versions after the requested LSN are not included
+	storePage(t, ps, 0, 9, 30, nil)
+	storePage(t, ps, 2, 0, 30, nil)
+
+	w := httptest.NewRecorder()
+	handleBasebackup(ps)(w, httptest.NewRequest(http.MethodGet, "/api/v1/basebackup?lsn=20", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-tar" || w.Header().Get("X-Basebackup-LSN") != "20" {
+		t.Fatalf("headers: %v", w.Header())
+	}
+
+	tr := tar.NewReader(w.Body)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != basebackup.ManifestName {
+		t.Fatalf("first entry %+v, %v; want the manifest", hdr, err)
+	}
+	var manifest types.BasebackupManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	entries := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[hdr.Name] = data
+	}
+
+	if manifest.LSN != 20 {
+		t.Fatalf("manifest LSN %d", manifest.LSN)
+	}
+	roles := map[string]string{
+		basebackupPath(0, 0): basebackup.RoleSystemPage,
+		basebackupPath(0, 6): basebackup.RoleSystemPage, // Also the first rollback segment
+		basebackupPath(0, 9): basebackup.RoleDictRoot,
+		basebackupPath(2, 0): basebackup.RoleUndoSpaceHeader,
+	}
+	missing := make(map[string]string)
+	for _, page := range manifest.Pages {
+		path := basebackupPath(page.SpaceID, page.PageNo)
+		if page.Missing {
+			missing[path] = page.Role
+			if _, ok := entries[path]; ok || page.Path != "" {
+				t.Fatalf("missing page %s has a tar entry", path)
+			}
+			continue
+		}
+		data, ok := entries[page.Path]
+		if !ok || page.Path != path {
+			t.Fatalf("page %+v has no tar entry", page)
+		}
+		if string(data) != string(want[path]) || page.Size != len(data) || page.PageLSN != 10 {
+			t.Fatalf("page %s: size=%d page_lsn=%d, contents differ from the version at LSN 10", path, page.Size, page.PageLSN)
+		}
+		if role, ok := roles[path]; ok && page.Role != role {
+			t.Fatalf("page %s: role %s, want %s", path, page.Role, role)
+		}
+		delete(want, path)
+	}
+	if len(want) != 0 || len(entries) != len(manifest.Pages)-len(missing) {
+		t.Fatalf("%d pages not in the manifest, %d tar entries", len(want), len(entries))
+	}
+	wantMissing := map[string]string{
+		basebackupPath(0, 4): basebackup.RoleSystemPage,
+		basebackupPath(0, 8): basebackup.RoleDictRoot,
+		basebackupPath(2, 3): basebackup.RoleRsegHeader,
+	}
+	if len(missing) != len(wantMissing) {
+		t.Fatalf("missing pages %v, want %v", missing, wantMissing)
+	}
+	for path, role := range wantMissing {
+		if missing[path] != role {
+			t.Fatalf("missing pages %v, want %v", missing, wantMissing)
+		}
+	}
+}
+
+func TestBasebackupErrors(t *testing.T) {
+	ps := newTestServer(t)
+	storePage(t, ps, innodb.SystemSpaceID, 0, 10, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		status int
+	}{
+		{"before the system tablespace exists", http.MethodGet, "?lsn=5", http.StatusNotFound},
+		{"invalid LSN", http.MethodGet, "?lsn=abc", http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "?lsn=20", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleBasebackup(ps)(w, httptest.NewRequest(tt.method, "/api/v1/basebackup"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+// basebackupPath is the tar entry name of a page
+func basebackupPath(spaceID, pageNo uint32) string {
+	return fmt.Sprintf("space_%d/page_%d", spaceID, pageNo)
+}
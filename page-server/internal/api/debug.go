@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/pagedecode"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// handleDecodeWAL decodes raw WAL bytes or stored WAL records into redo
+// records, reporting where records fail to parse
+func handleDecodeWAL(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req types.DecodeWALRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.PreviewBytes < 0 {
+			http.Error(w, "Invalid preview_bytes", http.StatusBadRequest)
+			return
+		}
+		if req.PreviewBytes == 0 {
+			req.PreviewBytes = wal.DefaultPreviewBytes
+		}
+
+		resp := types.DecodeWALResponse{Status: "success"}
+		w.Header().Set("Content-Type", "application/json")
+
+		if req.WALData != "" {
+			if req.FromLSN != 0 || req.ToLSN != 0 || req.Limit != 0 {
+				http.Error(w, "wal_data cannot be combined with from_lsn, to_lsn or limit", http.StatusBadRequest)
+				return
+			}
+			data, err := base64.StdEncoding.DecodeString(req.WALData)
+			if err != nil {
+				http.Error(w, "Invalid base64 encoding for wal_data", http.StatusBadRequest)
+				return
+			}
+			resp.Records = []types.DecodedWAL{wal.DecodeWAL(data, req.PreviewBytes)}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		maxRecords := pageServer.MaxBatchPages()
+		if req.Limit < 0 || req.Limit > maxRecords {
+			http.Error(w, fmt.Sprintf("Invalid limit (1 to %d)", maxRecords), http.StatusBadRequest)
+			return
+		}
+		if req.Limit == 0 {
+			req.Limit = maxRecords
+		}
+		if req.ToLSN != 0 && req.FromLSN > req.ToLSN {
+			http.Error(w, fmt.Sprintf("from_lsn %d is above to_lsn %d", req.FromLSN, req.ToLSN), http.StatusBadRequest)
+			return
+		}
+
+		records, next, err := pageServer.DecodeWAL(req.FromLSN, req.ToLSN, req.Limit, req.PreviewBytes)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, wal.ErrWALListUnsupported) {
+				status = http.StatusNotImplemented
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(types.DecodeWALResponse{Status: "error", Records: []types.DecodedWAL{}, Error: err.Error()})
+			return
+		}
+		resp.Records = records
+		resp.NextLSN = next
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// handleDecodePage decodes the InnoDB structures of a stored page, read like
+// get_page, or of page bytes sent in the request
+func handleDecodePage(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req types.DecodePageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.PageData != "" {
+			if req.LSN != 0 || req.RequestLSN != 0 || req.Latest || req.Timestamp != "" {
+				http.Error(w, "page_data cannot be combined with lsn, request_lsn, latest or timestamp", http.StatusBadRequest)
+				return
+			}
+			page, err := base64.StdEncoding.DecodeString(req.PageData)
+			if err != nil {
+				http.Error(w, "Invalid base64 encoding for page_data", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(types.DecodePageResponse{Status: "success", Page: pagedecode.Decode(page)})
+			return
+		}
+
+		read, ok := resolvePageRead(w, pageServer, types.PageRequest{
+			SpaceID:    req.SpaceID,
+			PageNo:     req.PageNo,
+			LSN:        req.LSN,
+			RequestLSN: req.RequestLSN,
+			Latest:     req.Latest,
+			Timestamp:  req.Timestamp,
+		})
+		if !ok {
+			return
+		}
+
+		page, pageLSN, err := pageServer.ReadPage(r.Context(), read)
+		if errors.Is(err, limits.ErrQueueTimeout) {
+			writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+			return
+		}
+		if errors.Is(err, server.ErrNotReplicated) {
+			writeNotReplicated(w, pageServer, err)
+			return
+		}
+		if errors.Is(err, server.ErrNotIngested) {
+			writeNotIngested(w, pageServer, err)
+			return
+		}
+		var wrongShard *server.WrongShardError
+		if errors.As(err, &wrongShard) {
+			writeWrongShard(w, wrongShard)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(types.DecodePageResponse{
+				Status: "error",
+				Error:  fmt.Sprintf("Page not found: space=%d page=%d lsn=%d: %v", req.SpaceID, req.PageNo, read.LSN, err),
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(types.DecodePageResponse{
+			Status:  "success",
+			PageLSN: pageLSN,
+			ReadLSN: read.LSN,
+			Page:    pagedecode.Decode(page),
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+func handleCreateExport(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req types.CreateExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		snapshot, err := pageServer.SnapshotManager.GetSnapshot(req.SnapshotID)
+		if err != nil {
+			writeExportError(w, http.StatusNotFound, err)
+			return
+		}
+
+		job, err := pageServer.StartExport(snapshot, req)
+		if errors.Is(err, gc.ErrBelowCutoff) {
+			writeExportError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeExportError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(types.ExportResponse{
+			Status: "success",
+			Export: job,
+		})
+
+		log.Printf("Export started: id=%s snapshot=%s lsn=%d format=%s destination=%s",
+			job.ID, job.SnapshotID, job.LSN, job.Format, job.Destination)
+	}
+}
+
+func handleListExports(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.ListExportsResponse{
+			Status:  "success",
+			Exports: pageServer.Exports.List(),
+		})
+	}
+}
+
+func handleGetExport(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "Missing export ID", http.StatusBadRequest)
+			return
+		}
+
+		job, err := pageServer.Exports.Get(id)
+		if err != nil {
+			writeExportError(w, http.StatusNotFound, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.ExportResponse{
+			Status: "success",
+			Export: job,
+		})
+	}
+}
+
+func handleDownloadExport(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "Missing export ID", http.StatusBadRequest)
+			return
+		}
+
+		path, err := pageServer.Exports.LocalFile(id)
+		if err != nil {
+			writeExportError(w, http.StatusNotFound, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		http.ServeFile(w, r, path)
+	}
+}
+
+// writeExportError writes an error response for the export endpoints
+func writeExportError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.ExportResponse{
+		Status: "error",
+		Error:  err.Error(),
+	})
+}
@@ -3,12 +3,21 @@ package api
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/logging"
 	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/snapshots"
 	"github.com/linux/projects/server/page-server/internal/storage"
 	"github.com/linux/projects/server/page-server/internal/wal"
 	"github.com/linux/projects/server/page-server/pkg/types"
@@ -16,21 +25,58 @@ import (
 
 // RegisterHandlers registers all HTTP handlers for the Page Server
 func RegisterHandlers(pageServer *server.PageServer) {
-	// Register HTTP handlers with authentication middleware
-	http.HandleFunc("/api/v1/get_page", pageServer.Auth.Middleware(handleGetPage(pageServer)))
-	http.HandleFunc("/api/v1/get_pages", pageServer.Auth.Middleware(handleGetPages(pageServer))) // Batch endpoint
-	http.HandleFunc("/api/v1/stream_wal", pageServer.Auth.Middleware(handleStreamWAL(pageServer)))
+	a := pageServer.Auth
+
+	// Register HTTP handlers with authentication middleware; JWTs must carry
+	// the scope named in Require (API keys and static tokens have full access).
+	// Read endpoints are rate limited per principal (see admission.go)
+	http.HandleFunc("/api/v1/get_page", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleGetPage(pageServer)))))
+	http.HandleFunc("/api/v1/get_pages", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleGetPages(pageServer))))) // Batch endpoint
+	http.HandleFunc("/api/v1/stream_wal", a.Middleware(a.Require(auth.ScopeWriteWAL, handleStreamWAL(pageServer))))
+	http.HandleFunc("/api/v1/stream_wal/frames", a.Middleware(a.Require(auth.ScopeWriteWAL, handleStreamWALFrames(pageServer))))
 	http.HandleFunc("/api/v1/ping", handlePing()) // Ping doesn't require auth
-	http.HandleFunc("/api/v1/metrics", pageServer.Auth.Middleware(handleMetrics(pageServer)))
+	http.HandleFunc("/api/v1/metrics", a.Middleware(a.Require(auth.ScopeReadPages, handleMetrics(pageServer))))
+	http.HandleFunc("/api/v1/replication/status", a.Middleware(a.Require(auth.ScopeReadPages, handleReplicationStatus(pageServer))))
+	http.HandleFunc("/api/v1/shard_map", a.Middleware(a.Require(auth.ScopeReadPages, handleShardMap(pageServer))))
+	http.HandleFunc("/api/v1/wal/gaps", a.Middleware(a.Require(auth.ScopeReadPages, handleWALGaps(pageServer))))
+	http.HandleFunc("/api/v1/debug/decode_wal", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleDecodeWAL(pageServer))))) // See debug.go
+	http.HandleFunc("/api/v1/debug/page", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleDecodePage(pageServer)))))
 	
 	// Time-travel and snapshot endpoints
-	http.HandleFunc("/api/v1/time_travel", pageServer.Auth.Middleware(handleTimeTravel(pageServer)))
-	http.HandleFunc("/api/v1/snapshots/create", pageServer.Auth.Middleware(handleCreateSnapshot(pageServer)))
-	http.HandleFunc("/api/v1/snapshots/list", pageServer.Auth.Middleware(handleListSnapshots(pageServer)))
-	http.HandleFunc("/api/v1/snapshots/get", pageServer.Auth.Middleware(handleGetSnapshot(pageServer)))
-	http.HandleFunc("/api/v1/snapshots/restore", pageServer.Auth.Middleware(handleRestoreSnapshot(pageServer)))
+	http.HandleFunc("/api/v1/time_travel", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleTimeTravel(pageServer)))))
+	http.HandleFunc("/api/v1/lsn_for_timestamp", a.Middleware(a.Require(auth.ScopeReadPages, handleLSNForTimestamp(pageServer))))
+	http.HandleFunc("/api/v1/spaces/list", a.Middleware(a.Require(auth.ScopeReadPages, handleListSpaces(pageServer))))
+	http.HandleFunc("/api/v1/spaces/size", a.Middleware(a.Require(auth.ScopeReadPages, handleGetSpace(pageServer))))
+	http.HandleFunc("/api/v1/spaces/diff", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handlePageDiff(pageServer)))))
+	http.HandleFunc("/api/v1/basebackup", a.Middleware(a.Require(auth.ScopeReadPages, admit(pageServer, handleBasebackup(pageServer)))))
+	http.HandleFunc("/api/v1/snapshots/create", a.Middleware(a.Require(auth.ScopeAdmin, handleCreateSnapshot(pageServer))))
+	http.HandleFunc("/api/v1/snapshots/list", a.Middleware(a.Require(auth.ScopeReadPages, handleListSnapshots(pageServer))))
+	http.HandleFunc("/api/v1/snapshots/get", a.Middleware(a.Require(auth.ScopeReadPages, handleGetSnapshot(pageServer))))
+	http.HandleFunc("/api/v1/snapshots/restore", a.Middleware(a.Require(auth.ScopeAdmin, handleRestoreSnapshot(pageServer))))
+	http.HandleFunc("/api/v1/snapshots/delete", a.Middleware(a.Require(auth.ScopeAdmin, handleDeleteSnapshot(pageServer))))
+	
+	// Tablespace exports (see export.go)
+	http.HandleFunc("/api/v1/exports/create", a.Middleware(a.Require(auth.ScopeAdmin, handleCreateExport(pageServer))))
+	http.HandleFunc("/api/v1/exports/list", a.Middleware(a.Require(auth.ScopeAdmin, handleListExports(pageServer))))
+	http.HandleFunc("/api/v1/exports/get", a.Middleware(a.Require(auth.ScopeAdmin, handleGetExport(pageServer))))
+	http.HandleFunc("/api/v1/exports/download", a.Middleware(a.Require(auth.ScopeAdmin, handleDownloadExport(pageServer))))
+	
+	// Admin endpoints
+	http.HandleFunc("/api/v1/admin/config", a.Middleware(a.Require(auth.ScopeAdmin, handleGetConfig(pageServer))))
+	http.HandleFunc("/api/v1/admin/reload", a.Middleware(a.Require(auth.ScopeAdmin, handleReloadConfig(pageServer))))
+	http.HandleFunc("/api/v1/admin/gc", a.Middleware(a.Require(auth.ScopeAdmin, handleRunGC(pageServer))))
+	http.HandleFunc("/api/v1/admin/scrub", a.Middleware(a.Require(auth.ScopeAdmin, handleScrubStatus(pageServer))))
+	http.HandleFunc("/api/v1/admin/scrub/run", a.Middleware(a.Require(auth.ScopeAdmin, handleRunScrub(pageServer))))
+	http.HandleFunc("/api/v1/admin/import", a.Middleware(a.Require(auth.ScopeAdmin, handleStartImport(pageServer))))
+	http.HandleFunc("/api/v1/admin/import/status", a.Middleware(a.Require(auth.ScopeAdmin, handleImportStatus(pageServer))))
+	http.HandleFunc("/api/v1/admin/tokens", a.Middleware(a.Require(auth.ScopeAdmin, a.HandleTokens)))
+	http.HandleFunc("/api/v1/admin/tokens/revoke", a.Middleware(a.Require(auth.ScopeAdmin, a.HandleRevokeToken)))
+	http.HandleFunc("/api/v1/admin/tokens/expire", a.Middleware(a.Require(auth.ScopeAdmin, a.HandleExpireToken)))
 }
 
+// batchWorkers is the number of goroutines serving one get_pages request
+const batchWorkers = 16
+
 func handleGetPage(pageServer *server.PageServer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -44,26 +90,39 @@ func handleGetPage(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
-		// Tier 1: Try memory cache first (hot data)
-		pageData, pageLSN, found := pageServer.Cache.Get(req.SpaceID, req.PageNo, req.LSN)
+		read, ok := resolvePageRead(w, pageServer, types.PageRequest(req))
+		if !ok {
+			return
+		}
 
-		// If not in cache, load from storage (Tier 2: Disk/LFC, Tier 3: S3)
-		if !found {
-			var err error
-			pageData, pageLSN, err = pageServer.Storage.LoadPage(req.SpaceID, req.PageNo, req.LSN)
-			if err != nil {
-				resp := types.GetPageResponse{
-					Status: "error",
-					Error:  fmt.Sprintf("Page not found: space=%d page=%d lsn=%d: %v", req.SpaceID, req.PageNo, req.LSN, err),
-				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(resp)
-				return
+		// Tier 1: memory cache, then storage (Tier 2: Disk/LFC, Tier 3: S3)
+		pageData, pageLSN, err := pageServer.ReadPage(r.Context(), read)
+		if errors.Is(err, limits.ErrQueueTimeout) {
+			writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+			return
+		}
+		if errors.Is(err, server.ErrNotReplicated) {
+			writeNotReplicated(w, pageServer, err)
+			return
+		}
+		if errors.Is(err, server.ErrNotIngested) {
+			writeNotIngested(w, pageServer, err)
+			return
+		}
+		var wrongShard *server.WrongShardError
+		if errors.As(err, &wrongShard) {
+			writeWrongShard(w, wrongShard)
+			return
+		}
+		if err != nil {
+			resp := types.GetPageResponse{
+				Status: "error",
+				Error:  fmt.Sprintf("Page not found: space=%d page=%d lsn=%d: %v", req.SpaceID, req.PageNo, read.LSN, err),
 			}
-
-			// Store in cache for future requests
-			pageServer.Cache.Put(req.SpaceID, req.PageNo, pageLSN, pageData)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(resp)
+			return
 		}
 
 		// Base64 encode page data
@@ -73,6 +132,7 @@ func handleGetPage(pageServer *server.PageServer) http.HandlerFunc {
 			Status:   "success",
 			PageData: pageDataB64,
 			PageLSN:  pageLSN, // Return actual page LSN
+			ReadLSN:  read.LSN,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -99,64 +159,159 @@ func handleGetPages(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
-		if len(req.Pages) > 1000 {
-			http.Error(w, "Too many pages requested (max 1000)", http.StatusBadRequest)
+		if maxPages := pageServer.MaxBatchPages(); len(req.Pages) > maxPages {
+			http.Error(w, fmt.Sprintf("Too many pages requested (max %d)", maxPages), http.StatusBadRequest)
 			return
 		}
 
-		// Process pages in parallel using goroutines
+		// Resolve timestamps and latest reads before loading anything
+		reads := make([]server.PageRead, len(req.Pages))
+		for i := range req.Pages {
+			var ok bool
+			if reads[i], ok = resolvePageRead(w, pageServer, req.Pages[i]); !ok {
+				return
+			}
+		}
+
+		// On a shard, pages of other shards are fetched from them concurrently
+		// with the local ones. Requests forwarded by a shard are served locally.
+		local := make([]int, 0, len(req.Pages))
+		remote := make(map[int][]int)
+		_, self := pageServer.ShardMap()
+		for shard, idxs := range pageServer.GroupByShard(req.Pages) {
+			if shard == self || forwarded(r) {
+				local = append(local, idxs...)
+			} else {
+				remote[shard] = idxs
+			}
+		}
+
+		// Process pages with a small set of workers; storage loads are
+		// further bounded across all requests by the server's load pool
 		responses := make([]types.PageResponse, len(req.Pages))
 		var wg sync.WaitGroup
 		var mu sync.Mutex
 		successCount := 0
+		overloaded := false
+		var notReplicated error
+		var notIngested error
 
-		for i, pageReq := range req.Pages {
+		for shard, idxs := range remote {
 			wg.Add(1)
-			go func(idx int, pr types.PageRequest) {
+			go func(shard int, idxs []int) {
 				defer wg.Done()
+				pages := make([]types.PageRequest, len(idxs))
+				for i, idx := range idxs {
+					// Other shards resolve latest reads themselves
+					pages[i] = req.Pages[idx]
+					if pages[i].Timestamp != "" {
+						pages[i].LSN, pages[i].Timestamp = reads[idx].LSN, "" // Already resolved
+					}
+				}
+				results, err := pageServer.ForwardPages(r.Context(), shard, r.Header, pages)
 
-				// Try cache first (Tier 1: Memory)
-				pageData, pageLSN, found := pageServer.Cache.Get(pr.SpaceID, pr.PageNo, pr.LSN)
+				mu.Lock()
+				defer mu.Unlock()
+				for i, idx := range idxs {
+					if err != nil {
+						pr := req.Pages[idx]
+						responses[idx] = types.PageResponse{
+							SpaceID: pr.SpaceID,
+							PageNo:  pr.PageNo,
+							Status:  "error",
+							Error:   fmt.Sprintf("Failed to read space=%d page=%d from shard %d: %v", pr.SpaceID, pr.PageNo, shard, err),
+						}
+						continue
+					}
+					responses[idx] = results[i]
+					if results[i].Status == "success" {
+						successCount++
+					}
+				}
+			}(shard, idxs)
+		}
 
-				// If not in cache, load from storage (handles Tier 2: Disk/LFC and Tier 3: S3)
-				if !found {
-					var err error
-					pageData, pageLSN, err = pageServer.Storage.LoadPage(pr.SpaceID, pr.PageNo, pr.LSN)
+		work := make(chan int)
+		workers := batchWorkers
+		if len(local) < workers {
+			workers = len(local)
+		}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for idx := range work {
+					pr := req.Pages[idx]
+					read := reads[idx]
+
+					// Memory cache, then storage (handles Tier 2: Disk/LFC and Tier 3: S3)
+					pageData, pageLSN, err := pageServer.ReadPage(r.Context(), read)
 					if err != nil {
 						mu.Lock()
+						if errors.Is(err, limits.ErrQueueTimeout) {
+							overloaded = true
+						}
+						if errors.Is(err, server.ErrNotReplicated) {
+							notReplicated = err
+						}
+						if errors.Is(err, server.ErrNotIngested) {
+							notIngested = err
+						}
+						msg := fmt.Sprintf("Page not found: space=%d page=%d lsn=%d", pr.SpaceID, pr.PageNo, read.LSN)
+						if errors.Is(err, server.ErrWrongShard) {
+							msg = err.Error()
+						}
 						responses[idx] = types.PageResponse{
 							SpaceID: pr.SpaceID,
 							PageNo:  pr.PageNo,
 							Status:  "error",
-							Error:   fmt.Sprintf("Page not found: space=%d page=%d lsn=%d", pr.SpaceID, pr.PageNo, pr.LSN),
+							Error:   msg,
+							Missing: errors.Is(err, storage.ErrPageNotFound),
 						}
 						mu.Unlock()
-						return
+						continue
 					}
 
-					// Store in cache for future requests
-					pageServer.Cache.Put(pr.SpaceID, pr.PageNo, pageLSN, pageData)
+					// Base64 encode page data
+					pageDataB64 := base64.StdEncoding.EncodeToString(pageData)
+
+					mu.Lock()
+					responses[idx] = types.PageResponse{
+						SpaceID:  pr.SpaceID,
+						PageNo:   pr.PageNo,
+						Status:   "success",
+						PageData: pageDataB64,
+						PageLSN:  pageLSN,
+						ReadLSN:  read.LSN,
+					}
+					successCount++
+					mu.Unlock()
 				}
+			}()
+		}
 
-				// Base64 encode page data
-				pageDataB64 := base64.StdEncoding.EncodeToString(pageData)
-
-				mu.Lock()
-				responses[idx] = types.PageResponse{
-					SpaceID:  pr.SpaceID,
-					PageNo:   pr.PageNo,
-					Status:   "success",
-					PageData: pageDataB64,
-					PageLSN:  pageLSN,
-				}
-				successCount++
-				mu.Unlock()
-			}(i, pageReq)
+		for _, idx := range local {
+			work <- idx
 		}
+		close(work)
 
-		// Wait for all goroutines to complete
+		// Wait for all workers to complete
 		wg.Wait()
 
+		// Ask the client to retry the whole batch rather than return holes
+		if overloaded {
+			writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+			return
+		}
+		if notReplicated != nil {
+			writeNotReplicated(w, pageServer, notReplicated)
+			return
+		}
+		if notIngested != nil {
+			writeNotIngested(w, pageServer, notIngested)
+			return
+		}
+
 		// Determine overall status
 		overallStatus := "success"
 		if successCount < len(req.Pages) {
@@ -171,7 +326,7 @@ func handleGetPages(pageServer *server.PageServer) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 
-		log.Printf("Batch request: %d pages requested, %d successful", len(req.Pages), successCount)
+		logging.Debugf("Batch request: %d pages requested, %d successful", len(req.Pages), successCount)
 	}
 }
 
@@ -198,13 +353,24 @@ func handleStreamWAL(pageServer *server.PageServer) http.HandlerFunc {
 		// Create WAL record
 		record := wal.WALRecord{
 			LSN:     req.LSN,
+			PrevLSN: req.PrevLSN,
 			WALData: walData,
 			SpaceID: req.SpaceID,
 			PageNo:  req.PageNo,
 		}
 
-		// Process WAL record (stores and applies to pages)
-		if err := pageServer.WALProcessor.ProcessWALRecord(record); err != nil {
+		if pageServer.IsReplica() {
+			writeReadOnly(w)
+			return
+		}
+
+		// On a shard, records for pages of other shards are acknowledged
+		// without being stored
+		skipped := record.SpaceID > 0 && record.PageNo > 0 && !pageServer.OwnsPage(record.SpaceID, record.PageNo)
+
+		// Process WAL record (stores and applies to pages, in LSN order)
+		outcome, err := pageServer.IngestWAL(record)
+		if err != nil {
 			log.Printf("Error processing WAL record: %v", err)
 			resp := types.StreamWALResponse{
 				Status: "error",
@@ -216,12 +382,22 @@ func handleStreamWAL(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
-		log.Printf("Received and processed WAL record: LSN=%d space=%d page=%d len=%d",
-			req.LSN, req.SpaceID, req.PageNo, len(walData))
+		// Index the commit time for timestamp queries
+		if outcome != wal.Duplicate {
+			if err := pageServer.RecordCommit(req.LSN, req.CommitTime); err != nil {
+				log.Printf("Warning: failed to index WAL record LSN=%d: %v", req.LSN, err)
+			}
+		}
+
+		logging.Debugf("Received WAL record: LSN=%d prev=%d space=%d page=%d len=%d: %s",
+			req.LSN, req.PrevLSN, req.SpaceID, req.PageNo, len(walData), outcome)
 
 		resp := types.StreamWALResponse{
 			Status:         "success",
-			LastAppliedLSN: req.LSN,
+			LastAppliedLSN: pageServer.Sequencer.AppliedLSN(),
+			Skipped:        skipped,
+			Duplicate:      outcome == wal.Duplicate,
+			Buffered:       outcome == wal.Buffered,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -254,14 +430,34 @@ func handleMetrics(pageServer *server.PageServer) http.HandlerFunc {
 		}
 
 		cacheStats := pageServer.Cache.Stats()
-		latestLSN := pageServer.Storage.GetLatestLSN()
+		latestLSN := pageServer.LatestLSN()
 
 		metrics := map[string]interface{}{
 			"cache": cacheStats,
 			"storage": map[string]interface{}{
 				"latest_lsn": latestLSN,
 			},
+			"limits": map[string]interface{}{
+				"rate":      pageServer.Limiter.Stats(),
+				"load_pool": pageServer.LoadPool.Stats(),
+			},
+		}
+
+		if pageServer.GC != nil {
+			metrics["gc"] = pageServer.GC.Stats()
 		}
+		if pageServer.Scrubber != nil {
+			metrics["scrub"] = pageServer.Scrubber.Stats()
+		}
+		if status, ok := replicationStatus(pageServer); ok {
+			metrics["replication"] = status
+		}
+		if stats, ok := pageServer.ShardingStats(); ok {
+			metrics["sharding"] = stats
+		}
+		metrics["wal_streams"] = pageServer.WALStreamStats()
+		metrics["wal_sequence"] = pageServer.WALSequence()
+		metrics["wal_apply"] = pageServer.WALProcessor.ApplyStats()
 
 		// Add hybrid storage statistics if using hybrid storage
 		if hybridStorage, ok := pageServer.Storage.(*storage.HybridStorage); ok {
@@ -309,8 +505,26 @@ func handleTimeTravel(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
+		var ok bool
+		if req.LSN, ok = resolveLSN(w, pageServer, req.LSN, req.Timestamp); !ok {
+			return
+		}
+
 		// Load page at the specified LSN (point in time)
-		pageData, pageLSN, err := pageServer.Storage.LoadPage(req.SpaceID, req.PageNo, req.LSN)
+		pageData, pageLSN, err := pageServer.LoadPage(r.Context(), req.SpaceID, req.PageNo, req.LSN)
+		if errors.Is(err, limits.ErrQueueTimeout) {
+			writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+			return
+		}
+		if errors.Is(err, server.ErrNotReplicated) {
+			writeNotReplicated(w, pageServer, err)
+			return
+		}
+		var wrongShard *server.WrongShardError
+		if errors.As(err, &wrongShard) {
+			writeWrongShard(w, wrongShard)
+			return
+		}
 		if err != nil {
 			resp := types.GetPageResponse{
 				Status: "error",
@@ -334,7 +548,7 @@ func handleTimeTravel(pageServer *server.PageServer) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 
-		log.Printf("Time-travel query: space=%d page=%d requested_lsn=%d actual_lsn=%d",
+		logging.Debugf("Time-travel query: space=%d page=%d requested_lsn=%d actual_lsn=%d",
 			req.SpaceID, req.PageNo, req.LSN, pageLSN)
 	}
 }
@@ -352,14 +566,40 @@ func handleCreateSnapshot(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
-		// Use latest LSN if not specified
-		lsn := req.LSN
+		// Use latest LSN if neither LSN nor timestamp is specified
+		lsn, ok := resolveLSN(w, pageServer, req.LSN, req.Timestamp)
+		if !ok {
+			return
+		}
 		if lsn == 0 {
-			lsn = pageServer.Storage.GetLatestLSN()
+			lsn = pageServer.LatestLSN()
+		}
+
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+				http.Error(w, "Invalid ttl", http.StatusBadRequest)
+				return
+			}
 		}
 
-		// Create snapshot
-		snapshot, err := pageServer.SnapshotManager.CreateSnapshot(lsn, req.Description)
+		// Create snapshot; its LSN pins page versions against GC
+		snapshot, err := pageServer.CreateSnapshot(lsn, req.Description, req.Labels, ttl)
+		if errors.Is(err, server.ErrReadOnlyReplica) {
+			writeReadOnly(w)
+			return
+		}
+		if errors.Is(err, gc.ErrBelowCutoff) {
+			resp := types.CreateSnapshotResponse{
+				Status: "error",
+				Error:  fmt.Sprintf("Failed to create snapshot: %v", err),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		if err != nil {
 			resp := types.CreateSnapshotResponse{
 				Status: "error",
@@ -390,11 +630,40 @@ func handleListSnapshots(pageServer *server.PageServer) http.HandlerFunc {
 			return
 		}
 
-		snapshots := pageServer.SnapshotManager.ListSnapshots()
+		// Sorted oldest first; ?limit=&page_token= paginate, ?label=key=value filters
+		query := r.URL.Query()
+		opts := snapshots.ListOptions{PageToken: query.Get("page_token")}
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			opts.Limit = n
+		}
+		for _, label := range query["label"] {
+			key, value, ok := strings.Cut(label, "=")
+			if !ok {
+				http.Error(w, "Invalid label filter (want key=value)", http.StatusBadRequest)
+				return
+			}
+			if opts.Labels == nil {
+				opts.Labels = make(map[string]string)
+			}
+			opts.Labels[key] = value
+		}
+
+		page, total, next, err := pageServer.SnapshotManager.ListPage(opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		resp := types.ListSnapshotsResponse{
-			Status:    "success",
-			Snapshots: snapshots,
+			Status:        "success",
+			Snapshots:     page,
+			Total:         total,
+			NextPageToken: next,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -438,6 +707,41 @@ func handleGetSnapshot(pageServer *server.PageServer) http.HandlerFunc {
 	}
 }
 
+func handleDeleteSnapshot(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req types.DeleteSnapshotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		// Page versions pinned only by this snapshot become collectable on the next GC run
+		if err := pageServer.SnapshotManager.DeleteSnapshot(req.SnapshotID); err != nil {
+			resp := map[string]string{
+				"status": "error",
+				"error":  err.Error(),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":      "success",
+			"snapshot_id": req.SnapshotID,
+		})
+
+		log.Printf("Snapshot deleted: id=%s", req.SnapshotID)
+	}
+}
+
 func handleRestoreSnapshot(pageServer *server.PageServer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -472,6 +776,7 @@ func handleRestoreSnapshot(pageServer *server.PageServer) http.HandlerFunc {
 			"usage": map[string]interface{}{
 				"lsn": snapshot.LSN,
 				"note": "Query pages using get_page or get_pages with lsn=" + fmt.Sprintf("%d", snapshot.LSN),
+				"export": "POST /api/v1/exports/create with snapshot_id to write the tablespaces as .ibd files",
 			},
 		}
 
@@ -482,3 +787,96 @@ func handleRestoreSnapshot(pageServer *server.PageServer) http.HandlerFunc {
 	}
 }
 
+
+func handleGetConfig(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Never return secrets
+		cfg := pageServer.Config()
+		cfg.APIKey = maskSecret(cfg.APIKey)
+		cfg.AuthTokens = maskSecret(cfg.AuthTokens)
+		cfg.S3AccessKey = maskSecret(cfg.S3AccessKey)
+		cfg.S3SecretKey = maskSecret(cfg.S3SecretKey)
+
+		resp := map[string]interface{}{
+			"status":    "ok",
+			"config":    cfg,
+			"log_level": logging.GetLevel().String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func handleReloadConfig(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := pageServer.Reload()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to reload config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"status":           "ok",
+			"applied":          result.Applied,
+			"requires_restart": result.RequiresRestart,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func handleRunGC(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Prune snapshots first so versions they pinned are collected in the same pass
+		pruned, err := pageServer.PruneSnapshots()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to prune snapshots: %v", err), http.StatusInternalServerError)
+			return
+		}
+		prunedIDs := make([]string, 0, len(pruned))
+		for _, snapshot := range pruned {
+			prunedIDs = append(prunedIDs, snapshot.ID)
+		}
+
+		resp := map[string]interface{}{
+			"status":           "ok",
+			"pruned_snapshots": prunedIDs,
+		}
+		if pageServer.GC != nil {
+			stats, err := pageServer.GC.Run(r.Context())
+			if err != nil {
+				http.Error(w, fmt.Sprintf("GC failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+			resp["gc"] = stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// maskSecret hides a configured secret, keeping only whether it is set
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "********"
+}
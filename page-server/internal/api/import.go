@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+func handleStartImport(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req types.StartImportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.SourceDir == "" {
+			http.Error(w, "source_dir is required", http.StatusBadRequest)
+			return
+		}
+
+		status, err := pageServer.StartImport(req)
+		if err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, server.ErrImportRunning) {
+				code = http.StatusConflict
+			}
+			if errors.Is(err, server.ErrReadOnlyReplica) {
+				code = http.StatusForbidden
+			}
+			writeImportError(w, code, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(types.ImportResponse{
+			Status: "success",
+			Import: &status,
+		})
+	}
+}
+
+func handleImportStatus(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status, ok := pageServer.ImportStatus()
+		if !ok {
+			writeImportError(w, http.StatusNotFound, errors.New("no import has been started"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.ImportResponse{
+			Status: "success",
+			Import: &status,
+		})
+	}
+}
+
+// writeImportError writes an error response for the import endpoints
+func writeImportError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.ImportResponse{
+		Status: "error",
+		Error:  err.Error(),
+	})
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/pagediff"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// windowLSN reads one end of a diff window from ?<prefix>_lsn= or ?<prefix>_timestamp=.
+// Returns 0 if neither is given; writes an error response and returns false if invalid.
+func windowLSN(w http.ResponseWriter, r *http.Request, pageServer *server.PageServer, prefix string) (uint64, bool) {
+	var lsn uint64
+	if value := r.URL.Query().Get(prefix + "_lsn"); value != "" {
+		var err error
+		if lsn, err = strconv.ParseUint(value, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s_lsn", prefix), http.StatusBadRequest)
+			return 0, false
+		}
+	}
+	return resolveLSN(w, pageServer, lsn, r.URL.Query().Get(prefix+"_timestamp"))
+}
+
+func handlePageDiff(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		spaceID, err := strconv.ParseUint(query.Get("space_id"), 10, 32)
+		if err != nil {
+			http.Error(w, "Missing or invalid space_id", http.StatusBadRequest)
+			return
+		}
+		q := pagediff.Query{
+			SpaceID:   uint32(spaceID),
+			Limit:     pageServer.MaxBatchPages(),
+			PageToken: query.Get("page_token"),
+		}
+
+		// The window is (from, to]; to defaults to the latest LSN
+		var ok bool
+		if q.FromLSN, ok = windowLSN(w, r, pageServer, "from"); !ok {
+			return
+		}
+		if q.ToLSN, ok = windowLSN(w, r, pageServer, "to"); !ok {
+			return
+		}
+		if q.ToLSN == 0 {
+			q.ToLSN = pageServer.LatestLSN()
+		}
+		if q.FromLSN >= q.ToLSN {
+			http.Error(w, fmt.Sprintf("from_lsn %d must be below to_lsn %d", q.FromLSN, q.ToLSN), http.StatusBadRequest)
+			return
+		}
+
+		if value := query.Get("page_no"); value != "" {
+			pageNo, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid page_no", http.StatusBadRequest)
+				return
+			}
+			n := uint32(pageNo)
+			q.PageNo = &n
+		}
+		if value := query.Get("diff"); value != "" {
+			if q.Diff, err = strconv.ParseBool(value); err != nil {
+				http.Error(w, "Invalid diff", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > q.Limit {
+				http.Error(w, fmt.Sprintf("Invalid limit (1 to %d)", q.Limit), http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+
+		resp := types.PageDiffResponse{
+			SpaceID: q.SpaceID,
+			FromLSN: q.FromLSN,
+			ToLSN:   q.ToLSN,
+		}
+		resp.Pages, resp.NextPageToken, err = pageServer.PageDiff(r.Context(), q)
+		if err != nil {
+			if errors.Is(err, limits.ErrQueueTimeout) {
+				writeTooManyRequests(w, loadRetryAfter, "Page server overloaded: storage load queue full")
+				return
+			}
+			if errors.Is(err, server.ErrNotReplicated) {
+				writeNotReplicated(w, pageServer, err)
+				return
+			}
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, pagediff.ErrInvalidPageToken):
+				status = http.StatusBadRequest
+			case errors.Is(err, storage.ErrPageNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, gc.ErrBelowCutoff):
+				status = http.StatusConflict
+			case errors.Is(err, server.ErrDiffUnsupported):
+				status = http.StatusNotImplemented
+			}
+			resp.Status = "error"
+			resp.Pages = nil
+			resp.Error = err.Error()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		resp.Status = "success"
+		if resp.Pages == nil {
+			resp.Pages = []*types.PageChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// getPageDiff calls the page diff handler and decodes the response
+func getPageDiff(t *testing.T, handler http.HandlerFunc, query string) (int, types.PageDiffResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/page_diff?"+query, nil))
+	var resp types.PageDiffResponse
+	if w.Code != http.StatusOK && w.Header().Get("Content-Type") != "application/json" {
+		return w.Code, resp
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+func TestPageDiff(t *testing.T) {
+	ps := newTestServer(t)
+	body := func(b byte) func(page []byte) {
+		return func(page []byte) {
+			page[1000] = b
+			page[1005] = b
+		}
+	}
+	// Page 1 changes inside the window, page 2 only before it, page 3 is
+	// created inside it and page 4 changes at its upper bound
+	storePage(t, ps, 5, 1, 10, nil)
+	storePage(t, ps, 5, 1, 20, body(0xab))
+	storePage(t, ps, 5, 1, 40, nil)
+	storePage(t, ps, 5, 2, 10, nil)
+	storePage(t, ps, 5, 3, 25, nil)
+	storePage(t, ps, 5, 4, 5, nil)
+	storePage(t, ps, 5, 4, 30, nil)
+	handler := handlePageDiff(ps)
+
+	// Without byte diffs only the versions in (from, to] are listed
+	code, resp := getPageDiff(t, handler, "space_id=5&from_lsn=10&to_lsn=30")
+	if code != http.StatusOK || resp.Status != "success" || resp.FromLSN != 10 || resp.ToLSN != 30 || resp.NextPageToken != "" {
+		t.Fatalf("status %d: %+v", code, resp)
+	}
+	want := []*types.PageChange{
+		{PageNo: 1, Versions: []uint64{20}},
+		{PageNo: 3, Versions: []uint64{25}, Created: true},
+		{PageNo: 4, Versions: []uint64{30}},
+	}
+	if !reflect.DeepEqual(resp.Pages, want) {
+		t.Fatalf("pages: %s", mustJSON(resp.Pages))
+	}
+
+	// With byte diffs both images are compared
+	_, resp = getPageDiff(t, handler, "space_id=5&from_lsn=10&to_lsn=30&diff=true")
+	if len(resp.Pages) != 3 {
+		t.Fatalf("pages: %s", mustJSON(resp.Pages))
+	}
+	page := resp.Pages[0]
+	if page.Before == nil || page.After == nil || page.Before.LSN != 10 || page.After.LSN != 20 || page.After.PageNo != 1 || page.After.SpaceID != 5 {
+		t.Fatalf("page 1 headers: %s", mustJSON(page))
+	}
+	// The two body bytes are close enough to share a range
+	wantDiff := []types.ByteRange{
+		{Offset: innodb.FilPageLSN + 7, Length: 1, Field: "lsn", Before: "0a", After: "14"},
+		{Offset: 1000, Length: 6, Before: "000000000000", After: "ab00000000ab"},
+	}
+	if page.ChangedBytes != 3 || !reflect.DeepEqual(page.Diff, wantDiff) {
+		t.Fatalf("page 1 diff: %s", mustJSON(page))
+	}
+	// A created page has nothing to compare with
+	if page := resp.Pages[1]; page.Before != nil || page.After == nil || page.After.LSN != 25 || page.Diff != nil {
+		t.Fatalf("page 3: %s", mustJSON(page))
+	}
+	if page := resp.Pages[2]; page.ChangedBytes != 1 || len(page.Diff) != 1 || page.Diff[0].Field != "lsn" {
+		t.Fatalf("page 4: %s", mustJSON(page))
+	}
+
+	// Results are paged by page number
+	_, resp = getPageDiff(t, handler, "space_id=5&from_lsn=10&to_lsn=30&limit=2")
+	if len(resp.Pages) != 2 || resp.NextPageToken != "4" {
+		t.Fatalf("first page of results: %s, next %q", mustJSON(resp.Pages), resp.NextPageToken)
+	}
+	_, resp = getPageDiff(t, handler, "space_id=5&from_lsn=10&to_lsn=30&limit=2&page_token=4")
+	if len(resp.Pages) != 1 || resp.Pages[0].PageNo != 4 || resp.NextPageToken != "" {
+		t.Fatalf("second page of results: %s, next %q", mustJSON(resp.Pages), resp.NextPageToken)
+	}
+
+	// One page, across the whole history
+	_, resp = getPageDiff(t, handler, "space_id=5&from_lsn=1&to_lsn=50&page_no=1")
+	if len(resp.Pages) != 1 || !resp.Pages[0].Created || !reflect.DeepEqual(resp.Pages[0].Versions, []uint64{10, 20, 40}) {
+		t.Fatalf("page 1 history: %s", mustJSON(resp.Pages))
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"empty window", "space_id=5&from_lsn=30&to_lsn=30", http.StatusBadRequest},
+		{"missing space", "from_lsn=10&to_lsn=30", http.StatusBadRequest},
+		{"unknown space", "space_id=6&from_lsn=10&to_lsn=30", http.StatusNotFound},
+		{"invalid page token", "space_id=5&from_lsn=10&to_lsn=30&page_token=x", http.StatusBadRequest},
+		{"invalid diff", "space_id=5&from_lsn=10&to_lsn=30&diff=maybe", http.StatusBadRequest},
+		{"limit too large", "space_id=5&from_lsn=10&to_lsn=30&limit=1000000", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, resp := getPageDiff(t, handler, tt.query); code != tt.status {
+				t.Fatalf("status %d, want %d: %+v", code, tt.status, resp)
+			}
+		})
+	}
+}
+
+// mustJSON formats a value for failure messages
+func mustJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
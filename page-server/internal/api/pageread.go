@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// resolvePageRead turns a page request into a resolved read, writing an
+// error response and returning false if it is invalid
+func resolvePageRead(w http.ResponseWriter, pageServer *server.PageServer, pr types.PageRequest) (server.PageRead, bool) {
+	points := 0
+	for _, set := range []bool{pr.LSN != 0, pr.RequestLSN != 0, pr.Latest, pr.Timestamp != ""} {
+		if set {
+			points++
+		}
+	}
+	if points > 1 {
+		http.Error(w, "lsn, request_lsn, latest and timestamp are mutually exclusive", http.StatusBadRequest)
+		return server.PageRead{}, false
+	}
+
+	lsn := pr.LSN
+	if pr.RequestLSN != 0 {
+		lsn = pr.RequestLSN
+	}
+	lsn, ok := resolveLSN(w, pageServer, lsn, pr.Timestamp)
+	if !ok {
+		return server.PageRead{}, false
+	}
+
+	read, err := pageServer.ResolveRead(server.PageRead{
+		SpaceID:          pr.SpaceID,
+		PageNo:           pr.PageNo,
+		LSN:              lsn,
+		Latest:           pr.Latest,
+		NotModifiedSince: pr.NotModifiedSince,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return server.PageRead{}, false
+	}
+	return read, true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// replicationStatus describes the server's replication role and lag; false for a standalone server
+func replicationStatus(pageServer *server.PageServer) (types.ReplicationStatusResponse, bool) {
+	status := types.ReplicationStatusResponse{Status: "success"}
+	var heartbeat time.Time
+	switch {
+	case pageServer.Follower != nil:
+		s := pageServer.Follower.Status()
+		status.Role = server.RoleReplica
+		status.ReplicatedLSN = s.ReplicatedLSN
+		status.PrimaryLSN = s.PrimaryLSN
+		status.LagLSN = s.LagLSN
+		status.Error = s.LastError
+		heartbeat = s.HeartbeatAt
+	case pageServer.Publisher != nil:
+		s := pageServer.Publisher.Stats()
+		status.Role = server.RolePrimary
+		status.ReplicatedLSN = s.LSN
+		status.PrimaryLSN = pageServer.Storage.GetLatestLSN()
+		if status.PrimaryLSN > status.ReplicatedLSN {
+			status.LagLSN = status.PrimaryLSN - status.ReplicatedLSN
+		}
+		status.Error = s.LastError
+		heartbeat = s.LastPublish
+	default:
+		status.Role = "standalone"
+		status.ReplicatedLSN = pageServer.LatestLSN()
+		status.PrimaryLSN = status.ReplicatedLSN
+		return status, false
+	}
+	if !heartbeat.IsZero() {
+		status.HeartbeatAt = &heartbeat
+		status.HeartbeatAgeMs = time.Since(heartbeat).Milliseconds()
+	}
+	return status, true
+}
+
+func handleReplicationStatus(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status, _ := replicationStatus(pageServer)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// writeNotReplicated sends a 503 for an LSN a replica has not replicated yet,
+// with a Retry-After of one replication interval
+func writeNotReplicated(w http.ResponseWriter, pageServer *server.PageServer, err error) {
+	interval := pageServer.Config().Replication.Interval
+	if interval <= 0 {
+		interval = server.DefaultReplicationInterval
+	}
+	seconds := int(math.Ceil(interval.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "error",
+		"error":          err.Error(),
+		"replicated_lsn": pageServer.LatestLSN(),
+	})
+}
+
+// writeNotIngested sends a 503 for a read that depends on WAL a primary has
+// not received yet
+func writeNotIngested(w http.ResponseWriter, pageServer *server.PageServer, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "error",
+		"error":      err.Error(),
+		"latest_lsn": pageServer.LatestLSN(),
+	})
+}
+
+// writeReadOnly sends a 403 for a write to a replica
+func writeReadOnly(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "error",
+		"error":  server.ErrReadOnlyReplica.Error() + ": send writes to the primary",
+	})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/scrub"
+	"github.com/linux/projects/server/page-server/internal/server"
+)
+
+func handleScrubStatus(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"status":  "success",
+			"enabled": pageServer.Scrubber != nil,
+		}
+		if pageServer.Scrubber != nil {
+			resp["scrub"] = pageServer.Scrubber.Stats()
+			resp["mismatches"] = pageServer.Scrubber.Mismatches()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func handleRunScrub(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if pageServer.Scrubber == nil {
+			http.Error(w, "Scrubber is not enabled (scrub.enabled)", http.StatusBadRequest)
+			return
+		}
+
+		// The pass runs in the background at the configured rate
+		if err := pageServer.Scrubber.Trigger(); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, scrub.ErrRunning) {
+				code = http.StatusConflict
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "started",
+			"scrub":  pageServer.Scrubber.Stats(),
+		})
+	}
+}
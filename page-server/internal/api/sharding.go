@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/sharding"
+)
+
+func handleShardMap(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		m, shard := pageServer.ShardMap()
+		resp := sharding.MapResponse{
+			Status:  "success",
+			Sharded: m != nil,
+			Shard:   shard,
+			Map:     m,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// forwarded reports whether another shard sent the request; such requests
+// are answered locally and never forwarded again
+func forwarded(r *http.Request) bool {
+	return r.Header.Get(sharding.ForwardedHeader) != ""
+}
+
+// writeWrongShard sends a 421 naming the shard that stores the page
+func writeWrongShard(w http.ResponseWriter, err *server.WrongShardError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMisdirectedRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "error",
+		"error":     err.Error(),
+		"shard":     err.Shard,
+		"shard_url": err.URL,
+	})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/linux/projects/server/page-server/internal/catalog"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// queryLSN reads the point in time of a query from ?lsn= or ?timestamp=.
+// Returns 0 for latest; writes an error response and returns false if invalid.
+func queryLSN(w http.ResponseWriter, r *http.Request, pageServer *server.PageServer) (uint64, bool) {
+	var lsn uint64
+	if value := r.URL.Query().Get("lsn"); value != "" {
+		var err error
+		if lsn, err = strconv.ParseUint(value, 10, 64); err != nil {
+			http.Error(w, "Invalid lsn", http.StatusBadRequest)
+			return 0, false
+		}
+	}
+	return resolveLSN(w, pageServer, lsn, r.URL.Query().Get("timestamp"))
+}
+
+// responseLSN is the LSN reported for a catalog query (the latest LSN if none was given)
+func responseLSN(pageServer *server.PageServer, lsn uint64) uint64 {
+	if lsn == 0 {
+		return pageServer.LatestLSN()
+	}
+	return lsn
+}
+
+func handleListSpaces(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		lsn, ok := queryLSN(w, r, pageServer)
+		if !ok {
+			return
+		}
+		includeDropped, _ := strconv.ParseBool(r.URL.Query().Get("include_dropped"))
+
+		resp := types.ListSpacesResponse{
+			Status: "success",
+			LSN:    responseLSN(pageServer, lsn),
+			Spaces: pageServer.Catalog.List(lsn, includeDropped),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func handleGetSpace(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		spaceID, err := strconv.ParseUint(r.URL.Query().Get("space_id"), 10, 32)
+		if err != nil {
+			http.Error(w, "Missing or invalid space_id", http.StatusBadRequest)
+			return
+		}
+		lsn, ok := queryLSN(w, r, pageServer)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		space, err := pageServer.Catalog.Get(uint32(spaceID), lsn)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, catalog.ErrSpaceNotFound) {
+				status = http.StatusNotFound
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(types.SpaceResponse{
+				Status: "error",
+				LSN:    responseLSN(pageServer, lsn),
+				Error:  err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(types.SpaceResponse{
+			Status: "success",
+			LSN:    responseLSN(pageServer, lsn),
+			Space:  space,
+		})
+	}
+}
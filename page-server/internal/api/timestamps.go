@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/linux/projects/server/common/lsnindex"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// resolveLSN resolves an optional timestamp to an LSN, writing an error
+// response and returning false if it cannot be resolved
+func resolveLSN(w http.ResponseWriter, pageServer *server.PageServer, lsn uint64, timestamp string) (uint64, bool) {
+	resolved, err := pageServer.ResolveLSN(lsn, timestamp)
+	if err == nil {
+		return resolved, true
+	}
+
+	status := http.StatusBadRequest
+	if errors.Is(err, lsnindex.ErrBeforeFirst) {
+		status = http.StatusNotFound
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.LSNForTimestampResponse{
+		Status:    "error",
+		Timestamp: timestamp,
+		Error:     err.Error(),
+	})
+	return 0, false
+}
+
+func handleLSNForTimestamp(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		timestamp := r.URL.Query().Get("timestamp")
+		if timestamp == "" {
+			http.Error(w, "Missing timestamp", http.StatusBadRequest)
+			return
+		}
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			http.Error(w, "Invalid timestamp (want RFC 3339)", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := pageServer.LSNIndex.Lookup(t)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(types.LSNForTimestampResponse{
+				Status:    "error",
+				Timestamp: timestamp,
+				Error:     err.Error(),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.LSNForTimestampResponse{
+			Status:     "success",
+			LSN:        entry.LSN,
+			Timestamp:  timestamp,
+			RecordedAt: &entry.Time,
+		})
+	}
+}
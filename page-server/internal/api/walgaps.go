@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// handleWALGaps lists the LSN ranges that never arrived, for backfilling
+// from a safekeeper
+func handleWALGaps(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := pageServer.WALSequence()
+		resp := types.WALGapsResponse{
+			Status:      "success",
+			AppliedLSN:  status.AppliedLSN,
+			ReceivedLSN: status.ReceivedLSN,
+			Buffered:    status.Buffered,
+			Gaps:        make([]types.WALGap, 0, len(status.Gaps)),
+		}
+		if status.Waiting != nil {
+			gap := walGap(*status.Waiting)
+			resp.Waiting = &gap
+		}
+		for _, gap := range status.Gaps {
+			resp.Gaps = append(resp.Gaps, walGap(gap))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func walGap(gap wal.Gap) types.WALGap {
+	return types.WALGap{
+		StartLSN:   gap.StartLSN,
+		EndLSN:     gap.EndLSN,
+		DetectedAt: gap.DetectedAt.Format(time.RFC3339),
+	}
+}
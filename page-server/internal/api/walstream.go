@@ -0,0 +1,255 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/logging"
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+	"github.com/linux/projects/server/page-server/pkg/walstream"
+)
+
+const (
+	// walAckInterval is how often a WAL stream is synced and acknowledged
+	walAckInterval = 10 * time.Millisecond
+	// walAckRecords acknowledges early once this many records are unacknowledged
+	walAckRecords = 1024
+)
+
+// walStream is the state of one streaming WAL connection
+type walStream struct {
+	pageServer *server.PageServer
+	w          http.ResponseWriter
+	rc         *http.ResponseController
+
+	mu         sync.Mutex // Guards the counters below and writes to acked
+	applied    uint64
+	records    int64
+	skipped    int64
+	duplicates int64
+	acked      int64 // Records covered by the last sync
+
+	durable uint64 // Only used by ack, which is never called concurrently
+
+	kick chan struct{} // Requests an acknowledgement now
+}
+
+// ack syncs the records processed so far and writes an acknowledgement.
+// An "ack" with nothing new since the last one is not written.
+func (s *walStream) ack(status string, streamErr error) error {
+	s.mu.Lock()
+	resp := types.WALStreamAck{
+		Status:     status,
+		AppliedLSN: s.applied,
+		Records:    s.records,
+		Skipped:    s.skipped,
+		Duplicates: s.duplicates,
+	}
+	s.mu.Unlock()
+
+	// Records the sequencer buffers are not stored yet, so durability stops
+	// below them and moves on once they are applied
+	durable := min(resp.AppliedLSN, s.pageServer.Sequencer.AppliedLSN())
+	if status == "ack" && resp.Records == s.acked && durable <= s.durable {
+		return nil
+	}
+
+	// One fsync covers every record since the last acknowledgement
+	if resp.Records > s.acked || durable > s.durable {
+		if err := s.pageServer.SyncWAL(); err != nil {
+			err = fmt.Errorf("failed to sync WAL: %w", err)
+			log.Printf("Error: WAL stream: %v", err)
+			resp.Status = "error"
+			streamErr = err
+		} else {
+			s.durable = max(s.durable, durable)
+			s.mu.Lock()
+			s.acked = resp.Records
+			s.mu.Unlock()
+		}
+	}
+	resp.DurableLSN = s.durable
+	if streamErr != nil {
+		resp.Error = streamErr.Error()
+	}
+
+	if err := json.NewEncoder(s.w).Encode(resp); err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil {
+		return err
+	}
+	if resp.Status == "error" && status == "ack" {
+		return streamErr
+	}
+	return nil
+}
+
+// process stores and applies one record, or skips it on a shard that does
+// not own its page; the sequencer may hold it back until its predecessor arrives
+func (s *walStream) process(f walstream.Frame) error {
+	record := wal.WALRecord{
+		LSN:     f.LSN,
+		PrevLSN: f.PrevLSN,
+		WALData: append([]byte(nil), f.WALData...), // The reader reuses its buffer
+		SpaceID: f.SpaceID,
+		PageNo:  f.PageNo,
+	}
+	skipped := record.SpaceID > 0 && record.PageNo > 0 && !s.pageServer.OwnsPage(record.SpaceID, record.PageNo)
+
+	outcome, err := s.pageServer.IngestWAL(record)
+	if err != nil {
+		return fmt.Errorf("failed to process WAL at LSN %d: %w", f.LSN, err)
+	}
+
+	if outcome != wal.Duplicate {
+		commitTime := f.CommitTime
+		if commitTime.IsZero() {
+			commitTime = time.Now()
+		}
+		if err := s.pageServer.RecordCommitTime(f.LSN, commitTime); err != nil {
+			log.Printf("Warning: failed to index WAL record LSN=%d: %v", f.LSN, err)
+		}
+	}
+	s.pageServer.StreamedRecord()
+
+	s.mu.Lock()
+	if f.LSN > s.applied {
+		s.applied = f.LSN
+	}
+	s.records++
+	if skipped {
+		s.skipped++
+	}
+	if outcome == wal.Duplicate {
+		s.duplicates++
+	}
+	unacked := s.records - s.acked
+	s.mu.Unlock()
+
+	if unacked >= walAckRecords {
+		s.requestAck()
+	}
+	return nil
+}
+
+// requestAck asks the acknowledgement loop to sync and acknowledge now
+func (s *walStream) requestAck() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// handleStreamWALFrames ingests a continuous stream of length-prefixed WAL
+// frames (see pkg/walstream) and answers with periodic acknowledgements
+func handleStreamWALFrames(pageServer *server.PageServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if pageServer.IsReplica() {
+			writeReadOnly(w)
+			return
+		}
+
+		// HTTP/1.1 needs full duplex to write acknowledgements while the
+		// request body is still being read; HTTP/2 always has it
+		rc := http.NewResponseController(w)
+		if err := rc.EnableFullDuplex(); err != nil && r.ProtoMajor == 1 {
+			http.Error(w, fmt.Sprintf("Streaming not supported: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		rc.Flush()
+
+		defer pageServer.StreamOpened()()
+		s := &walStream{pageServer: pageServer, w: w, rc: rc, kick: make(chan struct{}, 1)}
+
+		// Interrupt the blocked body read when the server shuts down
+		var stopping atomic.Bool
+		readDone := make(chan struct{})
+		go func() {
+			select {
+			case <-pageServer.StreamsStopping():
+				stopping.Store(true)
+				rc.SetReadDeadline(time.Now())
+			case <-readDone:
+			}
+		}()
+
+		// Sync and acknowledge in the background while frames are read
+		var ackErr atomic.Value
+		ackDone := make(chan struct{})
+		go func() {
+			defer close(ackDone)
+			ticker := time.NewTicker(walAckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-readDone:
+					return
+				case <-ticker.C:
+				case <-s.kick:
+				}
+				if err := s.ack("ack", nil); err != nil {
+					ackErr.Store(err)
+					rc.SetReadDeadline(time.Now()) // Stop reading
+					return
+				}
+			}
+		}()
+
+		reader := walstream.NewReader(r.Body)
+		var streamErr error
+		for {
+			f, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				switch {
+				case stopping.Load():
+					streamErr = server.ErrShuttingDown
+				case ackErr.Load() != nil:
+					streamErr = ackErr.Load().(error)
+				default:
+					streamErr = fmt.Errorf("failed to read frame: %w", err)
+				}
+				break
+			}
+			if f.Sync {
+				s.requestAck()
+				continue
+			}
+			if err := s.process(f); err != nil {
+				streamErr = err
+				break
+			}
+		}
+		close(readDone)
+		<-ackDone
+
+		// The last line reports the final durable LSN
+		status := "success"
+		if streamErr != nil {
+			status = "error"
+			log.Printf("Warning: WAL stream from %s ended: %v", r.RemoteAddr, streamErr)
+		}
+		if err := s.ack(status, streamErr); err != nil {
+			logging.Debugf("WAL stream from %s: failed to send final acknowledgement: %v", r.RemoteAddr, err)
+		}
+		logging.Debugf("WAL stream from %s closed: %d records, durable LSN %d", r.RemoteAddr, s.records, s.durable)
+	}
+}
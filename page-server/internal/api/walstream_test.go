@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+	"github.com/linux/projects/server/page-server/pkg/types"
+	"github.com/linux/projects/server/page-server/pkg/walstream"
+)
+
+// testStream is an open POST /api/v1/stream_wal/frames request
+type testStream struct {
+	body *io.PipeWriter
+	w    *walstream.Writer
+	acks *json.Decoder
+}
+
+func openStream(t *testing.T, url string) *testStream {
+	t.Helper()
+	pr, pw := io.Pipe()
+	resp, err := http.Post(url, "application/octet-stream", pr)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	t.Cleanup(func() { pw.Close(); resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST: status %d", resp.StatusCode)
+	}
+	return &testStream{body: pw, w: walstream.NewWriter(pw), acks: json.NewDecoder(resp.Body)}
+}
+
+// send writes a record writing b at offset 64 of space 1 page 3
+func (s *testStream) send(t *testing.T, lsn, prev uint64, b byte) {
+	t.Helper()
+	f := walstream.Frame{LSN: lsn, PrevLSN: prev, SpaceID: 1, PageNo: 3, WALData: []byte{0x34, 1, 3, 64, b}}
+	if err := s.w.Write(f); err != nil {
+		t.Fatalf("sending LSN %d: %v", lsn, err)
+	}
+}
+
+// sync sends a sync frame and returns the first acknowledgement durable up to lsn
+func (s *testStream) sync(t *testing.T, lsn uint64) types.WALStreamAck {
+	t.Helper()
+	if err := s.w.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	for {
+		ack := s.next(t)
+		if ack.Status != "ack" {
+			t.Fatalf("waiting for durable LSN %d: %+v", lsn, ack)
+		}
+		if ack.DurableLSN >= lsn {
+			return ack
+		}
+	}
+}
+
+// last returns the final line of the response, skipping acknowledgements
+func (s *testStream) last(t *testing.T) types.WALStreamAck {
+	t.Helper()
+	for {
+		if ack := s.next(t); ack.Status != "ack" {
+			var extra json.RawMessage
+			if err := s.acks.Decode(&extra); err != io.EOF {
+				t.Fatalf("after the final line: %v, want io.EOF", err)
+			}
+			return ack
+		}
+	}
+}
+
+func (s *testStream) next(t *testing.T) types.WALStreamAck {
+	t.Helper()
+	var ack types.WALStreamAck
+	if err := s.acks.Decode(&ack); err != nil {
+		t.Fatalf("reading acknowledgement: %v", err)
+	}
+	return ack
+}
+
+// pageByte returns byte 64 of space 1 page 3 at lsn
+func pageByte(t *testing.T, ps *server.PageServer, lsn uint64) byte {
+	t.Helper()
+	page, _, err := ps.GetPage(context.Background(), 1, 3, lsn)
+	if err != nil {
+		t.Fatalf("GetPage at %d: %v", lsn, err)
+	}
+	return page[64]
+}
+
+func TestStreamWALFrames(t *testing.T) {
+	ps := newTestServer(t)
+	srv := httptest.NewServer(handleStreamWALFrames(ps))
+	defer srv.Close()
+
+	// The first stream starts at LSN 100 and ends with the request body
+	s := openStream(t, srv.URL)
+	s.send(t, 100, 0, 'a')
+	s.send(t, 110, 100, 'b')
+	s.send(t, 120, 110, 'c')
+	if ack := s.sync(t, 120); ack.AppliedLSN != 120 || ack.Records != 3 {
+		t.Fatalf("acknowledgement: %+v", ack)
+	}
+	s.body.Close()
+	if ack := s.last(t); ack.Status != "success" || ack.DurableLSN != 120 || ack.Records != 3 || ack.Error != "" {
+		t.Fatalf("final line: %+v", ack)
+	}
+
+	// A client resuming after a lost acknowledgement resends from its
+	// last durable LSN; the records already received are ignored
+	s = openStream(t, srv.URL)
+	s.send(t, 110, 100, 'x')
+	s.send(t, 120, 110, 'y')
+	s.send(t, 130, 120, 'd')
+	s.body.Close()
+	if ack := s.last(t); ack.Status != "success" || ack.DurableLSN != 130 || ack.Records != 3 || ack.Duplicates != 2 {
+		t.Fatalf("resumed stream: %+v", ack)
+	}
+	for lsn, want := range map[uint64]byte{100: 'a', 115: 'b', 120: 'c', 130: 'd'} {
+		if got := pageByte(t, ps, lsn); got != want {
+			t.Errorf("page at LSN %d: %q, want %q", lsn, got, want)
+		}
+	}
+
+	// Shutting down ends an open stream with an error that still reports
+	// what became durable
+	s = openStream(t, srv.URL)
+	s.send(t, 140, 130, 'e')
+	s.sync(t, 140)
+	ps.StopStreams()
+	ack := s.last(t)
+	if ack.Status != "error" || ack.DurableLSN != 140 || !strings.Contains(ack.Error, server.ErrShuttingDown.Error()) {
+		t.Fatalf("stream open at shutdown: %+v", ack)
+	}
+}
@@ -0,0 +1,230 @@
+// Package basebackup collects the pages a compute node needs to start at an
+// LSN — the system tablespace header pages, the data dictionary roots and the
+// rollback segment headers — and writes them as a tar with a manifest, so a
+// cold start takes one request instead of hundreds of get_page calls.
+package basebackup
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// ManifestName is the first entry of the tar
+const ManifestName = "manifest.json"
+
+// Page roles in the manifest
+const (
+	RoleSystemPage      = "system"      // Fixed pages 0-7 of the system tablespace
+	RoleDictRoot        = "dict_root"   // Root of a SYS_* dictionary index
+	RoleRsegHeader      = "rseg_header" // Rollback segment header
+	RoleUndoSpaceHeader = "undo_space"  // Page 0 of an undo tablespace
+)
+
+// fetchWindow is the number of pages loaded concurrently
+const fetchWindow = 32
+
+// ErrNoSystemSpace is returned when page 0 of the system tablespace does not exist at the LSN
+var ErrNoSystemSpace = errors.New("system tablespace not found")
+
+// PageLoader loads a page at or before an LSN
+type PageLoader func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error)
+
+// Backup holds the collected pages of a basebackup
+type Backup struct {
+	Manifest *types.BasebackupManifest
+	pages    map[innodb.PageID][]byte
+}
+
+// Collect loads the pages needed to start compute at lsn. Pages referenced
+// by the system tablespace but never written are listed as missing.
+func Collect(ctx context.Context, load PageLoader, lsn uint64) (*Backup, error) {
+	b := &Backup{
+		Manifest: &types.BasebackupManifest{
+			LSN:       lsn,
+			CreatedAt: time.Now().UTC(),
+		},
+		pages: make(map[innodb.PageID][]byte),
+	}
+
+	// Fixed pages of the system tablespace
+	var fixed []innodb.PageID
+	for pageNo := uint32(0); pageNo <= innodb.DictHdrPageNo; pageNo++ {
+		fixed = append(fixed, innodb.PageID{SpaceID: innodb.SystemSpaceID, PageNo: pageNo})
+	}
+	if err := b.fetch(ctx, load, fixed, RoleSystemPage); err != nil {
+		return nil, err
+	}
+	if _, ok := b.pages[innodb.PageID{SpaceID: innodb.SystemSpaceID, PageNo: 0}]; !ok {
+		return nil, fmt.Errorf("%w at LSN %d: %w", ErrNoSystemSpace, lsn, storage.ErrPageNotFound)
+	}
+
+	// Data dictionary index roots
+	if page, ok := b.pages[innodb.PageID{SpaceID: innodb.SystemSpaceID, PageNo: innodb.DictHdrPageNo}]; ok {
+		roots, err := innodb.DictRoots(page)
+		if err != nil {
+			return nil, err
+		}
+		var ids []innodb.PageID
+		for _, root := range roots {
+			ids = append(ids, innodb.PageID{SpaceID: innodb.SystemSpaceID, PageNo: root})
+		}
+		if err := b.fetch(ctx, load, ids, RoleDictRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rollback segment headers, and the header page of each undo tablespace
+	if page, ok := b.pages[innodb.PageID{SpaceID: innodb.SystemSpaceID, PageNo: innodb.TrxSysPageNo}]; ok {
+		rsegs, err := innodb.RsegHeaders(page)
+		if err != nil {
+			return nil, err
+		}
+		var undoSpaces []innodb.PageID
+		seen := make(map[uint32]bool)
+		for _, rseg := range rsegs {
+			if rseg.SpaceID != innodb.SystemSpaceID && !seen[rseg.SpaceID] {
+				seen[rseg.SpaceID] = true
+				undoSpaces = append(undoSpaces, innodb.PageID{SpaceID: rseg.SpaceID, PageNo: 0})
+			}
+		}
+		if err := b.fetch(ctx, load, undoSpaces, RoleUndoSpaceHeader); err != nil {
+			return nil, err
+		}
+		if err := b.fetch(ctx, load, rsegs, RoleRsegHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// fetch loads pages not collected yet and adds them to the manifest
+func (b *Backup) fetch(ctx context.Context, load PageLoader, ids []innodb.PageID, role string) error {
+	var todo []innodb.PageID
+	for _, id := range ids {
+		if _, ok := b.pages[id]; !ok && !b.listed(id) {
+			todo = append(todo, id)
+		}
+	}
+
+	type result struct {
+		data    []byte
+		pageLSN uint64
+		err     error
+	}
+	results := make([]result, len(todo))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fetchWindow)
+	for i, id := range todo {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id innodb.PageID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			data, pageLSN, err := load(ctx, id.SpaceID, id.PageNo, b.Manifest.LSN)
+			results[i] = result{data: data, pageLSN: pageLSN, err: err}
+		}(i, id)
+	}
+	wg.Wait()
+
+	for i, id := range todo {
+		res := results[i]
+		entry := types.BasebackupPage{
+			SpaceID: id.SpaceID,
+			PageNo:  id.PageNo,
+			Role:    role,
+		}
+		switch {
+		case errors.Is(res.err, storage.ErrPageNotFound):
+			entry.Missing = true
+		case res.err != nil:
+			return fmt.Errorf("failed to load page space=%d page=%d: %w", id.SpaceID, id.PageNo, res.err)
+		default:
+			entry.PageLSN = res.pageLSN
+			entry.Path = pagePath(id)
+			entry.Size = len(res.data)
+			b.pages[id] = res.data
+		}
+		b.Manifest.Pages = append(b.Manifest.Pages, entry)
+	}
+	return nil
+}
+
+// listed reports whether a page is already in the manifest (possibly as missing)
+func (b *Backup) listed(id innodb.PageID) bool {
+	for _, page := range b.Manifest.Pages {
+		if page.SpaceID == id.SpaceID && page.PageNo == id.PageNo {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTar writes the manifest followed by one entry per collected page,
+// ordered by space and page number
+func (b *Backup) WriteTar(w io.Writer) error {
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeEntry(tw, ManifestName, manifest, b.Manifest.CreatedAt); err != nil {
+		return err
+	}
+
+	ids := make([]innodb.PageID, 0, len(b.pages))
+	for id := range b.pages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].SpaceID != ids[j].SpaceID {
+			return ids[i].SpaceID < ids[j].SpaceID
+		}
+		return ids[i].PageNo < ids[j].PageNo
+	})
+	for _, id := range ids {
+		if err := writeEntry(tw, pagePath(id), b.pages[id], b.Manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish tar: %w", err)
+	}
+	return nil
+}
+
+// writeEntry writes one regular file to the tar
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// pagePath is the tar entry name of a page
+func pagePath(id innodb.PageID) string {
+	return fmt.Sprintf("space_%d/page_%d", id.SpaceID, id.PageNo)
+}
@@ -23,6 +23,8 @@ type LFCCache struct {
 	hits       int64
 	misses    int64
 	evictions int64
+	
+	generation uint64 // Bumped by Remove and Clear
 }
 
 // LFCPage represents a page in the LFC
@@ -39,18 +41,37 @@ type LFCPage struct {
 // NewLFCCache creates a new Local File Cache
 // maxSizeBytes: Maximum size in bytes (typically 75% of available RAM)
 func NewLFCCache(maxSizeBytes int64) *LFCCache {
+	return &LFCCache{
+		cache:     make(map[string]*LFCPage),
+		maxSize:   maxSizeBytes,
+		maxPages:  lfcMaxPages(maxSizeBytes),
+		currentSize: 0,
+	}
+}
+
+// lfcMaxPages estimates the page limit for a size limit
+func lfcMaxPages(maxSizeBytes int64) int {
 	// Estimate max pages (assuming average page size of 16KB)
 	avgPageSize := int64(16384) // 16KB
 	maxPages := int(maxSizeBytes / avgPageSize)
 	if maxPages < 100 {
 		maxPages = 100 // Minimum 100 pages
 	}
+	return maxPages
+}
+
+// Resize changes the maximum LFC size, evicting least recently
+// used pages if the cache is now too large
+func (lfc *LFCCache) Resize(maxSizeBytes int64) {
+	lfc.mu.Lock()
+	defer lfc.mu.Unlock()
 	
-	return &LFCCache{
-		cache:     make(map[string]*LFCPage),
-		maxSize:   maxSizeBytes,
-		maxPages:  maxPages,
-		currentSize: 0,
+	lfc.maxSize = maxSizeBytes
+	lfc.maxPages = lfcMaxPages(maxSizeBytes)
+	for lfc.currentSize > lfc.maxSize || len(lfc.cache) > lfc.maxPages {
+		if !lfc.evictLRU() {
+			break
+		}
 	}
 }
 
@@ -58,31 +79,22 @@ func NewLFCCache(maxSizeBytes int64) *LFCCache {
 func (lfc *LFCCache) Get(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, bool) {
 	key := lfc.makeKey(spaceID, pageNo)
 	
-	lfc.mu.RLock()
-	page, exists := lfc.cache[key]
-	lfc.mu.RUnlock()
+	// Put updates cached pages in place, so read the page under the lock
+	lfc.mu.Lock()
+	defer lfc.mu.Unlock()
 	
-	if !exists {
-		lfc.mu.Lock()
-		lfc.misses++
-		lfc.mu.Unlock()
-		return nil, 0, false
-	}
+	page, exists := lfc.cache[key]
 	
 	// Check if cached version is acceptable (LSN <= requested)
-	if page.LSN > lsn {
-		lfc.mu.Lock()
+	if !exists || page.LSN > lsn {
 		lfc.misses++
-		lfc.mu.Unlock()
 		return nil, 0, false
 	}
 	
 	// Update access statistics
-	lfc.mu.Lock()
 	page.LastAccess = time.Now()
 	page.AccessCount++
 	lfc.hits++
-	lfc.mu.Unlock()
 	
 	// Return a copy to prevent modification
 	data := make([]byte, len(page.Data))
@@ -91,16 +103,42 @@ func (lfc *LFCCache) Get(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uin
 	return data, page.LSN, true
 }
 
-// Put stores a page in LFC
+// Put stores a page in LFC. A cached newer version of the page is kept.
 func (lfc *LFCCache) Put(spaceID uint32, pageNo uint32, lsn uint64, data []byte) {
-	key := lfc.makeKey(spaceID, pageNo)
-	pageSize := int64(len(data))
-	
 	lfc.mu.Lock()
 	defer lfc.mu.Unlock()
+	lfc.put(spaceID, pageNo, lsn, data)
+}
+
+// Generation returns a counter that changes whenever pages are removed
+// (see PageCache.Generation)
+func (lfc *LFCCache) Generation() uint64 {
+	lfc.mu.RLock()
+	defer lfc.mu.RUnlock()
+	return lfc.generation
+}
+
+// PutIfGeneration is Put, unless pages were removed since gen was read
+func (lfc *LFCCache) PutIfGeneration(gen uint64, spaceID uint32, pageNo uint32, lsn uint64, data []byte) bool {
+	lfc.mu.Lock()
+	defer lfc.mu.Unlock()
+	if lfc.generation != gen {
+		return false
+	}
+	lfc.put(spaceID, pageNo, lsn, data)
+	return true
+}
+
+// put stores a page; lfc.mu must be held
+func (lfc *LFCCache) put(spaceID uint32, pageNo uint32, lsn uint64, data []byte) {
+	key := lfc.makeKey(spaceID, pageNo)
+	pageSize := int64(len(data))
 	
 	// Check if page already exists (update)
 	if existing, exists := lfc.cache[key]; exists {
+		if existing.LSN > lsn {
+			return
+		}
 		// Update existing page
 		lfc.currentSize -= existing.Size
 		existing.Data = make([]byte, len(data))
@@ -205,6 +243,21 @@ func (lfc *LFCCache) Clear() {
 	
 	lfc.cache = make(map[string]*LFCPage)
 	lfc.currentSize = 0
+	lfc.generation++
+}
+
+// Remove drops a page from the LFC
+func (lfc *LFCCache) Remove(spaceID uint32, pageNo uint32) {
+	key := lfc.makeKey(spaceID, pageNo)
+	
+	lfc.mu.Lock()
+	defer lfc.mu.Unlock()
+	
+	if page, exists := lfc.cache[key]; exists {
+		lfc.currentSize -= page.Size
+		delete(lfc.cache, key)
+	}
+	lfc.generation++
 }
 
 // GetSize returns current size in bytes
@@ -216,6 +269,8 @@ func (lfc *LFCCache) GetSize() int64 {
 
 // GetMaxSize returns maximum size in bytes
 func (lfc *LFCCache) GetMaxSize() int64 {
+	lfc.mu.RLock()
+	defer lfc.mu.RUnlock()
 	return lfc.maxSize
 }
 
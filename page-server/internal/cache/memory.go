@@ -2,17 +2,23 @@ package cache
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"time"
 )
 
+// Newest is the ValidThrough of a version that is the newest version of its
+// page until a newer one is put or the page is removed
+const Newest = math.MaxUint64
+
 // PageVersion represents a versioned page
 type PageVersion struct {
-	Data       []byte
-	LSN        uint64
-	SpaceID    uint32
-	PageNo     uint32
-	LastAccess time.Time
+	Data         []byte
+	LSN          uint64
+	ValidThrough uint64 // Highest LSN at which this is known to be the newest version
+	SpaceID      uint32
+	PageNo       uint32
+	LastAccess   time.Time
 }
 
 // PageCache implements an LRU cache for pages
@@ -21,6 +27,7 @@ type PageCache struct {
 	mu         sync.RWMutex
 	maxSize    int
 	evictCount int
+	generation uint64 // Bumped by Remove and Clear
 }
 
 // NewPageCache creates a new page cache
@@ -31,8 +38,11 @@ func NewPageCache(maxSize int) *PageCache {
 	}
 }
 
-// Get retrieves a page from cache
-func (pc *PageCache) Get(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, bool) {
+// Get retrieves the version of a page at lsn. The cached version serves if
+// it is no newer than lsn and known to be the newest version at
+// notModifiedSince; the caller promises the page did not change between
+// notModifiedSince and lsn (pass lsn for no promise).
+func (pc *PageCache) Get(spaceID uint32, pageNo uint32, lsn uint64, notModifiedSince uint64) ([]byte, uint64, bool) {
 	key := pc.makeKey(spaceID, pageNo)
 	
 	pc.mu.RLock()
@@ -43,8 +53,7 @@ func (pc *PageCache) Get(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uin
 		return nil, 0, false
 	}
 	
-	// Check if cached version is acceptable (LSN <= requested)
-	if version.LSN > lsn {
+	if version.LSN > lsn || version.ValidThrough < notModifiedSince {
 		return nil, 0, false
 	}
 	
@@ -58,12 +67,50 @@ func (pc *PageCache) Get(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uin
 	return data, version.LSN, true
 }
 
-// Put stores a page in cache
+// Put stores a page version just written, the newest version of the page.
+// A cached newer version of the page is kept.
 func (pc *PageCache) Put(spaceID uint32, pageNo uint32, lsn uint64, data []byte) {
-	key := pc.makeKey(spaceID, pageNo)
-	
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
+	pc.put(spaceID, pageNo, lsn, Newest, data)
+}
+
+// Generation returns a counter that changes whenever pages are removed.
+// Read it before loading a page from storage and pass it to PutIfGeneration,
+// so a version loaded before an invalidation is not cached after it.
+func (pc *PageCache) Generation() uint64 {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.generation
+}
+
+// PutIfGeneration stores a page version loaded from storage, known to be the
+// newest version through validThrough, unless pages were removed since gen
+// was read. A cached newer version of the page is kept.
+func (pc *PageCache) PutIfGeneration(gen uint64, spaceID uint32, pageNo uint32, lsn uint64, validThrough uint64, data []byte) bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.generation != gen {
+		return false
+	}
+	return pc.put(spaceID, pageNo, lsn, validThrough, data)
+}
+
+// put stores a page; pc.mu must be held
+func (pc *PageCache) put(spaceID uint32, pageNo uint32, lsn uint64, validThrough uint64, data []byte) bool {
+	key := pc.makeKey(spaceID, pageNo)
+	
+	if existing, exists := pc.cache[key]; exists {
+		switch {
+		case existing.LSN > lsn:
+			return false
+		case existing.LSN == lsn:
+			// Same version; a later load may know it stayed newest for longer
+			existing.ValidThrough = max(existing.ValidThrough, validThrough)
+			existing.LastAccess = time.Now()
+			return true
+		}
+	}
 	
 	// Check if we need to evict
 	if len(pc.cache) >= pc.maxSize {
@@ -72,13 +119,15 @@ func (pc *PageCache) Put(spaceID uint32, pageNo uint32, lsn uint64, data []byte)
 	
 	// Store new version
 	pc.cache[key] = &PageVersion{
-		Data:       make([]byte, len(data)),
-		LSN:        lsn,
-		SpaceID:    spaceID,
-		PageNo:     pageNo,
-		LastAccess: time.Now(),
+		Data:         make([]byte, len(data)),
+		LSN:          lsn,
+		ValidThrough: validThrough,
+		SpaceID:      spaceID,
+		PageNo:       pageNo,
+		LastAccess:   time.Now(),
 	}
 	copy(pc.cache[key].Data, data)
+	return true
 }
 
 // evictLRU evicts the least recently used page
@@ -116,10 +165,31 @@ func (pc *PageCache) Stats() map[string]interface{} {
 	}
 }
 
+// Remove drops a page from the cache
+func (pc *PageCache) Remove(spaceID uint32, pageNo uint32) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.cache, pc.makeKey(spaceID, pageNo))
+	pc.generation++
+}
+
 // Clear clears the cache
 func (pc *PageCache) Clear() {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 	pc.cache = make(map[string]*PageVersion)
+	pc.generation++
 }
 
+
+// Resize changes the maximum number of cached pages, evicting
+// least recently used pages if the cache is now too large
+func (pc *PageCache) Resize(maxSize int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	
+	pc.maxSize = maxSize
+	for len(pc.cache) > pc.maxSize && len(pc.cache) > 0 {
+		pc.evictLRU()
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestPageCacheValidity(t *testing.T) {
+	pc := NewPageCache(10)
+
+	// Written by WAL application: current for any later LSN
+	pc.Put(1, 2, 20, []byte("v20"))
+	if _, lsn, ok := pc.Get(1, 2, 100, 100); !ok || lsn != 20 {
+		t.Fatalf("newest version at LSN 100: lsn=%d ok=%v", lsn, ok)
+	}
+
+	// A historic read must not replace the newest version
+	if pc.PutIfGeneration(pc.Generation(), 1, 2, 10, 15, []byte("v10")) {
+		t.Fatal("older version replaced a newer one")
+	}
+	if _, _, ok := pc.Get(1, 2, 15, 15); ok {
+		t.Fatal("version 20 served a read at LSN 15")
+	}
+
+	// A version loaded at LSN 50 is only known current through 50
+	pc.Remove(1, 2)
+	pc.PutIfGeneration(pc.Generation(), 1, 2, 20, 50, []byte("v20"))
+	if _, _, ok := pc.Get(1, 2, 80, 80); ok {
+		t.Fatal("version known current through 50 served a read at LSN 80")
+	}
+	if _, lsn, ok := pc.Get(1, 2, 80, 40); !ok || lsn != 20 {
+		t.Fatalf("read at 80 not modified since 40: lsn=%d ok=%v", lsn, ok)
+	}
+
+	// Removing pages invalidates loads started before
+	gen := pc.Generation()
+	pc.Remove(1, 2)
+	if pc.PutIfGeneration(gen, 1, 2, 20, Newest, []byte("v20")) {
+		t.Fatal("version loaded before Remove was cached")
+	}
+}
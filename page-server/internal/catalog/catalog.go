@@ -0,0 +1,328 @@
+// Package catalog tracks the tablespaces known to the page server: their size
+// in pages, flags and page size, and the LSNs at which they were created and
+// dropped. It is fed from FSP header writes on page 0 and from file-level redo
+// records, so compute can open tablespaces without local files.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// ErrSpaceNotFound is returned for spaces that are unknown or did not exist at the requested LSN
+var ErrSpaceNotFound = errors.New("tablespace not found")
+
+// fileName is the catalog file in the data directory
+const fileName = "spaces.json"
+
+// SizeChange records the size of a space from an LSN on
+type SizeChange struct {
+	LSN   uint64 `json:"lsn"`
+	Pages uint32 `json:"pages"`
+}
+
+// space is the persisted state of one tablespace
+type space struct {
+	SpaceID    uint32       `json:"space_id"`
+	Name       string       `json:"name,omitempty"`
+	Flags      uint32       `json:"flags"`
+	FreeLimit  uint32       `json:"free_limit"`
+	CreatedLSN uint64       `json:"created_lsn,omitempty"`
+	DroppedLSN uint64       `json:"dropped_lsn,omitempty"`
+	Sizes      []SizeChange `json:"sizes"` // Ascending LSN
+}
+
+// Catalog holds the tablespace catalog
+type Catalog struct {
+	path   string
+	mu     sync.RWMutex
+	spaces map[uint32]*space
+}
+
+// Open loads the catalog from dataDir, starting empty if there is none
+func Open(dataDir string) (*Catalog, error) {
+	c := &Catalog{
+		path:   filepath.Join(dataDir, fileName),
+		spaces: make(map[uint32]*space),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read space catalog: %w", err)
+	}
+
+	var spaces []*space
+	if err := json.Unmarshal(data, &spaces); err != nil {
+		return nil, fmt.Errorf("failed to parse space catalog %s: %w", c.path, err)
+	}
+	for _, s := range spaces {
+		c.spaces[s.SpaceID] = s
+	}
+	return c, nil
+}
+
+// Has reports whether the catalog knows a space
+func (c *Catalog) Has(spaceID uint32) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.spaces[spaceID]
+	return ok
+}
+
+// Seed adds a space from its stored page 0, for spaces written before the
+// catalog existed. Known spaces are left alone.
+func (c *Catalog) Seed(hdr innodb.FSPHeader, lsn uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.spaces[hdr.SpaceID]; ok {
+		return nil
+	}
+	c.spaces[hdr.SpaceID] = &space{
+		SpaceID:   hdr.SpaceID,
+		Flags:     hdr.Flags,
+		FreeLimit: hdr.FreeLimit,
+		Sizes:     []SizeChange{{LSN: lsn, Pages: hdr.Size}},
+	}
+	return c.save()
+}
+
+// Register records a space imported at lsn, replacing what the catalog knew about it
+func (c *Catalog) Register(hdr innodb.FSPHeader, name string, lsn uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.spaces[hdr.SpaceID] = &space{
+		SpaceID:   hdr.SpaceID,
+		Name:      name,
+		Flags:     hdr.Flags,
+		FreeLimit: hdr.FreeLimit,
+		Sizes:     []SizeChange{{LSN: lsn, Pages: hdr.Size}},
+	}
+	return c.save()
+}
+
+// ObserveRecords updates the catalog from the redo records of one WAL record.
+// It implements wal.RecordObserver.
+func (c *Catalog) ObserveRecords(lsn uint64, records []*wal.RedoLogRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := false
+	headers := make(map[uint32][]byte) // Page 0 headers touched by this record
+
+	for _, rec := range records {
+		switch {
+		case rec.Type == wal.FILE_CREATE:
+			c.spaces[rec.SpaceID] = &space{
+				SpaceID:    rec.SpaceID,
+				Name:       rec.FileName,
+				CreatedLSN: lsn,
+			}
+			changed = true
+
+		case rec.Type == wal.FILE_DELETE:
+			if s, ok := c.spaces[rec.SpaceID]; ok && s.DroppedLSN == 0 {
+				s.DroppedLSN = lsn
+				changed = true
+			}
+
+		case rec.Type == wal.FILE_RENAME:
+			s := c.lookupOrAdd(rec.SpaceID)
+			s.Name = rec.NewName
+			changed = true
+
+		case rec.Type == wal.FILE_MODIFY:
+			if s := c.lookupOrAdd(rec.SpaceID); s.Name != rec.FileName {
+				s.Name = rec.FileName
+				changed = true
+			}
+
+		case rec.IsFileOp() || rec.PageNo != 0:
+			// Other file-level records and pages other than page 0 don't change the catalog
+
+		case rec.Type == wal.MREC_INIT_PAGE:
+			hdr := make([]byte, innodb.FSPHeaderEnd)
+			headers[rec.SpaceID] = hdr
+
+		case rec.Type == wal.MREC_WRITE:
+			end := int(rec.Offset) + len(rec.Data)
+			if end <= innodb.FilPageData || int(rec.Offset) >= innodb.FSPHeaderEnd {
+				continue
+			}
+			hdr, ok := headers[rec.SpaceID]
+			if !ok {
+				hdr = c.header(rec.SpaceID)
+				headers[rec.SpaceID] = hdr
+			}
+			start := int(rec.Offset)
+			data := rec.Data
+			if end > innodb.FSPHeaderEnd {
+				data = data[:len(data)-(end-innodb.FSPHeaderEnd)]
+			}
+			copy(hdr[start:], data)
+		}
+	}
+
+	for spaceID, page := range headers {
+		hdr, err := innodb.ParseFSPHeader(page)
+		if err != nil {
+			return err
+		}
+		if c.applyHeader(spaceID, hdr, lsn) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+// lookupOrAdd returns a space, adding it if the catalog missed its creation
+func (c *Catalog) lookupOrAdd(spaceID uint32) *space {
+	s, ok := c.spaces[spaceID]
+	if !ok {
+		s = &space{SpaceID: spaceID}
+		c.spaces[spaceID] = s
+	}
+	return s
+}
+
+// header rebuilds the FSP header fields of a space's page 0 from the catalog
+func (c *Catalog) header(spaceID uint32) []byte {
+	page := make([]byte, innodb.FSPHeaderEnd)
+	if s, ok := c.spaces[spaceID]; ok {
+		innodb.PutFSPHeader(page, innodb.FSPHeader{
+			SpaceID:   spaceID,
+			Size:      s.size(0),
+			FreeLimit: s.FreeLimit,
+			Flags:     s.Flags,
+		})
+	}
+	return page
+}
+
+// applyHeader records a new FSP header of a space and reports whether anything changed
+func (c *Catalog) applyHeader(spaceID uint32, hdr innodb.FSPHeader, lsn uint64) bool {
+	s := c.lookupOrAdd(spaceID)
+	changed := s.Flags != hdr.Flags || s.FreeLimit != hdr.FreeLimit
+	s.Flags = hdr.Flags
+	s.FreeLimit = hdr.FreeLimit
+
+	if len(s.Sizes) > 0 && s.Sizes[len(s.Sizes)-1].Pages == hdr.Size {
+		return changed
+	}
+	if n := len(s.Sizes); n > 0 && s.Sizes[n-1].LSN == lsn {
+		s.Sizes[n-1].Pages = hdr.Size
+	} else {
+		s.Sizes = append(s.Sizes, SizeChange{LSN: lsn, Pages: hdr.Size})
+	}
+	return true
+}
+
+// Get returns a space as of lsn (0 for latest).
+// Returns ErrSpaceNotFound if the space did not exist at that LSN.
+func (c *Catalog) Get(spaceID uint32, lsn uint64) (*types.Space, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, ok := c.spaces[spaceID]
+	if !ok || !s.existsAt(lsn) {
+		if lsn == 0 {
+			return nil, fmt.Errorf("space %d: %w", spaceID, ErrSpaceNotFound)
+		}
+		return nil, fmt.Errorf("space %d at LSN %d: %w", spaceID, lsn, ErrSpaceNotFound)
+	}
+	return s.view(lsn), nil
+}
+
+// List returns the spaces that existed at lsn (0 for latest), sorted by ID.
+// With includeDropped, spaces dropped at or before lsn are included as well.
+func (c *Catalog) List(lsn uint64, includeDropped bool) []*types.Space {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]*types.Space, 0, len(c.spaces))
+	for _, s := range c.spaces {
+		if s.existsAt(lsn) || (includeDropped && s.DroppedLSN != 0 && (lsn == 0 || s.DroppedLSN <= lsn)) {
+			result = append(result, s.view(lsn))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].SpaceID < result[j].SpaceID })
+	return result
+}
+
+// existsAt reports whether the space existed at lsn (0 for latest)
+func (s *space) existsAt(lsn uint64) bool {
+	if lsn == 0 {
+		return s.DroppedLSN == 0
+	}
+	if s.CreatedLSN != 0 && lsn < s.CreatedLSN {
+		return false
+	}
+	return s.DroppedLSN == 0 || lsn < s.DroppedLSN
+}
+
+// size returns the size in pages at lsn (0 for latest)
+func (s *space) size(lsn uint64) uint32 {
+	var pages uint32
+	for _, change := range s.Sizes {
+		if lsn != 0 && change.LSN > lsn {
+			break
+		}
+		pages = change.Pages
+	}
+	return pages
+}
+
+// view returns the API representation of a space at lsn
+func (s *space) view(lsn uint64) *types.Space {
+	pages := s.size(lsn)
+	pageSize := innodb.PageSizeFromFlags(s.Flags)
+	return &types.Space{
+		SpaceID:    s.SpaceID,
+		Name:       s.Name,
+		SizePages:  pages,
+		SizeBytes:  int64(pages) * int64(pageSize),
+		PageSize:   pageSize,
+		Flags:      s.Flags,
+		CreatedLSN: s.CreatedLSN,
+		DroppedLSN: s.DroppedLSN,
+	}
+}
+
+// save writes the catalog atomically; the caller holds mu
+func (c *Catalog) save() error {
+	spaces := make([]*space, 0, len(c.spaces))
+	for _, s := range c.spaces {
+		spaces = append(spaces, s)
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i].SpaceID < spaces[j].SpaceID })
+
+	data, err := json.MarshalIndent(spaces, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode space catalog: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write space catalog: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write space catalog: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/wal"
+)
+
+// headerWrite returns an MREC_WRITE of the FSP header fields of page 0
+func headerWrite(hdr innodb.FSPHeader) *wal.RedoLogRecord {
+	page := make([]byte, innodb.FSPHeaderEnd)
+	innodb.PutFSPHeader(page, hdr)
+	return &wal.RedoLogRecord{
+		Type:    wal.MREC_WRITE,
+		SpaceID: hdr.SpaceID,
+		Offset:  innodb.FilPageData,
+		Data:    page[innodb.FilPageData:],
+	}
+}
+
+// sizeWrite returns an MREC_WRITE of FSP_SIZE only
+func sizeWrite(spaceID, pages uint32) *wal.RedoLogRecord {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, pages)
+	return &wal.RedoLogRecord{Type: wal.MREC_WRITE, SpaceID: spaceID, Offset: innodb.FilPageData + 8, Data: data}
+}
+
+func TestCatalogHistory(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	const flags = 0x15 // full_crc32, 16 KiB pages
+	steps := []struct {
+		lsn     uint64
+		records []*wal.RedoLogRecord
+	}{
+		{10, []*wal.RedoLogRecord{
+			{Type: wal.FILE_CREATE, SpaceID: 5, FileName: "./shop/orders.ibd"},
+			headerWrite(innodb.FSPHeader{SpaceID: 5, Size: 4, FreeLimit: 4, Flags: flags}),
+		}},
+		{20, []*wal.RedoLogRecord{
+			sizeWrite(5, 8),
+			{Type: wal.MREC_WRITE, SpaceID: 5, PageNo: 3, Offset: innodb.FilPageData + 8, Data: []byte{0xff, 0xff, 0xff, 0xff}},
+		}},
+		{30, []*wal.RedoLogRecord{{Type: wal.FILE_RENAME, SpaceID: 5, FileName: "./shop/orders.ibd", NewName: "./shop/orders_old.ibd"}}},
+		{40, []*wal.RedoLogRecord{{Type: wal.FILE_DELETE, SpaceID: 5, FileName: "./shop/orders_old.ibd"}}},
+	}
+	for _, step := range steps {
+		if err := c.ObserveRecords(step.lsn, step.records); err != nil {
+			t.Fatalf("ObserveRecords at %d: %v", step.lsn, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lsn   uint64
+		pages uint32
+		found bool
+	}{
+		{"before creation", 5, 0, false},
+		{"at creation", 10, 4, true},
+		{"between size changes", 15, 4, true},
+		{"after growth", 25, 8, true},
+		{"before the drop", 39, 8, true},
+		{"at the drop", 40, 0, false},
+		{"latest", 0, 0, false},
+	}
+	check := func(c *Catalog) {
+		t.Helper()
+		for _, tt := range tests {
+			space, err := c.Get(5, tt.lsn)
+			if !tt.found {
+				if !errors.Is(err, ErrSpaceNotFound) {
+					t.Fatalf("%s: Get = %+v, %v; want ErrSpaceNotFound", tt.name, space, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: Get: %v", tt.name, err)
+			}
+			pageSize := innodb.PageSizeFromFlags(flags)
+			if space.SizePages != tt.pages || space.PageSize != pageSize || space.SizeBytes != int64(tt.pages)*int64(pageSize) ||
+				space.CreatedLSN != 10 || space.DroppedLSN != 40 {
+				t.Fatalf("%s: %+v", tt.name, space)
+			}
+		}
+	}
+	check(c)
+
+	if space, _ := c.Get(5, 35); space.Name != "./shop/orders_old.ibd" {
+		t.Fatalf("renamed space: %+v", space)
+	}
+	if n := len(c.List(35, false)); n != 1 {
+		t.Fatalf("List at 35: %d spaces", n)
+	}
+	if n := len(c.List(0, false)); n != 0 {
+		t.Fatalf("List latest: %d spaces", n)
+	}
+	if n := len(c.List(0, true)); n != 1 {
+		t.Fatalf("List latest with dropped: %d spaces", n)
+	}
+
+	// The history survives a restart
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	check(reopened)
+}
+
+func TestCatalogSeedAndRegister(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if err := c.Seed(innodb.FSPHeader{SpaceID: 7, Size: 64}, 100); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	// Seeding does not override what the catalog already knows
+	if err := c.Seed(innodb.FSPHeader{SpaceID: 7, Size: 1}, 200); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if space, err := c.Get(7, 0); err != nil || space.SizePages != 64 {
+		t.Fatalf("seeded space: %+v, %v", space, err)
+	}
+
+	// Growth without a FILE_CREATE adds to the seeded history
+	if err := c.ObserveRecords(300, []*wal.RedoLogRecord{sizeWrite(7, 128)}); err != nil {
+		t.Fatalf("ObserveRecords: %v", err)
+	}
+	if space, _ := c.Get(7, 250); space.SizePages != 64 {
+		t.Fatalf("size before growth: %+v", space)
+	}
+	if space, _ := c.Get(7, 0); space.SizePages != 128 {
+		t.Fatalf("size after growth: %+v", space)
+	}
+
+	// An import replaces the space
+	if err := c.Register(innodb.FSPHeader{SpaceID: 7, Size: 16}, "./shop/imported.ibd", 400); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if space, _ := c.Get(7, 0); space.SizePages != 16 || space.Name != "./shop/imported.ibd" {
+		t.Fatalf("registered space: %+v", space)
+	}
+	if !c.Has(7) || c.Has(8) {
+		t.Fatal("Has")
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/linux/projects/server/page-server/internal/server"
+)
+
+// EnvPrefix is the prefix of environment variables that override the config file.
+// The variable name is the flag name upper-cased with dashes replaced by
+// underscores, e.g. PAGESERVER_CACHE_SIZE overrides -cache-size.
+const EnvPrefix = "PAGESERVER_"
+
+// File is the page server configuration file layout
+type File struct {
+	Port            int           `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	server.Config `yaml:",inline"`
+}
+
+// setter applies a flag or environment value to a File
+type setter func(f *File, value string) error
+
+// settings maps flag names to the config fields they set
+var settings = map[string]setter{
+	"port":                    intField(func(f *File) *int { return &f.Port }),
+	"data-dir":                stringField(func(f *File) *string { return &f.DataDir }),
+	"cache-size":              intField(func(f *File) *int { return &f.CacheSize }),
+	"storage-backend":         stringField(func(f *File) *string { return &f.StorageType }),
+	"s3-endpoint":             stringField(func(f *File) *string { return &f.S3Endpoint }),
+	"s3-bucket":               stringField(func(f *File) *string { return &f.S3Bucket }),
+	"s3-region":               stringField(func(f *File) *string { return &f.S3Region }),
+	"s3-access-key":           stringField(func(f *File) *string { return &f.S3AccessKey }),
+	"s3-secret-key":           stringField(func(f *File) *string { return &f.S3SecretKey }),
+	"s3-prefix":               stringField(func(f *File) *string { return &f.S3Prefix }),
+	"s3-use-ssl":              boolField(func(f *File) *bool { return &f.S3UseSSL }),
+	"api-key":                 stringField(func(f *File) *string { return &f.APIKey }),
+	"auth-tokens":             stringField(func(f *File) *string { return &f.AuthTokens }),
+	"tls":                     boolField(func(f *File) *bool { return &f.TLS.Enabled }),
+	"tls-cert":                stringField(func(f *File) *string { return &f.TLS.Cert }),
+	"tls-key":                 stringField(func(f *File) *string { return &f.TLS.Key }),
+	"tls-client-ca":           stringField(func(f *File) *string { return &f.TLS.ClientCA }),
+	"tls-require-client-cert": boolField(func(f *File) *bool { return &f.TLS.RequireClientCert }),
+	"client-cert-roles":       stringField(func(f *File) *string { return &f.ClientCertRoles }),
+	"shutdown-timeout":        durationField(func(f *File) *time.Duration { return &f.ShutdownTimeout }),
+	"jwt-jwks":                stringField(func(f *File) *string { return &f.JWTJWKS }),
+	"jwt-issuer":              stringField(func(f *File) *string { return &f.JWTIssuer }),
+	"jwt-audience":            stringField(func(f *File) *string { return &f.JWTAudience }),
+	"tenant-id":               stringField(func(f *File) *string { return &f.TenantID }),
+	"timeline-id":             stringField(func(f *File) *string { return &f.TimelineID }),
+	"log-level":               stringField(func(f *File) *string { return &f.LogLevel }),
+	"lfc-size-bytes":          int64Field(func(f *File) *int64 { return &f.Tiers.LFCSizeBytes }),
+	"max-batch-pages":         intField(func(f *File) *int { return &f.Limits.MaxBatchPages }),
+
+	"requests-per-second":  float64Field(func(f *File) *float64 { return &f.Limits.RequestsPerSecond }),
+	"request-burst":        intField(func(f *File) *int { return &f.Limits.RequestBurst }),
+	"bytes-per-second":     int64Field(func(f *File) *int64 { return &f.Limits.BytesPerSecond }),
+	"bytes-burst":          int64Field(func(f *File) *int64 { return &f.Limits.BytesBurst }),
+	"max-concurrent-loads": intField(func(f *File) *int { return &f.Limits.MaxConcurrentLoads }),
+	"load-queue-timeout":   durationField(func(f *File) *time.Duration { return &f.Limits.LoadQueueTimeout }),
+
+	"role":                 stringField(func(f *File) *string { return &f.Replication.Role }),
+	"replication-interval": durationField(func(f *File) *time.Duration { return &f.Replication.Interval }),
+
+	"shard-map":   stringField(func(f *File) *string { return &f.Sharding.MapFile }),
+	"shard-index": intField(func(f *File) *int { return &f.Sharding.Index }),
+
+	"wal-gap-timeout":   durationField(func(f *File) *time.Duration { return &f.WAL.GapTimeout }),
+	"wal-apply-workers": intField(func(f *File) *int { return &f.WAL.ApplyWorkers }),
+}
+
+// Load builds the configuration with the precedence
+// flag defaults < config file < environment < explicitly set flags.
+// path may be empty, in which case only flags and environment are used.
+func Load(path string, fs *flag.FlagSet) (*File, error) {
+	f := &File{}
+
+	// Start from flag defaults
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		if err == nil {
+			err = apply(f, fl.Name, fl.DefValue)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Config file
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	// Environment
+	fs.VisitAll(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if value, ok := os.LookupEnv(EnvName(fl.Name)); ok {
+			if applyErr := apply(f, fl.Name, value); applyErr != nil {
+				err = fmt.Errorf("invalid %s: %w", EnvName(fl.Name), applyErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Flags given on the command line
+	fs.Visit(func(fl *flag.Flag) {
+		if err == nil {
+			err = apply(f, fl.Name, fl.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// EnvName returns the environment variable that overrides the given flag
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// apply sets a config field by flag name; flags without a config field are ignored
+func apply(f *File, name, value string) error {
+	set, ok := settings[name]
+	if !ok {
+		return nil
+	}
+	if err := set(f, value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+	}
+	return nil
+}
+
+func stringField(field func(*File) *string) setter {
+	return func(f *File, value string) error {
+		*field(f) = value
+		return nil
+	}
+}
+
+func intField(field func(*File) *int) setter {
+	return func(f *File, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(f) = v
+		return nil
+	}
+}
+
+func int64Field(field func(*File) *int64) setter {
+	return func(f *File, value string) error {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(f) = v
+		return nil
+	}
+}
+
+func float64Field(field func(*File) *float64) setter {
+	return func(f *File, value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(f) = v
+		return nil
+	}
+}
+
+func boolField(field func(*File) *bool) setter {
+	return func(f *File, value string) error {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(f) = v
+		return nil
+	}
+}
+
+func durationField(field func(*File) *time.Duration) setter {
+	return func(f *File, value string) error {
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(f) = v
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testFlags defines a few of the page server's flags
+func testFlags(args ...string) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet("pageserver", flag.ContinueOnError)
+	fs.Int("port", 8080, "")
+	fs.String("data-dir", "./page-server-data", "")
+	fs.Int("cache-size", 1000, "")
+	fs.String("log-level", "info", "")
+	fs.String("tls-cert", "", "")
+	fs.Duration("shutdown-timeout", 30*time.Second, "")
+	fs.String("config", "", "") // Has no config field
+	return fs, fs.Parse(args)
+}
+
+// writeFile writes a config file and returns its path
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pageserver.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	// Each setting is given one more time than the one before it
+	path := writeFile(t, `
+cache_size: 2000
+log_level: debug
+port: 9000
+tls:
+  cert: /file/server.crt
+limits:
+  max_batch_pages: 64
+`)
+	t.Setenv("PAGESERVER_LOG_LEVEL", "warn")
+	t.Setenv("PAGESERVER_PORT", "9100")
+	t.Setenv("PAGESERVER_TLS_CERT", "/env/server.crt")
+	fs, err := testFlags("-tls-cert", "/flag/server.crt")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	f, err := Load(path, fs)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"flag default", f.DataDir, "./page-server-data"},
+		{"flag default without a file value", f.ShutdownTimeout, 30 * time.Second},
+		{"file over default", f.CacheSize, 2000},
+		{"file only", f.Limits.MaxBatchPages, 64},
+		{"env over file", f.LogLevel, "warn"},
+		{"env over file (int)", f.Port, 9100},
+		{"flag over env", f.TLS.Cert, "/flag/server.crt"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	t.Setenv("PAGESERVER_CACHE_SIZE", "5000")
+	fs, err := testFlags("-port", "9200")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	f, err := Load("", fs)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if f.Port != 9200 || f.CacheSize != 5000 || f.LogLevel != "info" {
+		t.Fatalf("config: port=%d cache_size=%d log_level=%s", f.Port, f.CacheSize, f.LogLevel)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+	}{
+		{"invalid yaml", "cache_size: [1", nil},
+		{"wrong type in file", "cache_size: lots", nil},
+		{"invalid env value", "", map[string]string{"PAGESERVER_CACHE_SIZE": "lots"}},
+		{"invalid env duration", "", map[string]string{"PAGESERVER_SHUTDOWN_TIMEOUT": "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			fs, _ := testFlags()
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, tt.file)
+			}
+			if _, err := Load(path, fs); err == nil {
+				t.Fatal("Load succeeded")
+			}
+		})
+	}
+
+	fs, _ := testFlags()
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), fs); err == nil {
+		t.Fatal("Load of a missing file succeeded")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("tls-require-client-cert"); got != "PAGESERVER_TLS_REQUIRE_CLIENT_CERT" {
+		t.Fatalf("EnvName: %s", got)
+	}
+}
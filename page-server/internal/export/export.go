@@ -0,0 +1,517 @@
+// Package export materializes tablespaces at a snapshot LSN as standalone
+// .ibd files (or a tar of all spaces) that MariaDB can attach with
+// ALTER TABLE ... IMPORT TABLESPACE.
+package export
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// Export formats
+const (
+	FormatIBD = "ibd" // A single tablespace file
+	FormatTar = "tar" // One space_<id>.ibd entry per tablespace
+)
+
+// Export destinations
+const (
+	DestinationLocal = "local"
+	DestinationS3    = "s3"
+)
+
+// Job states
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+)
+
+// fetchWindow is the number of pages loaded concurrently; pages are still written in order
+const fetchWindow = 32
+
+// PageLoader loads a page at or before an LSN
+type PageLoader func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error)
+
+// Uploader stores a finished export in object storage
+type Uploader interface {
+	// UploadFile uploads the file at path and returns the object key
+	UploadFile(ctx context.Context, key string, path string) (string, error)
+}
+
+// Manager runs export jobs in the background and tracks their progress
+type Manager struct {
+	exportsDir string
+	load       PageLoader
+	lister     storage.SpaceLister // nil if the backend cannot enumerate spaces
+	uploader   Uploader            // nil if there is no object storage
+
+	jobs map[string]*types.ExportJob
+	mu   sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates an export manager that writes to <baseDir>/exports
+func NewManager(baseDir string, load PageLoader, lister storage.SpaceLister, uploader Uploader) (*Manager, error) {
+	exportsDir := filepath.Join(baseDir, "exports")
+	if err := os.MkdirAll(exportsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		exportsDir: exportsDir,
+		load:       load,
+		lister:     lister,
+		uploader:   uploader,
+		jobs:       make(map[string]*types.ExportJob),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	if err := m.loadJobs(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to load export jobs: %w", err)
+	}
+
+	return m, nil
+}
+
+// Start validates the request and starts an export of the snapshot in the background
+func (m *Manager) Start(snapshot *types.Snapshot, req types.CreateExportRequest) (*types.ExportJob, error) {
+	format := req.Format
+	if format == "" {
+		format = FormatTar
+		if req.SpaceID != nil {
+			format = FormatIBD
+		}
+	}
+	if format != FormatIBD && format != FormatTar {
+		return nil, fmt.Errorf("unknown export format: %s (supported: ibd, tar)", format)
+	}
+	if format == FormatIBD && req.SpaceID == nil {
+		return nil, fmt.Errorf("space_id is required for the ibd format")
+	}
+
+	destination := req.Destination
+	if destination == "" {
+		destination = DestinationLocal
+	}
+	switch destination {
+	case DestinationLocal:
+	case DestinationS3:
+		if m.uploader == nil {
+			return nil, fmt.Errorf("s3 destination requires the s3 or hybrid storage backend")
+		}
+	default:
+		return nil, fmt.Errorf("unknown export destination: %s (supported: local, s3)", destination)
+	}
+
+	var spaces []uint32
+	if req.SpaceID != nil {
+		spaces = []uint32{*req.SpaceID}
+	} else if m.lister == nil {
+		return nil, fmt.Errorf("storage backend cannot list spaces; space_id is required")
+	}
+
+	now := time.Now()
+	job := &types.ExportJob{
+		ID:          fmt.Sprintf("export_%d_%d", snapshot.LSN, now.UnixNano()),
+		SnapshotID:  snapshot.ID,
+		LSN:         snapshot.LSN,
+		SpaceIDs:    spaces,
+		Format:      format,
+		Destination: destination,
+		State:       StatePending,
+		CreatedAt:   now,
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.saveJobLocked(job)
+	jobCopy := *job
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(job.ID, snapshot.Timestamp)
+	}()
+
+	return &jobCopy, nil
+}
+
+// Get returns a copy of the job with the given ID
+func (m *Manager) Get(id string) (*types.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return nil, fmt.Errorf("export not found: %s", id)
+	}
+	jobCopy := *job
+	return &jobCopy, nil
+}
+
+// List returns copies of all jobs, oldest first
+func (m *Manager) List() []*types.ExportJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*types.ExportJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobCopy := *job
+		jobs = append(jobs, &jobCopy)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// ActiveLSNs returns the LSNs read by pending and running exports; GC keeps their page versions
+func (m *Manager) ActiveLSNs() []uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lsns []uint64
+	for _, job := range m.jobs {
+		if job.State == StatePending || job.State == StateRunning {
+			lsns = append(lsns, job.LSN)
+		}
+	}
+	return lsns
+}
+
+// LocalFile returns the output path of a completed local export
+func (m *Manager) LocalFile(id string) (string, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if job.State != StateCompleted {
+		return "", fmt.Errorf("export %s is %s", id, job.State)
+	}
+	if job.Destination != DestinationLocal {
+		return "", fmt.Errorf("export %s was uploaded to %s", id, job.Location)
+	}
+	return job.Location, nil
+}
+
+// Close cancels running exports and waits for them to stop
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// spacePlan is a tablespace to export with its header from page 0
+type spacePlan struct {
+	spaceID  uint32
+	header   innodb.FSPHeader
+	pageSize int
+	page0    []byte
+}
+
+// run executes a job and records its outcome
+func (m *Manager) run(id string, modTime time.Time) {
+	m.update(id, func(job *types.ExportJob) { job.State = StateRunning }, true)
+
+	location, err := m.export(id, modTime)
+
+	now := time.Now()
+	m.update(id, func(job *types.ExportJob) {
+		job.FinishedAt = &now
+		if err != nil {
+			job.State = StateFailed
+			job.Error = err.Error()
+			return
+		}
+		job.State = StateCompleted
+		job.Location = location
+	}, true)
+
+	if err != nil {
+		log.Printf("Warning: export %s failed: %v", id, err)
+		return
+	}
+	log.Printf("Export completed: id=%s location=%s", id, location)
+}
+
+// export writes the output file and returns its final location
+func (m *Manager) export(id string, modTime time.Time) (string, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	spaces := job.SpaceIDs
+	if len(spaces) == 0 {
+		if spaces, err = m.lister.ListSpaces(); err != nil {
+			return "", err
+		}
+		if len(spaces) == 0 {
+			return "", fmt.Errorf("no tablespaces stored")
+		}
+	}
+
+	// Read every FSP header first so progress has a total
+	plans := make([]spacePlan, 0, len(spaces))
+	var total int64
+	for _, spaceID := range spaces {
+		plan, err := m.planSpace(spaceID, job.LSN)
+		if err != nil {
+			return "", err
+		}
+		plans = append(plans, plan)
+		total += int64(plan.header.Size)
+	}
+	m.update(id, func(job *types.ExportJob) {
+		job.SpaceIDs = spaces
+		job.PagesTotal = total
+	}, true)
+
+	finalPath := filepath.Join(m.exportsDir, id+"."+job.Format)
+	tmpPath := finalPath + ".tmp"
+	if err := m.writeFile(id, tmpPath, job.Format, plans, job.LSN, modTime); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to rename export file: %w", err)
+	}
+
+	if job.Destination != DestinationS3 {
+		return finalPath, nil
+	}
+
+	key, err := m.uploader.UploadFile(m.ctx, "exports/"+filepath.Base(finalPath), finalPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(finalPath); err != nil {
+		log.Printf("Warning: failed to remove uploaded export %s: %v", finalPath, err)
+	}
+	return key, nil
+}
+
+// planSpace reads page 0 of a space at the LSN
+func (m *Manager) planSpace(spaceID uint32, lsn uint64) (spacePlan, error) {
+	page0, page0LSN, err := m.load(m.ctx, spaceID, 0, lsn)
+	if err != nil {
+		return spacePlan{}, fmt.Errorf("failed to load page 0 of space %d: %w", spaceID, err)
+	}
+	header, err := innodb.ParseFSPHeader(page0)
+	if err != nil {
+		return spacePlan{}, fmt.Errorf("space %d: %w", spaceID, err)
+	}
+	if header.SpaceID != spaceID {
+		return spacePlan{}, fmt.Errorf("space %d: FSP header names space %d", spaceID, header.SpaceID)
+	}
+	pageSize := header.PageSize()
+	if len(page0) != pageSize {
+		return spacePlan{}, fmt.Errorf("space %d: page 0 is %d bytes, flags say %d", spaceID, len(page0), pageSize)
+	}
+	page0 = stampPage(page0, 0, page0LSN, header.Flags)
+	return spacePlan{spaceID: spaceID, header: header, pageSize: pageSize, page0: page0}, nil
+}
+
+// stampPage writes the LSN and checksum InnoDB expects into a page version
+// rebuilt from WAL. Pages that already verify, such as imported ones, are
+// exported unchanged.
+func stampPage(page []byte, pageNo uint32, lsn uint64, flags uint32) []byte {
+	fullCRC32 := innodb.IsFullCRC32(flags)
+	if innodb.VerifyChecksum(page, fullCRC32) == nil {
+		return page
+	}
+	return innodb.StampPage(page, pageNo, lsn, fullCRC32)
+}
+
+// writeFile writes all planned spaces to path in the given format
+func (m *Manager) writeFile(id, path, format string, plans []spacePlan, lsn uint64, modTime time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	if format == FormatIBD {
+		if err := m.writeSpace(id, file, plans[0], lsn); err != nil {
+			return err
+		}
+	} else {
+		tw := tar.NewWriter(file)
+		for _, plan := range plans {
+			hdr := &tar.Header{
+				Name:    fmt.Sprintf("space_%d.ibd", plan.spaceID),
+				Mode:    0644,
+				Size:    int64(plan.header.Size) * int64(plan.pageSize),
+				ModTime: modTime,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return fmt.Errorf("failed to write tar header: %w", err)
+			}
+			if err := m.writeSpace(id, tw, plan, lsn); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return fmt.Errorf("failed to finish tar: %w", err)
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync export file: %w", err)
+	}
+	return file.Close()
+}
+
+// writeSpace writes every page of a space in order. Pages never written
+// (free extents) are exported as zero pages, as InnoDB leaves them on disk.
+func (m *Manager) writeSpace(id string, w io.Writer, plan spacePlan, lsn uint64) error {
+	zero := make([]byte, plan.pageSize)
+	size := plan.header.Size
+
+	for start := uint32(0); start < size; start += fetchWindow {
+		end := start + fetchWindow
+		if end > size {
+			end = size
+		}
+
+		pages := make([][]byte, end-start)
+		lsns := make([]uint64, end-start)
+		errs := make([]error, end-start)
+		var wg sync.WaitGroup
+		for pageNo := start; pageNo < end; pageNo++ {
+			if pageNo == 0 {
+				pages[0] = plan.page0
+				continue
+			}
+			wg.Add(1)
+			go func(pageNo uint32) {
+				defer wg.Done()
+				pages[pageNo-start], lsns[pageNo-start], errs[pageNo-start] = m.load(m.ctx, plan.spaceID, pageNo, lsn)
+			}(pageNo)
+		}
+		wg.Wait()
+
+		var written, missing int64
+		for i, data := range pages {
+			pageNo := start + uint32(i)
+			if err := errs[i]; err != nil {
+				if !errors.Is(err, storage.ErrPageNotFound) {
+					return fmt.Errorf("failed to load page %d of space %d: %w", pageNo, plan.spaceID, err)
+				}
+				data = zero
+				missing++
+			}
+			if len(data) != plan.pageSize {
+				return fmt.Errorf("page %d of space %d is %d bytes, expected %d", pageNo, plan.spaceID, len(data), plan.pageSize)
+			}
+			if pageNo != 0 && errs[i] == nil {
+				data = stampPage(data, pageNo, lsns[i], plan.header.Flags)
+			}
+			if _, err := w.Write(data); err != nil {
+				return fmt.Errorf("failed to write page %d of space %d: %w", pageNo, plan.spaceID, err)
+			}
+			written += int64(len(data))
+		}
+
+		pagesDone := int64(end - start)
+		m.update(id, func(job *types.ExportJob) {
+			job.PagesDone += pagesDone
+			job.MissingPages += missing
+			job.BytesWritten += written
+		}, false)
+	}
+
+	return nil
+}
+
+// update applies fn to a job under the lock, optionally persisting it
+func (m *Manager) update(id string, fn func(job *types.ExportJob), save bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return
+	}
+	fn(job)
+	if save {
+		m.saveJobLocked(job)
+	}
+}
+
+// saveJobLocked writes the job metadata to disk; m.mu must be held
+func (m *Manager) saveJobLocked(job *types.ExportJob) {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		log.Printf("Warning: failed to marshal export %s: %v", job.ID, err)
+		return
+	}
+	jobFile := filepath.Join(m.exportsDir, job.ID+".json")
+	if err := os.WriteFile(jobFile, data, 0644); err != nil {
+		log.Printf("Warning: failed to save export %s: %v", job.ID, err)
+	}
+}
+
+// loadJobs loads job metadata from disk. Jobs that were running when the
+// server stopped are marked failed and their partial output removed.
+func (m *Manager) loadJobs() error {
+	entries, err := os.ReadDir(m.exportsDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if filepath.Ext(name) == ".tmp" {
+			os.Remove(filepath.Join(m.exportsDir, name))
+			continue
+		}
+		if filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.exportsDir, name))
+		if err != nil {
+			continue // Skip unreadable jobs
+		}
+		var job types.ExportJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			continue // Skip invalid jobs
+		}
+
+		if job.State == StatePending || job.State == StateRunning {
+			now := time.Now()
+			job.State = StateFailed
+			job.Error = "interrupted by page server restart"
+			job.FinishedAt = &now
+			m.saveJobLocked(&job)
+		}
+		m.jobs[job.ID] = &job
+	}
+
+	return nil
+}
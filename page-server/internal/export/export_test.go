@@ -0,0 +1,270 @@
+package export
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/cache"
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// testPage returns a page whose contents identify its space, number and LSN
+func testPage(spaceID, pageNo uint32, lsn uint64) []byte {
+	page := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint32(page[innodb.FilPageOffset:], pageNo)
+	binary.BigEndian.PutUint64(page[innodb.FilPageLSN:], lsn)
+	binary.BigEndian.PutUint32(page[innodb.FilPageSpaceID:], spaceID)
+	return page
+}
+
+// storeSpace stores a space of n pages at LSN 10. Page 1 is rewritten at LSN
+// 30, after the snapshot, and page 2 is never written.
+func storeSpace(backend *storage.FileStorage, spaceID uint32, n uint32) {
+	page0 := testPage(spaceID, 0, 10)
+	innodb.PutFSPHeader(page0, innodb.FSPHeader{SpaceID: spaceID, Size: n, FreeLimit: n, Flags: 0x15})
+	backend.StorePage(spaceID, 0, 10, page0)
+	for pageNo := uint32(1); pageNo < n; pageNo++ {
+		if pageNo != 2 {
+			backend.StorePage(spaceID, pageNo, 10, testPage(spaceID, pageNo, 10))
+		}
+	}
+	backend.StorePage(spaceID, 1, 30, testPage(spaceID, 1, 30))
+}
+
+// checkSpace verifies the exported file holds the space as of LSN 20
+func checkSpace(t *testing.T, data []byte, spaceID uint32, n uint32) {
+	t.Helper()
+	if len(data) != int(n)*innodb.DefaultPageSize {
+		t.Fatalf("space %d: %d bytes, want %d pages", spaceID, len(data), n)
+	}
+	for pageNo := uint32(0); pageNo < n; pageNo++ {
+		page := data[int(pageNo)*innodb.DefaultPageSize : int(pageNo+1)*innodb.DefaultPageSize]
+		if pageNo == 2 {
+			if string(page) != string(make([]byte, innodb.DefaultPageSize)) {
+				t.Fatalf("space %d: unwritten page 2 is not zero", spaceID)
+			}
+			continue
+		}
+		if got := binary.BigEndian.Uint32(page[innodb.FilPageOffset:]); got != pageNo {
+			t.Fatalf("space %d: page %d holds page %d", spaceID, pageNo, got)
+		}
+		if lsn := binary.BigEndian.Uint64(page[innodb.FilPageLSN:]); lsn != 10 {
+			t.Fatalf("space %d: page %d at LSN %d, want the version before the snapshot", spaceID, pageNo, lsn)
+		}
+	}
+	header, err := innodb.ParseFSPHeader(data[:innodb.DefaultPageSize])
+	if err != nil || header.SpaceID != spaceID || header.Size != n {
+		t.Fatalf("space %d: FSP header %+v, %v", spaceID, header, err)
+	}
+}
+
+// wait polls a job until it finishes
+func wait(t *testing.T, m *Manager, id string) *types.ExportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if job.State == StateCompleted || job.State == StateFailed {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("export %s did not finish", id)
+	return nil
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	backend, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	storeSpace(backend, 5, 4)
+	storeSpace(backend, 6, 40) // More than one fetch window
+	load := func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+		return backend.LoadPage(spaceID, pageNo, lsn)
+	}
+
+	dir := t.TempDir()
+	m, err := NewManager(dir, load, backend, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	snapshot := &types.Snapshot{ID: "snap", LSN: 20, Timestamp: time.Unix(1_700_000_000, 0)}
+
+	// A single space as an .ibd file
+	spaceID := uint32(5)
+	job, err := m.Start(snapshot, types.CreateExportRequest{SpaceID: &spaceID})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if job.Format != FormatIBD {
+		t.Fatalf("default format for one space: %s", job.Format)
+	}
+	job = wait(t, m, job.ID)
+	if job.State != StateCompleted || job.PagesTotal != 4 || job.PagesDone != 4 || job.MissingPages != 1 {
+		t.Fatalf("ibd export: %+v", job)
+	}
+	path, err := m.LocalFile(job.ID)
+	if err != nil {
+		t.Fatalf("LocalFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	checkSpace(t, data, 5, 4)
+
+	// Every space as a tar
+	job, err = m.Start(snapshot, types.CreateExportRequest{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	job = wait(t, m, job.ID)
+	if job.State != StateCompleted || job.PagesTotal != 44 || job.MissingPages != 2 || len(job.SpaceIDs) != 2 {
+		t.Fatalf("tar export: %+v", job)
+	}
+	path, _ = m.LocalFile(job.ID)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	entries := map[string]struct{ spaceID, pages uint32 }{
+		"space_5.ibd": {5, 4},
+		"space_6.ibd": {6, 40},
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		entry, ok := entries[hdr.Name]
+		if !ok {
+			t.Fatalf("unexpected tar entry %s", hdr.Name)
+		}
+		delete(entries, hdr.Name)
+		if !hdr.ModTime.Equal(snapshot.Timestamp) {
+			t.Fatalf("%s: mod time %v", hdr.Name, hdr.ModTime)
+		}
+		data, _ := io.ReadAll(tr)
+		checkSpace(t, data, entry.spaceID, entry.pages)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("missing tar entries: %v", entries)
+	}
+
+	// Finished jobs survive a restart
+	m.Close()
+	reopened, err := NewManager(dir, load, backend, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if jobs := reopened.List(); len(jobs) != 2 || jobs[0].State != StateCompleted {
+		t.Fatalf("jobs after restart: %+v", jobs)
+	}
+}
+
+func TestExportStampsRebuiltPages(t *testing.T) {
+	for _, flags := range []uint32{0x15, 0} { // full_crc32 and crc32
+		backend := storage.NewMemoryStorage()
+		wp := wal.NewWALProcessor(backend, cache.NewPageCache(10), wal.ApplyOptions{})
+		defer wp.Close()
+
+		// Every page is rebuilt by the WAL processor at LSN 20 on top of the
+		// version at LSN 10
+		const spaceID, n = 7, 4
+		for pageNo := uint32(0); pageNo < n; pageNo++ {
+			page := testPage(spaceID, pageNo, 10)
+			if pageNo == 0 {
+				innodb.PutFSPHeader(page, innodb.FSPHeader{SpaceID: spaceID, Size: n, FreeLimit: n, Flags: flags})
+			}
+			page = innodb.StampPage(page, pageNo, 10, innodb.IsFullCRC32(flags))
+			backend.StorePage(spaceID, pageNo, 10, page)
+
+			rebuilt, err := wp.Redo(page, []byte{0xff}, 20)
+			if err != nil {
+				t.Fatalf("Redo: %v", err)
+			}
+			if innodb.VerifyChecksum(rebuilt, innodb.IsFullCRC32(flags)) == nil {
+				t.Fatal("rebuilt page verifies before export")
+			}
+			backend.StorePage(spaceID, pageNo, 20, rebuilt)
+		}
+
+		load := func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+			return backend.LoadPage(spaceID, pageNo, lsn)
+		}
+		m, err := NewManager(t.TempDir(), load, backend, nil)
+		if err != nil {
+			t.Fatalf("NewManager: %v", err)
+		}
+		defer m.Close()
+		id := uint32(spaceID)
+		job, err := m.Start(&types.Snapshot{ID: "snap", LSN: 25}, types.CreateExportRequest{SpaceID: &id})
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		if job = wait(t, m, job.ID); job.State != StateCompleted {
+			t.Fatalf("export: %+v", job)
+		}
+		path, _ := m.LocalFile(job.ID)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+
+		for pageNo := uint32(0); pageNo < n; pageNo++ {
+			page := data[int(pageNo)*innodb.DefaultPageSize : int(pageNo+1)*innodb.DefaultPageSize]
+			if err := innodb.VerifyChecksum(page, innodb.IsFullCRC32(flags)); err != nil {
+				t.Fatalf("flags %#x, page %d: %v", flags, pageNo, err)
+			}
+			if innodb.PageNo(page) != pageNo || innodb.PageLSN(page) != 20 {
+				t.Fatalf("flags %#x: page %d has number %d and LSN %d", flags, pageNo, innodb.PageNo(page), innodb.PageLSN(page))
+			}
+		}
+	}
+}
+
+func TestExportValidation(t *testing.T) {
+	m, err := NewManager(t.TempDir(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	snapshot := &types.Snapshot{ID: "snap", LSN: 20}
+	spaceID := uint32(5)
+
+	tests := []struct {
+		name string
+		req  types.CreateExportRequest
+	}{
+		{"unknown format", types.CreateExportRequest{SpaceID: &spaceID, Format: "zip"}},
+		{"ibd without a space", types.CreateExportRequest{Format: FormatIBD}},
+		{"s3 without object storage", types.CreateExportRequest{SpaceID: &spaceID, Destination: DestinationS3}},
+		{"unknown destination", types.CreateExportRequest{SpaceID: &spaceID, Destination: "ftp"}},
+		{"all spaces without a lister", types.CreateExportRequest{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Start(snapshot, tt.req); err == nil {
+				t.Fatal("Start succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+// Package gc removes page versions that no reader can request anymore.
+//
+// Every version newer than the cutoff (latest LSN minus the horizon) is kept,
+// so time-travel within the horizon keeps working. Below the cutoff, a page
+// keeps only the newest version at or below each pinned LSN (snapshots,
+// running exports) and at or below the cutoff itself; the rest are deleted.
+// The cutoff is saved before anything below it is deleted, so pins below
+// it are still refused after a restart.
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// ErrBelowCutoff is returned by Protect for LSNs whose page versions may already be collected
+var ErrBelowCutoff = errors.New("lsn is below the GC cutoff")
+
+// Stats describes the collector state and the last run
+type Stats struct {
+	Runs            int64     `json:"runs"`
+	Cutoff          uint64    `json:"cutoff"`
+	Horizon         uint64    `json:"horizon"`
+	LastRun         time.Time `json:"last_run,omitempty"`
+	LastDuration    string    `json:"last_duration,omitempty"`
+	LastPins        int       `json:"last_pins"`
+	VersionsScanned int64     `json:"versions_scanned"` // Last run
+	VersionsDeleted int64     `json:"versions_deleted"` // Last run
+	TotalDeleted    int64     `json:"total_deleted"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// collectorState is the state file
+type collectorState struct {
+	Cutoff uint64 `json:"cutoff"`
+}
+
+// Collector garbage collects old page versions
+type Collector struct {
+	storage   storage.VersionedStorage
+	latestLSN func() uint64
+	pins      func() []uint64
+	horizon   uint64
+	interval  time.Duration
+	statePath string // Cutoff, kept across restarts; not saved if empty
+
+	// mu orders cutoff changes against new pins (see Protect)
+	mu     sync.RWMutex
+	cutoff uint64
+
+	runMu   sync.Mutex // One run at a time
+	statsMu sync.Mutex
+	stats   Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCollector creates a collector and loads the cutoff saved at statePath.
+// pins returns the LSNs that must stay readable; horizon is the LSN distance
+// behind the latest LSN that is kept in full.
+func NewCollector(backend storage.VersionedStorage, latestLSN func() uint64, pins func() []uint64, horizon uint64, interval time.Duration, statePath string) (*Collector, error) {
+	c := &Collector{
+		storage:   backend,
+		latestLSN: latestLSN,
+		pins:      pins,
+		horizon:   horizon,
+		interval:  interval,
+		statePath: statePath,
+		stats:     Stats{Horizon: horizon},
+	}
+	if statePath == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(statePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read GC state: %w", err)
+	default:
+		var state collectorState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse GC state %s: %w", statePath, err)
+		}
+		c.cutoff = state.Cutoff
+		c.stats.Cutoff = state.Cutoff
+	}
+	return c, nil
+}
+
+// saveCutoff writes the state file
+func (c *Collector) saveCutoff(cutoff uint64) error {
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(collectorState{Cutoff: cutoff})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(c.statePath), "."+filepath.Base(c.statePath)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save GC state: %w", err)
+	}
+	if err := os.Rename(tmp, c.statePath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save GC state: %w", err)
+	}
+	return nil
+}
+
+// Start runs the collector every interval until Stop is called
+func (c *Collector) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					select {
+					case <-c.stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				if _, err := c.Run(ctx); err != nil {
+					log.Printf("Warning: GC run failed: %v", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop, interrupting a run in progress
+func (c *Collector) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+}
+
+// Cutoff returns the LSN below which page versions may have been collected
+func (c *Collector) Cutoff() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cutoff
+}
+
+// Protect runs fn, which must register a pin for lsn (e.g. create a snapshot),
+// unless lsn is already below the cutoff. A run that starts later sees the pin.
+func (c *Collector) Protect(lsn uint64, fn func() error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if lsn < c.cutoff {
+		return fmt.Errorf("%w: lsn %d < cutoff %d", ErrBelowCutoff, lsn, c.cutoff)
+	}
+	return fn()
+}
+
+// Stats returns a copy of the collector statistics
+func (c *Collector) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// Run performs one collection pass and returns its statistics
+func (c *Collector) Run(ctx context.Context) (Stats, error) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	start := time.Now()
+	scanned, deleted, pins, err := c.collect(ctx)
+
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.stats.Runs++
+	c.stats.Cutoff = c.Cutoff()
+	c.stats.LastRun = start
+	c.stats.LastDuration = time.Since(start).Round(time.Millisecond).String()
+	c.stats.LastPins = pins
+	c.stats.VersionsScanned = scanned
+	c.stats.VersionsDeleted = deleted
+	c.stats.TotalDeleted += deleted
+	c.stats.LastError = ""
+	if err != nil {
+		c.stats.LastError = err.Error()
+	}
+
+	if deleted > 0 {
+		log.Printf("GC: deleted %d of %d page versions (cutoff %d, %d pins)", deleted, scanned, c.stats.Cutoff, pins)
+	}
+	return c.stats, err
+}
+
+// collect advances the cutoff and deletes unreachable versions
+func (c *Collector) collect(ctx context.Context) (scanned, deleted int64, pinCount int, err error) {
+	latest := c.latestLSN()
+	if latest <= c.horizon {
+		return 0, 0, 0, nil
+	}
+
+	// Advance the cutoff and snapshot the pins together, so a pin created
+	// after this point is at or above the cutoff and therefore safe. The
+	// new cutoff is saved first; nothing is deleted if that fails.
+	c.mu.Lock()
+	if cutoff := latest - c.horizon; cutoff > c.cutoff {
+		if err := c.saveCutoff(cutoff); err != nil {
+			c.mu.Unlock()
+			return 0, 0, 0, err
+		}
+		c.cutoff = cutoff
+	}
+	cutoff := c.cutoff
+	pins := c.pins()
+	c.mu.Unlock()
+
+	// Read points below the cutoff, newest first
+	points := []uint64{cutoff}
+	for _, pin := range pins {
+		if pin < cutoff {
+			points = append(points, pin)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] > points[j] })
+
+	spaces, err := c.storage.ListSpaces()
+	if err != nil {
+		return 0, 0, len(pins), err
+	}
+
+	for _, spaceID := range spaces {
+		if err := ctx.Err(); err != nil {
+			return scanned, deleted, len(pins), err
+		}
+
+		versions, err := c.storage.ListPageVersions(spaceID)
+		if err != nil {
+			return scanned, deleted, len(pins), err
+		}
+
+		for pageNo, lsns := range versions {
+			scanned += int64(len(lsns))
+			for _, lsn := range collectable(lsns, cutoff, points) {
+				if err := c.storage.DeletePageVersion(spaceID, pageNo, lsn); err != nil {
+					return scanned, deleted, len(pins), fmt.Errorf("failed to delete space=%d page=%d lsn=%d: %w", spaceID, pageNo, lsn, err)
+				}
+				deleted++
+			}
+		}
+	}
+
+	return scanned, deleted, len(pins), nil
+}
+
+// collectable returns the versions of one page that no read point needs.
+// points must be sorted newest first and include the cutoff.
+func collectable(lsns []uint64, cutoff uint64, points []uint64) []uint64 {
+	sorted := append([]uint64(nil), lsns...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	keep := make(map[uint64]bool)
+	for _, point := range points {
+		// Newest version at or below the point
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i] > point })
+		if i > 0 {
+			keep[sorted[i-1]] = true
+		}
+	}
+
+	var garbage []uint64
+	for _, lsn := range sorted {
+		if lsn <= cutoff && !keep[lsn] {
+			garbage = append(garbage, lsn)
+		}
+	}
+	return garbage
+}
@@ -0,0 +1,181 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/snapshots"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+func TestCollectable(t *testing.T) {
+	tests := []struct {
+		name   string
+		lsns   []uint64
+		cutoff uint64
+		pins   []uint64
+		want   []uint64
+	}{
+		{"above the cutoff", []uint64{80, 90}, 70, nil, nil},
+		{"newest below the cutoff kept", []uint64{10, 20, 30, 80}, 70, nil, []uint64{10, 20}},
+		{"pinned version kept", []uint64{10, 20, 30, 80}, 70, []uint64{25}, []uint64{10}},
+		{"pin on a version", []uint64{10, 20, 30}, 70, []uint64{20}, []uint64{10}},
+		{"pin before the first version", []uint64{10, 20, 30}, 70, []uint64{5}, []uint64{10, 20}},
+		{"unsorted input", []uint64{30, 10, 20}, 70, []uint64{15}, []uint64{20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := append([]uint64{tt.cutoff}, tt.pins...)
+			sort.Slice(points, func(i, j int) bool { return points[i] > points[j] })
+			if got := collectable(tt.lsns, tt.cutoff, points); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("collectable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// versions returns the stored LSNs of page 0 of space 1
+func versions(t *testing.T, backend *storage.FileStorage) []uint64 {
+	t.Helper()
+	pages, err := backend.ListPageVersions(1)
+	if err != nil {
+		t.Fatalf("ListPageVersions: %v", err)
+	}
+	lsns := pages[0]
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	return lsns
+}
+
+func TestGCKeepsSnapshotVersions(t *testing.T) {
+	backend, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	for lsn := uint64(10); lsn <= 100; lsn += 10 {
+		backend.StorePage(1, 0, lsn, []byte{byte(lsn)})
+	}
+	sm, err := snapshots.NewSnapshotManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSnapshotManager: %v", err)
+	}
+	snapshot, err := sm.CreateSnapshot(25, "before migration", nil, 0)
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+
+	latest := uint64(100)
+	c, err := NewCollector(backend, func() uint64 { return latest }, sm.PinnedLSNs, 30, time.Hour, "")
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+	stats, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Cutoff 70: the snapshot needs 20, reads at the cutoff need 70
+	if got, want := versions(t, backend), []uint64{20, 70, 80, 90, 100}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("versions after GC: %v, want %v", got, want)
+	}
+	if stats.Cutoff != 70 || stats.LastPins != 1 || stats.VersionsScanned != 10 || stats.VersionsDeleted != 5 {
+		t.Fatalf("stats: %+v", stats)
+	}
+	if data, lsn, err := backend.LoadPage(1, 0, snapshot.LSN); err != nil || lsn != 20 || data[0] != 20 {
+		t.Fatalf("read at the snapshot: lsn=%d err=%v", lsn, err)
+	}
+
+	// New pins below the cutoff are refused; at or above it they are safe
+	if err := c.Protect(60, func() error { return nil }); !errors.Is(err, ErrBelowCutoff) {
+		t.Fatalf("Protect below the cutoff: %v", err)
+	}
+	if err := c.Protect(70, func() error { _, err := sm.CreateSnapshot(70, "", nil, 0); return err }); err != nil {
+		t.Fatalf("Protect at the cutoff: %v", err)
+	}
+
+	// Once the first snapshot is deleted its version can go
+	if err := sm.DeleteSnapshot(snapshot.ID); err != nil {
+		t.Fatalf("DeleteSnapshot: %v", err)
+	}
+	latest = 120
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got, want := versions(t, backend), []uint64{70, 90, 100}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("versions after the snapshot was deleted: %v, want %v", got, want)
+	}
+	if c.Stats().TotalDeleted != 7 {
+		t.Fatalf("stats: %+v", c.Stats())
+	}
+}
+
+func TestCutoffSurvivesRestart(t *testing.T) {
+	backend, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	for lsn := uint64(10); lsn <= 100; lsn += 10 {
+		backend.StorePage(1, 0, lsn, []byte{byte(lsn)})
+	}
+	statePath := filepath.Join(t.TempDir(), "gc_state.json")
+	latest := func() uint64 { return 100 }
+	noPins := func() []uint64 { return nil }
+
+	c, err := NewCollector(backend, latest, noPins, 30, time.Hour, statePath)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// After a restart with a larger horizon, LSN 50 is within the horizon
+	// but its version was collected
+	c, err = NewCollector(backend, latest, noPins, 60, time.Hour, statePath)
+	if err != nil {
+		t.Fatalf("NewCollector after restart: %v", err)
+	}
+	if c.Cutoff() != 70 || c.Stats().Cutoff != 70 {
+		t.Fatalf("cutoff after restart: %d", c.Cutoff())
+	}
+	if err := c.Protect(50, func() error { return nil }); !errors.Is(err, ErrBelowCutoff) {
+		t.Fatalf("Protect below the saved cutoff: %v", err)
+	}
+	if _, err := c.Run(context.Background()); err != nil || c.Cutoff() != 70 {
+		t.Fatalf("Run after restart: cutoff %d, %v", c.Cutoff(), err)
+	}
+
+	// A corrupt state file is an error, not a zero cutoff
+	if err := os.WriteFile(statePath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCollector(backend, latest, noPins, 30, time.Hour, statePath); err == nil {
+		t.Fatal("NewCollector with a corrupt state file succeeded")
+	}
+}
+
+func TestNothingDeletedWhenCutoffCannotBeSaved(t *testing.T) {
+	backend, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	for lsn := uint64(10); lsn <= 100; lsn += 10 {
+		backend.StorePage(1, 0, lsn, []byte{byte(lsn)})
+	}
+	statePath := filepath.Join(t.TempDir(), "missing", "gc_state.json")
+	c, err := NewCollector(backend, func() uint64 { return 100 }, func() []uint64 { return nil }, 30, time.Hour, statePath)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+	if _, err := c.Run(context.Background()); err == nil {
+		t.Fatal("Run succeeded without saving the cutoff")
+	}
+	if got := versions(t, backend); len(got) != 10 || c.Cutoff() != 0 {
+		t.Fatalf("versions %v, cutoff %d", got, c.Cutoff())
+	}
+}
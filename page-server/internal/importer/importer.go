@@ -0,0 +1,543 @@
+// Package importer loads the tablespaces of a stopped MariaDB data directory
+// (ibdata*, undo* and .ibd files) into page storage as initial page images at
+// a base LSN. Pages are checksummed, written in parallel, and progress is kept
+// in a state file so an interrupted import resumes where it stopped.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// Import states
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+)
+
+const (
+	// DefaultParallelism is used when Options.Parallelism is not set
+	DefaultParallelism = 8
+	// chunkPages is the unit of work and of resume progress
+	chunkPages = 256
+)
+
+var (
+	systemFilePattern = regexp.MustCompile(`^ibdata(\d+)$`)
+	undoFilePattern   = regexp.MustCompile(`^undo\d+$`)
+)
+
+// Target stores imported pages
+type Target interface {
+	StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error
+}
+
+// Options configures an import
+type Options struct {
+	SourceDir       string
+	BaseLSN         uint64 // If 0, the flushed LSN of ibdata1
+	Parallelism     int
+	IgnoreChecksums bool   // Store pages with bad checksums instead of failing
+	StatePath       string // Progress file; empty disables resuming
+}
+
+// Space is a tablespace found in the source directory
+type Space struct {
+	Header innodb.FSPHeader
+	Name   string // Path relative to the source directory
+}
+
+// spaceFile is one data file; the system tablespace may span several
+type spaceFile struct {
+	path      string
+	rel       string
+	spaceID   uint32
+	firstPage uint32 // Page number of the first page in the file
+	pages     uint32
+	pageSize  int
+	fullCRC32 bool
+}
+
+// state is the persisted progress of an import
+type state struct {
+	SourceDir string            `json:"source_dir"`
+	BaseLSN   uint64            `json:"base_lsn"`
+	Done      map[string]uint32 `json:"done"` // Pages imported from the start of each file
+	Completed bool              `json:"completed"`
+}
+
+// Importer imports one data directory
+type Importer struct {
+	opts   Options
+	target Target
+	files  []*spaceFile
+	spaces []Space
+
+	state   *state
+	pending map[string]map[uint32]bool // Chunks done out of order, per file
+	stateMu sync.Mutex
+
+	status   types.ImportStatus
+	statusMu sync.Mutex
+
+	pagesDone      int64
+	pagesSkipped   int64
+	checksumErrors int64
+}
+
+// New scans the source directory and loads the progress of an earlier run
+// with the same source and base LSN
+func New(opts Options, target Target) (*Importer, error) {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultParallelism
+	}
+	im := &Importer{
+		opts:    opts,
+		target:  target,
+		pending: make(map[string]map[uint32]bool),
+	}
+
+	if err := im.scan(); err != nil {
+		return nil, err
+	}
+	if im.opts.BaseLSN == 0 {
+		lsn, err := im.flushLSN()
+		if err != nil {
+			return nil, err
+		}
+		im.opts.BaseLSN = lsn
+	}
+	if err := im.loadState(); err != nil {
+		return nil, err
+	}
+
+	var total, done int64
+	for _, f := range im.files {
+		total += int64(f.pages)
+		done += int64(im.state.Done[f.rel])
+	}
+	im.pagesDone = done
+	im.status = types.ImportStatus{
+		State:      StateRunning,
+		SourceDir:  opts.SourceDir,
+		BaseLSN:    im.opts.BaseLSN,
+		Resumed:    done > 0,
+		Files:      len(im.files),
+		Spaces:     len(im.spaces),
+		PagesTotal: total,
+		StartedAt:  time.Now().UTC(),
+	}
+	return im, nil
+}
+
+// BaseLSN returns the LSN the pages are imported at
+func (im *Importer) BaseLSN() uint64 {
+	return im.opts.BaseLSN
+}
+
+// Spaces returns the tablespaces in the source directory
+func (im *Importer) Spaces() []Space {
+	return im.spaces
+}
+
+// Completed reports whether an earlier run already imported everything
+func (im *Importer) Completed() bool {
+	im.stateMu.Lock()
+	defer im.stateMu.Unlock()
+	return im.state.Completed
+}
+
+// Status returns a copy of the current progress
+func (im *Importer) Status() types.ImportStatus {
+	im.statusMu.Lock()
+	status := im.status
+	im.statusMu.Unlock()
+
+	status.PagesDone = atomic.LoadInt64(&im.pagesDone)
+	status.PagesSkipped = atomic.LoadInt64(&im.pagesSkipped)
+	status.ChecksumErrors = atomic.LoadInt64(&im.checksumErrors)
+	return status
+}
+
+// Run imports every page not imported by an earlier run
+func (im *Importer) Run(ctx context.Context) error {
+	err := im.run(ctx)
+
+	im.statusMu.Lock()
+	now := time.Now().UTC()
+	im.status.FinishedAt = &now
+	if err != nil {
+		im.status.State = StateFailed
+		im.status.Error = err.Error()
+	} else {
+		im.status.State = StateCompleted
+	}
+	im.statusMu.Unlock()
+	return err
+}
+
+func (im *Importer) run(ctx context.Context) error {
+	if im.Completed() {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type chunk struct {
+		file  *spaceFile
+		index uint32
+	}
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		for _, f := range im.files {
+			first := im.state.Done[f.rel] / chunkPages
+			for index := first; index*chunkPages < f.pages; index++ {
+				select {
+				case chunks <- chunk{file: f, index: index}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < im.opts.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if err := im.importChunk(c.file, c.index); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	im.stateMu.Lock()
+	defer im.stateMu.Unlock()
+	im.state.Completed = true
+	return im.saveState()
+}
+
+// importChunk reads, verifies and stores one chunk of a file
+func (im *Importer) importChunk(f *spaceFile, index uint32) error {
+	start := index * chunkPages
+	n := f.pages - start
+	if n > chunkPages {
+		n = chunkPages
+	}
+	// Pages before the resume point were imported by an earlier run
+	skip := uint32(0)
+	if done := im.doneOf(f.rel); done > start {
+		skip = done - start
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.rel, err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, int(n)*f.pageSize)
+	if _, err := file.ReadAt(buf, int64(start)*int64(f.pageSize)); err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.rel, err)
+	}
+
+	for i := skip; i < n; i++ {
+		pageNo := f.firstPage + start + i
+		page := buf[int(i)*f.pageSize : int(i+1)*f.pageSize]
+
+		if innodb.IsZeroPage(page) {
+			atomic.AddInt64(&im.pagesSkipped, 1)
+			atomic.AddInt64(&im.pagesDone, 1)
+			continue
+		}
+		if err := innodb.VerifyChecksum(page, f.fullCRC32); err != nil {
+			if !im.opts.IgnoreChecksums {
+				return fmt.Errorf("%s: space %d: %w", f.rel, f.spaceID, err)
+			}
+			atomic.AddInt64(&im.checksumErrors, 1)
+			log.Printf("Warning: Importing %s page %d despite bad checksum: %v", f.rel, pageNo, err)
+		}
+
+		data := make([]byte, len(page))
+		copy(data, page)
+		if err := im.target.StorePage(f.spaceID, pageNo, im.opts.BaseLSN, data); err != nil {
+			return fmt.Errorf("failed to store space %d page %d: %w", f.spaceID, pageNo, err)
+		}
+		atomic.AddInt64(&im.pagesDone, 1)
+	}
+
+	return im.chunkDone(f, index)
+}
+
+// doneOf returns the number of pages imported from the start of a file
+func (im *Importer) doneOf(rel string) uint32 {
+	im.stateMu.Lock()
+	defer im.stateMu.Unlock()
+	return im.state.Done[rel]
+}
+
+// chunkDone records a finished chunk and advances the file's resume point
+// past every chunk finished in order
+func (im *Importer) chunkDone(f *spaceFile, index uint32) error {
+	im.stateMu.Lock()
+	defer im.stateMu.Unlock()
+
+	pending := im.pending[f.rel]
+	if pending == nil {
+		pending = make(map[uint32]bool)
+		im.pending[f.rel] = pending
+	}
+	pending[index] = true
+
+	done := im.state.Done[f.rel]
+	advanced := false
+	for pending[done/chunkPages] {
+		delete(pending, done/chunkPages)
+		done = (done/chunkPages + 1) * chunkPages
+		advanced = true
+	}
+	if done > f.pages {
+		done = f.pages
+	}
+	if !advanced {
+		return nil
+	}
+	im.state.Done[f.rel] = done
+	return im.saveState()
+}
+
+// scan finds the data files of the source directory and reads their FSP headers
+func (im *Importer) scan() error {
+	var system []*spaceFile
+	var systemOrder []int
+
+	err := filepath.WalkDir(im.opts.SourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(im.opts.SourceDir, path)
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+
+		switch {
+		case systemFilePattern.MatchString(name) && filepath.Dir(rel) == ".":
+			n, _ := strconv.Atoi(systemFilePattern.FindStringSubmatch(name)[1])
+			system = append(system, &spaceFile{path: path, rel: rel})
+			systemOrder = append(systemOrder, n)
+		case undoFilePattern.MatchString(name) || filepath.Ext(name) == ".ibd":
+			f, hdr, err := openSpaceFile(path, rel)
+			if err != nil {
+				return err
+			}
+			im.files = append(im.files, f)
+			im.spaces = append(im.spaces, Space{Header: hdr, Name: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", im.opts.SourceDir, err)
+	}
+
+	// ibdata1, ibdata2, ... hold consecutive pages of the system tablespace
+	if len(system) > 0 {
+		sort.Slice(system, func(i, j int) bool { return systemOrder[i] < systemOrder[j] })
+		first, hdr, err := openSpaceFile(system[0].path, system[0].rel)
+		if err != nil {
+			return err
+		}
+		if first.spaceID != innodb.SystemSpaceID {
+			return fmt.Errorf("%s: FSP header names space %d, want the system tablespace", first.rel, first.spaceID)
+		}
+		im.files = append(im.files, first)
+		next := first.pages
+		for _, f := range system[1:] {
+			pages, err := filePages(f.path, first.pageSize)
+			if err != nil {
+				return err
+			}
+			f.spaceID = innodb.SystemSpaceID
+			f.firstPage = next
+			f.pages = pages
+			f.pageSize = first.pageSize
+			f.fullCRC32 = first.fullCRC32
+			im.files = append(im.files, f)
+			next += pages
+		}
+		im.spaces = append(im.spaces, Space{Header: hdr, Name: first.rel})
+	}
+
+	if len(im.files) == 0 {
+		return fmt.Errorf("no InnoDB data files found in %s", im.opts.SourceDir)
+	}
+
+	seen := make(map[uint32]string)
+	for _, space := range im.spaces {
+		if other, ok := seen[space.Header.SpaceID]; ok {
+			return fmt.Errorf("%s and %s both hold space %d", other, space.Name, space.Header.SpaceID)
+		}
+		seen[space.Header.SpaceID] = space.Name
+	}
+	return nil
+}
+
+// openSpaceFile reads the FSP header on the first page of a data file
+func openSpaceFile(path, rel string) (*spaceFile, innodb.FSPHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, innodb.FSPHeader{}, fmt.Errorf("failed to open %s: %w", rel, err)
+	}
+	defer file.Close()
+
+	page := make([]byte, innodb.FSPHeaderEnd)
+	if _, err := file.ReadAt(page, 0); err != nil {
+		return nil, innodb.FSPHeader{}, fmt.Errorf("failed to read FSP header of %s: %w", rel, err)
+	}
+	hdr, err := innodb.ParseFSPHeader(page)
+	if err != nil {
+		return nil, innodb.FSPHeader{}, fmt.Errorf("%s: %w", rel, err)
+	}
+
+	pageSize := hdr.PageSize()
+	pages, err := filePages(path, pageSize)
+	if err != nil {
+		return nil, innodb.FSPHeader{}, err
+	}
+	return &spaceFile{
+		path:      path,
+		rel:       rel,
+		spaceID:   hdr.SpaceID,
+		pages:     pages,
+		pageSize:  pageSize,
+		fullCRC32: innodb.IsFullCRC32(hdr.Flags),
+	}, hdr, nil
+}
+
+// filePages returns the number of whole pages in a file
+func filePages(path string, pageSize int) (uint32, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.Size()%int64(pageSize) != 0 {
+		log.Printf("Warning: %s is not a multiple of the %d-byte page size; ignoring the partial page", path, pageSize)
+	}
+	return uint32(info.Size() / int64(pageSize)), nil
+}
+
+// flushLSN reads the LSN of the last clean shutdown from page 0 of the system tablespace
+func (im *Importer) flushLSN() (uint64, error) {
+	for _, f := range im.files {
+		if f.spaceID != innodb.SystemSpaceID || f.firstPage != 0 {
+			continue
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s: %w", f.rel, err)
+		}
+		defer file.Close()
+
+		page := make([]byte, innodb.FilPageFileFlushLSN+8)
+		if _, err := file.ReadAt(page, 0); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", f.rel, err)
+		}
+		if lsn := innodb.FlushLSN(page); lsn != 0 {
+			return lsn, nil
+		}
+		return 0, fmt.Errorf("%s has no flushed LSN (was the server shut down cleanly?); give a base LSN", f.rel)
+	}
+	return 0, fmt.Errorf("no ibdata1 in %s to read the flushed LSN from; give a base LSN", im.opts.SourceDir)
+}
+
+// loadState resumes the progress of an earlier run of the same import
+func (im *Importer) loadState() error {
+	im.state = &state{
+		SourceDir: im.opts.SourceDir,
+		BaseLSN:   im.opts.BaseLSN,
+		Done:      make(map[string]uint32),
+	}
+	if im.opts.StatePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(im.opts.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read import state: %w", err)
+	}
+
+	var previous state
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return fmt.Errorf("failed to parse import state %s: %w", im.opts.StatePath, err)
+	}
+	if previous.SourceDir != im.opts.SourceDir || previous.BaseLSN != im.opts.BaseLSN {
+		log.Printf("Import state is for %s at LSN %d; starting over", previous.SourceDir, previous.BaseLSN)
+		return nil
+	}
+	if previous.Done == nil {
+		previous.Done = make(map[string]uint32)
+	}
+	im.state = &previous
+	return nil
+}
+
+// saveState writes the progress atomically; the caller holds stateMu
+func (im *Importer) saveState() error {
+	if im.opts.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(im.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode import state: %w", err)
+	}
+	tmp := im.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write import state: %w", err)
+	}
+	if err := os.Rename(tmp, im.opts.StatePath); err != nil {
+		return fmt.Errorf("failed to write import state: %w", err)
+	}
+	return nil
+}
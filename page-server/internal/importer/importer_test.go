@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+const fullCRC32Flags = 0x15 // full_crc32, 16 KiB pages
+
+// newStorage returns file storage in a temporary directory
+func newStorage(t *testing.T) *storage.FileStorage {
+	t.Helper()
+	fs, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	return fs
+}
+
+// sealPage stores the full_crc32 checksum of a page
+func sealPage(page []byte) {
+	size := len(page)
+	binary.BigEndian.PutUint32(page[size-4:], crc32.Checksum(page[:size-4], crc32.MakeTable(crc32.Castagnoli)))
+}
+
+// writeSpace writes a full_crc32 tablespace file of n pages. Page 0 holds the
+// FSP header; the last page is left unwritten (all zeros).
+func writeSpace(t *testing.T, path string, spaceID uint32, n int, flushLSN uint64) []byte {
+	t.Helper()
+	data := make([]byte, n*innodb.DefaultPageSize)
+	for i := 0; i < n-1; i++ {
+		page := data[i*innodb.DefaultPageSize : (i+1)*innodb.DefaultPageSize]
+		binary.BigEndian.PutUint32(page[innodb.FilPageOffset:], uint32(i))
+		binary.BigEndian.PutUint32(page[innodb.FilPageSpaceID:], spaceID)
+		page[innodb.FilPageData+100] = byte(i + 1)
+		if i == 0 {
+			innodb.PutFSPHeader(page, innodb.FSPHeader{SpaceID: spaceID, Size: uint32(n), FreeLimit: uint32(n), Flags: fullCRC32Flags})
+			binary.BigEndian.PutUint64(page[innodb.FilPageFileFlushLSN:], flushLSN)
+		}
+		sealPage(page)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return data
+}
+
+func TestImport(t *testing.T) {
+	src := t.TempDir()
+	writeSpace(t, filepath.Join(src, "ibdata1"), innodb.SystemSpaceID, 2, 5000)
+	orders := writeSpace(t, filepath.Join(src, "shop", "orders.ibd"), 5, 4, 0)
+
+	target := newStorage(t)
+	im, err := New(Options{SourceDir: src, StatePath: filepath.Join(t.TempDir(), "import.json")}, target)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if im.BaseLSN() != 5000 {
+		t.Fatalf("base LSN %d, want the flushed LSN 5000", im.BaseLSN())
+	}
+	if spaces := im.Spaces(); len(spaces) != 2 || spaces[0].Name != filepath.Join("shop", "orders.ibd") || spaces[0].Header.Size != 4 {
+		t.Fatalf("spaces: %+v", spaces)
+	}
+	if err := im.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	status := im.Status()
+	if status.State != StateCompleted || status.PagesTotal != 6 || status.PagesDone != 6 || status.PagesSkipped != 2 || status.ChecksumErrors != 0 {
+		t.Fatalf("status: %+v", status)
+	}
+	for pageNo := uint32(0); pageNo < 3; pageNo++ {
+		data, lsn, err := target.LoadPage(5, pageNo, 5000)
+		want := orders[int(pageNo)*innodb.DefaultPageSize : int(pageNo+1)*innodb.DefaultPageSize]
+		if err != nil || lsn != 5000 || string(data) != string(want) {
+			t.Fatalf("page %d: lsn=%d err=%v", pageNo, lsn, err)
+		}
+	}
+	// Unwritten pages are not stored
+	if _, _, err := target.LoadPage(5, 3, 5000); !errors.Is(err, storage.ErrPageNotFound) {
+		t.Fatalf("zero page: %v", err)
+	}
+
+	// A rerun of a finished import does nothing
+	again, err := New(Options{SourceDir: src, StatePath: im.opts.StatePath}, newStorage(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if !again.Completed() || !again.Status().Resumed {
+		t.Fatalf("rerun: completed=%v status=%+v", again.Completed(), again.Status())
+	}
+}
+
+func TestImportCorruptPage(t *testing.T) {
+	src := t.TempDir()
+	path := filepath.Join(src, "shop", "orders.ibd")
+	data := writeSpace(t, path, 5, 4, 0)
+	data[2*innodb.DefaultPageSize+innodb.FilPageData] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Without a base LSN there must be an ibdata1 to read it from
+	if _, err := New(Options{SourceDir: src}, newStorage(t)); err == nil {
+		t.Fatal("New without ibdata1 or a base LSN succeeded")
+	}
+
+	im, err := New(Options{SourceDir: src, BaseLSN: 100}, newStorage(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := im.Run(context.Background()); !errors.Is(err, innodb.ErrChecksumMismatch) {
+		t.Fatalf("Run = %v, want ErrChecksumMismatch", err)
+	}
+	if status := im.Status(); status.State != StateFailed || status.Error == "" {
+		t.Fatalf("status: %+v", status)
+	}
+
+	target := newStorage(t)
+	im, err = New(Options{SourceDir: src, BaseLSN: 100, IgnoreChecksums: true}, target)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := im.Run(context.Background()); err != nil {
+		t.Fatalf("Run with IgnoreChecksums: %v", err)
+	}
+	if status := im.Status(); status.ChecksumErrors != 1 || status.PagesDone != 4 {
+		t.Fatalf("status: %+v", status)
+	}
+	if _, _, err := target.LoadPage(5, 2, 100); err != nil {
+		t.Fatalf("corrupt page not stored with IgnoreChecksums: %v", err)
+	}
+}
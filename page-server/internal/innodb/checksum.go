@@ -0,0 +1,150 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// ErrChecksumMismatch is returned for pages whose stored checksum does not match their contents
+var ErrChecksumMismatch = errors.New("page checksum mismatch")
+
+// Checksum fields
+const (
+	FilPageSpaceOrChecksum = 0  // Checksum of the original format
+	FilPageFileFlushLSN    = 26 // Flushed LSN, written to page 0 of the system tablespace on shutdown
+	filPageEndLSNOldChksum = 8  // Trailer: old-style checksum and low 32 bits of the LSN
+	filPageFCRC32Checksum  = 4  // full_crc32 trailer: CRC-32C of the rest of the page
+
+	noChecksumMagic = 0xDEADBEEF // innodb_checksum_algorithm=none
+)
+
+// Hash constants of ut_fold_ulint_pair
+const (
+	hashRandomMask  = 1463735687
+	hashRandomMask2 = 1653893711
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// IsFullCRC32 reports whether tablespace flags select the full_crc32 format
+func IsFullCRC32(flags uint32) bool {
+	return flags&fspFlagsFCRC32Marker != 0
+}
+
+// FlushLSN returns the LSN written to page 0 of the system tablespace on a clean shutdown
+func FlushLSN(page []byte) uint64 {
+	if len(page) < FilPageFileFlushLSN+8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(page[FilPageFileFlushLSN:])
+}
+
+// VerifyChecksum checks a page of a tablespace in the full_crc32 format
+// (CRC-32C trailer) or the original format, where MariaDB accepts the crc32,
+// innodb and none algorithms. Pages that were never written (all zeros) are valid.
+func VerifyChecksum(page []byte, fullCRC32 bool) error {
+	if IsZeroPage(page) {
+		return nil
+	}
+	size := len(page)
+	if size < FilPageData+filPageEndLSNOldChksum {
+		return fmt.Errorf("page too short: %d bytes", size)
+	}
+
+	if fullCRC32 {
+		stored := binary.BigEndian.Uint32(page[size-filPageFCRC32Checksum:])
+		if crc32.Checksum(page[:size-filPageFCRC32Checksum], crc32c) != stored {
+			return fmt.Errorf("page %d: %w", PageNo(page), ErrChecksumMismatch)
+		}
+		return nil
+	}
+
+	// The trailer repeats the low 32 bits of the page LSN
+	trailer := page[size-filPageEndLSNOldChksum:]
+	if binary.BigEndian.Uint32(page[FilPageLSN+4:]) != binary.BigEndian.Uint32(trailer[4:]) {
+		return fmt.Errorf("page %d: LSN does not match trailer: %w", PageNo(page), ErrChecksumMismatch)
+	}
+
+	newStored := binary.BigEndian.Uint32(page[FilPageSpaceOrChecksum:])
+	oldStored := binary.BigEndian.Uint32(trailer)
+
+	crc := legacyCRC32(page)
+	newValid := newStored == crc || newStored == noChecksumMagic || newStored == innodbNewChecksum(page)
+	oldValid := oldStored == crc || oldStored == noChecksumMagic || oldStored == innodbOldChecksum(page) ||
+		oldStored == binary.BigEndian.Uint32(page[FilPageLSN:]) // Written by very old versions
+	if !newValid || !oldValid {
+		return fmt.Errorf("page %d: %w", PageNo(page), ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// StampPage returns a copy of page with its page number, LSN and checksum
+// written the way InnoDB writes them when it flushes the page: the LSN in
+// the header and trailer, and the full_crc32 or crc32 checksum. Pages
+// rebuilt by the WAL processor need this before InnoDB can read them.
+func StampPage(page []byte, pageNo uint32, lsn uint64, fullCRC32 bool) []byte {
+	out := make([]byte, len(page))
+	copy(out, page)
+	size := len(out)
+
+	binary.BigEndian.PutUint32(out[FilPageOffset:], pageNo)
+	binary.BigEndian.PutUint64(out[FilPageLSN:], lsn)
+	if fullCRC32 {
+		binary.BigEndian.PutUint32(out[FilPageSpaceOrChecksum:], 0) // Key version of unencrypted pages
+		binary.BigEndian.PutUint32(out[size-filPageEndLSNOldChksum:], uint32(lsn))
+		binary.BigEndian.PutUint32(out[size-filPageFCRC32Checksum:], crc32.Checksum(out[:size-filPageFCRC32Checksum], crc32c))
+		return out
+	}
+
+	binary.BigEndian.PutUint32(out[size-4:], uint32(lsn))
+	crc := legacyCRC32(out)
+	binary.BigEndian.PutUint32(out[FilPageSpaceOrChecksum:], crc)
+	binary.BigEndian.PutUint32(out[size-filPageEndLSNOldChksum:], crc)
+	return out
+}
+
+// legacyCRC32 is the crc32 checksum of the original format: the header
+// after the checksum field and the body before the trailer
+func legacyCRC32(page []byte) uint32 {
+	size := len(page)
+	return crc32.Checksum(page[FilPageOffset:FilPageFileFlushLSN], crc32c) ^
+		crc32.Checksum(page[FilPageData:size-filPageEndLSNOldChksum], crc32c)
+}
+
+// innodbNewChecksum is buf_calc_page_new_checksum
+func innodbNewChecksum(page []byte) uint32 {
+	size := len(page)
+	return uint32(foldBinary(page[FilPageOffset:FilPageFileFlushLSN]) +
+		foldBinary(page[FilPageData:size-filPageEndLSNOldChksum]))
+}
+
+// innodbOldChecksum is buf_calc_page_old_checksum
+func innodbOldChecksum(page []byte) uint32 {
+	return uint32(foldBinary(page[:FilPageFileFlushLSN]))
+}
+
+// foldBinary is ut_fold_binary on a 64-bit build
+func foldBinary(data []byte) uint64 {
+	var fold uint64
+	for _, b := range data {
+		fold = foldPair(fold, uint64(b))
+	}
+	return fold
+}
+
+// foldPair is ut_fold_ulint_pair
+func foldPair(n1, n2 uint64) uint64 {
+	return ((((n1 ^ n2 ^ hashRandomMask2) << 8) + n1) ^ hashRandomMask) + n2
+}
+
+// IsZeroPage reports whether a page was never written (all zeros)
+func IsZeroPage(page []byte) bool {
+	for _, b := range page {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
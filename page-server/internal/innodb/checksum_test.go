@@ -0,0 +1,117 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// testPage returns a 16 KiB page with a header, some contents and the LSN in the trailer
+func testPage() []byte {
+	page := make([]byte, DefaultPageSize)
+	binary.BigEndian.PutUint32(page[FilPageOffset:], 3)
+	binary.BigEndian.PutUint64(page[FilPageLSN:], 0x1_2345_6789)
+	binary.BigEndian.PutUint16(page[FilPageType:], PageTypeIndex)
+	binary.BigEndian.PutUint32(page[FilPageSpaceID:], 5)
+	for i := FilPageData; i < len(page)-filPageEndLSNOldChksum; i++ {
+		page[i] = byte(i * 7)
+	}
+	binary.BigEndian.PutUint32(page[len(page)-4:], 0x2345_6789)
+	return page
+}
+
+// setChecksums stores the checksums of the original format
+func setChecksums(page []byte, newSum, oldSum func([]byte) uint32) {
+	binary.BigEndian.PutUint32(page[FilPageSpaceOrChecksum:], newSum(page))
+	binary.BigEndian.PutUint32(page[len(page)-filPageEndLSNOldChksum:], oldSum(page))
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	fullCRC32 := func() []byte {
+		page := testPage()
+		size := len(page)
+		binary.BigEndian.PutUint32(page[size-4:], crc32.Checksum(page[:size-4], crc32c))
+		return page
+	}
+	crc := func() []byte {
+		page := testPage()
+		setChecksums(page, legacyCRC32, legacyCRC32)
+		return page
+	}
+	innodbSums := func() []byte {
+		page := testPage()
+		setChecksums(page, innodbNewChecksum, innodbOldChecksum)
+		return page
+	}
+	none := func() []byte {
+		page := testPage()
+		magic := func([]byte) uint32 { return noChecksumMagic }
+		setChecksums(page, magic, magic)
+		return page
+	}
+	corrupt := func(page []byte, off int) []byte {
+		page[off] ^= 0x01
+		return page
+	}
+
+	tests := []struct {
+		name      string
+		page      []byte
+		fullCRC32 bool
+		valid     bool
+	}{
+		{"zero page", make([]byte, DefaultPageSize), false, true},
+		{"full_crc32", fullCRC32(), true, true},
+		{"full_crc32 body corrupt", corrupt(fullCRC32(), 1000), true, false},
+		{"full_crc32 header corrupt", corrupt(fullCRC32(), FilPageOffset), true, false},
+		{"crc32", crc(), false, true},
+		{"crc32 body corrupt", corrupt(crc(), 1000), false, false},
+		{"innodb", innodbSums(), false, true},
+		{"innodb body corrupt", corrupt(innodbSums(), 2000), false, false},
+		{"innodb header corrupt", corrupt(innodbSums(), FilPageType), false, false},
+		{"innodb first body byte corrupt", corrupt(innodbSums(), FilPageData), false, false},
+		{"none", none(), false, true},
+		{"trailer LSN mismatch", corrupt(crc(), DefaultPageSize-1), false, false},
+		{"crc32 page read as full_crc32", crc(), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChecksum(tt.page, tt.fullCRC32)
+			if tt.valid && err != nil {
+				t.Fatalf("VerifyChecksum: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("VerifyChecksum = %v, want ErrChecksumMismatch", err)
+			}
+		})
+	}
+
+	if err := VerifyChecksum([]byte{1, 2, 3}, false); err == nil || errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("short page: %v", err)
+	}
+}
+
+func TestStampPage(t *testing.T) {
+	for _, fullCRC32 := range []bool{true, false} {
+		// The WAL processor writes the version LSN little-endian over the
+		// checksum and page number
+		page := testPage()
+		binary.LittleEndian.PutUint64(page[:8], 0x2_0000_0042)
+		if err := VerifyChecksum(page, fullCRC32); err == nil {
+			t.Fatalf("full_crc32=%v: unstamped page verified", fullCRC32)
+		}
+		before := append([]byte(nil), page...)
+
+		stamped := StampPage(page, 3, 0x2_0000_0042, fullCRC32)
+		if err := VerifyChecksum(stamped, fullCRC32); err != nil {
+			t.Fatalf("full_crc32=%v: %v", fullCRC32, err)
+		}
+		if PageNo(stamped) != 3 || PageLSN(stamped) != 0x2_0000_0042 || SpaceID(stamped) != 5 {
+			t.Fatalf("full_crc32=%v: page %d, LSN %#x, space %d", fullCRC32, PageNo(stamped), PageLSN(stamped), SpaceID(stamped))
+		}
+		if string(page) != string(before) {
+			t.Fatalf("full_crc32=%v: StampPage modified its input", fullCRC32)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FIL header offsets not listed in page.go
+const (
+	FilPagePrev     = 8  // Previous page at the same B-tree level
+	FilPageNext     = 12 // Next page at the same B-tree level
+	FilHeaderEnd    = FilPageData
+	FilTrailerBytes = 8
+)
+
+// Page types (FIL_PAGE_TYPE)
+const (
+	PageTypeAllocated      = 0
+	PageTypeUndoLog        = 2
+	PageTypeInode          = 3
+	PageTypeIbufFreeList   = 4
+	PageTypeIbufBitmap     = 5
+	PageTypeSys            = 6
+	PageTypeTrxSys         = 7
+	PageTypeFSPHdr         = 8
+	PageTypeXDES           = 9
+	PageTypeBlob           = 10
+	PageTypeZBlob          = 11
+	PageTypeZBlob2         = 12
+	PageTypeUnknown        = 13
+	PageTypeInstant        = 18
+	PageTypeCompressed     = 34354
+	PageTypeCompressedEncr = 37401
+	PageTypeRTree          = 17854
+	PageTypeIndex          = 17855
+)
+
+var pageTypeNames = map[uint16]string{
+	PageTypeAllocated:      "ALLOCATED",
+	PageTypeUndoLog:        "UNDO_LOG",
+	PageTypeInode:          "INODE",
+	PageTypeIbufFreeList:   "IBUF_FREE_LIST",
+	PageTypeIbufBitmap:     "IBUF_BITMAP",
+	PageTypeSys:            "SYS",
+	PageTypeTrxSys:         "TRX_SYS",
+	PageTypeFSPHdr:         "FSP_HDR",
+	PageTypeXDES:           "XDES",
+	PageTypeBlob:           "BLOB",
+	PageTypeZBlob:          "ZBLOB",
+	PageTypeZBlob2:         "ZBLOB2",
+	PageTypeUnknown:        "UNKNOWN",
+	PageTypeInstant:        "INSTANT",
+	PageTypeCompressed:     "PAGE_COMPRESSED",
+	PageTypeCompressedEncr: "PAGE_COMPRESSED_ENCRYPTED",
+	PageTypeRTree:          "RTREE",
+	PageTypeIndex:          "INDEX",
+}
+
+// PageTypeName returns the name of a page type, or TYPE_<n> for unknown values
+func PageTypeName(t uint16) string {
+	if name, ok := pageTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE_%d", t)
+}
+
+// FilHeader holds the FIL header fields of a page
+type FilHeader struct {
+	Checksum uint32 // Or space ID in the full_crc32 format
+	PageNo   uint32
+	Prev     uint32
+	Next     uint32
+	LSN      uint64
+	Type     uint16
+	FlushLSN uint64 // Only meaningful on page 0 of the system tablespace
+	SpaceID  uint32
+}
+
+// ParseFilHeader reads the FIL header of a page
+func ParseFilHeader(page []byte) (FilHeader, error) {
+	if len(page) < FilHeaderEnd {
+		return FilHeader{}, fmt.Errorf("page too short for FIL header: %d bytes", len(page))
+	}
+	return FilHeader{
+		Checksum: binary.BigEndian.Uint32(page[FilPageSpaceOrChecksum:]),
+		PageNo:   binary.BigEndian.Uint32(page[FilPageOffset:]),
+		Prev:     binary.BigEndian.Uint32(page[FilPagePrev:]),
+		Next:     binary.BigEndian.Uint32(page[FilPageNext:]),
+		LSN:      binary.BigEndian.Uint64(page[FilPageLSN:]),
+		Type:     binary.BigEndian.Uint16(page[FilPageType:]),
+		FlushLSN: binary.BigEndian.Uint64(page[FilPageFileFlushLSN:]),
+		SpaceID:  binary.BigEndian.Uint32(page[FilPageSpaceID:]),
+	}, nil
+}
+
+// FilField names the FIL header or trailer field holding byte off of a page
+// of pageSize bytes, or returns "" for the page body
+func FilField(off, pageSize int) string {
+	switch {
+	case off < 0 || off >= pageSize:
+		return ""
+	case off < FilPageOffset:
+		return "checksum"
+	case off < FilPagePrev:
+		return "page_no"
+	case off < FilPageNext:
+		return "prev"
+	case off < FilPageLSN:
+		return "next"
+	case off < FilPageType:
+		return "lsn"
+	case off < FilPageFileFlushLSN:
+		return "type"
+	case off < FilPageSpaceID:
+		return "flush_lsn"
+	case off < FilHeaderEnd:
+		return "space_id"
+	case off >= pageSize-FilTrailerBytes:
+		return "trailer"
+	}
+	return ""
+}
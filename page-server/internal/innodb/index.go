@@ -0,0 +1,71 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// INDEX page header offsets (relative to FilPageData)
+const (
+	pageNDirSlots  = 0  // Slots in the page directory
+	pageHeapTop    = 2  // First free byte of the heap
+	pageNHeap      = 4  // Records in the heap; the top bit marks the compact format
+	pageFree       = 6  // First record in the free list
+	pageGarbage    = 8  // Bytes in deleted records
+	pageLastInsert = 10 // Last inserted record
+	pageDirection  = 12
+	pageNDirection = 14 // Consecutive inserts in the same direction
+	pageNRecs      = 16 // User records
+	pageMaxTrxID   = 18 // Highest transaction ID that modified a record (secondary indexes)
+	pageLevel      = 26 // 0 for leaf pages
+	pageIndexID    = 28
+
+	// IndexHeaderEnd is the offset just past the INDEX page header
+	IndexHeaderEnd = FilPageData + 36
+
+	pageNHeapCompact = 0x8000
+)
+
+// IndexHeader holds the page header of an INDEX (B-tree) page
+type IndexHeader struct {
+	NDirSlots  uint16
+	HeapTop    uint16
+	NHeap      uint16 // Without the compact flag
+	Compact    bool   // Records use the compact (not redundant) format
+	Free       uint16
+	Garbage    uint16
+	LastInsert uint16
+	Direction  uint16
+	NDirection uint16
+	NRecs      uint16
+	MaxTrxID   uint64
+	Level      uint16
+	IndexID    uint64
+}
+
+// ParseIndexHeader reads the page header of an INDEX page
+func ParseIndexHeader(page []byte) (IndexHeader, error) {
+	if len(page) < IndexHeaderEnd {
+		return IndexHeader{}, fmt.Errorf("page too short for INDEX header: %d bytes", len(page))
+	}
+	if t := PageType(page); t != PageTypeIndex && t != PageTypeRTree {
+		return IndexHeader{}, fmt.Errorf("INDEX header requested from a %s page", PageTypeName(t))
+	}
+	hdr := page[FilPageData:IndexHeaderEnd]
+	nHeap := binary.BigEndian.Uint16(hdr[pageNHeap:])
+	return IndexHeader{
+		NDirSlots:  binary.BigEndian.Uint16(hdr[pageNDirSlots:]),
+		HeapTop:    binary.BigEndian.Uint16(hdr[pageHeapTop:]),
+		NHeap:      nHeap &^ pageNHeapCompact,
+		Compact:    nHeap&pageNHeapCompact != 0,
+		Free:       binary.BigEndian.Uint16(hdr[pageFree:]),
+		Garbage:    binary.BigEndian.Uint16(hdr[pageGarbage:]),
+		LastInsert: binary.BigEndian.Uint16(hdr[pageLastInsert:]),
+		Direction:  binary.BigEndian.Uint16(hdr[pageDirection:]),
+		NDirection: binary.BigEndian.Uint16(hdr[pageNDirection:]),
+		NRecs:      binary.BigEndian.Uint16(hdr[pageNRecs:]),
+		MaxTrxID:   binary.BigEndian.Uint64(hdr[pageMaxTrxID:]),
+		Level:      binary.BigEndian.Uint16(hdr[pageLevel:]),
+		IndexID:    binary.BigEndian.Uint64(hdr[pageIndexID:]),
+	}, nil
+}
@@ -0,0 +1,134 @@
+// Package innodb reads the parts of the InnoDB on-disk page format the page
+// server needs: the FIL page header, the FSP header and extent descriptors,
+// INDEX page headers and records, and undo log headers.
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FIL header offsets (every page)
+const (
+	FilPageOffset  = 4  // Page number
+	FilPageLSN     = 16 // LSN of the newest modification
+	FilPageType    = 24 // Page type
+	FilPageSpaceID = 34 // Tablespace ID
+	FilPageData    = 38 // Start of the page body
+)
+
+// FSP header offsets (page 0, relative to FilPageData)
+const (
+	fspSpaceID   = 0
+	fspSize      = 8  // Tablespace size in pages
+	fspFreeLimit = 12 // First page not yet initialized
+	fspFlags     = 16
+)
+
+// Tablespace flag layout. MariaDB's full_crc32 format keeps the page size in
+// bits 0-3 and sets bit 4; the original format keeps it in bits 6-9.
+const (
+	fspFlagsFCRC32Marker   = 1 << 4
+	fspFlagsFCRC32SSizePos = 0
+	fspFlagsSSizePos       = 6
+	fspFlagsSSizeMask      = 0xF
+)
+
+const (
+	// DefaultPageSize is innodb_page_size when the flags do not say otherwise
+	DefaultPageSize = 16384
+	minPageSize     = 4096
+	maxPageSize     = 65536
+)
+
+// FSPHeader holds the tablespace header stored on page 0
+type FSPHeader struct {
+	SpaceID   uint32
+	Size      uint32 // Pages in the tablespace
+	FreeLimit uint32
+	Flags     uint32
+}
+
+// PageSize returns the physical page size encoded in the flags
+func (h FSPHeader) PageSize() int {
+	return PageSizeFromFlags(h.Flags)
+}
+
+// ParseFSPHeader reads the FSP header from page 0 of a tablespace
+func ParseFSPHeader(page []byte) (FSPHeader, error) {
+	if len(page) < FilPageData+fspFlags+4 {
+		return FSPHeader{}, fmt.Errorf("page too short for FSP header: %d bytes", len(page))
+	}
+	if no := PageNo(page); no != 0 {
+		return FSPHeader{}, fmt.Errorf("FSP header requested from page %d, want page 0", no)
+	}
+	hdr := page[FilPageData:]
+	return FSPHeader{
+		SpaceID:   binary.BigEndian.Uint32(hdr[fspSpaceID:]),
+		Size:      binary.BigEndian.Uint32(hdr[fspSize:]),
+		FreeLimit: binary.BigEndian.Uint32(hdr[fspFreeLimit:]),
+		Flags:     binary.BigEndian.Uint32(hdr[fspFlags:]),
+	}, nil
+}
+
+// PageSizeFromFlags decodes the page size from tablespace flags
+func PageSizeFromFlags(flags uint32) int {
+	var ssize uint32
+	if flags&fspFlagsFCRC32Marker != 0 {
+		ssize = (flags >> fspFlagsFCRC32SSizePos) & fspFlagsSSizeMask
+	} else {
+		ssize = (flags >> fspFlagsSSizePos) & fspFlagsSSizeMask
+	}
+	if ssize == 0 {
+		return DefaultPageSize
+	}
+	size := 512 << ssize
+	if size < minPageSize || size > maxPageSize {
+		return DefaultPageSize
+	}
+	return size
+}
+
+// PageNo returns the page number from the FIL header
+func PageNo(page []byte) uint32 {
+	if len(page) < FilPageOffset+4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(page[FilPageOffset:])
+}
+
+// SpaceID returns the tablespace ID from the FIL header
+func SpaceID(page []byte) uint32 {
+	if len(page) < FilPageSpaceID+4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(page[FilPageSpaceID:])
+}
+
+// PageLSN returns the page LSN from the FIL header
+func PageLSN(page []byte) uint64 {
+	if len(page) < FilPageLSN+8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(page[FilPageLSN:])
+}
+
+// PageType returns the page type from the FIL header
+func PageType(page []byte) uint16 {
+	if len(page) < FilPageType+2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(page[FilPageType:])
+}
+
+// FSPHeaderEnd is the offset just past the FSP header fields in FSPHeader
+const FSPHeaderEnd = FilPageData + fspFlags + 4
+
+// PutFSPHeader writes the FSP header fields to page 0 of a tablespace
+func PutFSPHeader(page []byte, h FSPHeader) {
+	hdr := page[FilPageData:FSPHeaderEnd]
+	binary.BigEndian.PutUint32(hdr[fspSpaceID:], h.SpaceID)
+	binary.BigEndian.PutUint32(hdr[fspSize:], h.Size)
+	binary.BigEndian.PutUint32(hdr[fspFreeLimit:], h.FreeLimit)
+	binary.BigEndian.PutUint32(hdr[fspFlags:], h.Flags)
+}
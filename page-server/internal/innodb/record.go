@@ -0,0 +1,172 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Index page body layout. The INDEX header is followed by the two file
+// segment headers and then the infimum and supremum records.
+const (
+	fsegHeaderSize = 10
+	pageData       = IndexHeaderEnd + 2*fsegHeaderSize
+
+	recNewExtraBytes = 5 // Compact record header
+	recOldExtraBytes = 6 // Redundant record header
+
+	// PageNewInfimum and PageNewSupremum are the offsets of the infimum and
+	// supremum records of a compact page
+	PageNewInfimum  = pageData + recNewExtraBytes
+	PageNewSupremum = PageNewInfimum + 8 + recNewExtraBytes
+
+	// PageOldInfimum and PageOldSupremum are the same for a redundant page
+	PageOldInfimum  = pageData + 1 + recOldExtraBytes
+	PageOldSupremum = pageData + 2 + 2*recOldExtraBytes + 8
+
+	pageDirSlotSize = 2
+)
+
+// Record header bits
+const (
+	recInfoMinRec  = 0x10 // Leftmost record of a non-leaf level
+	recInfoDeleted = 0x20 // Delete-marked
+	recNOwnedMask  = 0x0F
+	recHeapNoShift = 3
+	recStatusMask  = 0x07
+	recOldNFields  = 0x07FE
+	recOld1ByteOff = 0x01
+)
+
+// Record status of the compact format (REC_STATUS_*)
+const (
+	RecStatusOrdinary = 0
+	RecStatusNodePtr  = 1
+	RecStatusInfimum  = 2
+	RecStatusSupremum = 3
+)
+
+var recStatusNames = [...]string{"ordinary", "node_ptr", "infimum", "supremum"}
+
+// RecStatusName returns the name of a record status
+func RecStatusName(status uint8) string {
+	if int(status) < len(recStatusNames) {
+		return recStatusNames[status]
+	}
+	return fmt.Sprintf("STATUS_%d", status)
+}
+
+// RecordHeader holds the header fields stored in front of a record
+type RecordHeader struct {
+	Offset  uint16 // Record origin: the byte after the header
+	HeapNo  uint16 // 0 for the infimum, 1 for the supremum
+	Status  uint8  // Compact pages; derived from the heap number and level on redundant pages
+	Deleted bool
+	MinRec  bool
+	NOwned  uint8  // Records owned by this record's directory slot, 0 if it owns no slot
+	Next    uint16 // Page offset of the next record, 0 for none
+	NFields uint16 // Redundant pages only
+	Short   bool   // Redundant pages only: 1-byte field end offsets
+}
+
+// ParseRecordHeader reads the header of the record at off. Compact pages
+// store the next record as a relative offset, which is resolved here.
+func ParseRecordHeader(page []byte, off int, compact bool) (RecordHeader, error) {
+	extra := recOldExtraBytes
+	if compact {
+		extra = recNewExtraBytes
+	}
+	if off < pageData+extra || off >= len(page)-FilTrailerBytes {
+		return RecordHeader{}, fmt.Errorf("record offset %d outside the page body", off)
+	}
+
+	rec := RecordHeader{Offset: uint16(off)}
+	info := page[off-extra]
+	rec.Deleted = info&recInfoDeleted != 0
+	rec.MinRec = info&recInfoMinRec != 0
+	rec.NOwned = info & recNOwnedMask
+	next := binary.BigEndian.Uint16(page[off-2:])
+
+	if compact {
+		rec.HeapNo = binary.BigEndian.Uint16(page[off-4:]) >> recHeapNoShift
+		rec.Status = page[off-3] & recStatusMask
+		if next != 0 {
+			rec.Next = uint16((off + int(int16(next))) & (len(page) - 1))
+		}
+		return rec, nil
+	}
+
+	rec.HeapNo = binary.BigEndian.Uint16(page[off-5:]) >> recHeapNoShift
+	rec.NFields = (binary.BigEndian.Uint16(page[off-4:]) & recOldNFields) >> 1
+	rec.Short = page[off-3]&recOld1ByteOff != 0
+	rec.Next = next
+	switch rec.HeapNo {
+	case 0:
+		rec.Status = RecStatusInfimum
+	case 1:
+		rec.Status = RecStatusSupremum
+	}
+	return rec, nil
+}
+
+// PageDirectory returns the record offsets stored in the page directory,
+// from the first slot (the infimum) to the last (the supremum)
+func PageDirectory(page []byte, hdr IndexHeader) ([]uint16, error) {
+	n := int(hdr.NDirSlots)
+	end := len(page) - FilTrailerBytes
+	if n == 0 || end-n*pageDirSlotSize < int(hdr.HeapTop) || end-n*pageDirSlotSize < pageData {
+		return nil, fmt.Errorf("page directory of %d slots does not fit the page", n)
+	}
+	slots := make([]uint16, n)
+	for i := range slots {
+		slots[i] = binary.BigEndian.Uint16(page[end-(i+1)*pageDirSlotSize:])
+	}
+	return slots, nil
+}
+
+// Records follows the next pointers from the infimum to the supremum and
+// returns the records in key order, both included. The records read
+// before a broken pointer or a loop are returned with the error.
+func Records(page []byte, hdr IndexHeader) ([]RecordHeader, error) {
+	infimum, supremum := PageOldInfimum, PageOldSupremum
+	if hdr.Compact {
+		infimum, supremum = PageNewInfimum, PageNewSupremum
+	}
+	return walkRecords(page, hdr, infimum, supremum)
+}
+
+// FreeRecords follows the free list of deleted records (PAGE_FREE)
+func FreeRecords(page []byte, hdr IndexHeader) ([]RecordHeader, error) {
+	if hdr.Free == 0 {
+		return nil, nil
+	}
+	return walkRecords(page, hdr, int(hdr.Free), 0)
+}
+
+// walkRecords follows next pointers from start until stop (or the end of
+// the list for stop 0), visiting at most as many records as the heap holds
+func walkRecords(page []byte, hdr IndexHeader, start, stop int) ([]RecordHeader, error) {
+	var recs []RecordHeader
+	seen := make(map[int]bool)
+	for off := start; ; {
+		if seen[off] || len(recs) > int(hdr.NHeap) {
+			return recs, fmt.Errorf("record list loops at offset %d", off)
+		}
+		seen[off] = true
+
+		rec, err := ParseRecordHeader(page, off, hdr.Compact)
+		if err != nil {
+			return recs, err
+		}
+		if !hdr.Compact && rec.HeapNo > 1 && hdr.Level > 0 {
+			rec.Status = RecStatusNodePtr
+		}
+		recs = append(recs, rec)
+		if off == stop || (stop == 0 && rec.Next == 0) {
+			return recs, nil
+		}
+		if rec.Next == 0 {
+			return recs, fmt.Errorf("record at offset %d has no next record", off)
+		}
+		off = int(rec.Next)
+	}
+}
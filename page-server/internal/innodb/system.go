@@ -0,0 +1,75 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FilNull is the "no page" page number
+const FilNull = 0xFFFFFFFF
+
+// Fixed pages of the system tablespace (space 0)
+const (
+	SystemSpaceID      = 0
+	IbufBitmapPageNo   = 1
+	InodePageNo        = 2
+	IbufHeaderPageNo   = 3
+	IbufTreeRootPageNo = 4
+	TrxSysPageNo       = 5 // Transaction system header with the rollback segment slots
+	FirstRsegPageNo    = 6
+	DictHdrPageNo      = 7 // Data dictionary header with the SYS_* index roots
+)
+
+// Data dictionary header offsets (page 7, relative to FilPageData)
+const (
+	dictHdrTables   = 32 // Root of SYS_TABLES clustered index
+	dictHdrFields   = 48 // Root of SYS_FIELDS; the roots are consecutive 4-byte fields
+	dictHdrRootSize = 4
+)
+
+// Transaction system header offsets (page 5, relative to FilPageData)
+const (
+	trxSysRsegs    = 18 // Rollback segment slots: space ID and page number
+	trxSysRsegSlot = 8
+	trxSysNRsegs   = 128
+)
+
+// PageID identifies a page
+type PageID struct {
+	SpaceID uint32
+	PageNo  uint32
+}
+
+// DictRoots returns the root pages (in space 0) of the SYS_TABLES,
+// SYS_TABLE_IDS, SYS_COLUMNS, SYS_INDEXES and SYS_FIELDS indexes
+func DictRoots(page []byte) ([]uint32, error) {
+	if len(page) < FilPageData+dictHdrFields+dictHdrRootSize {
+		return nil, fmt.Errorf("page too short for dictionary header: %d bytes", len(page))
+	}
+	var roots []uint32
+	for off := dictHdrTables; off <= dictHdrFields; off += dictHdrRootSize {
+		if root := binary.BigEndian.Uint32(page[FilPageData+off:]); root != FilNull && root != 0 {
+			roots = append(roots, root)
+		}
+	}
+	return roots, nil
+}
+
+// RsegHeaders returns the rollback segment header pages listed in the
+// transaction system header
+func RsegHeaders(page []byte) ([]PageID, error) {
+	if len(page) < FilPageData+trxSysRsegs+trxSysNRsegs*trxSysRsegSlot {
+		return nil, fmt.Errorf("page too short for transaction system header: %d bytes", len(page))
+	}
+	var rsegs []PageID
+	for i := 0; i < trxSysNRsegs; i++ {
+		slot := page[FilPageData+trxSysRsegs+i*trxSysRsegSlot:]
+		spaceID := binary.BigEndian.Uint32(slot)
+		pageNo := binary.BigEndian.Uint32(slot[4:])
+		if pageNo == FilNull || spaceID == FilNull {
+			continue
+		}
+		rsegs = append(rsegs, PageID{SpaceID: spaceID, PageNo: pageNo})
+	}
+	return rsegs, nil
+}
@@ -0,0 +1,149 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Undo page header offsets (every undo log page, relative to FilPageData)
+const (
+	undoPageType    = 0 // TRX_UNDO_INSERT or TRX_UNDO_UPDATE in older formats
+	undoPageStart   = 2 // First undo record of the newest log on the page
+	undoPageFree    = 4 // First free byte
+	undoPageHdrSize = 18
+)
+
+// Undo segment header offsets (first page of an undo segment, relative to
+// FilPageData). The undo log headers it points to use page offsets.
+const (
+	undoSegState    = undoPageHdrSize + 0
+	undoSegLastLog  = undoPageHdrSize + 2  // Newest undo log header, 0 if none
+	undoSegPageList = undoPageHdrSize + 14 // After the 10-byte file segment header
+	undoSegHdrEnd   = FilPageData + undoPageHdrSize + 30
+
+	undoLogTrxID     = 0
+	undoLogTrxNo     = 8
+	undoLogDelMarks  = 16
+	undoLogLogStart  = 18
+	undoLogXIDExists = 20
+	undoLogDictTrans = 21
+	undoLogTableID   = 22
+	undoLogNextLog   = 30
+	undoLogPrevLog   = 32
+	undoLogHdrSize   = 46 // Without the XID
+)
+
+// Undo segment states (TRX_UNDO_*)
+const (
+	UndoActive   = 1
+	UndoCached   = 2
+	UndoToFree   = 3
+	UndoToPurge  = 4
+	UndoPrepared = 5
+)
+
+var undoStateNames = map[uint16]string{
+	UndoActive:   "ACTIVE",
+	UndoCached:   "CACHED",
+	UndoToFree:   "TO_FREE",
+	UndoToPurge:  "TO_PURGE",
+	UndoPrepared: "PREPARED",
+}
+
+// UndoStateName returns the name of an undo segment state
+func UndoStateName(state uint16) string {
+	if name, ok := undoStateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("STATE_%d", state)
+}
+
+// UndoPageHeader holds the header of an undo log page
+type UndoPageHeader struct {
+	Type  uint16
+	Start uint16
+	Free  uint16
+}
+
+// UndoSegmentHeader holds the header on the first page of an undo segment
+type UndoSegmentHeader struct {
+	State    uint16
+	LastLog  uint16
+	PageList ListBase // Pages of the segment
+}
+
+// UndoLogHeader holds the header of one undo log
+type UndoLogHeader struct {
+	Offset    uint16 // Page offset of the header
+	TrxID     uint64
+	TrxNo     uint64 // Commit number, once committed
+	DelMarks  bool
+	LogStart  uint16
+	XIDExists bool
+	DictTrans bool
+	TableID   uint64
+	NextLog   uint16
+	PrevLog   uint16
+}
+
+// ParseUndoPageHeader reads the header of an undo log page
+func ParseUndoPageHeader(page []byte) (UndoPageHeader, error) {
+	if len(page) < FilPageData+undoPageHdrSize {
+		return UndoPageHeader{}, fmt.Errorf("page too short for undo page header: %d bytes", len(page))
+	}
+	if t := PageType(page); t != PageTypeUndoLog {
+		return UndoPageHeader{}, fmt.Errorf("undo page header requested from a %s page", PageTypeName(t))
+	}
+	hdr := page[FilPageData:]
+	return UndoPageHeader{
+		Type:  binary.BigEndian.Uint16(hdr[undoPageType:]),
+		Start: binary.BigEndian.Uint16(hdr[undoPageStart:]),
+		Free:  binary.BigEndian.Uint16(hdr[undoPageFree:]),
+	}, nil
+}
+
+// ParseUndoSegmentHeader reads the undo segment header. Only the first
+// page of a segment has one; the bytes are undo records on the others, so
+// a header with an unknown state or a last log outside the page is
+// rejected.
+func ParseUndoSegmentHeader(page []byte) (UndoSegmentHeader, error) {
+	if _, err := ParseUndoPageHeader(page); err != nil {
+		return UndoSegmentHeader{}, err
+	}
+	if len(page) < undoSegHdrEnd {
+		return UndoSegmentHeader{}, fmt.Errorf("page too short for undo segment header: %d bytes", len(page))
+	}
+	hdr := page[FilPageData:]
+	seg := UndoSegmentHeader{
+		State:    binary.BigEndian.Uint16(hdr[undoSegState:]),
+		LastLog:  binary.BigEndian.Uint16(hdr[undoSegLastLog:]),
+		PageList: parseListBase(hdr[undoSegPageList:]),
+	}
+	if _, ok := undoStateNames[seg.State]; !ok {
+		return UndoSegmentHeader{}, fmt.Errorf("no undo segment header: state %d", seg.State)
+	}
+	if seg.LastLog != 0 && (int(seg.LastLog) < undoSegHdrEnd || int(seg.LastLog)+undoLogHdrSize > len(page)-FilTrailerBytes) {
+		return UndoSegmentHeader{}, fmt.Errorf("no undo segment header: last log at offset %d", seg.LastLog)
+	}
+	return seg, nil
+}
+
+// ParseUndoLogHeader reads the undo log header at a page offset
+func ParseUndoLogHeader(page []byte, off uint16) (UndoLogHeader, error) {
+	if int(off) < undoSegHdrEnd || int(off)+undoLogHdrSize > len(page)-FilTrailerBytes {
+		return UndoLogHeader{}, fmt.Errorf("undo log header offset %d outside the page body", off)
+	}
+	hdr := page[off:]
+	return UndoLogHeader{
+		Offset:    off,
+		TrxID:     binary.BigEndian.Uint64(hdr[undoLogTrxID:]),
+		TrxNo:     binary.BigEndian.Uint64(hdr[undoLogTrxNo:]),
+		DelMarks:  binary.BigEndian.Uint16(hdr[undoLogDelMarks:]) != 0,
+		LogStart:  binary.BigEndian.Uint16(hdr[undoLogLogStart:]),
+		XIDExists: hdr[undoLogXIDExists] != 0,
+		DictTrans: hdr[undoLogDictTrans] != 0,
+		TableID:   binary.BigEndian.Uint64(hdr[undoLogTableID:]),
+		NextLog:   binary.BigEndian.Uint16(hdr[undoLogNextLog:]),
+		PrevLog:   binary.BigEndian.Uint16(hdr[undoLogPrevLog:]),
+	}, nil
+}
@@ -0,0 +1,164 @@
+package innodb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FSP header offsets past the fields of FSPHeader (relative to FilPageData)
+const (
+	fspFragNUsed      = 20 // Used pages in the FSP_FREE_FRAG list
+	fspFree           = 24 // List bases: free extents,
+	fspFreeFrag       = 40 // extents with free fragment pages,
+	fspFullFrag       = 56 // full fragment extents,
+	fspSegID          = 72 // the next segment ID,
+	fspSegInodesFull  = 80 // full inode pages
+	fspSegInodesFree  = 96 // and inode pages with free slots
+	fspHeaderSize     = 112
+	flstBaseLen       = 0
+	flstBaseFirst     = 4
+	flstBaseLast      = 10
+	fspListsHeaderEnd = FilPageData + fspHeaderSize
+)
+
+// XDES entry layout. Page 0 and every XDES page hold an array of extent
+// descriptors after the space for the FSP header.
+const (
+	xdesArrOffset = FilPageData + fspHeaderSize
+	xdesID        = 0  // Segment ID of an FSEG extent
+	xdesState     = 20 // After the 12-byte list node
+	xdesBitmap    = 24 // Two bits per page: free, clean
+	xdesBitsPer   = 2
+)
+
+// Extent states (XDES_*)
+const (
+	XDESFree     = 1
+	XDESFreeFrag = 2
+	XDESFullFrag = 3
+	XDESFseg     = 4
+)
+
+var xdesStateNames = map[uint32]string{
+	XDESFree:     "FREE",
+	XDESFreeFrag: "FREE_FRAG",
+	XDESFullFrag: "FULL_FRAG",
+	XDESFseg:     "FSEG",
+}
+
+// XDESStateName returns the name of an extent state
+func XDESStateName(state uint32) string {
+	if name, ok := xdesStateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("STATE_%d", state)
+}
+
+// FilAddr is a page number and byte offset, as stored in file lists
+type FilAddr struct {
+	PageNo uint32 // FilNull for none
+	Offset uint16
+}
+
+// ListBase is the base node of a file list
+type ListBase struct {
+	Len   uint32
+	First FilAddr
+	Last  FilAddr
+}
+
+// FSPLists holds the FSP header fields that track extent and inode allocation
+type FSPLists struct {
+	FragNUsed     uint32
+	Free          ListBase
+	FreeFrag      ListBase
+	FullFrag      ListBase
+	NextSegID     uint64
+	SegInodesFull ListBase
+	SegInodesFree ListBase
+}
+
+// ParseFSPLists reads the list bases of the FSP header on page 0
+func ParseFSPLists(page []byte) (FSPLists, error) {
+	if len(page) < fspListsHeaderEnd {
+		return FSPLists{}, fmt.Errorf("page too short for FSP header: %d bytes", len(page))
+	}
+	if no := PageNo(page); no != 0 {
+		return FSPLists{}, fmt.Errorf("FSP header requested from page %d, want page 0", no)
+	}
+	hdr := page[FilPageData:]
+	return FSPLists{
+		FragNUsed:     binary.BigEndian.Uint32(hdr[fspFragNUsed:]),
+		Free:          parseListBase(hdr[fspFree:]),
+		FreeFrag:      parseListBase(hdr[fspFreeFrag:]),
+		FullFrag:      parseListBase(hdr[fspFullFrag:]),
+		NextSegID:     binary.BigEndian.Uint64(hdr[fspSegID:]),
+		SegInodesFull: parseListBase(hdr[fspSegInodesFull:]),
+		SegInodesFree: parseListBase(hdr[fspSegInodesFree:]),
+	}, nil
+}
+
+func parseListBase(b []byte) ListBase {
+	return ListBase{
+		Len:   binary.BigEndian.Uint32(b[flstBaseLen:]),
+		First: parseFilAddr(b[flstBaseFirst:]),
+		Last:  parseFilAddr(b[flstBaseLast:]),
+	}
+}
+
+func parseFilAddr(b []byte) FilAddr {
+	return FilAddr{PageNo: binary.BigEndian.Uint32(b), Offset: binary.BigEndian.Uint16(b[4:])}
+}
+
+// XDESEntry is one extent descriptor
+type XDESEntry struct {
+	FirstPage uint32 // First page of the extent
+	State     uint32
+	SegID     uint64 // FSEG extents only
+	FreePages int
+}
+
+// ExtentSize returns the pages per extent for a page size: 1 MiB worth of
+// pages up to 16 KiB pages, 64 pages above
+func ExtentSize(pageSize int) int {
+	if pageSize <= DefaultPageSize {
+		return (1 << 20) / pageSize
+	}
+	return 64
+}
+
+// ParseXDES reads the initialized extent descriptors of page 0 or an XDES
+// page. The page size is the length of the page.
+func ParseXDES(page []byte) ([]XDESEntry, error) {
+	if t := PageType(page); t != PageTypeFSPHdr && t != PageTypeXDES {
+		return nil, fmt.Errorf("XDES entries requested from a %s page", PageTypeName(t))
+	}
+	pageSize := len(page)
+	if pageSize < minPageSize || pageSize > maxPageSize || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("unsupported page size %d", pageSize)
+	}
+	extent := ExtentSize(pageSize)
+	entrySize := xdesBitmap + (extent*xdesBitsPer+7)/8
+
+	var entries []XDESEntry
+	first := PageNo(page)
+	for i := 0; i < pageSize/extent; i++ {
+		e := page[xdesArrOffset+i*entrySize:]
+		state := binary.BigEndian.Uint32(e[xdesState:])
+		if state == 0 {
+			continue // Not initialized
+		}
+		entry := XDESEntry{FirstPage: first + uint32(i*extent), State: state}
+		if state == XDESFseg {
+			entry.SegID = binary.BigEndian.Uint64(e[xdesID:])
+		}
+		for p := 0; p < extent; p++ {
+			bit := p * xdesBitsPer
+			if e[xdesBitmap+bit/8]&(1<<(bit%8)) != 0 {
+				entry.FreePages++
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
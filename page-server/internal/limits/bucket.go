@@ -0,0 +1,117 @@
+package limits
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket refilled at a constant rate up to a burst size.
+// A zero rate means unlimited.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full bucket
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	if burst < 1 {
+		burst = math.Max(rate, 1)
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the last call (caller holds mu)
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+}
+
+// retryAfter returns how long until the bucket holds n tokens (caller holds mu)
+func (b *TokenBucket) retryAfter(n float64) time.Duration {
+	missing := n - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+// Take removes n tokens if available. Otherwise nothing is taken and
+// the time until n tokens are available is returned.
+func (b *TokenBucket) Take(n float64) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return true, 0
+	}
+
+	b.refill(time.Now())
+	if b.tokens >= n {
+		b.tokens -= n
+		return true, 0
+	}
+	return false, b.retryAfter(n)
+}
+
+// Check reports whether the bucket is not in debt, without taking tokens.
+// Used for costs only known after the request (response bytes).
+func (b *TokenBucket) Check() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return true, 0
+	}
+
+	b.refill(time.Now())
+	if b.tokens > 0 {
+		return true, 0
+	}
+	return false, b.retryAfter(math.Min(1, b.burst))
+}
+
+// Charge removes n tokens unconditionally; the bucket may go into debt
+func (b *TokenBucket) Charge(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return
+	}
+
+	b.refill(time.Now())
+	b.tokens -= n
+}
+
+// SetRate changes the rate and burst, keeping the current fill level
+func (b *TokenBucket) SetRate(rate float64, burst float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if burst < 1 {
+		burst = math.Max(rate, 1)
+	}
+	b.refill(time.Now())
+	b.rate = rate
+	b.burst = burst
+	b.tokens = math.Min(b.tokens, burst)
+}
+
+// lastUsed returns the time of the last refill
+func (b *TokenBucket) lastUsed() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"testing"
+	"time"
+)
+
+// advance moves the bucket's clock back as if d had passed
+func advance(b *TokenBucket, d time.Duration) {
+	b.mu.Lock()
+	b.last = b.last.Add(-d)
+	b.mu.Unlock()
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	b := NewTokenBucket(10, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Take(1); !ok {
+			t.Fatalf("burst token %d refused", i)
+		}
+	}
+	ok, wait := b.Take(1)
+	if ok || wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("empty bucket: ok=%v wait=%v, want a wait of up to 100ms", ok, wait)
+	}
+
+	// One token every 100ms
+	advance(b, 150*time.Millisecond)
+	if ok, _ := b.Take(1); !ok {
+		t.Fatal("refilled token refused")
+	}
+	if ok, _ := b.Take(1); ok {
+		t.Fatal("took more tokens than were refilled")
+	}
+
+	// Refill stops at the burst size
+	advance(b, time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Take(1); !ok {
+			t.Fatalf("token %d after an idle hour refused", i)
+		}
+	}
+	if ok, _ := b.Take(1); ok {
+		t.Fatal("bucket refilled past its burst")
+	}
+}
+
+func TestTokenBucketDebt(t *testing.T) {
+	b := NewTokenBucket(1000, 1000)
+
+	// Response bytes are charged after the fact and may overdraw the bucket
+	b.Charge(3000)
+	ok, wait := b.Check()
+	if ok || wait < 1900*time.Millisecond || wait > 2001*time.Millisecond {
+		t.Fatalf("bucket 2000 in debt: ok=%v wait=%v, want about 2s", ok, wait)
+	}
+	advance(b, 2100*time.Millisecond)
+	if ok, _ := b.Check(); !ok {
+		t.Fatal("bucket still in debt after repaying")
+	}
+
+	// Zero rate means unlimited
+	unlimited := NewTokenBucket(0, 0)
+	unlimited.Charge(1 << 30)
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.Take(1); !ok {
+			t.Fatal("unlimited bucket refused a token")
+		}
+	}
+}
+
+func TestTokenBucketSetRate(t *testing.T) {
+	b := NewTokenBucket(1, 10)
+	b.SetRate(1, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Take(1); !ok {
+			t.Fatalf("token %d refused", i)
+		}
+	}
+	if ok, _ := b.Take(1); ok {
+		t.Fatal("lowered burst not applied to the fill level")
+	}
+	if ok, wait := b.Take(1); ok || wait < 900*time.Millisecond {
+		t.Fatalf("wait %v at 1 token per second", wait)
+	}
+}
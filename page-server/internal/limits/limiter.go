@@ -0,0 +1,152 @@
+package limits
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// idleBucketTTL is how long an unused principal keeps its buckets
+const idleBucketTTL = 10 * time.Minute
+
+// RateConfig holds per-principal rate limits. Zero rates mean unlimited.
+type RateConfig struct {
+	RequestsPerSecond float64
+	RequestBurst      int
+	BytesPerSecond    int64
+	BytesBurst        int64
+}
+
+// Limiter enforces per-principal request and byte rate limits
+type Limiter struct {
+	mu        sync.Mutex
+	cfg       RateConfig
+	buckets   map[string]*principalBuckets
+	lastSweep time.Time
+
+	rejectedRequests map[string]int64 // Rejections by principal
+	rejectedTotal    int64
+}
+
+// principalBuckets holds the buckets of one principal
+type principalBuckets struct {
+	requests *TokenBucket
+	bytes    *TokenBucket
+}
+
+// NewLimiter creates a limiter
+func NewLimiter(cfg RateConfig) *Limiter {
+	return &Limiter{
+		cfg:              cfg,
+		buckets:          make(map[string]*principalBuckets),
+		lastSweep:        time.Now(),
+		rejectedRequests: make(map[string]int64),
+	}
+}
+
+// Admit takes one request token from the principal and checks that it is
+// not over its byte budget. If the request is rejected, the returned
+// duration is how long the client should wait before retrying.
+func (l *Limiter) Admit(principal string) (bool, time.Duration) {
+	b := l.bucketsFor(principal)
+
+	if ok, wait := b.bytes.Check(); !ok {
+		l.recordRejection(principal)
+		return false, wait
+	}
+	if ok, wait := b.requests.Take(1); !ok {
+		l.recordRejection(principal)
+		return false, wait
+	}
+	return true, 0
+}
+
+// ChargeBytes charges the principal for n bytes sent
+func (l *Limiter) ChargeBytes(principal string, n int64) {
+	l.bucketsFor(principal).bytes.Charge(float64(n))
+}
+
+// SetConfig changes the limits of all principals at runtime
+func (l *Limiter) SetConfig(cfg RateConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = cfg
+	for _, b := range l.buckets {
+		b.requests.SetRate(cfg.RequestsPerSecond, float64(cfg.RequestBurst))
+		b.bytes.SetRate(float64(cfg.BytesPerSecond), float64(cfg.BytesBurst))
+	}
+}
+
+// Stats returns limiter statistics
+func (l *Limiter) Stats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Report the principals with the most rejections
+	type rejection struct {
+		principal string
+		count     int64
+	}
+	rejections := make([]rejection, 0, len(l.rejectedRequests))
+	for p, c := range l.rejectedRequests {
+		rejections = append(rejections, rejection{p, c})
+	}
+	sort.Slice(rejections, func(i, j int) bool { return rejections[i].count > rejections[j].count })
+	if len(rejections) > 10 {
+		rejections = rejections[:10]
+	}
+	top := make(map[string]int64, len(rejections))
+	for _, r := range rejections {
+		top[r.principal] = r.count
+	}
+
+	return map[string]interface{}{
+		"requests_per_second":   l.cfg.RequestsPerSecond,
+		"request_burst":         l.cfg.RequestBurst,
+		"bytes_per_second":      l.cfg.BytesPerSecond,
+		"bytes_burst":           l.cfg.BytesBurst,
+		"active_principals":     len(l.buckets),
+		"rejected_requests":     l.rejectedTotal,
+		"rejected_by_principal": top,
+	}
+}
+
+// bucketsFor returns the buckets of a principal, creating them on first use
+func (l *Limiter) bucketsFor(principal string) *principalBuckets {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > time.Minute {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[principal]
+	if !ok {
+		b = &principalBuckets{
+			requests: NewTokenBucket(l.cfg.RequestsPerSecond, float64(l.cfg.RequestBurst)),
+			bytes:    NewTokenBucket(float64(l.cfg.BytesPerSecond), float64(l.cfg.BytesBurst)),
+		}
+		l.buckets[principal] = b
+	}
+	return b
+}
+
+// sweep drops the buckets of idle principals (caller holds mu)
+func (l *Limiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for p, b := range l.buckets {
+		if now.Sub(b.requests.lastUsed()) > idleBucketTTL && now.Sub(b.bytes.lastUsed()) > idleBucketTTL {
+			delete(l.buckets, p)
+		}
+	}
+}
+
+// recordRejection counts a rejected request
+func (l *Limiter) recordRejection(principal string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rejectedRequests[principal]++
+	l.rejectedTotal++
+}
@@ -0,0 +1,45 @@
+package limits
+
+import (
+	"testing"
+)
+
+func TestLimiterPerPrincipal(t *testing.T) {
+	l := NewLimiter(RateConfig{RequestsPerSecond: 1, RequestBurst: 2, BytesPerSecond: 100, BytesBurst: 100})
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Admit("tenant:a"); !ok {
+			t.Fatalf("request %d within the burst rejected", i)
+		}
+	}
+	if ok, wait := l.Admit("tenant:a"); ok || wait <= 0 {
+		t.Fatalf("request over the burst: ok=%v wait=%v", ok, wait)
+	}
+
+	// Other principals have their own buckets
+	if ok, _ := l.Admit("tenant:b"); !ok {
+		t.Fatal("other principal rejected")
+	}
+
+	// A principal over its byte budget is rejected until it pays back
+	l.ChargeBytes("tenant:b", 500)
+	if ok, wait := l.Admit("tenant:b"); ok || wait <= 0 {
+		t.Fatalf("principal over its byte budget: ok=%v wait=%v", ok, wait)
+	}
+
+	stats := l.Stats()
+	if stats["rejected_requests"].(int64) != 2 || stats["active_principals"].(int) != 2 {
+		t.Fatalf("stats: %v", stats)
+	}
+	if top := stats["rejected_by_principal"].(map[string]int64); top["tenant:a"] != 1 || top["tenant:b"] != 1 {
+		t.Fatalf("rejections: %v", top)
+	}
+
+	// Lifting the limits applies to existing principals
+	l.SetConfig(RateConfig{})
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Admit("tenant:a"); !ok {
+			t.Fatal("request rejected without limits")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueTimeout is returned when a slot did not free up before the queue deadline
+var ErrQueueTimeout = errors.New("timed out waiting for a storage load slot")
+
+// Pool bounds the number of concurrent storage loads across all requests.
+// Callers that find the pool full wait in FIFO order until a slot frees up,
+// the queue timeout expires or their context is cancelled.
+type Pool struct {
+	mu           sync.Mutex
+	limit        int
+	queueTimeout time.Duration
+	active       int
+	waiters      []chan struct{}
+
+	acquired int64
+	timeouts int64
+}
+
+// NewPool creates a pool with limit slots (limit <= 0 means unbounded)
+func NewPool(limit int, queueTimeout time.Duration) *Pool {
+	return &Pool{
+		limit:        limit,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire takes a slot; every successful Acquire must be paired with Release
+func (p *Pool) Acquire(ctx context.Context) error {
+	p.mu.Lock()
+	if p.limit <= 0 || p.active < p.limit {
+		p.active++
+		p.acquired++
+		p.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	p.waiters = append(p.waiters, ready)
+	timeout := p.queueTimeout
+	p.mu.Unlock()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-deadline:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, w := range p.waiters {
+		if w == ready {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			if err == ErrQueueTimeout {
+				p.timeouts++
+			}
+			return err
+		}
+	}
+	// The slot was handed to us while timing out; keep it
+	return nil
+}
+
+// Release returns a slot to the pool
+func (p *Pool) Release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.grant()
+}
+
+// SetLimits changes the slot limit and queue timeout at runtime
+func (p *Pool) SetLimits(limit int, queueTimeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = limit
+	p.queueTimeout = queueTimeout
+	p.grant()
+}
+
+// grant hands free slots to waiters in FIFO order (caller holds mu)
+func (p *Pool) grant() {
+	for len(p.waiters) > 0 && (p.limit <= 0 || p.active < p.limit) {
+		ready := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.active++
+		p.acquired++
+		close(ready)
+	}
+}
+
+// Stats returns pool statistics
+func (p *Pool) Stats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[string]interface{}{
+		"max_concurrent_loads": p.limit,
+		"queue_timeout_ms":     p.queueTimeout.Milliseconds(),
+		"loads_in_flight":      p.active,
+		"loads_queued":         len(p.waiters),
+		"loads_total":          p.acquired,
+		"queue_timeouts":       p.timeouts,
+	}
+}
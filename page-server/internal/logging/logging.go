@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity level
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// current holds the active level (defaults to info)
+var current = int32(LevelInfo)
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s (supported: debug, info, warn, error)", s)
+	}
+}
+
+// String returns the level name
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// SetLevel changes the active level; safe to call at runtime
+func SetLevel(l Level) {
+	atomic.StoreInt32(&current, int32(l))
+}
+
+// GetLevel returns the active level
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&current))
+}
+
+// Enabled returns true if messages at level l are logged
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
+
+// Debugf logs per-request detail that is too noisy for production
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+	}
+}
+
+// Infof logs normal operational messages
+func Infof(format string, args ...interface{}) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf logs recoverable problems
+func Warnf(format string, args ...interface{}) {
+	if Enabled(LevelWarn) {
+		log.Printf("Warning: "+format, args...)
+	}
+}
+
+// Errorf logs failures
+func Errorf(format string, args ...interface{}) {
+	if Enabled(LevelError) {
+		log.Printf("Error: "+format, args...)
+	}
+}
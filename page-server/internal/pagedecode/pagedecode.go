@@ -0,0 +1,229 @@
+// Package pagedecode turns an InnoDB page image into the structures its page
+// type carries: the FIL header and trailer, the INDEX page header, directory
+// and record headers, the FSP header and extent descriptors, and the undo
+// page, segment and log headers. Decoding never fails as a whole; a
+// structure that cannot be read is left out and its error listed.
+package pagedecode
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// Decode decodes a page image
+func Decode(page []byte) *types.DecodedPage {
+	d := &types.DecodedPage{Bytes: len(page)}
+	if innodb.IsZeroPage(page) {
+		d.ZeroPage = true
+		return d
+	}
+
+	hdr, err := FilHeader(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return d
+	}
+	d.FilHeader = hdr
+	if len(page) >= innodb.FilHeaderEnd+innodb.FilTrailerBytes {
+		trailer := page[len(page)-innodb.FilTrailerBytes:]
+		d.FilTrailer = &types.FilTrailer{
+			Checksum: binary.BigEndian.Uint32(trailer),
+			LSNLow32: binary.BigEndian.Uint32(trailer[4:]),
+		}
+		d.FilTrailer.LSNMatches = d.FilTrailer.LSNLow32 == uint32(hdr.LSN)
+	}
+
+	switch hdr.Type {
+	case innodb.PageTypeIndex, innodb.PageTypeRTree:
+		d.Index = decodeIndex(page, d)
+	case innodb.PageTypeFSPHdr:
+		d.FSP = decodeFSP(page, d)
+		d.XDES = decodeXDES(page, d)
+	case innodb.PageTypeXDES:
+		d.XDES = decodeXDES(page, d)
+	case innodb.PageTypeUndoLog:
+		d.Undo = decodeUndo(page, d)
+	}
+	return d
+}
+
+// FilHeader decodes the FIL header of a page
+func FilHeader(page []byte) (*types.FilHeader, error) {
+	hdr, err := innodb.ParseFilHeader(page)
+	if err != nil {
+		return nil, err
+	}
+	return &types.FilHeader{
+		Checksum: hdr.Checksum,
+		PageNo:   hdr.PageNo,
+		Prev:     hdr.Prev,
+		Next:     hdr.Next,
+		LSN:      hdr.LSN,
+		Type:     hdr.Type,
+		TypeName: innodb.PageTypeName(hdr.Type),
+		FlushLSN: hdr.FlushLSN,
+		SpaceID:  hdr.SpaceID,
+	}, nil
+}
+
+func decodeIndex(page []byte, d *types.DecodedPage) *types.IndexPage {
+	hdr, err := innodb.ParseIndexHeader(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return nil
+	}
+	index := &types.IndexPage{
+		IndexID:    hdr.IndexID,
+		Level:      hdr.Level,
+		NRecs:      hdr.NRecs,
+		NHeap:      hdr.NHeap,
+		Compact:    hdr.Compact,
+		NDirSlots:  hdr.NDirSlots,
+		HeapTop:    hdr.HeapTop,
+		Free:       hdr.Free,
+		Garbage:    hdr.Garbage,
+		LastInsert: hdr.LastInsert,
+		Direction:  hdr.Direction,
+		NDirection: hdr.NDirection,
+		MaxTrxID:   hdr.MaxTrxID,
+		Directory:  []uint16{},
+		Records:    []types.RecordSummary{},
+	}
+
+	if index.Directory, err = innodb.PageDirectory(page, hdr); err != nil {
+		index.Directory = []uint16{}
+		d.Errors = append(d.Errors, fmt.Sprintf("page directory: %v", err))
+	}
+	recs, err := innodb.Records(page, hdr)
+	index.Records = recordSummaries(recs)
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("records: %v", err))
+	}
+	free, err := innodb.FreeRecords(page, hdr)
+	index.FreeRecords = recordSummaries(free)
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("free records: %v", err))
+	}
+	return index
+}
+
+func recordSummaries(recs []innodb.RecordHeader) []types.RecordSummary {
+	summaries := make([]types.RecordSummary, len(recs))
+	for i, rec := range recs {
+		summaries[i] = types.RecordSummary{
+			Offset:  rec.Offset,
+			HeapNo:  rec.HeapNo,
+			Status:  innodb.RecStatusName(rec.Status),
+			Deleted: rec.Deleted,
+			MinRec:  rec.MinRec,
+			NOwned:  rec.NOwned,
+			Next:    rec.Next,
+			NFields: rec.NFields,
+		}
+	}
+	return summaries
+}
+
+func decodeFSP(page []byte, d *types.DecodedPage) *types.FSPInfo {
+	hdr, err := innodb.ParseFSPHeader(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return nil
+	}
+	lists, err := innodb.ParseFSPLists(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return nil
+	}
+	return &types.FSPInfo{
+		SpaceID:       hdr.SpaceID,
+		Size:          hdr.Size,
+		FreeLimit:     hdr.FreeLimit,
+		Flags:         hdr.Flags,
+		PageSize:      hdr.PageSize(),
+		FullCRC32:     innodb.IsFullCRC32(hdr.Flags),
+		FragNUsed:     lists.FragNUsed,
+		NextSegID:     lists.NextSegID,
+		Free:          listBase(lists.Free),
+		FreeFrag:      listBase(lists.FreeFrag),
+		FullFrag:      listBase(lists.FullFrag),
+		SegInodesFull: listBase(lists.SegInodesFull),
+		SegInodesFree: listBase(lists.SegInodesFree),
+	}
+}
+
+func listBase(base innodb.ListBase) types.ListBase {
+	return types.ListBase{Len: base.Len, First: filAddr(base.First), Last: filAddr(base.Last)}
+}
+
+func filAddr(addr innodb.FilAddr) string {
+	if addr.PageNo == innodb.FilNull {
+		return "FIL_NULL"
+	}
+	return fmt.Sprintf("%d:%d", addr.PageNo, addr.Offset)
+}
+
+func decodeXDES(page []byte, d *types.DecodedPage) []types.XDESExtent {
+	entries, err := innodb.ParseXDES(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return nil
+	}
+	extents := make([]types.XDESExtent, len(entries))
+	for i, e := range entries {
+		extents[i] = types.XDESExtent{
+			FirstPage: e.FirstPage,
+			State:     innodb.XDESStateName(e.State),
+			SegID:     e.SegID,
+			FreePages: e.FreePages,
+		}
+	}
+	return extents
+}
+
+func decodeUndo(page []byte, d *types.DecodedPage) *types.UndoPage {
+	hdr, err := innodb.ParseUndoPageHeader(page)
+	if err != nil {
+		d.Errors = append(d.Errors, err.Error())
+		return nil
+	}
+	undo := &types.UndoPage{Type: hdr.Type, Start: hdr.Start, Free: hdr.Free}
+
+	// Other pages of a segment have undo records where the segment header
+	// would be; they are recognized by the header not parsing
+	seg, err := innodb.ParseUndoSegmentHeader(page)
+	if err != nil {
+		return undo
+	}
+	undo.Segment = &types.UndoSegment{
+		State:     innodb.UndoStateName(seg.State),
+		LastLog:   seg.LastLog,
+		PageCount: seg.PageList.Len,
+	}
+	seen := make(map[uint16]bool)
+	for off := seg.LastLog; off != 0 && !seen[off]; {
+		seen[off] = true
+		log, err := innodb.ParseUndoLogHeader(page, off)
+		if err != nil {
+			d.Errors = append(d.Errors, err.Error())
+			break
+		}
+		undo.Segment.Logs = append(undo.Segment.Logs, types.UndoLog{
+			Offset:    log.Offset,
+			TrxID:     log.TrxID,
+			TrxNo:     log.TrxNo,
+			DelMarks:  log.DelMarks,
+			LogStart:  log.LogStart,
+			XIDExists: log.XIDExists,
+			DictTrans: log.DictTrans,
+			TableID:   log.TableID,
+			NextLog:   log.NextLog,
+			PrevLog:   log.PrevLog,
+		})
+		off = log.PrevLog
+	}
+	return undo
+}
@@ -0,0 +1,118 @@
+package pagedecode
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+)
+
+// putCompactRecord writes a compact record header in front of off
+func putCompactRecord(page []byte, off int, info byte, heapNo uint16, status byte, next int) {
+	page[off-5] = info
+	binary.BigEndian.PutUint16(page[off-4:], heapNo<<3|uint16(status))
+	rel := 0
+	if next != 0 {
+		rel = next - off
+	}
+	binary.BigEndian.PutUint16(page[off-2:], uint16(int16(rel)))
+}
+
+func TestDecodeCompactIndexPage(t *testing.T) {
+	page := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint32(page[innodb.FilPageOffset:], 3)
+	binary.BigEndian.PutUint64(page[innodb.FilPageLSN:], 0x1_0000_0042)
+	binary.BigEndian.PutUint16(page[innodb.FilPageType:], innodb.PageTypeIndex)
+	binary.BigEndian.PutUint32(page[innodb.FilPageSpaceID:], 5)
+	binary.BigEndian.PutUint32(page[len(page)-4:], 0x42)
+
+	hdr := page[innodb.FilPageData:]
+	binary.BigEndian.PutUint16(hdr[0:], 2)        // Directory slots
+	binary.BigEndian.PutUint16(hdr[2:], 200)      // Heap top
+	binary.BigEndian.PutUint16(hdr[4:], 0x8000|4) // Compact, four heap records
+	binary.BigEndian.PutUint16(hdr[6:], 160)      // Free list
+	binary.BigEndian.PutUint16(hdr[16:], 1)       // User records
+	binary.BigEndian.PutUint64(hdr[28:], 77)      // Index ID
+
+	// infimum -> 130 -> supremum; 160 is a deleted record on the free list
+	putCompactRecord(page, innodb.PageNewInfimum, 0x01, 0, innodb.RecStatusInfimum, 130)
+	putCompactRecord(page, 130, 0x00, 2, innodb.RecStatusOrdinary, innodb.PageNewSupremum)
+	putCompactRecord(page, innodb.PageNewSupremum, 0x02, 1, innodb.RecStatusSupremum, 0)
+	putCompactRecord(page, 160, 0x20, 3, innodb.RecStatusOrdinary, 0)
+	binary.BigEndian.PutUint16(page[len(page)-10:], innodb.PageNewInfimum)
+	binary.BigEndian.PutUint16(page[len(page)-12:], innodb.PageNewSupremum)
+
+	d := Decode(page)
+	if len(d.Errors) != 0 {
+		t.Fatalf("errors: %v", d.Errors)
+	}
+	if d.FilHeader.TypeName != "INDEX" || d.FilHeader.SpaceID != 5 || !d.FilTrailer.LSNMatches {
+		t.Fatalf("FIL header %+v, trailer %+v", d.FilHeader, d.FilTrailer)
+	}
+	index := d.Index
+	if index == nil || !index.Compact || index.NHeap != 4 || index.IndexID != 77 {
+		t.Fatalf("index header: %+v", index)
+	}
+	if len(index.Directory) != 2 || index.Directory[0] != innodb.PageNewInfimum || index.Directory[1] != innodb.PageNewSupremum {
+		t.Fatalf("directory: %v", index.Directory)
+	}
+
+	if len(index.Records) != 3 {
+		t.Fatalf("records: %+v", index.Records)
+	}
+	infimum, user, supremum := index.Records[0], index.Records[1], index.Records[2]
+	if infimum.Status != "infimum" || infimum.Next != 130 || infimum.NOwned != 1 {
+		t.Fatalf("infimum: %+v", infimum)
+	}
+	if user.Offset != 130 || user.HeapNo != 2 || user.Status != "ordinary" || user.Deleted || user.Next != innodb.PageNewSupremum {
+		t.Fatalf("user record: %+v", user)
+	}
+	if supremum.Status != "supremum" || supremum.HeapNo != 1 || supremum.NOwned != 2 || supremum.Next != 0 {
+		t.Fatalf("supremum: %+v", supremum)
+	}
+	if len(index.FreeRecords) != 1 || !index.FreeRecords[0].Deleted || index.FreeRecords[0].HeapNo != 3 {
+		t.Fatalf("free records: %+v", index.FreeRecords)
+	}
+
+	// A next pointer back into the list is reported with the records read so far
+	putCompactRecord(page, 130, 0x00, 2, innodb.RecStatusOrdinary, innodb.PageNewInfimum)
+	d = Decode(page)
+	if len(d.Errors) != 1 || len(d.Index.Records) != 2 {
+		t.Fatalf("loop: errors %v, records %+v", d.Errors, d.Index.Records)
+	}
+}
+
+func TestDecodeFSPPage(t *testing.T) {
+	page := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint16(page[innodb.FilPageType:], innodb.PageTypeFSPHdr)
+	binary.BigEndian.PutUint32(page[innodb.FilPageSpaceID:], 9)
+	innodb.PutFSPHeader(page, innodb.FSPHeader{SpaceID: 9, Size: 192, FreeLimit: 128, Flags: 0x15})
+	fsp := page[innodb.FilPageData:]
+	binary.BigEndian.PutUint32(fsp[24:], 1)                // FSP_FREE length
+	binary.BigEndian.PutUint32(fsp[28:], 0)                // First node: page 0
+	binary.BigEndian.PutUint16(fsp[32:], 230)              // offset 230
+	binary.BigEndian.PutUint32(fsp[40+4:], innodb.FilNull) // FSP_FREE_FRAG empty
+
+	// Extent 0 holds fragment pages; extent 1 is free
+	xdes := page[innodb.FilPageData+112:]
+	binary.BigEndian.PutUint32(xdes[20:], innodb.XDESFreeFrag)
+	xdes[24] = 0x01 << 6 // Page 3 free
+	binary.BigEndian.PutUint32(xdes[40+20:], innodb.XDESFree)
+	for i := 0; i < 16; i++ {
+		xdes[40+24+i] = 0x55
+	}
+
+	d := Decode(page)
+	if len(d.Errors) != 0 {
+		t.Fatalf("errors: %v", d.Errors)
+	}
+	if d.FSP == nil || d.FSP.Size != 192 || !d.FSP.FullCRC32 || d.FSP.Free.First != "0:230" || d.FSP.FreeFrag.First != "FIL_NULL" {
+		t.Fatalf("FSP: %+v", d.FSP)
+	}
+	if len(d.XDES) != 2 {
+		t.Fatalf("XDES: %+v", d.XDES)
+	}
+	if d.XDES[0].State != "FREE_FRAG" || d.XDES[0].FreePages != 1 || d.XDES[1].FirstPage != 64 || d.XDES[1].FreePages != 64 {
+		t.Fatalf("XDES: %+v", d.XDES)
+	}
+}
@@ -0,0 +1,185 @@
+// Package pagediff lists the pages of a tablespace that have versions between
+// two LSNs and, optionally, the bytes that differ between the two page images
+// with the FIL header fields decoded.
+package pagediff
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/pagedecode"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// mergeGap is the number of identical bytes that still joins two changed ranges
+const mergeGap = 8
+
+// ErrInvalidPageToken is returned for page tokens not issued by Changes
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageLoader loads a page at or before an LSN
+type PageLoader func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error)
+
+// Query selects the changes to return
+type Query struct {
+	SpaceID   uint32
+	FromLSN   uint64  // Exclusive
+	ToLSN     uint64  // Inclusive
+	PageNo    *uint32 // Restrict to one page
+	Diff      bool    // Load both images and compute byte diffs
+	Limit     int     // Pages per response
+	PageToken string  // From a previous response
+}
+
+// Changes returns the pages with versions in (FromLSN, ToLSN] in page number
+// order, given the stored versions of every page of the space. The returned
+// token is empty on the last page of results.
+func Changes(ctx context.Context, load PageLoader, versions map[uint32][]uint64, q Query) ([]*types.PageChange, string, error) {
+	var start uint32
+	if q.PageToken != "" {
+		n, err := strconv.ParseUint(q.PageToken, 10, 32)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidPageToken, q.PageToken)
+		}
+		start = uint32(n)
+	}
+
+	pageNos := make([]uint32, 0, len(versions))
+	for pageNo := range versions {
+		if pageNo >= start && (q.PageNo == nil || *q.PageNo == pageNo) {
+			pageNos = append(pageNos, pageNo)
+		}
+	}
+	sort.Slice(pageNos, func(i, j int) bool { return pageNos[i] < pageNos[j] })
+
+	var changes []*types.PageChange
+	next := ""
+	for _, pageNo := range pageNos {
+		change := window(pageNo, versions[pageNo], q.FromLSN, q.ToLSN)
+		if change == nil {
+			continue
+		}
+		if q.Limit > 0 && len(changes) == q.Limit {
+			next = strconv.FormatUint(uint64(pageNo), 10)
+			break
+		}
+		if q.Diff {
+			if err := compare(ctx, load, q, change); err != nil {
+				return nil, "", err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, next, nil
+}
+
+// window returns the change of a page in (from, to], or nil if it has no version there
+func window(pageNo uint32, lsns []uint64, from, to uint64) *types.PageChange {
+	sorted := append([]uint64(nil), lsns...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	change := &types.PageChange{PageNo: pageNo, Created: true}
+	for _, lsn := range sorted {
+		switch {
+		case lsn <= from:
+			change.Created = false
+		case lsn <= to:
+			change.Versions = append(change.Versions, lsn)
+		}
+	}
+	if len(change.Versions) == 0 {
+		return nil
+	}
+	return change
+}
+
+// compare loads the page at both ends of the window and fills in the headers and diff
+func compare(ctx context.Context, load PageLoader, q Query, change *types.PageChange) error {
+	after, _, err := load(ctx, q.SpaceID, change.PageNo, q.ToLSN)
+	if err != nil {
+		return fmt.Errorf("failed to load page %d at LSN %d: %w", change.PageNo, q.ToLSN, err)
+	}
+	if change.After, err = pagedecode.FilHeader(after); err != nil {
+		return err
+	}
+	if change.Created {
+		return nil
+	}
+
+	before, _, err := load(ctx, q.SpaceID, change.PageNo, q.FromLSN)
+	if err != nil {
+		return fmt.Errorf("failed to load page %d at LSN %d: %w", change.PageNo, q.FromLSN, err)
+	}
+	if change.Before, err = pagedecode.FilHeader(before); err != nil {
+		return err
+	}
+	change.Diff, change.ChangedBytes = Diff(before, after)
+	return nil
+}
+
+// Diff returns the ranges in which two page images differ and the number of
+// differing bytes. Ranges separated by fewer than mergeGap equal bytes are
+// joined. Bytes past the end of the shorter image count as changed.
+func Diff(before, after []byte) ([]types.ByteRange, int) {
+	size := len(before)
+	if len(after) > size {
+		size = len(after)
+	}
+	at := func(page []byte, i int) (byte, bool) {
+		if i < len(page) {
+			return page[i], true
+		}
+		return 0, false
+	}
+
+	var ranges []types.ByteRange
+	changed := 0
+	start, end := -1, -1 // Current range [start, end)
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		ranges = append(ranges, types.ByteRange{
+			Offset: start,
+			Length: end - start,
+			Field:  innodb.FilField(start, size),
+			Before: hex.EncodeToString(slice(before, start, end)),
+			After:  hex.EncodeToString(slice(after, start, end)),
+		})
+		start, end = -1, -1
+	}
+
+	for i := 0; i < size; i++ {
+		b, okBefore := at(before, i)
+		a, okAfter := at(after, i)
+		if okBefore == okAfter && a == b {
+			continue
+		}
+		changed++
+		if start >= 0 && i-end >= mergeGap {
+			flush()
+		}
+		if start < 0 {
+			start = i
+		}
+		end = i + 1
+	}
+	flush()
+	return ranges, changed
+}
+
+// slice returns page[start:end], clipped to the page
+func slice(page []byte, start, end int) []byte {
+	if start > len(page) {
+		start = len(page)
+	}
+	if end > len(page) {
+		end = len(page)
+	}
+	return page[start:end]
+}
@@ -0,0 +1,209 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/objectstore"
+)
+
+// maxReplay is the most manifests a poll reads; a replica further behind
+// drops all cached pages instead
+const maxReplay = 10000
+
+// FollowerOptions configure a Follower
+type FollowerOptions struct {
+	Interval      time.Duration
+	Invalidate    func(page PageRef) // Drops a page from the replica's caches
+	InvalidateAll func()             // Drops all cached pages
+}
+
+// Status describes how far a replica is behind its primary
+type Status struct {
+	ReplicatedLSN uint64    `json:"replicated_lsn"`
+	PrimaryLSN    uint64    `json:"primary_lsn"`
+	LagLSN        uint64    `json:"lag_lsn"`
+	Seq           uint64    `json:"seq"`
+	HeartbeatAt   time.Time `json:"heartbeat_at,omitempty"` // When the primary last published
+	LastPoll      time.Time `json:"last_poll,omitempty"`
+	PagesDropped  int64     `json:"pages_dropped"`
+	FullDrops     int64     `json:"full_drops"` // Times all cached pages were dropped
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Follower follows a primary's manifests
+type Follower struct {
+	store objectstore.ObjectStore
+	opts  FollowerOptions
+
+	// mu is held for writing while pages are dropped and the LSN advances,
+	// so a reader holding it (see View) never caches a page the next
+	// manifest changed
+	mu      sync.RWMutex
+	lsn     uint64
+	seq     uint64
+	started bool
+
+	pollMu sync.Mutex // One poll at a time
+
+	statusMu sync.Mutex
+	status   Status
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFollower creates a follower; its replicated LSN is 0 until the first poll
+func NewFollower(store objectstore.ObjectStore, opts FollowerOptions) *Follower {
+	return &Follower{store: store, opts: opts}
+}
+
+// LSN returns the replicated LSN
+func (f *Follower) LSN() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lsn
+}
+
+// View runs fn with the replicated LSN, which does not advance until fn returns
+func (f *Follower) View(fn func(lsn uint64)) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	fn(f.lsn)
+}
+
+// Poll reads the heartbeat and applies the manifests published since the last poll
+func (f *Follower) Poll(ctx context.Context) error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+
+	hb, dropped, all, err := f.poll(ctx)
+
+	f.statusMu.Lock()
+	defer f.statusMu.Unlock()
+	f.status.LastPoll = time.Now()
+	f.status.LastError = ""
+	if err != nil {
+		f.status.LastError = err.Error()
+		return err
+	}
+	f.status.ReplicatedLSN = hb.LSN
+	f.status.PrimaryLSN = hb.PrimaryLSN
+	f.status.Seq = hb.Seq
+	f.status.HeartbeatAt = hb.UpdatedAt
+	f.status.PagesDropped += int64(dropped)
+	if all {
+		f.status.FullDrops++
+	}
+	return nil
+}
+
+// poll applies the manifests up to the heartbeat; pollMu must be held
+func (f *Follower) poll(ctx context.Context) (Heartbeat, int, bool, error) {
+	hb, err := ReadHeartbeat(ctx, f.store)
+	if err != nil {
+		return hb, 0, false, err
+	}
+
+	// Only poll changes seq, so it can be read without mu
+	var pages []PageRef
+	all := false
+	switch {
+	case !f.started:
+		// Nothing is cached yet
+	case hb.Seq < f.seq || hb.Seq-f.seq > maxReplay:
+		// The primary started over, or we fell too far behind
+		all = true
+	default:
+		for seq := f.seq + 1; seq <= hb.Seq && !all; seq++ {
+			var m Manifest
+			err := getJSON(ctx, f.store, manifestKey(seq), &m)
+			switch {
+			case errors.Is(err, objectstore.ErrNotFound):
+				// Pruned before we read it
+				log.Printf("Replication manifest %d is gone; dropping all cached pages", seq)
+				all = true
+			case err != nil:
+				return hb, 0, false, err
+			case m.Reset:
+				all = true
+			default:
+				pages = append(pages, m.Pages...)
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if all {
+		f.opts.InvalidateAll()
+		pages = nil
+	} else {
+		for _, page := range pages {
+			f.opts.Invalidate(page)
+		}
+	}
+	f.lsn, f.seq, f.started = hb.LSN, hb.Seq, true
+	return hb, len(pages), all, nil
+}
+
+// Status returns the replication status
+func (f *Follower) Status() Status {
+	f.statusMu.Lock()
+	defer f.statusMu.Unlock()
+	status := f.status
+	if status.PrimaryLSN > status.ReplicatedLSN {
+		status.LagLSN = status.PrimaryLSN - status.ReplicatedLSN
+	}
+	return status
+}
+
+// Start polls every interval until Stop is called
+func (f *Follower) Start() {
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(f.opts.Interval)
+		defer ticker.Stop()
+		lastErr := ""
+		for {
+			select {
+			case <-f.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					select {
+					case <-f.stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				// Log a failure once rather than on every poll
+				err := f.Poll(ctx)
+				if err != nil && err.Error() != lastErr {
+					log.Printf("Warning: Failed to follow primary: %v", err)
+				}
+				lastErr = ""
+				if err != nil {
+					lastErr = err.Error()
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop, interrupting a poll in progress
+func (f *Follower) Stop() {
+	if f.stop == nil {
+		return
+	}
+	close(f.stop)
+	<-f.done
+}
@@ -0,0 +1,88 @@
+// Package replication lets read-only page servers follow a primary that
+// shares their object store.
+//
+// The primary's storage backend already writes every page version and WAL
+// record to the store. After making its writes durable, the Publisher adds a
+// manifest listing the pages changed since the previous one and rewrites a
+// heartbeat naming the newest manifest and the LSN everything up to which is
+// in the store. A Follower polls the heartbeat, drops the listed pages from
+// its caches and then advances its replicated LSN, so reads at or below that
+// LSN see exactly what the primary would return.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linux/projects/server/objectstore"
+)
+
+const (
+	// heartbeatKey is rewritten by the primary on every publish round
+	heartbeatKey = "replication/heartbeat"
+	// manifestPrefix holds one manifest per publish round that changed something
+	manifestPrefix = "replication/manifest_"
+)
+
+// PageRef identifies a page
+type PageRef struct {
+	SpaceID uint32 `json:"space_id"`
+	PageNo  uint32 `json:"page_no"`
+}
+
+// Manifest lists the pages changed between the previous manifest and LSN
+type Manifest struct {
+	Seq       uint64    `json:"seq"`
+	LSN       uint64    `json:"lsn"`             // Everything at or below is in the store
+	Reset     bool      `json:"reset,omitempty"` // Changes are unknown (import, primary restart): drop all cached pages
+	Pages     []PageRef `json:"pages,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Heartbeat names the newest manifest and the primary's progress
+type Heartbeat struct {
+	Seq        uint64    `json:"seq"`         // Newest manifest
+	LSN        uint64    `json:"lsn"`         // LSN of the newest manifest
+	PrimaryLSN uint64    `json:"primary_lsn"` // Latest LSN received by the primary, published or not
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// manifestKey returns the object key of a manifest; zero padding keeps listings in order
+func manifestKey(seq uint64) string {
+	return fmt.Sprintf("%s%020d", manifestPrefix, seq)
+}
+
+// putJSON stores v as a JSON object
+func putJSON(ctx context.Context, store objectstore.ObjectStore, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// getJSON reads a JSON object into v; a missing object wraps objectstore.ErrNotFound
+func getJSON(ctx context.Context, store objectstore.ObjectStore, key string, v interface{}) error {
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return nil
+}
+
+// ReadHeartbeat returns the heartbeat in store, or an error wrapping
+// objectstore.ErrNotFound if no primary has published yet
+func ReadHeartbeat(ctx context.Context, store objectstore.ObjectStore) (Heartbeat, error) {
+	var hb Heartbeat
+	err := getJSON(ctx, store, heartbeatKey, &hb)
+	return hb, err
+}
@@ -0,0 +1,264 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/objectstore"
+)
+
+// PublisherOptions configure a Publisher
+type PublisherOptions struct {
+	Interval  time.Duration
+	Keep      int                             // Manifests kept in the store
+	LatestLSN func() uint64                   // Latest LSN received by the primary
+	Flush     func(ctx context.Context) error // Makes queued writes durable in the store; may be nil
+}
+
+// PublisherStats describes the publisher state
+type PublisherStats struct {
+	Seq         uint64    `json:"seq"`
+	LSN         uint64    `json:"lsn"`
+	Published   int64     `json:"published"`
+	LastPublish time.Time `json:"last_publish,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Publisher publishes the primary's changes for replicas
+type Publisher struct {
+	store objectstore.ObjectStore
+	opts  PublisherOptions
+
+	// Changes since the last manifest
+	mu      sync.Mutex
+	applied uint64
+	changed map[PageRef]bool
+	reset   bool
+
+	runMu sync.Mutex // One round at a time
+	seq   uint64     // Newest manifest written
+	lsn   uint64     // Its LSN
+
+	statsMu sync.Mutex
+	stats   PublisherStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPublisher creates a publisher continuing after the heartbeat already in
+// store. If the primary received WAL the heartbeat does not cover (it stopped
+// before publishing), the first manifest tells replicas to drop all cached pages.
+func NewPublisher(ctx context.Context, store objectstore.ObjectStore, opts PublisherOptions) (*Publisher, error) {
+	p := &Publisher{
+		store:   store,
+		opts:    opts,
+		changed: make(map[PageRef]bool),
+		applied: opts.LatestLSN(),
+	}
+
+	hb, err := ReadHeartbeat(ctx, store)
+	if err != nil && !errors.Is(err, objectstore.ErrNotFound) {
+		return nil, err
+	}
+	p.seq, p.lsn = hb.Seq, hb.LSN
+	p.reset = p.applied > hb.LSN
+	p.stats = PublisherStats{Seq: hb.Seq, LSN: hb.LSN}
+
+	p.prune(ctx)
+	return p, nil
+}
+
+// Applied records a stored WAL record; spaceID and pageNo are zero unless
+// it wrote a page version
+func (p *Publisher) Applied(lsn uint64, spaceID uint32, pageNo uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if lsn > p.applied {
+		p.applied = lsn
+	}
+	if spaceID > 0 || pageNo > 0 {
+		p.changed[PageRef{spaceID, pageNo}] = true
+	}
+}
+
+// Changed records a page version rewritten outside WAL ingestion
+func (p *Publisher) Changed(spaceID uint32, pageNo uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.changed[PageRef{spaceID, pageNo}] = true
+}
+
+// Reset records that pages changed in untracked ways (an import) up to lsn
+func (p *Publisher) Reset(lsn uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if lsn > p.applied {
+		p.applied = lsn
+	}
+	p.reset = true
+}
+
+// Publish flushes queued writes, writes a manifest if anything changed and
+// rewrites the heartbeat
+func (p *Publisher) Publish(ctx context.Context) error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+
+	err := p.publish(ctx)
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	p.stats.Seq, p.stats.LSN = p.seq, p.lsn
+	p.stats.LastPublish = time.Now()
+	p.stats.LastError = ""
+	if err != nil {
+		p.stats.LastError = err.Error()
+	}
+	return err
+}
+
+// publish performs one round; runMu must be held
+func (p *Publisher) publish(ctx context.Context) error {
+	// Take the changes so far; records arriving meanwhile go to the next round
+	p.mu.Lock()
+	lsn, changed, reset := p.applied, p.changed, p.reset
+	p.changed, p.reset = make(map[PageRef]bool), false
+	p.mu.Unlock()
+
+	if lsn > p.lsn || len(changed) > 0 || reset {
+		m := Manifest{Seq: p.seq + 1, LSN: lsn, Reset: reset, CreatedAt: time.Now()}
+		if m.LSN < p.lsn {
+			m.LSN = p.lsn
+		}
+		if !reset {
+			for page := range changed {
+				m.Pages = append(m.Pages, page)
+			}
+			sort.Slice(m.Pages, func(i, j int) bool {
+				if m.Pages[i].SpaceID != m.Pages[j].SpaceID {
+					return m.Pages[i].SpaceID < m.Pages[j].SpaceID
+				}
+				return m.Pages[i].PageNo < m.Pages[j].PageNo
+			})
+		}
+
+		// Replicas may read up to m.LSN as soon as the heartbeat names it
+		var err error
+		if p.opts.Flush != nil {
+			err = p.opts.Flush(ctx)
+		}
+		if err == nil {
+			err = putJSON(ctx, p.store, manifestKey(m.Seq), m)
+		}
+		if err != nil {
+			p.requeue(changed, reset)
+			return err
+		}
+		p.seq, p.lsn = m.Seq, m.LSN
+
+		p.statsMu.Lock()
+		p.stats.Published++
+		p.statsMu.Unlock()
+
+		if p.opts.Keep > 0 && p.seq > uint64(p.opts.Keep) {
+			if err := p.store.Delete(ctx, manifestKey(p.seq-uint64(p.opts.Keep))); err != nil {
+				log.Printf("Warning: Failed to delete old replication manifest: %v", err)
+			}
+		}
+	}
+
+	return putJSON(ctx, p.store, heartbeatKey, Heartbeat{
+		Seq:        p.seq,
+		LSN:        p.lsn,
+		PrimaryLSN: p.opts.LatestLSN(),
+		UpdatedAt:  time.Now(),
+	})
+}
+
+// requeue returns the changes of a failed round to the next one
+func (p *Publisher) requeue(changed map[PageRef]bool, reset bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for page := range changed {
+		p.changed[page] = true
+	}
+	p.reset = p.reset || reset
+}
+
+// prune deletes manifests older than the Keep newest, e.g. left behind by a
+// primary that stopped before deleting them
+func (p *Publisher) prune(ctx context.Context) {
+	if p.opts.Keep <= 0 || p.seq <= uint64(p.opts.Keep) {
+		return
+	}
+	objects, err := p.store.List(ctx, manifestPrefix)
+	if err != nil {
+		log.Printf("Warning: Failed to list replication manifests: %v", err)
+		return
+	}
+	for _, obj := range objects {
+		seq, err := strconv.ParseUint(strings.TrimPrefix(obj.Key, manifestPrefix), 10, 64)
+		if err != nil || seq > p.seq-uint64(p.opts.Keep) {
+			continue
+		}
+		if err := p.store.Delete(ctx, obj.Key); err != nil {
+			log.Printf("Warning: Failed to delete old replication manifest: %v", err)
+			return
+		}
+	}
+}
+
+// Stats returns a copy of the publisher statistics
+func (p *Publisher) Stats() PublisherStats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	return p.stats
+}
+
+// Start publishes every interval until Stop is called
+func (p *Publisher) Start() {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					select {
+					case <-p.stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				if err := p.Publish(ctx); err != nil {
+					log.Printf("Warning: Failed to publish replication manifest: %v", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop, interrupting a round in progress.
+// Call Publish afterwards to publish the final changes.
+func (p *Publisher) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
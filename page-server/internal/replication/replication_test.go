@@ -0,0 +1,204 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/linux/projects/server/objectstore"
+)
+
+// primary simulates the primary's WAL position
+type primary struct {
+	mu  sync.Mutex
+	lsn uint64
+}
+
+func (p *primary) latest() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lsn
+}
+
+func (p *primary) apply(pub *Publisher, lsn uint64, spaceID, pageNo uint32) {
+	p.mu.Lock()
+	p.lsn = lsn
+	p.mu.Unlock()
+	pub.Applied(lsn, spaceID, pageNo)
+}
+
+// replica records what a follower invalidated
+type replica struct {
+	pages []PageRef
+	all   int
+}
+
+func (r *replica) follower(store objectstore.ObjectStore) *Follower {
+	return NewFollower(store, FollowerOptions{
+		Invalidate:    func(page PageRef) { r.pages = append(r.pages, page) },
+		InvalidateAll: func() { r.all++ },
+	})
+}
+
+func newPublisher(t *testing.T, store objectstore.ObjectStore, p *primary, keep int) *Publisher {
+	t.Helper()
+	pub, err := NewPublisher(context.Background(), store, PublisherOptions{Keep: keep, LatestLSN: p.latest})
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	return pub
+}
+
+func TestFollowerTracksPublishedChanges(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewMemoryStore()
+	p := &primary{}
+	pub := newPublisher(t, store, p, 10)
+	r := &replica{}
+	f := r.follower(store)
+
+	if err := f.Poll(ctx); !errors.Is(err, objectstore.ErrNotFound) {
+		t.Fatalf("Poll before the first publish = %v, want ErrNotFound", err)
+	}
+
+	p.apply(pub, 100, 1, 3)
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f.LSN() != 100 || len(r.pages) != 0 || r.all != 0 {
+		t.Fatalf("first poll: lsn=%d pages=%v all=%d, want lsn 100 without invalidation", f.LSN(), r.pages, r.all)
+	}
+
+	// Unpublished WAL is not visible, but shows up as lag
+	p.apply(pub, 200, 1, 4)
+	p.apply(pub, 250, 2, 7)
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f.LSN() != 100 {
+		t.Fatalf("LSN before publish = %d, want 100", f.LSN())
+	}
+
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p.apply(pub, 300, 1, 4)
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []PageRef{{1, 4}, {2, 7}, {1, 4}}
+	if f.LSN() != 300 || len(r.pages) != len(want) || r.all != 0 {
+		t.Fatalf("lsn=%d pages=%v all=%d, want lsn 300 pages %v", f.LSN(), r.pages, r.all, want)
+	}
+	for i := range want {
+		if r.pages[i] != want[i] {
+			t.Fatalf("pages = %v, want %v", r.pages, want)
+		}
+	}
+
+	// An idle round only refreshes the heartbeat
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pub.Stats(); stats.Seq != 3 || stats.Published != 3 {
+		t.Fatalf("publisher stats = %+v, want seq 3 after 3 manifests", stats)
+	}
+	if status := f.Status(); status.LagLSN != 0 || status.ReplicatedLSN != 300 {
+		t.Fatalf("status = %+v, want no lag at 300", status)
+	}
+}
+
+func TestFollowerDropsEverythingWhenChangesAreUnknown(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewMemoryStore()
+	p := &primary{}
+	pub := newPublisher(t, store, p, 2)
+	r := &replica{}
+	f := r.follower(store)
+
+	p.apply(pub, 10, 1, 1)
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Manifests pruned before the replica read them
+	for lsn := uint64(20); lsn <= 50; lsn += 10 {
+		p.apply(pub, lsn, 1, 2)
+		if err := pub.Publish(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.all != 1 || f.LSN() != 50 {
+		t.Fatalf("after a gap: all=%d lsn=%d, want one full drop at 50", r.all, f.LSN())
+	}
+
+	// Imports publish a reset
+	pub.Reset(1000)
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.all != 2 || f.LSN() != 1000 {
+		t.Fatalf("after a reset: all=%d lsn=%d, want a second full drop at 1000", r.all, f.LSN())
+	}
+
+	// A primary restarting with WAL it never published continues the
+	// sequence and resets
+	p.apply(pub, 1100, 3, 3)
+	restarted := newPublisher(t, store, p, 2)
+	if err := restarted.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r.all != 3 || f.LSN() != 1100 {
+		t.Fatalf("after a primary restart: all=%d lsn=%d, want a third full drop at 1100", r.all, f.LSN())
+	}
+}
+
+func TestPublishFailureKeepsChanges(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewMemoryStore()
+	p := &primary{}
+	pub := newPublisher(t, store, p, 10)
+	r := &replica{}
+	f := r.follower(store)
+
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	p.apply(pub, 10, 5, 5)
+	store.FailNext(objectstore.OpPut, 1, errors.New("injected"))
+	if err := pub.Publish(ctx); err == nil {
+		t.Fatal("Publish with a failing store succeeded")
+	}
+	if err := pub.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f.LSN() != 10 || len(r.pages) != 1 || r.pages[0] != (PageRef{5, 5}) {
+		t.Fatalf("lsn=%d pages=%v, want page 5/5 at 10 after the retry", f.LSN(), r.pages)
+	}
+}
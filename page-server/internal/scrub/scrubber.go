@@ -0,0 +1,443 @@
+// Package scrub verifies stored page versions in the background.
+//
+// A version written by the WAL processor is re-derived from the previous
+// version plus the WAL record stored at its LSN and compared byte for byte.
+// A version stored without WAL (e.g. by an import) is checked against its
+// InnoDB checksum instead. Versions at or below the GC cutoff are not
+// replayed, because GC may have removed the version they were derived from.
+//
+// Only the checksum check is meaningful for pages InnoDB wrote. The WAL
+// processor stores its LSN little-endian over bytes 0-8 of a rebuilt page
+// and does not update the checksum (exports stamp it, see
+// innodb.StampPage), so a WAL-applied version whose WAL record is gone
+// always fails the checksum check and is reported as a checksum mismatch.
+package scrub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/pagediff"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// Mismatch kinds
+const (
+	KindReplay   = "replay"   // Stored version differs from the replay of its WAL record
+	KindChecksum = "checksum" // Stored version without WAL fails its InnoDB checksum
+)
+
+// maxMismatches bounds the mismatches kept for the status endpoint
+const maxMismatches = 1000
+
+// ErrRunning is returned by Trigger while a pass is in progress
+var ErrRunning = errors.New("scrub pass already running")
+
+// Backend is the storage the scrubber reads and repairs
+type Backend interface {
+	storage.StorageBackend
+	storage.VersionedStorage
+	storage.WALReader
+}
+
+// Redo applies a WAL record to a page image (nil for an empty page)
+type Redo func(page []byte, walData []byte, lsn uint64) ([]byte, error)
+
+// Options configure a Scrubber
+type Options struct {
+	Interval       time.Duration                       // Pause between passes
+	PagesPerSecond int                                 // Versions checked per second
+	Repair         bool                                // Overwrite versions that differ from their replay
+	Cutoff         func() uint64                       // GC cutoff, nil without GC
+	SpaceFlags     func(spaceID uint32) (uint32, bool) // Tablespace flags, for the checksum format
+	Invalidate     func(spaceID uint32, pageNo uint32) // Drops a repaired page from caches
+}
+
+// Mismatch is a stored version that failed verification
+type Mismatch struct {
+	SpaceID      uint32    `json:"space_id"`
+	PageNo       uint32    `json:"page_no"`
+	LSN          uint64    `json:"lsn"`
+	Kind         string    `json:"kind"`
+	ChangedBytes int       `json:"changed_bytes,omitempty"` // Replay mismatches
+	Error        string    `json:"error,omitempty"`         // Checksum mismatches
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Repaired     bool      `json:"repaired,omitempty"`
+}
+
+// Stats describes the scrubber state; counters are totals over all passes
+type Stats struct {
+	Running          bool      `json:"running"`
+	Repair           bool      `json:"repair"`
+	PagesPerSecond   int       `json:"pages_per_second"`
+	Passes           int64     `json:"passes"`
+	LastPassStart    time.Time `json:"last_pass_start,omitempty"`
+	LastPassDuration string    `json:"last_pass_duration,omitempty"`
+	VersionsChecked  int64     `json:"versions_checked"`
+	Replayed         int64     `json:"replayed"`         // Verified by WAL replay
+	ChecksumChecked  int64     `json:"checksum_checked"` // Verified by checksum
+	Skipped          int64     `json:"skipped"`          // Below the GC cutoff, collected meanwhile or never written
+	ReplayMismatches int64     `json:"replay_mismatches"`
+	ChecksumErrors   int64     `json:"checksum_errors"`
+	Repaired         int64     `json:"repaired"`
+	LastError        string    `json:"last_error,omitempty"`
+}
+
+// mismatchKey identifies a mismatch across passes
+type mismatchKey struct {
+	spaceID uint32
+	pageNo  uint32
+	lsn     uint64
+	kind    string
+}
+
+// Scrubber verifies page versions at a bounded rate
+type Scrubber struct {
+	storage Backend
+	redo    Redo
+	opts    Options
+
+	runMu      sync.Mutex // One pass at a time
+	statsMu    sync.Mutex
+	stats      Stats
+	mismatches map[mismatchKey]*Mismatch
+
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a scrubber. PagesPerSecond must be positive.
+func New(backend Backend, redo Redo, opts Options) *Scrubber {
+	return &Scrubber{
+		storage: backend,
+		redo:    redo,
+		opts:    opts,
+		stats: Stats{
+			Repair:         opts.Repair,
+			PagesPerSecond: opts.PagesPerSecond,
+		},
+		mismatches: make(map[mismatchKey]*Mismatch),
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Start runs a pass every interval, or when triggered, until Stop is called
+func (s *Scrubber) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				select {
+				case <-s.stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			if _, err := s.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Warning: Scrub pass failed: %v", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Stop stops the background loop, interrupting a pass in progress
+func (s *Scrubber) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Trigger starts a pass in the background without waiting for the interval
+func (s *Scrubber) Trigger() error {
+	if s.Stats().Running {
+		return ErrRunning
+	}
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+		// A pass is already queued
+	}
+	return nil
+}
+
+// Stats returns a copy of the scrubber statistics
+func (s *Scrubber) Stats() Stats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.stats
+}
+
+// Mismatches returns the recorded mismatches ordered by space, page and LSN
+func (s *Scrubber) Mismatches() []Mismatch {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	result := make([]Mismatch, 0, len(s.mismatches))
+	for _, m := range s.mismatches {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.SpaceID != b.SpaceID {
+			return a.SpaceID < b.SpaceID
+		}
+		if a.PageNo != b.PageNo {
+			return a.PageNo < b.PageNo
+		}
+		return a.LSN < b.LSN
+	})
+	return result
+}
+
+// Run performs one pass over every stored page version
+func (s *Scrubber) Run(ctx context.Context) (Stats, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	start := time.Now()
+	s.statsMu.Lock()
+	s.stats.Running = true
+	s.stats.LastPassStart = start
+	s.statsMu.Unlock()
+
+	err := s.pass(ctx)
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	s.stats.Running = false
+	s.stats.Passes++
+	s.stats.LastPassDuration = time.Since(start).Round(time.Millisecond).String()
+	s.stats.LastError = ""
+	if err != nil {
+		s.stats.LastError = err.Error()
+	}
+	return s.stats, err
+}
+
+// pass checks the versions of every page, oldest first so repairs carry forward
+func (s *Scrubber) pass(ctx context.Context) error {
+	throttle := time.NewTicker(time.Second / time.Duration(s.opts.PagesPerSecond))
+	defer throttle.Stop()
+
+	spaces, err := s.storage.ListSpaces()
+	if err != nil {
+		return err
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i] < spaces[j] })
+
+	for _, spaceID := range spaces {
+		versions, err := s.storage.ListPageVersions(spaceID)
+		if err != nil {
+			return err
+		}
+		pageNos := make([]uint32, 0, len(versions))
+		for pageNo := range versions {
+			pageNos = append(pageNos, pageNo)
+		}
+		sort.Slice(pageNos, func(i, j int) bool { return pageNos[i] < pageNos[j] })
+
+		for _, pageNo := range pageNos {
+			lsns := versions[pageNo]
+			sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+			for _, lsn := range lsns {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-throttle.C:
+				}
+				if err := s.check(spaceID, pageNo, lsn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// check verifies one stored version
+func (s *Scrubber) check(spaceID uint32, pageNo uint32, lsn uint64) error {
+	s.count(func(st *Stats) { st.VersionsChecked++ })
+
+	stored, storedLSN, err := s.storage.LoadPage(spaceID, pageNo, lsn)
+	if errors.Is(err, storage.ErrPageNotFound) || (err == nil && storedLSN != lsn) {
+		// Collected since the listing
+		s.count(func(st *Stats) { st.Skipped++ })
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	walData, err := s.storage.LoadWAL(lsn)
+	if err != nil && !errors.Is(err, storage.ErrWALNotFound) {
+		return err
+	}
+	if len(walData) == 0 {
+		return s.checkChecksum(spaceID, pageNo, lsn, stored)
+	}
+	return s.checkReplay(spaceID, pageNo, lsn, stored, walData)
+}
+
+// checkReplay re-derives a version from its predecessor and WAL record
+func (s *Scrubber) checkReplay(spaceID uint32, pageNo uint32, lsn uint64, stored []byte, walData []byte) error {
+	if s.belowCutoff(lsn) {
+		s.count(func(st *Stats) { st.Skipped++ })
+		return nil
+	}
+
+	var base []byte
+	if lsn > 0 {
+		page, _, err := s.storage.LoadPage(spaceID, pageNo, lsn-1)
+		if err != nil && !errors.Is(err, storage.ErrPageNotFound) {
+			return err
+		}
+		base = page
+	}
+	replayed, err := s.redo(base, walData, lsn)
+	if err != nil {
+		return fmt.Errorf("failed to replay space=%d page=%d lsn=%d: %w", spaceID, pageNo, lsn, err)
+	}
+
+	// GC may have passed the LSN while the predecessor was read
+	if s.belowCutoff(lsn) {
+		s.count(func(st *Stats) { st.Skipped++ })
+		return nil
+	}
+	s.count(func(st *Stats) { st.Replayed++ })
+	if bytes.Equal(replayed, stored) {
+		return nil
+	}
+
+	_, changed := pagediff.Diff(stored, replayed)
+	m := s.record(mismatchKey{spaceID, pageNo, lsn, KindReplay}, func(m *Mismatch) { m.ChangedBytes = changed })
+	log.Printf("Warning: Scrub: space=%d page=%d lsn=%d differs from WAL replay in %d bytes", spaceID, pageNo, lsn, changed)
+
+	if !s.opts.Repair || m.Repaired {
+		return nil
+	}
+	if err := s.storage.StorePage(spaceID, pageNo, lsn, replayed); err != nil {
+		return fmt.Errorf("failed to repair space=%d page=%d lsn=%d: %w", spaceID, pageNo, lsn, err)
+	}
+	if s.opts.Invalidate != nil {
+		s.opts.Invalidate(spaceID, pageNo)
+	}
+	s.record(mismatchKey{spaceID, pageNo, lsn, KindReplay}, func(m *Mismatch) { m.Repaired = true })
+	s.count(func(st *Stats) { st.Repaired++ })
+	log.Printf("Scrub: repaired space=%d page=%d lsn=%d from WAL replay", spaceID, pageNo, lsn)
+	return nil
+}
+
+// checkChecksum verifies the InnoDB checksum of a version stored without WAL
+func (s *Scrubber) checkChecksum(spaceID uint32, pageNo uint32, lsn uint64, stored []byte) error {
+	if innodb.IsZeroPage(stored) {
+		s.count(func(st *Stats) { st.Skipped++ })
+		return nil
+	}
+	s.count(func(st *Stats) { st.ChecksumChecked++ })
+
+	var err error
+	if flags, ok := s.spaceFlags(spaceID); ok {
+		err = innodb.VerifyChecksum(stored, innodb.IsFullCRC32(flags))
+	} else if err = innodb.VerifyChecksum(stored, false); err != nil {
+		// Unknown format: either one will do
+		if innodb.VerifyChecksum(stored, true) == nil {
+			err = nil
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	s.record(mismatchKey{spaceID, pageNo, lsn, KindChecksum}, func(m *Mismatch) { m.Error = err.Error() })
+	log.Printf("Warning: Scrub: space=%d page=%d lsn=%d: %v", spaceID, pageNo, lsn, err)
+	return nil
+}
+
+// belowCutoff reports whether GC may have collected the predecessor of a version at lsn
+func (s *Scrubber) belowCutoff(lsn uint64) bool {
+	return s.opts.Cutoff != nil && lsn <= s.opts.Cutoff()
+}
+
+// spaceFlags returns the flags of a space, if known
+func (s *Scrubber) spaceFlags(spaceID uint32) (uint32, bool) {
+	if s.opts.SpaceFlags == nil {
+		return 0, false
+	}
+	return s.opts.SpaceFlags(spaceID)
+}
+
+// count updates the statistics
+func (s *Scrubber) count(fn func(st *Stats)) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	fn(&s.stats)
+}
+
+// record adds or refreshes a mismatch and returns a copy of it. New
+// mismatches are counted; the oldest is dropped when the list is full.
+func (s *Scrubber) record(key mismatchKey, update func(m *Mismatch)) Mismatch {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	now := time.Now()
+	m, ok := s.mismatches[key]
+	if !ok {
+		if len(s.mismatches) >= maxMismatches {
+			s.dropOldest()
+		}
+		m = &Mismatch{
+			SpaceID:   key.spaceID,
+			PageNo:    key.pageNo,
+			LSN:       key.lsn,
+			Kind:      key.kind,
+			FirstSeen: now,
+		}
+		s.mismatches[key] = m
+		if key.kind == KindReplay {
+			s.stats.ReplayMismatches++
+		} else {
+			s.stats.ChecksumErrors++
+		}
+	}
+	m.LastSeen = now
+	update(m)
+	return *m
+}
+
+// dropOldest removes the mismatch seen least recently; the caller holds statsMu
+func (s *Scrubber) dropOldest() {
+	var oldest mismatchKey
+	var oldestSeen time.Time
+	for key, m := range s.mismatches {
+		if oldestSeen.IsZero() || m.LastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = key, m.LastSeen
+		}
+	}
+	delete(s.mismatches, oldest)
+}
@@ -0,0 +1,214 @@
+package scrub
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// versionStore keeps page versions and WAL in memory
+type versionStore struct {
+	pages map[uint32]map[uint32]map[uint64][]byte // space -> page -> LSN -> data
+	wal   map[uint64][]byte
+}
+
+func newVersionStore() *versionStore {
+	return &versionStore{pages: make(map[uint32]map[uint32]map[uint64][]byte), wal: make(map[uint64][]byte)}
+}
+
+func (vs *versionStore) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error {
+	if vs.pages[spaceID] == nil {
+		vs.pages[spaceID] = make(map[uint32]map[uint64][]byte)
+	}
+	if vs.pages[spaceID][pageNo] == nil {
+		vs.pages[spaceID][pageNo] = make(map[uint64][]byte)
+	}
+	vs.pages[spaceID][pageNo][lsn] = append([]byte(nil), data...)
+	return nil
+}
+
+func (vs *versionStore) LoadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+	var best uint64
+	var data []byte
+	for v, d := range vs.pages[spaceID][pageNo] {
+		if v <= lsn && (data == nil || v > best) {
+			best, data = v, d
+		}
+	}
+	if data == nil {
+		return nil, 0, storage.ErrPageNotFound
+	}
+	return append([]byte(nil), data...), best, nil
+}
+
+func (vs *versionStore) StoreWAL(lsn uint64, data []byte) error {
+	vs.wal[lsn] = data
+	return nil
+}
+
+func (vs *versionStore) LoadWAL(lsn uint64) ([]byte, error) {
+	data, ok := vs.wal[lsn]
+	if !ok {
+		return nil, storage.ErrWALNotFound
+	}
+	return data, nil
+}
+
+func (vs *versionStore) GetLatestLSN() uint64 { return 0 }
+func (vs *versionStore) Close() error         { return nil }
+
+func (vs *versionStore) ListSpaces() ([]uint32, error) {
+	var spaces []uint32
+	for spaceID := range vs.pages {
+		spaces = append(spaces, spaceID)
+	}
+	return spaces, nil
+}
+
+func (vs *versionStore) ListPageVersions(spaceID uint32) (map[uint32][]uint64, error) {
+	versions := make(map[uint32][]uint64)
+	for pageNo, lsns := range vs.pages[spaceID] {
+		for lsn := range lsns {
+			versions[pageNo] = append(versions[pageNo], lsn)
+		}
+	}
+	return versions, nil
+}
+
+func (vs *versionStore) DeletePageVersion(spaceID uint32, pageNo uint32, lsn uint64) error {
+	delete(vs.pages[spaceID][pageNo], lsn)
+	return nil
+}
+
+// redo is a stand-in for WAL application: the record is written at the
+// start of a 64-byte page
+func redo(page []byte, walData []byte, lsn uint64) ([]byte, error) {
+	out := make([]byte, 64)
+	copy(out, page)
+	copy(out, walData)
+	return out, nil
+}
+
+// ingest stores a WAL record and the page version it produces
+func ingest(t *testing.T, backend *versionStore, pageNo uint32, lsn uint64, walData string) {
+	t.Helper()
+	base, _, _ := backend.LoadPage(1, pageNo, lsn-1)
+	page, _ := redo(base, []byte(walData), lsn)
+	backend.StoreWAL(lsn, []byte(walData))
+	backend.StorePage(1, pageNo, lsn, page)
+}
+
+func TestScrubDetectsTamperedVersion(t *testing.T) {
+	backend := newVersionStore()
+	ingest(t, backend, 0, 10, "first")
+	ingest(t, backend, 0, 20, "second")
+	ingest(t, backend, 0, 30, "third!")
+
+	// Bit rot in the middle version
+	page, _, _ := backend.LoadPage(1, 0, 20)
+	page[40] ^= 0xff
+	page[41] ^= 0xff
+	backend.StorePage(1, 0, 20, page)
+
+	var invalidated []uint32
+	s := New(backend, redo, Options{PagesPerSecond: 100000})
+	stats, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.VersionsChecked != 3 || stats.Replayed != 3 || stats.ReplayMismatches != 2 || stats.Repaired != 0 {
+		t.Fatalf("stats: %+v", stats)
+	}
+	// The version after the tampered one replays from it and differs too
+	mismatches := s.Mismatches()
+	if len(mismatches) != 2 {
+		t.Fatalf("mismatches: %+v", mismatches)
+	}
+	for _, m := range mismatches {
+		if (m.LSN != 20 && m.LSN != 30) || m.Kind != KindReplay || m.ChangedBytes != 2 || m.Repaired {
+			t.Fatalf("mismatch: %+v", m)
+		}
+	}
+
+	// A second pass keeps one entry per mismatch
+	s.Run(context.Background())
+	if stats := s.Stats(); stats.ReplayMismatches != 2 || stats.Passes != 2 {
+		t.Fatalf("stats after a second pass: %+v", stats)
+	}
+
+	// With repair, the replayed version replaces the stored one. Versions are
+	// checked in LSN order, so the later one replays from the repaired page.
+	s = New(backend, redo, Options{
+		PagesPerSecond: 100000,
+		Repair:         true,
+		Invalidate:     func(spaceID, pageNo uint32) { invalidated = append(invalidated, pageNo) },
+	})
+	if stats, err := s.Run(context.Background()); err != nil || stats.Repaired != 1 {
+		t.Fatalf("repair pass: %+v, %v", stats, err)
+	}
+	if m := s.Mismatches(); len(m) != 1 || m[0].LSN != 20 || !m[0].Repaired || len(invalidated) != 1 {
+		t.Fatalf("repaired mismatch: %+v, invalidated %v", m, invalidated)
+	}
+	want, _ := redo([]byte("first"), []byte("second"), 20)
+	if got, _, _ := backend.LoadPage(1, 0, 20); string(got) != string(want) {
+		t.Fatal("tampered version not repaired")
+	}
+	if stats, _ := New(backend, redo, Options{PagesPerSecond: 100000}).Run(context.Background()); stats.ReplayMismatches != 0 {
+		t.Fatalf("mismatches after repair: %+v", stats)
+	}
+}
+
+func TestScrubChecksum(t *testing.T) {
+	backend := newVersionStore()
+
+	// Imported pages have no WAL and are checked against their checksum
+	page := make([]byte, innodb.DefaultPageSize)
+	binary.BigEndian.PutUint32(page[innodb.FilPageOffset:], 7)
+	page[innodb.FilPageData] = 1
+	size := len(page)
+	binary.BigEndian.PutUint32(page[size-4:], crc32.Checksum(page[:size-4], crc32.MakeTable(crc32.Castagnoli)))
+	backend.StorePage(2, 7, 5, page)
+
+	tampered := append([]byte(nil), page...)
+	tampered[innodb.FilPageData+10] = 0xee
+	backend.StorePage(2, 8, 5, tampered)
+	backend.StorePage(2, 9, 5, make([]byte, innodb.DefaultPageSize)) // Never written
+
+	s := New(backend, redo, Options{
+		PagesPerSecond: 100000,
+		SpaceFlags:     func(uint32) (uint32, bool) { return 0x15, true }, // full_crc32
+	})
+	stats, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.ChecksumChecked != 2 || stats.ChecksumErrors != 1 || stats.Skipped != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+	if m := s.Mismatches(); len(m) != 1 || m[0].PageNo != 8 || m[0].Kind != KindChecksum || m[0].Error == "" {
+		t.Fatalf("mismatches: %+v", m)
+	}
+}
+
+func TestScrubSkipsBelowCutoff(t *testing.T) {
+	backend := newVersionStore()
+	ingest(t, backend, 0, 10, "first")
+	ingest(t, backend, 0, 20, "second")
+	page, _, _ := backend.LoadPage(1, 0, 20)
+	page[50] = 1
+	backend.StorePage(1, 0, 20, page)
+
+	// GC may have removed the predecessor of versions at or below its cutoff
+	s := New(backend, redo, Options{PagesPerSecond: 100000, Cutoff: func() uint64 { return 20 }})
+	stats, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Skipped != 2 || stats.ReplayMismatches != 0 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
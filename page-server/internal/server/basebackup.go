@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/linux/projects/server/page-server/internal/basebackup"
+)
+
+// Basebackup collects the pages compute needs to start at lsn (0 for the
+// latest LSN). The LSN is protected from GC while the pages are loaded.
+// On a shard, pages stored by other shards are read from them with the
+// credentials in header.
+func (ps *PageServer) Basebackup(ctx context.Context, lsn uint64, header http.Header) (*basebackup.Backup, error) {
+	if lsn == 0 {
+		lsn = ps.LatestLSN()
+	}
+
+	var backup *basebackup.Backup
+	collect := func() error {
+		var err error
+		backup, err = basebackup.Collect(ctx, ps.pageLoader(header), lsn)
+		return err
+	}
+
+	var err error
+	if ps.GC == nil {
+		err = collect()
+	} else {
+		err = ps.GC.Protect(lsn, collect)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backup.Manifest.Spaces = ps.Catalog.List(lsn, false)
+	return backup, nil
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/linux/projects/server/page-server/internal/innodb"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// seedCatalog adds stored spaces the catalog does not know yet (data written
+// before the catalog existed) from the FSP header on their latest page 0
+func (ps *PageServer) seedCatalog() error {
+	lister, ok := ps.Storage.(storage.SpaceLister)
+	if !ok {
+		return nil
+	}
+	spaceIDs, err := lister.ListSpaces()
+	if err != nil {
+		return fmt.Errorf("failed to list spaces: %w", err)
+	}
+
+	seeded := 0
+	for _, spaceID := range spaceIDs {
+		if ps.Catalog.Has(spaceID) {
+			continue
+		}
+		page, pageLSN, err := ps.Storage.LoadPage(spaceID, 0, math.MaxUint64)
+		if err != nil {
+			log.Printf("Warning: Space %d has no readable page 0, not cataloged: %v", spaceID, err)
+			continue
+		}
+		hdr, err := innodb.ParseFSPHeader(page)
+		if err != nil || hdr.SpaceID != spaceID {
+			log.Printf("Warning: Space %d has no valid FSP header, not cataloged", spaceID)
+			continue
+		}
+		if err := ps.Catalog.Seed(hdr, pageLSN); err != nil {
+			return err
+		}
+		seeded++
+	}
+	if seeded > 0 {
+		log.Printf("Space catalog: added %d stored spaces", seeded)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/page-server/internal/logging"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// ReloadResult lists the settings changed by a reload
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Settings applied at runtime
+	RequiresRestart []string `json:"requires_restart"` // Changed settings that only take effect after a restart
+}
+
+// Config returns a copy of the live configuration
+func (ps *PageServer) Config() Config {
+	ps.cfgMu.RLock()
+	defer ps.cfgMu.RUnlock()
+	return ps.cfg
+}
+
+// MaxBatchPages returns the maximum number of pages per batch request
+func (ps *PageServer) MaxBatchPages() int {
+	ps.cfgMu.RLock()
+	defer ps.cfgMu.RUnlock()
+	if ps.cfg.Limits.MaxBatchPages > 0 {
+		return ps.cfg.Limits.MaxBatchPages
+	}
+	return DefaultMaxBatchPages
+}
+
+// SetConfigSource sets the function Reload uses to build a fresh configuration
+// (usually: re-read the config file and re-apply env and flag overrides)
+func (ps *PageServer) SetConfigSource(source func() (Config, error)) {
+	ps.cfgMu.Lock()
+	defer ps.cfgMu.Unlock()
+	ps.configSource = source
+}
+
+// Reload rebuilds the configuration from the config source and applies it
+func (ps *PageServer) Reload() (ReloadResult, error) {
+	ps.cfgMu.RLock()
+	source := ps.configSource
+	ps.cfgMu.RUnlock()
+
+	if source == nil {
+		return ReloadResult{}, fmt.Errorf("no config source: start the server with -config to enable reload")
+	}
+
+	cfg, err := source()
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return ps.ApplyConfig(cfg)
+}
+
+// ApplyConfig hot-applies the settings that are safe to change at runtime:
+// auth credentials and JWT keys, cache sizes, S3 credentials, log level, limits
+// and snapshot retention.
+// Other changed settings are reported in RequiresRestart and not applied.
+func (ps *PageServer) ApplyConfig(cfg Config) (ReloadResult, error) {
+	result := ReloadResult{Applied: []string{}, RequiresRestart: []string{}}
+
+	// Validate before changing anything
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return result, err
+	}
+	if cfg.CacheSize <= 0 {
+		return result, fmt.Errorf("cache_size must be positive")
+	}
+	certRoles, err := auth.ParseCertRoles(cfg.ClientCertRoles)
+	if err != nil {
+		return result, err
+	}
+	var verifier *auth.JWTVerifier
+	if cfg.JWTJWKS != "" {
+		// Re-read the key set on every reload so rotated keys are picked up
+		// without changing the path
+		verifier, err = auth.NewJWTVerifier(cfg.JWTJWKS, cfg.JWTIssuer, cfg.JWTAudience)
+		if err != nil {
+			return result, fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+	}
+
+	ps.cfgMu.Lock()
+	defer ps.cfgMu.Unlock()
+	old := ps.cfg
+
+	// Settings that are baked into the storage backend or the listener
+	restart := []struct {
+		name    string
+		changed bool
+	}{
+		{"data_dir", cfg.DataDir != old.DataDir},
+		{"storage_backend", cfg.StorageType != old.StorageType},
+		{"s3_endpoint", cfg.S3Endpoint != old.S3Endpoint},
+		{"s3_bucket", cfg.S3Bucket != old.S3Bucket},
+		{"s3_region", cfg.S3Region != old.S3Region},
+		{"s3_prefix", cfg.S3Prefix != old.S3Prefix},
+		{"s3_use_ssl", cfg.S3UseSSL != old.S3UseSSL},
+		{"gc", cfg.GC != old.GC},
+		{"scrub", cfg.Scrub != old.Scrub},
+		{"replication", cfg.Replication != old.Replication},
+		{"sharding", cfg.Sharding != old.Sharding},
+		{"wal", cfg.WAL != old.WAL},
+		{"tls", cfg.TLS != old.TLS},
+	}
+	for _, r := range restart {
+		if r.changed {
+			result.RequiresRestart = append(result.RequiresRestart, r.name)
+		}
+	}
+
+	if cfg.APIKey != old.APIKey || cfg.AuthTokens != old.AuthTokens {
+		ps.Auth.SetCredentials(cfg.APIKey, cfg.AuthTokens)
+		result.Applied = append(result.Applied, "auth")
+	}
+
+	// The same path may hold rotated keys
+	current := ps.Auth.JWTVerifier()
+	jwtChanged := cfg.JWTJWKS != old.JWTJWKS || cfg.JWTIssuer != old.JWTIssuer || cfg.JWTAudience != old.JWTAudience
+	if jwtChanged || verifier != nil && (current == nil || !current.SameKeys(verifier)) {
+		ps.Auth.SetJWTVerifier(verifier)
+		result.Applied = append(result.Applied, "jwt_jwks")
+	}
+
+	if cfg.ClientCertRoles != old.ClientCertRoles {
+		ps.Auth.SetCertRoles(certRoles)
+		result.Applied = append(result.Applied, "client_cert_roles")
+	}
+
+	if cfg.TenantID != old.TenantID || cfg.TimelineID != old.TimelineID {
+		ps.Auth.SetServedTenant(cfg.TenantID, cfg.TimelineID)
+		result.Applied = append(result.Applied, "tenant")
+	}
+
+	if cfg.CacheSize != old.CacheSize {
+		ps.Cache.Resize(cfg.CacheSize)
+		result.Applied = append(result.Applied, "cache_size")
+	}
+
+	if cfg.S3AccessKey != old.S3AccessKey || cfg.S3SecretKey != old.S3SecretKey {
+		if s3Storage := ps.s3Tier(); s3Storage != nil {
+			s3Storage.UpdateCredentials(cfg.S3AccessKey, cfg.S3SecretKey)
+			result.Applied = append(result.Applied, "s3_credentials")
+		}
+	}
+
+	if cfg.Tiers.LFCSizeBytes != old.Tiers.LFCSizeBytes {
+		if hybrid, ok := ps.Storage.(*storage.HybridStorage); ok {
+			hybrid.GetLFC().Resize(storage.DefaultLFCSize(cfg.Tiers.LFCSizeBytes))
+			result.Applied = append(result.Applied, "tiers.lfc_size_bytes")
+		}
+	}
+
+	if level != logging.GetLevel() {
+		logging.SetLevel(level)
+		result.Applied = append(result.Applied, "log_level")
+	}
+
+	if cfg.Snapshots != old.Snapshots {
+		// Read by the pruner on its next tick
+		result.Applied = append(result.Applied, "snapshots")
+	}
+
+	if cfg.Limits != old.Limits {
+		ps.Limiter.SetConfig(cfg.Limits.rateConfig())
+		ps.LoadPool.SetLimits(cfg.Limits.poolLimits())
+		result.Applied = append(result.Applied, "limits")
+	}
+
+	// Keep the old values of settings that need a restart so the live
+	// config always describes what the server is actually running with
+	cfg.DataDir = old.DataDir
+	cfg.StorageType = old.StorageType
+	cfg.S3Endpoint = old.S3Endpoint
+	cfg.S3Bucket = old.S3Bucket
+	cfg.S3Region = old.S3Region
+	cfg.S3Prefix = old.S3Prefix
+	cfg.S3UseSSL = old.S3UseSSL
+	cfg.GC = old.GC
+	cfg.Scrub = old.Scrub
+	cfg.Replication = old.Replication
+	cfg.Sharding = old.Sharding
+	cfg.WAL = old.WAL
+	cfg.TLS = old.TLS
+	ps.cfg = cfg
+
+	log.Printf("Configuration reloaded: applied=%v requires_restart=%v", result.Applied, result.RequiresRestart)
+	return result, nil
+}
+
+// s3Tier returns the S3 storage behind the backend, if any
+func (ps *PageServer) s3Tier() *storage.S3Storage {
+	switch s := ps.Storage.(type) {
+	case *storage.S3Storage:
+		return s
+	case *storage.HybridStorage:
+		return s.S3()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/logging"
+)
+
+// newConfigServer returns a page server with file storage in a temporary directory
+func newConfigServer(t *testing.T) *PageServer {
+	t.Helper()
+	ps, err := NewPageServer(Config{DataDir: t.TempDir(), CacheSize: 100})
+	if err != nil {
+		t.Fatalf("NewPageServer: %v", err)
+	}
+	t.Cleanup(func() { ps.Shutdown(context.Background()) })
+	return ps
+}
+
+func TestApplyConfig(t *testing.T) {
+	defer logging.SetLevel(logging.GetLevel())
+
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		applied []string
+		restart []string
+	}{
+		{"unchanged", func(cfg *Config) {}, nil, nil},
+		{"api key", func(cfg *Config) { cfg.APIKey = "secret" }, []string{"auth"}, nil},
+		{"auth tokens", func(cfg *Config) { cfg.AuthTokens = "token1" }, []string{"auth"}, nil},
+		{"client cert roles", func(cfg *Config) { cfg.ClientCertRoles = "spiffe://dev/cp=admin" }, []string{"client_cert_roles"}, nil},
+		{"tenant", func(cfg *Config) { cfg.TenantID = "t1" }, []string{"tenant"}, nil},
+		{"cache size", func(cfg *Config) { cfg.CacheSize = 200 }, []string{"cache_size"}, nil},
+		{"log level", func(cfg *Config) { cfg.LogLevel = "debug" }, []string{"log_level"}, nil},
+		{"snapshots", func(cfg *Config) { cfg.Snapshots.KeepLast = 3 }, []string{"snapshots"}, nil},
+		{"limits", func(cfg *Config) { cfg.Limits.MaxBatchPages = 10 }, []string{"limits"}, nil},
+		{"data dir", func(cfg *Config) { cfg.DataDir = "/elsewhere" }, nil, []string{"data_dir"}},
+		{"storage backend", func(cfg *Config) { cfg.StorageType = "s3" }, nil, []string{"storage_backend"}},
+		{"s3 bucket", func(cfg *Config) { cfg.S3Bucket = "other" }, nil, []string{"s3_bucket"}},
+		{"gc", func(cfg *Config) { cfg.GC.Enabled = true }, nil, []string{"gc"}},
+		{"scrub", func(cfg *Config) { cfg.Scrub.Interval = time.Hour }, nil, []string{"scrub"}},
+		{"replication", func(cfg *Config) { cfg.Replication.Role = "primary" }, nil, []string{"replication"}},
+		{"sharding", func(cfg *Config) { cfg.Sharding.Index = 1 }, nil, []string{"sharding"}},
+		{"wal", func(cfg *Config) { cfg.WAL.ApplyWorkers = 2 }, nil, []string{"wal"}},
+		{"tls paths", func(cfg *Config) { cfg.TLS.Cert = "/etc/new.crt" }, nil, []string{"tls"}},
+		{"runtime and restart settings together", func(cfg *Config) {
+			cfg.CacheSize = 300
+			cfg.DataDir = "/elsewhere"
+		}, []string{"cache_size"}, []string{"data_dir"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := newConfigServer(t)
+			logging.SetLevel(logging.LevelInfo)
+			before := ps.Config()
+			cfg := before
+			tt.change(&cfg)
+
+			result, err := ps.ApplyConfig(cfg)
+			if err != nil {
+				t.Fatalf("ApplyConfig: %v", err)
+			}
+			if len(result.Applied) != len(tt.applied) || len(tt.applied) > 0 && !reflect.DeepEqual(result.Applied, tt.applied) {
+				t.Fatalf("applied %v, want %v", result.Applied, tt.applied)
+			}
+			if len(result.RequiresRestart) != len(tt.restart) || len(tt.restart) > 0 && !reflect.DeepEqual(result.RequiresRestart, tt.restart) {
+				t.Fatalf("requires_restart %v, want %v", result.RequiresRestart, tt.restart)
+			}
+
+			// The live config keeps the running values of restart settings
+			live := ps.Config()
+			want := cfg
+			if len(tt.restart) > 0 {
+				want = before
+				want.CacheSize = cfg.CacheSize
+			}
+			if !reflect.DeepEqual(live, want) {
+				t.Fatalf("live config %+v, want %+v", live, want)
+			}
+
+			// Applying the same config again changes nothing
+			result, err = ps.ApplyConfig(cfg)
+			if err != nil || len(result.Applied) != 0 || len(result.RequiresRestart) != len(tt.restart) {
+				t.Fatalf("second reload: %+v, %v", result, err)
+			}
+		})
+	}
+}
+
+func TestApplyConfigRejectsInvalid(t *testing.T) {
+	ps := newConfigServer(t)
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+	}{
+		{"log level", func(cfg *Config) { cfg.LogLevel = "loud" }},
+		{"cache size", func(cfg *Config) { cfg.CacheSize = 0 }},
+		{"client cert roles", func(cfg *Config) { cfg.ClientCertRoles = "no-role" }},
+		{"jwks file", func(cfg *Config) { cfg.JWTJWKS = filepath.Join(t.TempDir(), "missing.json") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := ps.Config()
+			cfg := before
+			cfg.APIKey = "changed"
+			tt.change(&cfg)
+			if _, err := ps.ApplyConfig(cfg); err == nil {
+				t.Fatal("ApplyConfig succeeded")
+			}
+			if !reflect.DeepEqual(ps.Config(), before) {
+				t.Fatal("config changed by a rejected reload")
+			}
+		})
+	}
+}
+
+// writeJWKS writes a JWKS file with a new Ed25519 key
+func writeJWKS(t *testing.T, path string) {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{"kty": "OKP", "crv": "Ed25519", "kid": "k1", "x": base64.RawURLEncoding.EncodeToString(pub)}},
+	})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestApplyConfigJWKS(t *testing.T) {
+	ps := newConfigServer(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path)
+
+	reload := func(jwks string) []string {
+		t.Helper()
+		cfg := ps.Config()
+		cfg.JWTJWKS = jwks
+		result, err := ps.ApplyConfig(cfg)
+		if err != nil {
+			t.Fatalf("ApplyConfig: %v", err)
+		}
+		return result.Applied
+	}
+
+	if applied := reload(path); !reflect.DeepEqual(applied, []string{"jwt_jwks"}) || ps.Auth.JWTVerifier() == nil {
+		t.Fatalf("new JWKS path: applied %v", applied)
+	}
+	// Same path and keys
+	verifier := ps.Auth.JWTVerifier()
+	if applied := reload(path); len(applied) != 0 || ps.Auth.JWTVerifier() != verifier {
+		t.Fatalf("unchanged JWKS: applied %v", applied)
+	}
+	// Rotated keys at the same path
+	writeJWKS(t, path)
+	if applied := reload(path); !reflect.DeepEqual(applied, []string{"jwt_jwks"}) || ps.Auth.JWTVerifier() == verifier {
+		t.Fatalf("rotated keys: applied %v", applied)
+	}
+	// A new required issuer replaces the verifier
+	cfg := ps.Config()
+	cfg.JWTIssuer = "control-plane"
+	if result, err := ps.ApplyConfig(cfg); err != nil || !reflect.DeepEqual(result.Applied, []string{"jwt_jwks"}) {
+		t.Fatalf("new issuer: %+v, %v", result, err)
+	}
+	// Removed
+	if applied := reload(""); !reflect.DeepEqual(applied, []string{"jwt_jwks"}) || ps.Auth.JWTVerifier() != nil {
+		t.Fatalf("removed JWKS: applied %v", applied)
+	}
+	if applied := reload(""); len(applied) != 0 {
+		t.Fatalf("still no JWKS: applied %v", applied)
+	}
+}
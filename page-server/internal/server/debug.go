@@ -0,0 +1,16 @@
+package server
+
+import (
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// DecodeWAL decodes up to limit stored WAL records with from <= LSN <= to
+// (to 0: the latest LSN) and returns the LSN to continue at, or 0.
+// Returns wal.ErrWALListUnsupported for backends that cannot list WAL.
+func (ps *PageServer) DecodeWAL(from, to uint64, limit int, previewBytes int) ([]types.DecodedWAL, uint64, error) {
+	if to == 0 {
+		to = ps.LatestLSN()
+	}
+	return wal.DecodeStoredWAL(ps.Storage, from, to, limit, previewBytes)
+}
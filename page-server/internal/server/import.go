@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/importer"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// ErrImportRunning is returned when an import is started while another one runs
+var ErrImportRunning = errors.New("an import is already running")
+
+// importStateFile keeps the progress of the last import for resuming
+const importStateFile = "import_state.json"
+
+// importJob is a data directory import started through the API
+type importJob struct {
+	importer *importer.Importer
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// shardTarget drops imported pages stored by other shards; every shard
+// imports the same data directory
+type shardTarget struct {
+	ps     *PageServer
+	target importer.Target
+}
+
+func (t shardTarget) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error {
+	if !t.ps.OwnsPage(spaceID, pageNo) {
+		return nil
+	}
+	return t.target.StorePage(spaceID, pageNo, lsn, data)
+}
+
+// newImporter prepares an import of req.SourceDir
+func (ps *PageServer) newImporter(req types.StartImportRequest) (*importer.Importer, error) {
+	if ps.IsReplica() {
+		return nil, ErrReadOnlyReplica
+	}
+
+	// Hybrid storage uploads in the background; write imported pages to S3
+	// directly so the import is bounded by its parallelism
+	var target importer.Target = ps.Storage
+	if _, ok := ps.Storage.(*storage.HybridStorage); ok {
+		target = ps.s3Tier()
+	}
+	if ps.shards != nil {
+		target = shardTarget{ps: ps, target: target}
+	}
+
+	im, err := importer.New(importer.Options{
+		SourceDir:       req.SourceDir,
+		BaseLSN:         req.BaseLSN,
+		Parallelism:     req.Parallelism,
+		IgnoreChecksums: req.IgnoreChecksums,
+		StatePath:       filepath.Join(ps.Config().DataDir, importStateFile),
+	}, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Imported images must not hide newer page versions
+	if latest := ps.Storage.GetLatestLSN(); im.BaseLSN() < latest {
+		return nil, fmt.Errorf("base LSN %d is below the latest stored LSN %d", im.BaseLSN(), latest)
+	}
+	return im, nil
+}
+
+// RunImport imports a stopped MariaDB data directory and waits for it to finish
+func (ps *PageServer) RunImport(ctx context.Context, req types.StartImportRequest) (types.ImportStatus, error) {
+	im, err := ps.newImporter(req)
+	if err != nil {
+		return types.ImportStatus{}, err
+	}
+	err = ps.runImport(ctx, im)
+	return im.Status(), err
+}
+
+// StartImport imports a data directory in the background
+func (ps *PageServer) StartImport(req types.StartImportRequest) (types.ImportStatus, error) {
+	ps.importMu.Lock()
+	defer ps.importMu.Unlock()
+
+	if ps.importJob != nil {
+		select {
+		case <-ps.importJob.done:
+		default:
+			return types.ImportStatus{}, ErrImportRunning
+		}
+	}
+
+	im, err := ps.newImporter(req)
+	if err != nil {
+		return types.ImportStatus{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &importJob{importer: im, cancel: cancel, done: make(chan struct{})}
+	ps.importJob = job
+	go func() {
+		defer close(job.done)
+		defer cancel()
+		if err := ps.runImport(ctx, im); err != nil {
+			log.Printf("Import of %s failed: %v", req.SourceDir, err)
+		}
+	}()
+	return im.Status(), nil
+}
+
+// ImportStatus returns the progress of the last import started through the API
+func (ps *PageServer) ImportStatus() (types.ImportStatus, bool) {
+	ps.importMu.Lock()
+	defer ps.importMu.Unlock()
+	if ps.importJob == nil {
+		return types.ImportStatus{}, false
+	}
+	return ps.importJob.importer.Status(), true
+}
+
+// stopImport cancels a running import and waits for it; it resumes on the next start
+func (ps *PageServer) stopImport() {
+	ps.importMu.Lock()
+	job := ps.importJob
+	ps.importMu.Unlock()
+	if job != nil {
+		job.cancel()
+		<-job.done
+	}
+}
+
+// runImport imports the pages, then registers the spaces and makes the base
+// LSN the latest LSN so WAL from that point on applies on top
+func (ps *PageServer) runImport(ctx context.Context, im *importer.Importer) error {
+	status := im.Status()
+	log.Printf("Importing %s at LSN %d: %d files, %d spaces, %d pages (resumed=%v)",
+		status.SourceDir, status.BaseLSN, status.Files, status.Spaces, status.PagesTotal, status.Resumed)
+
+	if err := im.Run(ctx); err != nil {
+		return err
+	}
+
+	lsn := im.BaseLSN()
+	for _, space := range im.Spaces() {
+		if err := ps.Catalog.Register(space.Header, space.Name, lsn); err != nil {
+			return err
+		}
+	}
+
+	// An empty WAL record at the base LSN moves the latest LSN and survives restarts
+	if err := ps.Storage.StoreWAL(lsn, nil); err != nil {
+		return fmt.Errorf("failed to record base LSN: %w", err)
+	}
+	if flusher, ok := ps.Storage.(storage.Flusher); ok {
+		if pending, err := flusher.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush %d uploads: %w", pending, err)
+		}
+	}
+	if err := ps.LSNIndex.Record(time.Now(), lsn); err != nil {
+		log.Printf("Warning: failed to index base LSN %d: %v", lsn, err)
+	}
+	// Cached versions older than the base LSN would hide the imported images
+	ps.clearCaches()
+	if ps.Publisher != nil {
+		ps.Publisher.Reset(lsn)
+	}
+	// WAL before the base LSN is covered by the imported images
+	ps.Sequencer.Advance(lsn)
+
+	status = im.Status()
+	log.Printf("Import of %s complete: %d pages, %d never written, %d bad checksums; latest LSN is %d",
+		status.SourceDir, status.PagesDone, status.PagesSkipped, status.ChecksumErrors, lsn)
+	return nil
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/limits"
+)
+
+// rateConfig converts the limits section to limiter settings
+func (l LimitsConfig) rateConfig() limits.RateConfig {
+	return limits.RateConfig{
+		RequestsPerSecond: l.RequestsPerSecond,
+		RequestBurst:      l.RequestBurst,
+		BytesPerSecond:    l.BytesPerSecond,
+		BytesBurst:        l.BytesBurst,
+	}
+}
+
+// poolLimits returns the load pool size and queue timeout, applying defaults
+func (l LimitsConfig) poolLimits() (int, time.Duration) {
+	maxLoads := l.MaxConcurrentLoads
+	if maxLoads <= 0 {
+		maxLoads = DefaultMaxConcurrentLoads
+	}
+	queueTimeout := l.LoadQueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = DefaultLoadQueueTimeout
+	}
+	return maxLoads, queueTimeout
+}
+
+// LoadPage loads a page from storage through the bounded load pool.
+// Returns limits.ErrQueueTimeout if no slot frees up in time, and
+// ErrNotReplicated on a replica that has not replicated lsn yet, and
+// ErrWrongShard for pages stored by another shard.
+func (ps *PageServer) LoadPage(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+	return ps.loadPage(ctx, spaceID, pageNo, lsn, lsn)
+}
+
+// loadPage is LoadPage for a page that did not change after notModifiedSince
+func (ps *PageServer) loadPage(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64, notModifiedSince uint64) ([]byte, uint64, error) {
+	if err := ps.checkShard(spaceID, pageNo); err != nil {
+		return nil, 0, err
+	}
+	if ps.Follower != nil {
+		if err := ps.checkReplicated(lsn, ps.Follower.LSN()); err != nil {
+			return nil, 0, err
+		}
+	}
+	// Records received for the page may still be queued for its apply worker
+	if err := ps.WALProcessor.WaitForPage(ctx, spaceID, pageNo, notModifiedSince); err != nil {
+		return nil, 0, err
+	}
+	if err := ps.LoadPool.Acquire(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer ps.LoadPool.Release()
+
+	return ps.Storage.LoadPage(spaceID, pageNo, lsn)
+}
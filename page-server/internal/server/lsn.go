@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResolveLSN returns lsn, or the largest LSN committed at or before timestamp
+// (RFC 3339) if one is given. Returns an error wrapping lsnindex.ErrBeforeFirst
+// if no WAL was recorded by then.
+func (ps *PageServer) ResolveLSN(lsn uint64, timestamp string) (uint64, error) {
+	if timestamp == "" {
+		return lsn, nil
+	}
+	if lsn != 0 {
+		return 0, fmt.Errorf("lsn and timestamp are mutually exclusive")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q (want RFC 3339): %w", timestamp, err)
+	}
+
+	entry, err := ps.LSNIndex.Lookup(t)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", timestamp, err)
+	}
+	return entry.LSN, nil
+}
+
+// RecordCommit adds a WAL record to the timestamp index.
+// commitTime is RFC 3339 or empty for now.
+func (ps *PageServer) RecordCommit(lsn uint64, commitTime string) error {
+	t := time.Now()
+	if commitTime != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, commitTime); err != nil {
+			return fmt.Errorf("invalid commit_time %q (want RFC 3339): %w", commitTime, err)
+		}
+	}
+	return ps.RecordCommitTime(lsn, t)
+}
+
+// RecordCommitTime adds a WAL record committed at t to the timestamp index
+func (ps *PageServer) RecordCommitTime(lsn uint64, t time.Time) error {
+	return ps.LSNIndex.Record(t, lsn)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/linux/projects/server/page-server/internal/pagediff"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// ErrDiffUnsupported is returned when the storage backend cannot list page versions
+var ErrDiffUnsupported = errors.New("storage backend does not list page versions")
+
+// PageDiff returns the pages of a space with versions in (FromLSN, ToLSN]
+// and the token for the next page of results.
+// FromLSN is protected from GC so no version in the window is collected meanwhile.
+func (ps *PageServer) PageDiff(ctx context.Context, q pagediff.Query) ([]*types.PageChange, string, error) {
+	versioned, ok := ps.Storage.(storage.VersionedStorage)
+	if !ok {
+		return nil, "", ErrDiffUnsupported
+	}
+	if ps.Follower != nil {
+		if err := ps.checkReplicated(q.ToLSN, ps.Follower.LSN()); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var changes []*types.PageChange
+	var next string
+	diff := func() error {
+		versions, err := versioned.ListPageVersions(q.SpaceID)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && len(versions) == 0) {
+			return fmt.Errorf("space %d has no stored pages: %w", q.SpaceID, storage.ErrPageNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		changes, next, err = pagediff.Changes(ctx, ps.LoadPage, versions, q)
+		return err
+	}
+
+	var err error
+	if ps.GC == nil {
+		err = diff()
+	} else {
+		err = ps.GC.Protect(q.FromLSN, diff)
+	}
+	return changes, next, err
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/cache"
+)
+
+// ErrNotIngested is returned for reads whose not_modified_since is beyond the
+// WAL a primary has received, once waiting for that WAL timed out
+var ErrNotIngested = errors.New("lsn is not ingested yet")
+
+const (
+	// IngestWait is how long a read waits for the WAL up to its
+	// not_modified_since to arrive
+	IngestWait = 5 * time.Second
+	// ingestPollInterval is how often a waiting read checks the latest LSN
+	ingestPollInterval = 5 * time.Millisecond
+)
+
+// PageRead asks for the newest version of a page at LSN. NotModifiedSince
+// is the client's promise that the page did not change between it and LSN,
+// so only WAL up to there has to be applied before the page is served, and
+// a cached version known to be current at that point serves any LSN after it.
+type PageRead struct {
+	SpaceID          uint32
+	PageNo           uint32
+	LSN              uint64
+	Latest           bool   // Read at the newest LSN this server has instead of LSN
+	NotModifiedSince uint64 // 0 for no promise (the page may have changed up to LSN)
+}
+
+// since returns the LSN the page must be current at
+func (r PageRead) since() uint64 {
+	if r.NotModifiedSince == 0 {
+		return r.LSN
+	}
+	return r.NotModifiedSince
+}
+
+// ResolveRead turns a read of the latest version into a read at the newest
+// LSN this server has: the latest LSN on a primary, the replayed LSN on a
+// replica. A replica also answers a read beyond its replay horizon at the
+// horizon if the page did not change after it.
+func (ps *PageServer) ResolveRead(read PageRead) (PageRead, error) {
+	horizon := ps.LatestLSN()
+	if ps.Follower != nil {
+		horizon = ps.Follower.LSN()
+	}
+	if read.Latest {
+		read.Latest = false
+		read.LSN = max(horizon, read.NotModifiedSince)
+	}
+	if read.NotModifiedSince > read.LSN {
+		return read, fmt.Errorf("not_modified_since %d is after the request LSN %d", read.NotModifiedSince, read.LSN)
+	}
+	if ps.Follower != nil && read.NotModifiedSince != 0 && read.NotModifiedSince <= horizon && read.LSN > horizon {
+		read.LSN = horizon
+	}
+	return read, nil
+}
+
+// GetPage returns the newest version of a page at lsn (see ReadPage)
+func (ps *PageServer) GetPage(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+	return ps.ReadPage(ctx, PageRead{SpaceID: spaceID, PageNo: pageNo, LSN: lsn})
+}
+
+// ReadPage returns a page from the memory cache, or loads it through
+// LoadPage and caches it. A version loaded while the page was invalidated
+// is not cached. read must be resolved (see ResolveRead).
+func (ps *PageServer) ReadPage(ctx context.Context, read PageRead) ([]byte, uint64, error) {
+	since := read.since()
+
+	// The promise covers WAL this server may not have received yet
+	if read.NotModifiedSince != 0 {
+		if err := ps.waitForIngest(ctx, read.NotModifiedSince); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The cache may hold a version older than a record still being applied
+	if err := ps.WALProcessor.WaitForPage(ctx, read.SpaceID, read.PageNo, since); err != nil {
+		return nil, 0, err
+	}
+
+	var data []byte
+	var pageLSN, gen uint64
+	var found bool
+	var err error
+	ps.viewReplica(func(replicated uint64) {
+		if err = ps.checkReplicated(read.LSN, replicated); err != nil {
+			return
+		}
+		data, pageLSN, found = ps.Cache.Get(read.SpaceID, read.PageNo, read.LSN, since)
+		gen = ps.Cache.Generation()
+	})
+	if err != nil || found {
+		return data, pageLSN, err
+	}
+
+	data, pageLSN, err = ps.loadPage(ctx, read.SpaceID, read.PageNo, read.LSN, since)
+	if err != nil {
+		return nil, 0, err
+	}
+	ps.viewReplica(func(replicated uint64) {
+		// A version read at the newest LSN stays current until the WAL
+		// processor (or, on a replica, the follower) replaces or removes it.
+		// Otherwise it is only known current at since: another client's
+		// promise does not extend it.
+		horizon := ps.LatestLSN()
+		if ps.Follower != nil {
+			horizon = replicated
+		}
+		validThrough := since
+		if read.LSN >= horizon {
+			validThrough = cache.Newest
+		}
+		ps.Cache.PutIfGeneration(gen, read.SpaceID, read.PageNo, pageLSN, validThrough, data)
+	})
+	return data, pageLSN, nil
+}
+
+// waitForIngest waits until a primary has received the WAL up to lsn, for at
+// most IngestWait. A replica's reads are bounded by checkReplicated instead.
+func (ps *PageServer) waitForIngest(ctx context.Context, lsn uint64) error {
+	if ps.Follower != nil || ps.LatestLSN() >= lsn {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, IngestWait)
+	defer cancel()
+	ticker := time.NewTicker(ingestPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: lsn %d, latest lsn %d", ErrNotIngested, lsn, ps.LatestLSN())
+		case <-ticker.C:
+		}
+		if ps.LatestLSN() >= lsn {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/wal"
+)
+
+func TestReadWaitsForNotModifiedSince(t *testing.T) {
+	ps := newConfigServer(t)
+	ingest := func(lsn, prev uint64, b byte) {
+		t.Helper()
+		// WRITE of one byte at offset 64 of space 1 page 3
+		record := wal.WALRecord{LSN: lsn, PrevLSN: prev, SpaceID: 1, PageNo: 3, WALData: []byte{0x34, 1, 3, 64, b}}
+		if _, err := ps.IngestWAL(record); err != nil {
+			t.Fatalf("IngestWAL(%d): %v", lsn, err)
+		}
+	}
+	ingest(10, 0, 'a')
+
+	// The page is cached as the newest version at LSN 10
+	if page, lsn, err := ps.GetPage(context.Background(), 1, 3, 10); err != nil || lsn != 10 || page[64] != 'a' {
+		t.Fatalf("GetPage at 10: lsn=%d err=%v", lsn, err)
+	}
+
+	// WAL up to the promise has not arrived: no stale page from the cache
+	read, err := ps.ResolveRead(PageRead{SpaceID: 1, PageNo: 3, Latest: true, NotModifiedSince: 20})
+	if err != nil || read.LSN != 20 {
+		t.Fatalf("ResolveRead: %+v, %v", read, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := ps.ReadPage(ctx, read); !errors.Is(err, ErrNotIngested) {
+		t.Fatalf("ReadPage before the WAL arrived: %v", err)
+	}
+
+	// The read waits for the record and serves the page it wrote
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ingest(20, 10, 'b')
+	}()
+	page, lsn, err := ps.ReadPage(context.Background(), read)
+	if err != nil || lsn != 20 || page[64] != 'b' {
+		t.Fatalf("ReadPage after the WAL arrived: lsn=%d err=%v", lsn, err)
+	}
+}
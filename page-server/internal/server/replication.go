@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/linux/projects/server/page-server/internal/replication"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+const (
+	// RolePrimary publishes changes for replicas
+	RolePrimary = "primary"
+	// RoleReplica follows a primary and rejects writes
+	RoleReplica = "replica"
+)
+
+// ErrReadOnlyReplica is returned for writes to a replica
+var ErrReadOnlyReplica = errors.New("page server is a read-only replica")
+
+// ErrNotReplicated is returned for reads beyond a replica's replicated LSN
+var ErrNotReplicated = errors.New("lsn is not replicated yet")
+
+// validateReplication rejects replication settings the server cannot honour
+func validateReplication(cfg Config) error {
+	role := cfg.Replication.Role
+	switch role {
+	case "":
+		return nil
+	case RolePrimary, RoleReplica:
+	default:
+		return fmt.Errorf("unknown replication role: %s (supported: primary, replica)", role)
+	}
+
+	if cfg.StorageType != "s3" && cfg.StorageType != "hybrid" {
+		return fmt.Errorf("replication role %s requires the s3 or hybrid storage backend", role)
+	}
+	if role == RoleReplica {
+		if cfg.GC.Enabled {
+			return fmt.Errorf("gc.enabled must be off on a replica (the primary collects garbage)")
+		}
+		if cfg.Scrub.Repair {
+			return fmt.Errorf("scrub.repair must be off on a replica (the primary repairs pages)")
+		}
+	}
+	return nil
+}
+
+// startReplication starts publishing changes (primary) or following them (replica)
+func (ps *PageServer) startReplication(cfg ReplicationConfig) error {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultReplicationInterval
+	}
+	keep := cfg.KeepManifests
+	if keep <= 0 {
+		keep = DefaultKeepManifests
+	}
+	store := ps.s3Tier().ObjectStore()
+
+	switch cfg.Role {
+	case RolePrimary:
+		publisher, err := replication.NewPublisher(context.Background(), store, replication.PublisherOptions{
+			Interval:  interval,
+			Keep:      keep,
+			LatestLSN: ps.Storage.GetLatestLSN,
+			Flush:     ps.flushStorage,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start replication publisher: %w", err)
+		}
+		ps.Publisher = publisher
+		ps.WALProcessor.SetApplyHook(publisher.Applied)
+		publisher.Start()
+		log.Printf("Replication: publishing changes for replicas every %s", interval)
+
+	case RoleReplica:
+		follower := replication.NewFollower(store, replication.FollowerOptions{
+			Interval: interval,
+			Invalidate: func(page replication.PageRef) {
+				ps.invalidatePage(page.SpaceID, page.PageNo)
+			},
+			InvalidateAll: ps.clearCaches,
+		})
+		if err := follower.Poll(context.Background()); err != nil {
+			log.Printf("Warning: Failed to read the primary's replication state: %v", err)
+		}
+		ps.Follower = follower
+		follower.Start()
+		log.Printf("Replication: read-only replica at LSN %d, polling every %s", follower.LSN(), interval)
+	}
+	return nil
+}
+
+// flushStorage waits for background uploads to the object store
+func (ps *PageServer) flushStorage(ctx context.Context) error {
+	if flusher, ok := ps.Storage.(storage.Flusher); ok {
+		if pending, err := flusher.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush %d uploads: %w", pending, err)
+		}
+	}
+	return nil
+}
+
+// IsReplica returns true if the server is a read-only replica
+func (ps *PageServer) IsReplica() bool {
+	return ps.Follower != nil
+}
+
+// LatestLSN returns the newest LSN readers can request: the latest stored
+// LSN (on a shard, or skipped for another shard), or on a replica the
+// replicated LSN
+func (ps *PageServer) LatestLSN() uint64 {
+	if ps.Follower != nil {
+		return ps.Follower.LSN()
+	}
+	lsn := ps.Storage.GetLatestLSN()
+	if ps.shards != nil {
+		if skipped := ps.shards.skipLSN.Load(); skipped > lsn {
+			lsn = skipped
+		}
+	}
+	return lsn
+}
+
+// viewReplica runs fn while a replica's replicated LSN cannot advance,
+// so pages fn caches are dropped by the next manifest that changes them
+func (ps *PageServer) viewReplica(fn func(replicated uint64)) {
+	if ps.Follower == nil {
+		fn(0)
+		return
+	}
+	ps.Follower.View(fn)
+}
+
+// checkReplicated returns an error wrapping ErrNotReplicated if a replica
+// cannot serve lsn yet
+func (ps *PageServer) checkReplicated(lsn uint64, replicated uint64) error {
+	if ps.Follower != nil && lsn > replicated {
+		return fmt.Errorf("%w: lsn %d, replicated lsn %d", ErrNotReplicated, lsn, replicated)
+	}
+	return nil
+}
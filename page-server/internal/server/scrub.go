@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/linux/projects/server/page-server/internal/scrub"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// startScrub starts the background scrubber
+func (ps *PageServer) startScrub(cfg ScrubConfig) error {
+	backend, ok := ps.Storage.(scrub.Backend)
+	if !ok {
+		return fmt.Errorf("scrub.enabled is set but the storage backend does not support scrubbing")
+	}
+
+	opts := scrub.Options{
+		Interval:       cfg.Interval,
+		PagesPerSecond: cfg.PagesPerSecond,
+		Repair:         cfg.Repair,
+		SpaceFlags:     ps.spaceFlags,
+		Invalidate:     ps.invalidatePage,
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultScrubInterval
+	}
+	if opts.PagesPerSecond <= 0 {
+		opts.PagesPerSecond = DefaultScrubPagesPerSecond
+	}
+	if ps.GC != nil {
+		opts.Cutoff = ps.GC.Cutoff
+	}
+
+	ps.Scrubber = scrub.New(backend, ps.WALProcessor.Redo, opts)
+	ps.Scrubber.Start()
+	log.Printf("Page scrubber enabled: interval=%s pages_per_second=%d repair=%v", opts.Interval, opts.PagesPerSecond, opts.Repair)
+	return nil
+}
+
+// spaceFlags returns the tablespace flags recorded in the catalog
+func (ps *PageServer) spaceFlags(spaceID uint32) (uint32, bool) {
+	space, err := ps.Catalog.Get(spaceID, 0)
+	if err != nil {
+		return 0, false
+	}
+	return space.Flags, true
+}
+
+// invalidatePage drops a page from the memory cache and the LFC after one
+// of its versions was rewritten, and tells replicas to do the same
+func (ps *PageServer) invalidatePage(spaceID uint32, pageNo uint32) {
+	ps.Cache.Remove(spaceID, pageNo)
+	if hybrid, ok := ps.Storage.(*storage.HybridStorage); ok {
+		hybrid.GetLFC().Remove(spaceID, pageNo)
+	}
+	if ps.Publisher != nil {
+		ps.Publisher.Changed(spaceID, pageNo)
+	}
+}
+
+// clearCaches drops all pages from the memory cache and the LFC
+func (ps *PageServer) clearCaches() {
+	ps.Cache.Clear()
+	if hybrid, ok := ps.Storage.(*storage.HybridStorage); ok {
+		hybrid.GetLFC().Clear()
+	}
+}
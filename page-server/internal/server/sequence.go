@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/linux/projects/server/page-server/internal/wal"
+)
+
+// sequenceStateFile holds the applied LSN and the known gaps
+const sequenceStateFile = "wal_sequence.json"
+
+// startSequencer puts a Sequencer in front of the WAL processor
+func (ps *PageServer) startSequencer(cfg WALConfig) error {
+	if cfg.GapTimeout <= 0 {
+		cfg.GapTimeout = DefaultWALGapTimeout
+	}
+	if cfg.MaxBuffered <= 0 {
+		cfg.MaxBuffered = DefaultWALMaxBuffered
+	}
+
+	sequencer, err := wal.NewSequencer(ps.applyWAL, wal.SequencerOptions{
+		StatePath:   filepath.Join(ps.cfg.DataDir, sequenceStateFile),
+		InitialLSN:  ps.LatestLSN(),
+		GapTimeout:  cfg.GapTimeout,
+		MaxBuffered: cfg.MaxBuffered,
+		Backfill:    ps.WALProcessor.BackfillWALRecord,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start WAL sequencer: %w", err)
+	}
+	sequencer.Start()
+	ps.Sequencer = sequencer
+	return nil
+}
+
+// applyWAL applies a record the sequencer released
+func (ps *PageServer) applyWAL(record wal.WALRecord) error {
+	if err := ps.WALProcessor.ProcessWALRecord(record); err != nil {
+		return err
+	}
+	// On a shard, records for pages of other shards are not stored but
+	// still move the latest LSN
+	if record.SpaceID > 0 && record.PageNo > 0 && !ps.OwnsPage(record.SpaceID, record.PageNo) {
+		ps.SkippedRecord(record.LSN)
+	}
+	return nil
+}
+
+// IngestWAL passes a received WAL record to the sequencer, which applies
+// it, buffers it until the records before it arrive, or ignores it as a
+// duplicate
+func (ps *PageServer) IngestWAL(record wal.WALRecord) (wal.Outcome, error) {
+	return ps.Sequencer.Submit(record)
+}
+
+// WALSequence returns the received and applied LSNs and the known gaps
+func (ps *PageServer) WALSequence() wal.SequencerStatus {
+	return ps.Sequencer.Status()
+}
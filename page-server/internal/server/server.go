@@ -3,9 +3,19 @@ package server
 import (
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
-	"github.com/linux/projects/server/page-server/internal/auth"
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/common/lsnindex"
 	"github.com/linux/projects/server/page-server/internal/cache"
+	"github.com/linux/projects/server/page-server/internal/catalog"
+	"github.com/linux/projects/server/page-server/internal/export"
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/limits"
+	"github.com/linux/projects/server/page-server/internal/logging"
+	"github.com/linux/projects/server/page-server/internal/replication"
+	"github.com/linux/projects/server/page-server/internal/scrub"
 	"github.com/linux/projects/server/page-server/internal/snapshots"
 	"github.com/linux/projects/server/page-server/internal/storage"
 	"github.com/linux/projects/server/page-server/internal/wal"
@@ -15,32 +25,209 @@ import (
 type PageServer struct {
 	Storage         storage.StorageBackend
 	WALProcessor    *wal.WALProcessor
+	Sequencer       *wal.Sequencer // Orders records before the WAL processor (see sequence.go)
 	Cache           *cache.PageCache
 	Auth            *auth.AuthMiddleware
 	SnapshotManager *snapshots.SnapshotManager
+	Exports         *export.Manager        // Tablespace exports at snapshot LSNs
+	GC              *gc.Collector          // nil unless gc.enabled
+	Scrubber        *scrub.Scrubber        // nil unless scrub.enabled
+	LSNIndex        *lsnindex.Index        // Commit time to LSN, for timestamp queries
+	Catalog         *catalog.Catalog       // Tablespace sizes and lifetimes
+	Limiter         *limits.Limiter        // Per-principal rate limits
+	LoadPool        *limits.Pool           // Bounds concurrent storage loads
+	Publisher       *replication.Publisher // nil unless replication.role is primary
+	Follower        *replication.Follower  // nil unless replication.role is replica
+
+	// Key-space sharding (see sharding.go); nil unless sharding.map_file is set
+	shards *shardState
+
+	// Streaming WAL ingestion (see walstream.go)
+	streams *walStreams
+
+	// Live configuration (see config.go)
+	cfg          Config
+	cfgMu        sync.RWMutex
+	configSource func() (Config, error)
+
+	// Background snapshot pruning (see snapshots.go)
+	stopPruner chan struct{}
+	prunerDone chan struct{}
+
+	// Data directory import started through the API (see import.go)
+	importJob *importJob
+	importMu  sync.Mutex
 }
 
 // Config holds configuration for creating a PageServer
+// The yaml tags define the layout of the config file (see internal/config)
 type Config struct {
-	DataDir        string
-	CacheSize      int
-	StorageType    string
-	S3Endpoint     string
-	S3Bucket       string
-	S3Region       string
-	S3AccessKey    string
-	S3SecretKey    string
-	S3Prefix       string
-	S3UseSSL       bool
-	APIKey         string
-	AuthTokens     string
+	DataDir        string `yaml:"data_dir"`
+	CacheSize      int    `yaml:"cache_size"`
+	StorageType    string `yaml:"storage_backend"`
+	S3Endpoint     string `yaml:"s3_endpoint"`
+	S3Bucket       string `yaml:"s3_bucket"`
+	S3Region       string `yaml:"s3_region"`
+	S3AccessKey    string `yaml:"s3_access_key"`
+	S3SecretKey    string `yaml:"s3_secret_key"`
+	S3Prefix       string `yaml:"s3_prefix"`
+	S3UseSSL       bool   `yaml:"s3_use_ssl"`
+	APIKey         string `yaml:"api_key"`
+	AuthTokens     string `yaml:"auth_tokens"`
+	LogLevel       string `yaml:"log_level"`
+
+	// JWT authentication: tokens are verified against the JWKS file and,
+	// unless they carry the admin scope, must name the served tenant/timeline
+	JWTJWKS     string `yaml:"jwt_jwks"`
+	JWTIssuer   string `yaml:"jwt_issuer"`   // Required iss claim, if set
+	JWTAudience string `yaml:"jwt_audience"` // Required entry in the aud claim, if set
+	TenantID    string `yaml:"tenant_id"`
+	TimelineID  string `yaml:"timeline_id"`
+
+	// Roles for verified client certificates (mTLS), "pattern=role,..."
+	ClientCertRoles string `yaml:"client_cert_roles"`
+
+	TLS         TLSConfig         `yaml:"tls"`
+	Tiers       TiersConfig       `yaml:"tiers"`
+	GC          GCConfig          `yaml:"gc"`
+	Scrub       ScrubConfig       `yaml:"scrub"`
+	Limits      LimitsConfig      `yaml:"limits"`
+	Snapshots   SnapshotsConfig   `yaml:"snapshots"`
+	Replication ReplicationConfig `yaml:"replication"`
+	Sharding    ShardingConfig    `yaml:"sharding"`
+	WAL         WALConfig         `yaml:"wal"`
+}
+
+// TLSConfig holds the TLS settings of the HTTP listener. Changed paths need a
+// restart; the files themselves are re-read when they change.
+type TLSConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	Cert              string `yaml:"cert"`
+	Key               string `yaml:"key"`
+	ClientCA          string `yaml:"client_ca"`           // Enables mTLS
+	RequireClientCert bool   `yaml:"require_client_cert"` // Reject clients without a certificate
+}
+
+// TiersConfig holds settings for the hybrid storage tiers
+type TiersConfig struct {
+	LFCSizeBytes int64 `yaml:"lfc_size_bytes"` // Tier 2 size; 0 means 75% of RAM
+}
+
+// GCConfig holds settings for garbage collection of old page versions.
+// Versions within Horizon LSNs of the latest LSN and versions needed by
+// snapshots are never collected.
+type GCConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Horizon  uint64        `yaml:"horizon"` // LSN distance kept in full for time-travel
+}
+
+// ScrubConfig holds settings for the background scrubber, which verifies
+// stored page versions against WAL replay and InnoDB checksums
+type ScrubConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Interval       time.Duration `yaml:"interval"`         // Pause between passes
+	PagesPerSecond int           `yaml:"pages_per_second"` // Versions checked per second
+	Repair         bool          `yaml:"repair"`           // Overwrite versions that differ from their WAL replay
+}
+
+// SnapshotsConfig holds the snapshot retention policy for snapshots without a TTL.
+// All keep_* zero keeps every snapshot.
+type SnapshotsConfig struct {
+	KeepLast      int           `yaml:"keep_last"`
+	KeepHourly    int           `yaml:"keep_hourly"`
+	KeepDaily     int           `yaml:"keep_daily"`
+	KeepWeekly    int           `yaml:"keep_weekly"`
+	KeepMonthly   int           `yaml:"keep_monthly"`
+	PruneInterval time.Duration `yaml:"prune_interval"` // How often expired snapshots are deleted
+}
+
+// ReplicationConfig holds settings for read-only replicas sharing the object
+// store of a primary (s3 and hybrid backends only). Role is "primary"
+// (publish changes for replicas), "replica" (follow a primary, reject
+// writes) or empty for a standalone server.
+type ReplicationConfig struct {
+	Role          string        `yaml:"role"`
+	Interval      time.Duration `yaml:"interval"`       // How often the primary publishes and replicas poll
+	KeepManifests int           `yaml:"keep_manifests"` // Change manifests kept for replicas that fall behind
+}
+
+// ShardingConfig holds settings for spreading a tenant's pages over several
+// page servers. Every shard loads the same map (see pkg/sharding) and stores
+// only the pages the map assigns to its index.
+type ShardingConfig struct {
+	MapFile    string `yaml:"map_file"`    // JSON shard map; empty for an unsharded server
+	Index      int    `yaml:"index"`       // This server's position in the map
+	CAFile     string `yaml:"ca_file"`     // CA bundle for other shards' certificates (system roots otherwise)
+	ClientCert string `yaml:"client_cert"` // Certificate presented to other shards (optional)
+	ClientKey  string `yaml:"client_key"`
+}
+
+// WALConfig holds settings for ordering and applying incoming WAL records.
+// A record whose prev_lsn has not arrived waits up to GapTimeout; the missing
+// range is then recorded as a gap and listed by GET /api/v1/wal/gaps for
+// backfilling. Stored records are applied by ApplyWorkers workers, each page
+// always by the same one.
+type WALConfig struct {
+	GapTimeout   time.Duration `yaml:"gap_timeout"`   // How long records wait for a missing predecessor
+	MaxBuffered  int           `yaml:"max_buffered"`  // Waiting records before the oldest gap is skipped
+	ApplyWorkers int           `yaml:"apply_workers"` // Pages applied in parallel
+	ApplyQueue   int           `yaml:"apply_queue"`   // Records queued per worker before ingestion waits
+}
+
+// LimitsConfig holds request limits and admission control settings.
+// Rate limits apply per principal (auth token, API key or client IP); zero means unlimited.
+type LimitsConfig struct {
+	MaxBatchPages      int           `yaml:"max_batch_pages"`      // Maximum pages per get_pages request
+	RequestsPerSecond  float64       `yaml:"requests_per_second"`  // Read requests per second per principal
+	RequestBurst       int           `yaml:"request_burst"`        // Request bucket size
+	BytesPerSecond     int64         `yaml:"bytes_per_second"`     // Response bytes per second per principal
+	BytesBurst         int64         `yaml:"bytes_burst"`          // Byte bucket size
+	MaxConcurrentLoads int           `yaml:"max_concurrent_loads"` // Storage loads in flight across all requests
+	LoadQueueTimeout   time.Duration `yaml:"load_queue_timeout"`   // Maximum wait for a storage load slot
 }
 
+const (
+	// DefaultMaxBatchPages is used when Limits.MaxBatchPages is not set
+	DefaultMaxBatchPages = 1000
+	// DefaultMaxConcurrentLoads is used when Limits.MaxConcurrentLoads is not set
+	DefaultMaxConcurrentLoads = 64
+	// DefaultLoadQueueTimeout is used when Limits.LoadQueueTimeout is not set
+	DefaultLoadQueueTimeout = 5 * time.Second
+	// DefaultGCInterval is used when GC.Interval is not set
+	DefaultGCInterval = time.Hour
+	// DefaultGCHorizon is used when GC.Horizon is not set (64 MiB of WAL)
+	DefaultGCHorizon = 64 * 1024 * 1024
+	// DefaultScrubInterval is used when Scrub.Interval is not set
+	DefaultScrubInterval = 6 * time.Hour
+	// DefaultScrubPagesPerSecond is used when Scrub.PagesPerSecond is not set
+	DefaultScrubPagesPerSecond = 100
+	// DefaultPruneInterval is used when Snapshots.PruneInterval is not set
+	DefaultPruneInterval = 10 * time.Minute
+	// DefaultReplicationInterval is used when Replication.Interval is not set
+	DefaultReplicationInterval = time.Second
+	// DefaultKeepManifests is used when Replication.KeepManifests is not set
+	DefaultKeepManifests = 1000
+	// DefaultWALGapTimeout is used when WAL.GapTimeout is not set
+	DefaultWALGapTimeout = 5 * time.Second
+	// DefaultWALMaxBuffered is used when WAL.MaxBuffered is not set
+	DefaultWALMaxBuffered = 10000
+	// DefaultWALApplyWorkers is used when WAL.ApplyWorkers is not set
+	DefaultWALApplyWorkers = wal.DefaultApplyWorkers
+)
+
 // NewPageServer creates a new Page Server with persistent storage
 func NewPageServer(cfg Config) (*PageServer, error) {
+	if err := validateReplication(cfg); err != nil {
+		return nil, err
+	}
+	shards, err := loadSharding(cfg.Sharding)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Create storage backend based on type
 	var storageBackend storage.StorageBackend
-	var err error
 	
 	switch cfg.StorageType {
 	case "s3":
@@ -84,7 +271,7 @@ func NewPageServer(cfg Config) (*PageServer, error) {
 			Prefix:    cfg.S3Prefix,
 			UseSSL:    cfg.S3UseSSL,
 		}
-		storageBackend, err = storage.NewHybridStorage(cfg.DataDir, cfg.CacheSize, s3Config)
+		storageBackend, err = storage.NewHybridStorage(cfg.DataDir, cfg.CacheSize, cfg.Tiers.LFCSizeBytes, s3Config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create hybrid storage: %w", err)
 		}
@@ -109,10 +296,34 @@ func NewPageServer(cfg Config) (*PageServer, error) {
 	pageCache := cache.NewPageCache(cfg.CacheSize)
 	
 	// Create WAL processor
-	walProcessor := wal.NewWALProcessor(storageBackend, pageCache)
+	walProcessor := wal.NewWALProcessor(storageBackend, pageCache, wal.ApplyOptions{
+		Workers:   cfg.WAL.ApplyWorkers,
+		QueueSize: cfg.WAL.ApplyQueue,
+	})
 	
 	// Create auth middleware
 	authMiddleware := auth.NewAuthMiddleware(cfg.APIKey, cfg.AuthTokens)
+	if cfg.JWTJWKS != "" {
+		verifier, err := auth.NewJWTVerifier(cfg.JWTJWKS, cfg.JWTIssuer, cfg.JWTAudience)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+		authMiddleware.SetJWTVerifier(verifier)
+	}
+	authMiddleware.SetServedTenant(cfg.TenantID, cfg.TimelineID)
+	
+	certRoles, err := auth.ParseCertRoles(cfg.ClientCertRoles)
+	if err != nil {
+		return nil, err
+	}
+	authMiddleware.SetCertRoles(certRoles)
+	
+	// Tokens created through the admin API
+	tokenStore, err := auth.NewTokenStore(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token store: %w", err)
+	}
+	authMiddleware.SetTokenStore(tokenStore)
 	
 	// Create snapshot manager
 	snapshotManager, err := snapshots.NewSnapshotManager(cfg.DataDir)
@@ -120,12 +331,79 @@ func NewPageServer(cfg Config) (*PageServer, error) {
 		return nil, fmt.Errorf("failed to create snapshot manager: %w", err)
 	}
 	
-	return &PageServer{
+	// Timestamp to LSN index
+	lsnIndex, err := lsnindex.Open(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Tablespace catalog, kept current by the WAL processor
+	spaceCatalog, err := catalog.Open(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	walProcessor.SetObserver(spaceCatalog)
+	
+	// Apply log level
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logging.SetLevel(level)
+	
+	ps := &PageServer{
 		Storage:         storageBackend,
 		WALProcessor:    walProcessor,
 		Cache:           pageCache,
 		Auth:            authMiddleware,
 		SnapshotManager: snapshotManager,
-	}, nil
+		LSNIndex:        lsnIndex,
+		Catalog:         spaceCatalog,
+		Limiter:         limits.NewLimiter(cfg.Limits.rateConfig()),
+		LoadPool:        limits.NewPool(cfg.Limits.poolLimits()),
+		shards:          shards,
+		streams:         &walStreams{stop: make(chan struct{})},
+		cfg:             cfg,
+	}
+	if shards != nil {
+		walProcessor.SetPageFilter(ps.OwnsPage)
+	}
+	if err := ps.startSequencer(cfg.WAL); err != nil {
+		return nil, err
+	}
+	
+	if err := ps.seedCatalog(); err != nil {
+		log.Printf("Warning: Failed to seed space catalog: %v", err)
+	}
+	
+	// Exports read pages through the load pool like any other reader
+	lister, _ := storageBackend.(storage.SpaceLister)
+	var uploader export.Uploader
+	if s3Storage := ps.s3Tier(); s3Storage != nil {
+		uploader = s3Storage
+	}
+	ps.Exports, err = export.NewManager(cfg.DataDir, ps.LoadPage, lister, uploader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export manager: %w", err)
+	}
+	
+	if cfg.Replication.Role != "" {
+		if err := ps.startReplication(cfg.Replication); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.GC.Enabled {
+		if err := ps.startGC(cfg.GC); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Scrub.Enabled {
+		if err := ps.startScrub(cfg.Scrub); err != nil {
+			return nil, err
+		}
+	}
+	ps.startSnapshotPruner()
+	
+	return ps, nil
 }
 
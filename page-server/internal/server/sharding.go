@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/linux/projects/server/common/tlsutil"
+	"github.com/linux/projects/server/page-server/internal/basebackup"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/sharding"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// shardRequestTimeout bounds a request forwarded to another shard
+const shardRequestTimeout = 30 * time.Second
+
+// ErrWrongShard is returned for pages owned by another shard
+var ErrWrongShard = errors.New("page is owned by another shard")
+
+// ErrShardUnavailable is returned when another shard cannot serve forwarded pages
+var ErrShardUnavailable = errors.New("shard unavailable")
+
+// WrongShardError names the shard owning a page; it matches ErrWrongShard
+type WrongShardError struct {
+	SpaceID uint32
+	PageNo  uint32
+	Shard   int
+	URL     string
+}
+
+func (e *WrongShardError) Error() string {
+	return fmt.Sprintf("%v: space=%d page=%d belongs to shard %d (%s)", ErrWrongShard, e.SpaceID, e.PageNo, e.Shard, e.URL)
+}
+
+// Is makes errors.Is(err, ErrWrongShard) true
+func (e *WrongShardError) Is(target error) bool {
+	return target == ErrWrongShard
+}
+
+// shardState is the shard map and this server's place in it
+type shardState struct {
+	m      *sharding.Map
+	index  int
+	client *sharding.Client
+
+	skipped   atomic.Int64  // WAL records for other shards
+	skipLSN   atomic.Uint64 // Newest skipped record
+	forwarded atomic.Int64  // Pages read from other shards
+	failed    atomic.Int64  // Pages other shards could not be reached for
+}
+
+// ShardingStats reports a shard's routing counters
+type ShardingStats struct {
+	Shard          int    `json:"shard"`
+	Shards         int    `json:"shards"`
+	MapVersion     uint64 `json:"map_version"`
+	Scheme         string `json:"scheme"`
+	SkippedRecords int64  `json:"skipped_wal_records"`
+	ForwardedPages int64  `json:"forwarded_pages"`
+	FailedForwards int64  `json:"failed_forwards"`
+}
+
+// loadSharding loads the shard map; returns nil for an unsharded server
+func loadSharding(cfg ShardingConfig) (*shardState, error) {
+	if cfg.MapFile == "" {
+		return nil, nil
+	}
+	m, err := sharding.Load(cfg.MapFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Index < 0 || cfg.Index >= len(m.Shards) {
+		return nil, fmt.Errorf("shard index %d is out of range: the map has %d shards", cfg.Index, len(m.Shards))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" || cfg.ClientCert != "" {
+		tlsConfig, _, err := tlsutil.ClientTLSConfig(cfg.ClientCert, cfg.ClientKey, cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS for other shards: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	log.Printf("Sharding: shard %d of %d (%s scheme, map version %d)", cfg.Index, len(m.Shards), m.Scheme, m.Version)
+	return &shardState{
+		m:      m,
+		index:  cfg.Index,
+		client: &sharding.Client{HTTP: &http.Client{Transport: transport, Timeout: shardRequestTimeout}},
+	}, nil
+}
+
+// ShardMap returns the shard map and this server's index; nil if unsharded
+func (ps *PageServer) ShardMap() (*sharding.Map, int) {
+	if ps.shards == nil {
+		return nil, 0
+	}
+	return ps.shards.m, ps.shards.index
+}
+
+// OwnsPage returns true if this server stores the page
+func (ps *PageServer) OwnsPage(spaceID uint32, pageNo uint32) bool {
+	return ps.shards == nil || ps.shards.m.ShardFor(spaceID, pageNo) == ps.shards.index
+}
+
+// checkShard returns a WrongShardError if another shard stores the page
+func (ps *PageServer) checkShard(spaceID uint32, pageNo uint32) error {
+	if ps.shards == nil {
+		return nil
+	}
+	if owner := ps.shards.m.ShardFor(spaceID, pageNo); owner != ps.shards.index {
+		return &WrongShardError{SpaceID: spaceID, PageNo: pageNo, Shard: owner, URL: ps.shards.m.Shards[owner].URL}
+	}
+	return nil
+}
+
+// ShardingStats returns the routing counters; ok is false if unsharded
+func (ps *PageServer) ShardingStats() (ShardingStats, bool) {
+	s := ps.shards
+	if s == nil {
+		return ShardingStats{}, false
+	}
+	return ShardingStats{
+		Shard:          s.index,
+		Shards:         len(s.m.Shards),
+		MapVersion:     s.m.Version,
+		Scheme:         s.m.Scheme,
+		SkippedRecords: s.skipped.Load(),
+		ForwardedPages: s.forwarded.Load(),
+		FailedForwards: s.failed.Load(),
+	}, true
+}
+
+// SkippedRecord counts a WAL record left to another shard
+func (ps *PageServer) SkippedRecord(lsn uint64) {
+	s := ps.shards
+	if s == nil {
+		return
+	}
+	s.skipped.Add(1)
+	for {
+		old := s.skipLSN.Load()
+		if lsn <= old || s.skipLSN.CompareAndSwap(old, lsn) {
+			return
+		}
+	}
+}
+
+// GroupByShard splits page requests by owning shard; the values are the
+// indexes of the requests in pages
+func (ps *PageServer) GroupByShard(pages []types.PageRequest) map[int][]int {
+	groups := make(map[int][]int)
+	for i, page := range pages {
+		shard := 0
+		if ps.shards != nil {
+			shard = ps.shards.m.ShardFor(page.SpaceID, page.PageNo)
+		}
+		groups[shard] = append(groups[shard], i)
+	}
+	return groups
+}
+
+// ForwardPages reads pages from another shard with the credentials in header
+func (ps *PageServer) ForwardPages(ctx context.Context, shard int, header http.Header, pages []types.PageRequest) ([]types.PageResponse, error) {
+	s := ps.shards
+	if s == nil || shard == s.index || shard < 0 || shard >= len(s.m.Shards) {
+		return nil, fmt.Errorf("no shard %d to forward to", shard)
+	}
+	resp, err := s.client.GetPages(ctx, s.m.Shards[shard].URL, header, types.GetPagesRequest{Pages: pages})
+	if err != nil {
+		s.failed.Add(int64(len(pages)))
+		return nil, fmt.Errorf("%w: %w", ErrShardUnavailable, err)
+	}
+	s.forwarded.Add(int64(len(pages)))
+	return resp.Pages, nil
+}
+
+// pageLoader returns a loader reading local pages through LoadPage and other
+// shards' pages over HTTP with the credentials in header
+func (ps *PageServer) pageLoader(header http.Header) basebackup.PageLoader {
+	return func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+		err := ps.checkShard(spaceID, pageNo)
+		var wrong *WrongShardError
+		if !errors.As(err, &wrong) {
+			return ps.LoadPage(ctx, spaceID, pageNo, lsn)
+		}
+
+		pages, err := ps.ForwardPages(ctx, wrong.Shard, header, []types.PageRequest{{SpaceID: spaceID, PageNo: pageNo, LSN: lsn}})
+		if err != nil {
+			return nil, 0, err
+		}
+		page := pages[0]
+		switch {
+		case page.Missing:
+			return nil, 0, fmt.Errorf("%w: space=%d page=%d on shard %d", storage.ErrPageNotFound, spaceID, pageNo, wrong.Shard)
+		case page.Status != "success":
+			return nil, 0, fmt.Errorf("shard %d failed to load space=%d page=%d: %s", wrong.Shard, spaceID, pageNo, page.Error)
+		}
+		data, err := base64.StdEncoding.DecodeString(page.PageData)
+		if err != nil {
+			return nil, 0, fmt.Errorf("shard %d returned invalid page data: %w", wrong.Shard, err)
+		}
+		return data, page.PageLSN, nil
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// ShutdownReport describes what was left behind by a shutdown
+type ShutdownReport struct {
+	UnflushedUploads int   // Background writes to the lower tier that did not complete
+	FlushErr         error // Set if the flush deadline expired
+	CloseErr         error // Error returned by StorageBackend.Close
+}
+
+// Clean returns true if every queued write was flushed and storage closed cleanly
+func (r ShutdownReport) Clean() bool {
+	return r.UnflushedUploads == 0 && r.FlushErr == nil && r.CloseErr == nil
+}
+
+// String returns a one-line summary of the report
+func (r ShutdownReport) String() string {
+	if r.Clean() {
+		return "all queued writes flushed, storage closed"
+	}
+	s := fmt.Sprintf("unflushed_uploads=%d", r.UnflushedUploads)
+	if r.FlushErr != nil {
+		s += fmt.Sprintf(" flush_error=%q", r.FlushErr.Error())
+	}
+	if r.CloseErr != nil {
+		s += fmt.Sprintf(" close_error=%q", r.CloseErr.Error())
+	}
+	return s
+}
+
+// Shutdown stops WAL ingestion, flushes queued background writes until ctx
+// expires and closes the storage backend.
+// The HTTP server must already be shut down so no handler is still running.
+func (ps *PageServer) Shutdown(ctx context.Context) ShutdownReport {
+	var report ShutdownReport
+
+	// Apply records still waiting for a gap to fill, then wait for the WAL
+	// record being applied and reject further records
+	if err := ps.Sequencer.Close(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	ps.WALProcessor.Close()
+
+	// Stop snapshot pruning, GC and scrubbing before storage goes away
+	ps.stopBackground()
+
+	// Cancel running exports (they are recorded as failed)
+	ps.Exports.Close()
+
+	// Stop a running import; it resumes from its state file next time
+	ps.stopImport()
+
+	// Flush background uploads (hybrid storage writes to S3 asynchronously)
+	if flusher, ok := ps.Storage.(storage.Flusher); ok {
+		pending, err := flusher.Flush(ctx)
+		report.UnflushedUploads = pending
+		report.FlushErr = err
+		if err != nil {
+			log.Printf("Warning: Shutdown deadline reached with %d uploads still pending", pending)
+		}
+	}
+
+	// Publish the last changes so replicas catch up with everything flushed
+	if ps.Publisher != nil && report.FlushErr == nil {
+		if err := ps.Publisher.Publish(ctx); err != nil {
+			log.Printf("Warning: Failed to publish final replication manifest: %v", err)
+		}
+	}
+
+	// Persist token last-used times (informational, so only logged)
+	if store := ps.Auth.TokenStore(); store != nil {
+		if err := store.Flush(); err != nil {
+			log.Printf("Warning: failed to save token store: %v", err)
+		}
+	}
+
+	// Persist the newest timestamp index entry
+	if err := ps.LSNIndex.Close(); err != nil {
+		log.Printf("Warning: failed to close LSN index: %v", err)
+	}
+
+	if err := ps.Storage.Close(); err != nil {
+		report.CloseErr = err
+	}
+
+	return report
+}
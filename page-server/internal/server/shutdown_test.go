@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/export"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/internal/wal"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// shutdownLog records the shutdown steps in the order they happen
+type shutdownLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *shutdownLog) add(event string) {
+	l.mu.Lock()
+	l.events = append(l.events, event)
+	l.mu.Unlock()
+}
+
+// flushingStorage is a backend with background writes that records when
+// they are flushed and when it is closed
+type flushingStorage struct {
+	storage.StorageBackend
+	log      *shutdownLog
+	pending  int
+	flushErr error
+	closeErr error
+}
+
+func (s *flushingStorage) Flush(ctx context.Context) (int, error) {
+	s.log.add("flush")
+	return s.pending, s.flushErr
+}
+
+func (s *flushingStorage) Close() error {
+	s.log.add("close")
+	s.StorageBackend.Close()
+	return s.closeErr
+}
+
+// newShutdownServer returns a page server with a running export and import
+// that record when they are stopped, and storage that records the flush
+func newShutdownServer(t *testing.T, backend *flushingStorage) *PageServer {
+	t.Helper()
+	ps, err := NewPageServer(Config{DataDir: t.TempDir(), CacheSize: 100})
+	if err != nil {
+		t.Fatalf("NewPageServer: %v", err)
+	}
+	backend.StorageBackend = ps.Storage
+	ps.Storage = backend
+
+	// An export blocked loading page 0; ingestion must be closed by the time
+	// it is cancelled
+	started := make(chan struct{})
+	load := func(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
+		close(started)
+		<-ctx.Done()
+		if err := ps.WALProcessor.ProcessWALRecord(wal.WALRecord{LSN: 100}); !errors.Is(err, wal.ErrProcessorClosed) {
+			t.Errorf("WAL accepted while exports were running: %v", err)
+		}
+		backend.log.add("export")
+		return nil, 0, ctx.Err()
+	}
+	ps.Exports.Close()
+	ps.Exports, err = export.NewManager(t.TempDir(), load, nil, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	spaceID := uint32(5)
+	if _, err := ps.Exports.Start(&types.Snapshot{ID: "snap", LSN: 10}, types.CreateExportRequest{SpaceID: &spaceID}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &importJob{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		<-ctx.Done()
+		backend.log.add("import")
+		close(job.done)
+	}()
+	ps.importJob = job
+	return ps
+}
+
+func TestShutdownOrder(t *testing.T) {
+	backend := &flushingStorage{log: &shutdownLog{}}
+	ps := newShutdownServer(t, backend)
+
+	report := ps.Shutdown(context.Background())
+	if !report.Clean() {
+		t.Fatalf("report: %s", report)
+	}
+	want := []string{"export", "import", "flush", "close"}
+	if len(backend.log.events) != len(want) {
+		t.Fatalf("steps %v, want %v", backend.log.events, want)
+	}
+	for i := range want {
+		if backend.log.events[i] != want[i] {
+			t.Fatalf("steps %v, want %v", backend.log.events, want)
+		}
+	}
+}
+
+func TestShutdownReportsFailedSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *flushingStorage
+	}{
+		{"flush deadline", &flushingStorage{pending: 3, flushErr: context.DeadlineExceeded}},
+		{"close error", &flushingStorage{closeErr: errors.New("disk gone")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.backend.log = &shutdownLog{}
+			ps := newShutdownServer(t, tt.backend)
+
+			report := ps.Shutdown(context.Background())
+			if report.Clean() {
+				t.Fatalf("report is clean: %s", report)
+			}
+			if report.UnflushedUploads != tt.backend.pending || report.FlushErr != tt.backend.flushErr || report.CloseErr != tt.backend.closeErr {
+				t.Fatalf("report: %+v", report)
+			}
+			// Storage is closed even when the flush fails
+			if events := tt.backend.log.events; events[len(events)-1] != "close" {
+				t.Fatalf("steps: %v", events)
+			}
+		})
+	}
+}
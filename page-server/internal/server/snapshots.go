@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/gc"
+	"github.com/linux/projects/server/page-server/internal/snapshots"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// retentionPolicy converts the snapshots section to a retention policy
+func (s SnapshotsConfig) retentionPolicy() snapshots.RetentionPolicy {
+	return snapshots.RetentionPolicy{
+		KeepLast:    s.KeepLast,
+		KeepHourly:  s.KeepHourly,
+		KeepDaily:   s.KeepDaily,
+		KeepWeekly:  s.KeepWeekly,
+		KeepMonthly: s.KeepMonthly,
+	}
+}
+
+// pruneInterval returns the pruning interval, applying the default
+func (s SnapshotsConfig) pruneInterval() time.Duration {
+	if s.PruneInterval > 0 {
+		return s.PruneInterval
+	}
+	return DefaultPruneInterval
+}
+
+// gcStateFile holds the GC cutoff
+const gcStateFile = "gc_state.json"
+
+// startGC starts the page version collector
+func (ps *PageServer) startGC(cfg GCConfig) error {
+	versioned, ok := ps.Storage.(storage.VersionedStorage)
+	if !ok {
+		return fmt.Errorf("gc.enabled is set but the storage backend does not support GC")
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultGCInterval
+	}
+	horizon := cfg.Horizon
+	if horizon == 0 {
+		horizon = DefaultGCHorizon
+	}
+
+	collector, err := gc.NewCollector(versioned, ps.Storage.GetLatestLSN, ps.pinnedLSNs, horizon, interval,
+		filepath.Join(ps.cfg.DataDir, gcStateFile))
+	if err != nil {
+		return err
+	}
+	ps.GC = collector
+	ps.GC.Start()
+	log.Printf("Page version GC enabled: interval=%s horizon=%d", interval, horizon)
+	return nil
+}
+
+// pinnedLSNs returns the LSNs whose page versions GC must keep
+func (ps *PageServer) pinnedLSNs() []uint64 {
+	return append(ps.SnapshotManager.PinnedLSNs(), ps.Exports.ActiveLSNs()...)
+}
+
+// CreateSnapshot creates a snapshot that pins its LSN against GC.
+// Returns an error wrapping gc.ErrBelowCutoff if the LSN's page versions may already be gone.
+// Replicas return ErrReadOnlyReplica: their snapshots would not pin the primary's GC.
+func (ps *PageServer) CreateSnapshot(lsn uint64, description string, labels map[string]string, ttl time.Duration) (*types.Snapshot, error) {
+	if ps.IsReplica() {
+		return nil, ErrReadOnlyReplica
+	}
+	if ps.GC == nil {
+		return ps.SnapshotManager.CreateSnapshot(lsn, description, labels, ttl)
+	}
+
+	var snapshot *types.Snapshot
+	err := ps.GC.Protect(lsn, func() error {
+		var err error
+		snapshot, err = ps.SnapshotManager.CreateSnapshot(lsn, description, labels, ttl)
+		return err
+	})
+	return snapshot, err
+}
+
+// PruneSnapshots deletes expired snapshots and applies the retention policy
+func (ps *PageServer) PruneSnapshots() ([]*types.Snapshot, error) {
+	deleted, err := ps.SnapshotManager.Prune(ps.Config().Snapshots.retentionPolicy(), time.Now())
+	for _, snapshot := range deleted {
+		log.Printf("Snapshot pruned: id=%s lsn=%d", snapshot.ID, snapshot.LSN)
+	}
+	return deleted, err
+}
+
+// startSnapshotPruner prunes snapshots periodically; the interval and
+// policy are read from the live config so reloads take effect
+func (ps *PageServer) startSnapshotPruner() {
+	ps.stopPruner = make(chan struct{})
+	ps.prunerDone = make(chan struct{})
+
+	go func() {
+		defer close(ps.prunerDone)
+		for {
+			select {
+			case <-ps.stopPruner:
+				return
+			case <-time.After(ps.Config().Snapshots.pruneInterval()):
+				if _, err := ps.PruneSnapshots(); err != nil {
+					log.Printf("Warning: snapshot pruning failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopBackground stops snapshot pruning, GC, the scrubber and replication
+func (ps *PageServer) stopBackground() {
+	close(ps.stopPruner)
+	<-ps.prunerDone
+	if ps.GC != nil {
+		ps.GC.Stop()
+	}
+	if ps.Scrubber != nil {
+		ps.Scrubber.Stop()
+	}
+	if ps.Publisher != nil {
+		ps.Publisher.Stop()
+	}
+	if ps.Follower != nil {
+		ps.Follower.Stop()
+	}
+}
+
+// StartExport starts an export of a snapshot, pinning its LSN against GC
+func (ps *PageServer) StartExport(snapshot *types.Snapshot, req types.CreateExportRequest) (*types.ExportJob, error) {
+	if ps.GC == nil {
+		return ps.Exports.Start(snapshot, req)
+	}
+
+	var job *types.ExportJob
+	err := ps.GC.Protect(snapshot.LSN, func() error {
+		var err error
+		job, err = ps.Exports.Start(snapshot, req)
+		return err
+	})
+	return job, err
+}
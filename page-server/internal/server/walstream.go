@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// ErrShuttingDown ends WAL streams open when the server shuts down
+var ErrShuttingDown = errors.New("page server is shutting down")
+
+// walStreams tracks the open streaming WAL connections
+type walStreams struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	open    atomic.Int64
+	records atomic.Int64
+	syncs   atomic.Int64
+}
+
+// WALStreamStats reports streaming WAL ingestion
+type WALStreamStats struct {
+	Open    int64 `json:"open"`    // Streams currently connected
+	Records int64 `json:"records"` // Records received over streams
+	Syncs   int64 `json:"syncs"`   // Group fsyncs of stored WAL
+}
+
+// StreamOpened counts an open WAL stream; call the returned function when it closes
+func (ps *PageServer) StreamOpened() func() {
+	ps.streams.open.Add(1)
+	return func() { ps.streams.open.Add(-1) }
+}
+
+// StreamedRecord counts a record received over a WAL stream
+func (ps *PageServer) StreamedRecord() {
+	ps.streams.records.Add(1)
+}
+
+// StopStreams asks open WAL streams to end; called when the HTTP server
+// starts shutting down, which waits for in-flight requests
+func (ps *PageServer) StopStreams() {
+	ps.streams.stopOnce.Do(func() { close(ps.streams.stop) })
+}
+
+// StreamsStopping is closed once open WAL streams must end
+func (ps *PageServer) StreamsStopping() <-chan struct{} {
+	return ps.streams.stop
+}
+
+// WALStreamStats returns the streaming WAL counters
+func (ps *PageServer) WALStreamStats() WALStreamStats {
+	return WALStreamStats{
+		Open:    ps.streams.open.Load(),
+		Records: ps.streams.records.Load(),
+		Syncs:   ps.streams.syncs.Load(),
+	}
+}
+
+// SyncWAL makes every WAL record stored so far durable, on backends that
+// buffer them. Streams call it once per acknowledgement (group fsync).
+func (ps *PageServer) SyncWAL() error {
+	syncer, ok := ps.Storage.(storage.WALSyncer)
+	if !ok {
+		return nil
+	}
+	ps.streams.syncs.Add(1)
+	return syncer.SyncWAL()
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,17 +41,24 @@ func NewSnapshotManager(baseDir string) (*SnapshotManager, error) {
 	return sm, nil
 }
 
-// CreateSnapshot creates a new snapshot at the current LSN
-func (sm *SnapshotManager) CreateSnapshot(lsn uint64, description string) (*types.Snapshot, error) {
+// CreateSnapshot creates a new snapshot at the given LSN.
+// A snapshot with a positive ttl expires after it; others follow the retention policy.
+func (sm *SnapshotManager) CreateSnapshot(lsn uint64, description string, labels map[string]string, ttl time.Duration) (*types.Snapshot, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	snapshotID := fmt.Sprintf("snapshot_%d_%d", lsn, time.Now().Unix())
+	now := time.Now()
+	snapshotID := fmt.Sprintf("snapshot_%d_%d", lsn, now.UnixNano())
 	snapshot := &types.Snapshot{
 		ID:          snapshotID,
 		LSN:         lsn,
-		Timestamp:   time.Now(),
+		Timestamp:   now,
 		Description: description,
+		Labels:      labels,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		snapshot.ExpiresAt = &expiresAt
 	}
 
 	// Save snapshot metadata
@@ -81,19 +91,132 @@ func (sm *SnapshotManager) GetSnapshot(id string) (*types.Snapshot, error) {
 	return &snapshotCopy, nil
 }
 
-// ListSnapshots returns all snapshots
+// ListSnapshots returns copies of all snapshots, oldest first
 func (sm *SnapshotManager) ListSnapshots() []*types.Snapshot {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	snapshots := make([]*types.Snapshot, 0, len(sm.snapshots))
 	for _, snapshot := range sm.snapshots {
-		snapshots = append(snapshots, snapshot)
+		snapshotCopy := *snapshot
+		snapshots = append(snapshots, &snapshotCopy)
 	}
+	sortSnapshots(snapshots)
 
 	return snapshots
 }
 
+// ListOptions selects a page of snapshots
+type ListOptions struct {
+	Labels    map[string]string // Only snapshots with all of these labels
+	Limit     int               // Page size; 0 means DefaultPageSize
+	PageToken string            // NextPageToken of the previous page
+}
+
+const (
+	// DefaultPageSize is the page size when ListOptions.Limit is not set
+	DefaultPageSize = 100
+	// MaxPageSize caps ListOptions.Limit
+	MaxPageSize = 1000
+)
+
+// ListPage returns one page of snapshots, oldest first, the number of
+// matching snapshots and the token of the next page ("" on the last page)
+func (sm *SnapshotManager) ListPage(opts ListOptions) ([]*types.Snapshot, int, string, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	var after *types.Snapshot
+	if opts.PageToken != "" {
+		var err error
+		if after, err = parsePageToken(opts.PageToken); err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	var matching []*types.Snapshot
+	for _, snapshot := range sm.ListSnapshots() {
+		if hasLabels(snapshot, opts.Labels) {
+			matching = append(matching, snapshot)
+		}
+	}
+
+	// Snapshots are sorted, so the page starts after the token's position
+	// even if the snapshot it names was deleted in the meantime
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matching), func(i int) bool { return snapshotLess(after, matching[i]) })
+	}
+	end := start + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	page := matching[start:end]
+	next := ""
+	if end < len(matching) {
+		next = pageToken(page[len(page)-1])
+	}
+	return page, len(matching), next, nil
+}
+
+// PinnedLSNs returns the LSNs of all snapshots; GC keeps their page versions
+func (sm *SnapshotManager) PinnedLSNs() []uint64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	lsns := make([]uint64, 0, len(sm.snapshots))
+	for _, snapshot := range sm.snapshots {
+		lsns = append(lsns, snapshot.LSN)
+	}
+	return lsns
+}
+
+// sortSnapshots orders snapshots by creation time, then ID
+func sortSnapshots(snapshots []*types.Snapshot) {
+	sort.Slice(snapshots, func(i, j int) bool { return snapshotLess(snapshots[i], snapshots[j]) })
+}
+
+func snapshotLess(a, b *types.Snapshot) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.ID < b.ID
+}
+
+// pageToken encodes a snapshot's sort position
+func pageToken(snapshot *types.Snapshot) string {
+	return fmt.Sprintf("%d:%s", snapshot.Timestamp.UnixNano(), snapshot.ID)
+}
+
+// parsePageToken decodes a page token into a sort position
+func parsePageToken(token string) (*types.Snapshot, error) {
+	nanos, id, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	return &types.Snapshot{ID: id, Timestamp: time.Unix(0, n)}, nil
+}
+
+// hasLabels reports whether the snapshot carries every wanted label
+func hasLabels(snapshot *types.Snapshot, want map[string]string) bool {
+	for k, v := range want {
+		if snapshot.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
 // DeleteSnapshot deletes a snapshot
 func (sm *SnapshotManager) DeleteSnapshot(id string) error {
 	sm.mu.Lock()
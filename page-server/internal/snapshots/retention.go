@@ -0,0 +1,97 @@
+package snapshots
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// RetentionPolicy decides which snapshots without a TTL are kept.
+// KeepHourly..KeepMonthly keep the newest snapshot of each of the N most
+// recent hours, days, ISO weeks and months that have one. A snapshot is kept
+// if any rule keeps it. The zero policy keeps everything.
+type RetentionPolicy struct {
+	KeepLast    int // Newest N snapshots
+	KeepHourly  int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// Enabled returns true if the policy removes anything
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepHourly > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// Prune deletes snapshots whose TTL has passed and, if the policy is
+// enabled, snapshots without a TTL that the policy does not keep.
+// It returns the deleted snapshots.
+func (sm *SnapshotManager) Prune(policy RetentionPolicy, now time.Time) ([]*types.Snapshot, error) {
+	var expired, governed []*types.Snapshot
+	for _, snapshot := range sm.ListSnapshots() {
+		if snapshot.ExpiresAt != nil {
+			if !now.Before(*snapshot.ExpiresAt) {
+				expired = append(expired, snapshot)
+			}
+			continue
+		}
+		governed = append(governed, snapshot)
+	}
+
+	doomed := expired
+	if policy.Enabled() {
+		keep := policy.keep(governed)
+		for _, snapshot := range governed {
+			if !keep[snapshot.ID] {
+				doomed = append(doomed, snapshot)
+			}
+		}
+	}
+
+	var deleted []*types.Snapshot
+	for _, snapshot := range doomed {
+		if err := sm.DeleteSnapshot(snapshot.ID); err != nil {
+			return deleted, fmt.Errorf("failed to prune snapshot %s: %w", snapshot.ID, err)
+		}
+		deleted = append(deleted, snapshot)
+	}
+	return deleted, nil
+}
+
+// keep returns the IDs of snapshots the policy keeps; snapshots must be sorted oldest first
+func (p RetentionPolicy) keep(snapshots []*types.Snapshot) map[string]bool {
+	keep := make(map[string]bool)
+
+	rules := []struct {
+		count  int
+		bucket func(s *types.Snapshot) string
+	}{
+		{p.KeepLast, func(s *types.Snapshot) string { return s.ID }}, // Every snapshot is its own bucket
+		{p.KeepHourly, func(s *types.Snapshot) string { return s.Timestamp.UTC().Format("2006-01-02T15") }},
+		{p.KeepDaily, func(s *types.Snapshot) string { return s.Timestamp.UTC().Format("2006-01-02") }},
+		{p.KeepWeekly, func(s *types.Snapshot) string {
+			year, week := s.Timestamp.UTC().ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.KeepMonthly, func(s *types.Snapshot) string { return s.Timestamp.UTC().Format("2006-01") }},
+	}
+
+	for _, rule := range rules {
+		kept := 0
+		last := ""
+		// Newest first: the first snapshot seen in a bucket is its newest
+		for j := len(snapshots) - 1; j >= 0 && kept < rule.count; j-- {
+			snapshot := snapshots[j]
+			bucket := rule.bucket(snapshot)
+			if kept > 0 && bucket == last {
+				continue
+			}
+			keep[snapshot.ID] = true
+			last = bucket
+			kept++
+		}
+	}
+
+	return keep
+}
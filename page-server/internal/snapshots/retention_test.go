@@ -0,0 +1,94 @@
+package snapshots
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// create adds a snapshot taken at ts
+func create(t *testing.T, sm *SnapshotManager, lsn uint64, ts time.Time, ttl time.Duration) string {
+	t.Helper()
+	snapshot, err := sm.CreateSnapshot(lsn, "", nil, ttl)
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	sm.mu.Lock()
+	sm.snapshots[snapshot.ID].Timestamp = ts
+	if ttl > 0 {
+		expiresAt := ts.Add(ttl)
+		sm.snapshots[snapshot.ID].ExpiresAt = &expiresAt
+	}
+	sm.mu.Unlock()
+	return snapshot.ID
+}
+
+// remaining returns the LSNs of the snapshots left, ascending
+func remaining(sm *SnapshotManager) []uint64 {
+	lsns := sm.PinnedLSNs()
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	return lsns
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	// Snapshots at LSN 1..6, hourly on the 10th plus one each on the 9th and 8th
+	times := []time.Time{
+		now.Add(-50 * time.Hour), // 1: March 8
+		now.Add(-26 * time.Hour), // 2: March 9
+		now.Add(-3 * time.Hour),  // 3: 09:30
+		now.Add(-2 * time.Hour),  // 4: 10:30
+		now.Add(-time.Hour),      // 5: 11:30
+		now.Add(-time.Minute),    // 6: 12:29
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []uint64
+	}{
+		{"zero policy keeps everything", RetentionPolicy{}, []uint64{1, 2, 3, 4, 5, 6, 8}},
+		{"keep last", RetentionPolicy{KeepLast: 2}, []uint64{5, 6, 8}},
+		{"hourly", RetentionPolicy{KeepHourly: 3}, []uint64{4, 5, 6, 8}},
+		{"daily", RetentionPolicy{KeepDaily: 2}, []uint64{2, 6, 8}},
+		{"rules combine", RetentionPolicy{KeepLast: 1, KeepDaily: 3}, []uint64{1, 2, 6, 8}},
+		{"monthly", RetentionPolicy{KeepMonthly: 12}, []uint64{6, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm, err := NewSnapshotManager(dir)
+			if err != nil {
+				t.Fatalf("NewSnapshotManager: %v", err)
+			}
+			for i, ts := range times {
+				create(t, sm, uint64(i+1), ts, 0)
+			}
+			// Snapshots with a TTL ignore the policy
+			create(t, sm, 7, now.Add(-2*time.Hour), time.Hour)
+			create(t, sm, 8, now.Add(-100*time.Hour), 200*time.Hour)
+
+			deleted, err := sm.Prune(tt.policy, now)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			if got := remaining(sm); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("remaining %v, want %v", got, tt.want)
+			}
+			if len(deleted)+len(tt.want) != 8 {
+				t.Fatalf("deleted %d snapshots", len(deleted))
+			}
+
+			// Deletions are persisted
+			reopened, err := NewSnapshotManager(dir)
+			if err != nil {
+				t.Fatalf("NewSnapshotManager: %v", err)
+			}
+			if got := remaining(reopened); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("remaining after reopen %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
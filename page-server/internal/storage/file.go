@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
@@ -16,6 +17,9 @@ type FileStorage struct {
 	latestLSN  uint64
 	lsnMu      sync.RWMutex
 	walMu      sync.Mutex
+	walDirty   []string   // WAL files written since the last SyncWAL
+	syncMu     sync.Mutex // One SyncWAL at a time
+	linkMu     sync.Mutex // Orders updates of page_<n>_latest symlinks
 }
 
 // NewFileStorage creates a new file-based storage backend
@@ -34,9 +38,36 @@ func NewFileStorage(baseDir string) (*FileStorage, error) {
 		return nil, fmt.Errorf("failed to create pages directory: %w", err)
 	}
 	
+	// Recover latest LSN from stored WAL
+	if err := fs.loadLatestLSN(); err != nil {
+		return nil, err
+	}
+	
 	return fs, nil
 }
 
+// loadLatestLSN scans the WAL directory to find the latest LSN
+func (fs *FileStorage) loadLatestLSN() error {
+	entries, err := os.ReadDir(fs.walDir)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+	
+	var maxLSN uint64
+	for _, entry := range entries {
+		var lsn uint64
+		if _, err := fmt.Sscanf(entry.Name(), "wal_%d", &lsn); err == nil && lsn > maxLSN {
+			maxLSN = lsn
+		}
+	}
+	
+	fs.lsnMu.Lock()
+	fs.latestLSN = maxLSN
+	fs.lsnMu.Unlock()
+	
+	return nil
+}
+
 // StorePage stores a page with versioning
 func (fs *FileStorage) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error {
 	// Create space directory
@@ -45,31 +76,58 @@ func (fs *FileStorage) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data
 		return fmt.Errorf("failed to create space directory: %w", err)
 	}
 	
-	// Page file: page_<pageNo>_<lsn>
+	// Page file: page_<pageNo>_<lsn>, with header: [LSN (8 bytes)][Page Data]
 	pageFile := filepath.Join(spaceDir, fmt.Sprintf("page_%d_%d", pageNo, lsn))
-	
-	// Write page data with header: [LSN (8 bytes)][Page Data]
-	file, err := os.Create(pageFile)
-	if err != nil {
-		return fmt.Errorf("failed to create page file: %w", err)
-	}
-	defer file.Close()
-	
-	// Write LSN header
-	if err := binary.Write(file, binary.LittleEndian, lsn); err != nil {
-		return fmt.Errorf("failed to write LSN: %w", err)
+	buf := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint64(buf, lsn)
+	copy(buf[8:], data)
+	if err := writeFileAtomic(pageFile, buf); err != nil {
+		return fmt.Errorf("failed to write page file: %w", err)
 	}
 	
-	// Write page data
-	if _, err := file.Write(data); err != nil {
-		return fmt.Errorf("failed to write page data: %w", err)
-	}
+	// Point the latest symlink at the newest version for quick access
+	return fs.updateLatestLink(spaceDir, pageNo, lsn)
+}
+
+// updateLatestLink points page_<pageNo>_latest at the version at lsn unless
+// it already points at a newer one
+func (fs *FileStorage) updateLatestLink(spaceDir string, pageNo uint32, lsn uint64) error {
+	fs.linkMu.Lock()
+	defer fs.linkMu.Unlock()
 	
-	// Also create/update latest symlink for quick access
 	latestLink := filepath.Join(spaceDir, fmt.Sprintf("page_%d_latest", pageNo))
-	os.Remove(latestLink) // Remove old symlink if exists
-	os.Symlink(filepath.Base(pageFile), latestLink)
+	if target, err := os.Readlink(latestLink); err == nil {
+		var current uint64
+		if _, err := fmt.Sscanf(target, fmt.Sprintf("page_%d_%%d", pageNo), &current); err == nil && current > lsn {
+			return nil
+		}
+	}
 	
+	// Replace the link atomically so readers never miss it
+	tmpLink := filepath.Join(spaceDir, fmt.Sprintf(".page_%d_latest.tmp", pageNo))
+	os.Remove(tmpLink)
+	if err := os.Symlink(fmt.Sprintf("page_%d_%d", pageNo, lsn), tmpLink); err != nil {
+		return fmt.Errorf("failed to create latest link: %w", err)
+	}
+	if err := os.Rename(tmpLink, latestLink); err != nil {
+		return fmt.Errorf("failed to update latest link: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file through a temporary file and a rename, so
+// readers see either the old or the new contents. Temporary names start
+// with a dot and never match page_* or wal_* patterns.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
 	return nil
 }
 
@@ -104,76 +162,118 @@ func (fs *FileStorage) LoadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]by
 		}
 		
 		// Find the highest LSN <= requested LSN
-		if fileLSN <= lsn && fileLSN > bestLSN {
+		if fileLSN <= lsn && (bestFile == "" || fileLSN > bestLSN) {
 			bestLSN = fileLSN
 			bestFile = match
 		}
 	}
 	
 	if bestFile == "" {
-		return nil, 0, fmt.Errorf("page not found: space=%d page=%d lsn=%d", spaceID, pageNo, lsn)
+		return nil, 0, fmt.Errorf("%w: space=%d page=%d lsn=%d", ErrPageNotFound, spaceID, pageNo, lsn)
 	}
 	
 	return fs.readPageFile(bestFile, lsn)
 }
 
-// readPageFile reads a page file and returns data and LSN
-func (fs *FileStorage) readPageFile(pageFile string, maxLSN uint64) ([]byte, uint64, error) {
-	file, err := os.Open(pageFile)
+// ListSpaces returns the IDs of all tablespaces with stored pages
+func (fs *FileStorage) ListSpaces() ([]uint32, error) {
+	entries, err := os.ReadDir(fs.pagesDir)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to open page file: %w", err)
+		return nil, fmt.Errorf("failed to read pages directory: %w", err)
 	}
-	defer file.Close()
 	
-	// Read LSN
-	var pageLSN uint64
-	if err := binary.Read(file, binary.LittleEndian, &pageLSN); err != nil {
-		return nil, 0, fmt.Errorf("failed to read LSN: %w", err)
+	var spaces []uint32
+	for _, entry := range entries {
+		var spaceID uint32
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := fmt.Sscanf(entry.Name(), "space_%d", &spaceID); err != nil {
+			continue
+		}
+		spaces = append(spaces, spaceID)
 	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i] < spaces[j] })
 	
-	// Check if this version is acceptable
-	if pageLSN > maxLSN {
-		return nil, 0, fmt.Errorf("page LSN %d exceeds requested LSN %d", pageLSN, maxLSN)
+	return spaces, nil
+}
+
+// ListPageVersions returns the stored LSNs of every page in a space
+func (fs *FileStorage) ListPageVersions(spaceID uint32) (map[uint32][]uint64, error) {
+	spaceDir := filepath.Join(fs.pagesDir, fmt.Sprintf("space_%d", spaceID))
+	versions := make(map[uint32][]uint64)
+	entries, err := os.ReadDir(spaceDir)
+	if os.IsNotExist(err) {
+		return versions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read space directory: %w", err)
 	}
 	
-	// Read page data
-	data := make([]byte, 16384) // InnoDB default page size
-	n, err := file.Read(data)
-	if err != nil && n == 0 {
-		return nil, 0, fmt.Errorf("failed to read page data: %w", err)
+	for _, entry := range entries {
+		var pageNo uint32
+		var lsn uint64
+		// page_<pageNo>_latest symlinks do not match
+		if _, err := fmt.Sscanf(entry.Name(), "page_%d_%d", &pageNo, &lsn); err != nil {
+			continue
+		}
+		versions[pageNo] = append(versions[pageNo], lsn)
 	}
 	
-	return data[:n], pageLSN, nil
+	return versions, nil
 }
 
-// StoreWAL stores a WAL record
-func (fs *FileStorage) StoreWAL(lsn uint64, data []byte) error {
-	fs.walMu.Lock()
-	defer fs.walMu.Unlock()
+// DeletePageVersion deletes one stored version of a page
+func (fs *FileStorage) DeletePageVersion(spaceID uint32, pageNo uint32, lsn uint64) error {
+	spaceDir := filepath.Join(fs.pagesDir, fmt.Sprintf("space_%d", spaceID))
+	pageFile := filepath.Join(spaceDir, fmt.Sprintf("page_%d_%d", pageNo, lsn))
 	
-	// WAL file: wal_<lsn>
-	walFile := filepath.Join(fs.walDir, fmt.Sprintf("wal_%d", lsn))
+	// Never leave the latest symlink dangling
+	latestLink := filepath.Join(spaceDir, fmt.Sprintf("page_%d_latest", pageNo))
+	if target, err := os.Readlink(latestLink); err == nil && target == filepath.Base(pageFile) {
+		return fmt.Errorf("refusing to delete latest version of space=%d page=%d lsn=%d", spaceID, pageNo, lsn)
+	}
 	
-	file, err := os.Create(walFile)
+	if err := os.Remove(pageFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete page version: %w", err)
+	}
+	return nil
+}
+
+// readPageFile reads a page file and returns data and LSN
+func (fs *FileStorage) readPageFile(pageFile string, maxLSN uint64) ([]byte, uint64, error) {
+	buf, err := os.ReadFile(pageFile)
 	if err != nil {
-		return fmt.Errorf("failed to create WAL file: %w", err)
+		return nil, 0, fmt.Errorf("failed to read page file: %w", err)
 	}
-	defer file.Close()
-	
-	// Write LSN header
-	if err := binary.Write(file, binary.LittleEndian, lsn); err != nil {
-		return fmt.Errorf("failed to write LSN: %w", err)
+	if len(buf) < 8 {
+		return nil, 0, fmt.Errorf("page file %s is truncated: %d bytes", pageFile, len(buf))
 	}
 	
-	// Write WAL data length
-	if err := binary.Write(file, binary.LittleEndian, uint32(len(data))); err != nil {
-		return fmt.Errorf("failed to write WAL length: %w", err)
+	// Check if this version is acceptable
+	pageLSN := binary.LittleEndian.Uint64(buf)
+	if pageLSN > maxLSN {
+		return nil, 0, fmt.Errorf("page LSN %d exceeds requested LSN %d", pageLSN, maxLSN)
 	}
 	
-	// Write WAL data
-	if _, err := file.Write(data); err != nil {
-		return fmt.Errorf("failed to write WAL data: %w", err)
+	return buf[8:], pageLSN, nil
+}
+
+// StoreWAL stores a WAL record
+func (fs *FileStorage) StoreWAL(lsn uint64, data []byte) error {
+	fs.walMu.Lock()
+	defer fs.walMu.Unlock()
+	
+	// WAL file: wal_<lsn>, with header: [LSN (8 bytes)][Length (4 bytes)][WAL Data]
+	walFile := filepath.Join(fs.walDir, fmt.Sprintf("wal_%d", lsn))
+	buf := make([]byte, 12+len(data))
+	binary.LittleEndian.PutUint64(buf, lsn)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(data)))
+	copy(buf[12:], data)
+	if err := writeFileAtomic(walFile, buf); err != nil {
+		return fmt.Errorf("failed to write WAL file: %w", err)
 	}
+	fs.walDirty = append(fs.walDirty, walFile)
 	
 	// Update latest LSN
 	fs.lsnMu.Lock()
@@ -185,6 +285,90 @@ func (fs *FileStorage) StoreWAL(lsn uint64, data []byte) error {
 	return nil
 }
 
+// SyncWAL fsyncs the WAL files written since the last call, then the WAL
+// directory, so their contents and names survive a crash
+func (fs *FileStorage) SyncWAL() error {
+	// Holding syncMu across the sync means a caller never returns while
+	// its records are still being synced by a concurrent call
+	fs.syncMu.Lock()
+	defer fs.syncMu.Unlock()
+	
+	fs.walMu.Lock()
+	dirty := fs.walDirty
+	fs.walDirty = nil
+	fs.walMu.Unlock()
+	if len(dirty) == 0 {
+		return nil
+	}
+	
+	for _, path := range dirty {
+		if err := syncPath(path); err != nil {
+			return fmt.Errorf("failed to sync WAL file: %w", err)
+		}
+	}
+	if err := syncPath(fs.walDir); err != nil {
+		return fmt.Errorf("failed to sync WAL directory: %w", err)
+	}
+	return nil
+}
+
+// syncPath fsyncs a file or directory
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// LoadWAL reads the WAL record stored at lsn
+func (fs *FileStorage) LoadWAL(lsn uint64) ([]byte, error) {
+	walFile := filepath.Join(fs.walDir, fmt.Sprintf("wal_%d", lsn))
+	data, err := os.ReadFile(walFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: lsn=%d", ErrWALNotFound, lsn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL file: %w", err)
+	}
+	return decodeWAL(data, lsn)
+}
+
+// ListWAL returns the LSNs of all stored WAL records in ascending order
+func (fs *FileStorage) ListWAL() ([]uint64, error) {
+	entries, err := os.ReadDir(fs.walDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+	
+	var lsns []uint64
+	for _, entry := range entries {
+		var lsn uint64
+		if _, err := fmt.Sscanf(entry.Name(), "wal_%d", &lsn); err == nil {
+			lsns = append(lsns, lsn)
+		}
+	}
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	
+	return lsns, nil
+}
+
+// decodeWAL returns the record of a stored WAL file: [LSN (8 bytes)][Length (4 bytes)][WAL Data]
+func decodeWAL(data []byte, lsn uint64) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("WAL record at LSN %d is truncated: %d bytes", lsn, len(data))
+	}
+	if stored := binary.LittleEndian.Uint64(data); stored != lsn {
+		return nil, fmt.Errorf("WAL record at LSN %d has LSN %d in its header", lsn, stored)
+	}
+	length := binary.LittleEndian.Uint32(data[8:])
+	if int(length) > len(data)-12 {
+		return nil, fmt.Errorf("WAL record at LSN %d is truncated: %d of %d bytes", lsn, len(data)-12, length)
+	}
+	return data[12 : 12+int(length)], nil
+}
+
 // GetLatestLSN returns the highest LSN stored
 func (fs *FileStorage) GetLatestLSN() uint64 {
 	fs.lsnMu.RLock()
@@ -0,0 +1,29 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/internal/storage/storagetest"
+)
+
+func TestFileStorage(t *testing.T) {
+	dirs := make(map[storage.StorageBackend]string)
+	open := func(t *testing.T, dir string) storage.StorageBackend {
+		fs, err := storage.NewFileStorage(dir)
+		if err != nil {
+			t.Fatalf("NewFileStorage: %v", err)
+		}
+		dirs[fs] = dir
+		return fs
+	}
+	storagetest.Run(t, storagetest.Harness{
+		New: func(t *testing.T) storage.StorageBackend {
+			return open(t, t.TempDir())
+		},
+		Reopen: func(t *testing.T, b storage.StorageBackend) storage.StorageBackend {
+			b.Close()
+			return open(t, dirs[b])
+		},
+	})
+}
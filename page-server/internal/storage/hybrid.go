@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/linux/projects/server/page-server/internal/cache"
@@ -28,6 +31,17 @@ type HybridStorage struct {
 	mu              sync.RWMutex
 	stats            HybridStats
 	promoteThreshold time.Duration // Promote to memory if accessed within this time
+
+	// Background S3 uploads that have not completed yet
+	uploads        sync.WaitGroup
+	pendingUploads int64
+
+	// Writes queued for upload, readable until the upload completes
+	pending pendingWrites
+
+	// Highest WAL LSN stored, updated before the S3 upload completes
+	lsnMu     sync.Mutex
+	latestLSN uint64
 }
 
 // HybridStats tracks tiered storage statistics (Neon-style)
@@ -43,15 +57,9 @@ type HybridStats struct {
 
 // NewHybridStorage creates a new hybrid storage with Neon's exact tiered caching
 // Note: Memory cache (Tier 1) is managed by PageServer, not here
-func NewHybridStorage(localDir string, memorySize int, s3Config S3Config) (*HybridStorage, error) {
-	// Get total system memory
-	totalRAM := cache.GetSystemMemory()
-	
-	// LFC uses 75% of total RAM (Neon's exact approach)
-	lfcSize := int64(float64(totalRAM) * 0.75)
-	if lfcSize < 100*1024*1024 { // Minimum 100MB
-		lfcSize = 100 * 1024 * 1024
-	}
+// lfcSizeBytes sets the LFC size; 0 uses 75% of RAM
+func NewHybridStorage(localDir string, memorySize int, lfcSizeBytes int64, s3Config S3Config) (*HybridStorage, error) {
+	lfcSize := DefaultLFCSize(lfcSizeBytes)
 	
 	// Create LFC (Tier 2) - Neon's Local File Cache (RAM-based)
 	lfc := cache.NewLFCCache(lfcSize)
@@ -77,11 +85,15 @@ func NewHybridStorage(localDir string, memorySize int, s3Config S3Config) (*Hybr
 		localDisk:       localDisk,
 		localDir:        localDir,
 		promoteThreshold: 5 * time.Minute,
+		latestLSN:        s3Storage.GetLatestLSN(),
+	}
+	if localDisk != nil && localDisk.GetLatestLSN() > hs.latestLSN {
+		hs.latestLSN = localDisk.GetLatestLSN()
 	}
 
 	log.Printf("Hybrid storage initialized (Neon's exact tiered caching):")
 	log.Printf("  Tier 1 (Memory): Small cache managed by PageServer (%d pages)", memorySize)
-	log.Printf("  Tier 2 (LFC): Large RAM-based cache (%.2f GB, 75%% of RAM)", float64(lfcSize)/(1024*1024*1024))
+	log.Printf("  Tier 2 (LFC): Large RAM-based cache (%.2f GB)", float64(lfcSize)/(1024*1024*1024))
 	log.Printf("  Tier 3 (S3): Cold storage bucket=%s", s3Config.Bucket)
 	if localDisk != nil {
 		log.Printf("  WAL Persistence: Local disk for WAL only (%s)", localDir)
@@ -90,17 +102,43 @@ func NewHybridStorage(localDir string, memorySize int, s3Config S3Config) (*Hybr
 	return hs, nil
 }
 
+// DefaultLFCSize returns the LFC size to use for a configured size.
+// 0 selects 75% of total RAM (Neon's exact approach), with a 100MB minimum.
+func DefaultLFCSize(configured int64) int64 {
+	if configured > 0 {
+		return configured
+	}
+	
+	// Get total system memory
+	totalRAM := cache.GetSystemMemory()
+	
+	// LFC uses 75% of total RAM (Neon's exact approach)
+	lfcSize := int64(float64(totalRAM) * 0.75)
+	if lfcSize < 100*1024*1024 { // Minimum 100MB
+		lfcSize = 100 * 1024 * 1024
+	}
+	return lfcSize
+}
+
 // StorePage stores a page using Neon's tiered strategy:
 // Note: Tier 1 (Memory) is handled by PageServer.cache.Put()
 // 1. Store in LFC (Tier 2, RAM-based, synchronous)
 // 2. Store in S3 (Tier 3, async, background)
 func (hs *HybridStorage) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error {
+	// The caller may reuse data once StorePage returns
+	data = append([]byte(nil), data...)
+
 	// Tier 2: Store in LFC (RAM-based, fast, synchronous)
 	hs.lfc.Put(spaceID, pageNo, lsn, data)
 
 	// Tier 3: Store in S3 (async, background)
-	// Use goroutine to avoid blocking
+	// Use goroutine to avoid blocking; the version stays readable from the
+	// pending set if the LFC evicts it before the upload completes
+	seq := hs.pending.addPage(spaceID, pageNo, lsn, data)
+	hs.startUpload()
 	go func() {
+		defer hs.finishUpload()
+		defer hs.pending.donePage(spaceID, pageNo, lsn, seq)
 		if err := hs.s3Storage.StorePage(spaceID, pageNo, lsn, data); err != nil {
 			log.Printf("Warning: Failed to store page in S3: %v", err)
 		}
@@ -128,16 +166,26 @@ func (hs *HybridStorage) LoadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]
 	hs.mu.Lock()
 	hs.stats.LFCMisses++
 	hs.mu.Unlock()
+	gen := hs.lfc.Generation()
 
-	// Tier 3: Fetch from S3
-	pageData, pageLSN, err := hs.s3Storage.LoadPage(spaceID, pageNo, lsn)
+	// Tier 3: Fetch from S3, preferring a newer version still queued for upload
+	pendingData, pendingLSN, queued := hs.pending.loadPage(spaceID, pageNo, lsn)
+	pageData, pageLSN, newest, err := hs.s3Storage.loadPage(spaceID, pageNo, lsn)
+	if queued && (err != nil || pendingLSN >= pageLSN) {
+		return pendingData, pendingLSN, nil
+	}
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Found in S3 - promote to LFC (PageServer will promote to memory)
-	// Store in LFC (Tier 2, RAM) for future access
-	hs.lfc.Put(spaceID, pageNo, pageLSN, pageData)
+	// Store in LFC (Tier 2, RAM) for future access. The LFC holds one version
+	// per page and serves it for any later LSN, so an older version read for a
+	// historic LSN is not promoted.
+	// Nor is a version loaded before the page was invalidated.
+	if newest {
+		hs.lfc.PutIfGeneration(gen, spaceID, pageNo, pageLSN, pageData)
+	}
 
 	hs.mu.Lock()
 	hs.stats.S3Hits++
@@ -158,8 +206,19 @@ func (hs *HybridStorage) StoreWAL(lsn uint64, data []byte) error {
 		}
 	}
 
+	hs.lsnMu.Lock()
+	if lsn > hs.latestLSN {
+		hs.latestLSN = lsn
+	}
+	hs.lsnMu.Unlock()
+
 	// Store in S3 (async, background)
+	data = append([]byte(nil), data...)
+	seq := hs.pending.addWAL(lsn, data)
+	hs.startUpload()
 	go func() {
+		defer hs.finishUpload()
+		defer hs.pending.doneWAL(lsn, seq)
 		if err := hs.s3Storage.StoreWAL(lsn, data); err != nil {
 			log.Printf("Warning: Failed to store WAL in S3: %v", err)
 		}
@@ -168,10 +227,89 @@ func (hs *HybridStorage) StoreWAL(lsn uint64, data []byte) error {
 	return nil
 }
 
-// GetLatestLSN returns the highest LSN from S3 (source of truth)
+// SyncWAL makes stored WAL durable: on local disk if there is one,
+// otherwise by waiting for the uploads to S3
+func (hs *HybridStorage) SyncWAL() error {
+	if hs.localDisk != nil {
+		return hs.localDisk.SyncWAL()
+	}
+	if pending, err := hs.Flush(context.Background()); err != nil {
+		return fmt.Errorf("failed to flush %d uploads: %w", pending, err)
+	}
+	return nil
+}
+
+// LoadWAL reads a WAL record from local disk, falling back to the upload queue and S3
+func (hs *HybridStorage) LoadWAL(lsn uint64) ([]byte, error) {
+	if hs.localDisk != nil {
+		if data, err := hs.localDisk.LoadWAL(lsn); err == nil {
+			return data, nil
+		}
+	}
+	if data, ok := hs.pending.loadWAL(lsn); ok {
+		return data, nil
+	}
+	return hs.s3Storage.LoadWAL(lsn)
+}
+
+// ListWAL returns the LSNs of the WAL records on local disk, in S3 or queued for upload
+func (hs *HybridStorage) ListWAL() ([]uint64, error) {
+	lsns, err := hs.s3Storage.ListWAL()
+	if err != nil {
+		return nil, err
+	}
+	if hs.localDisk != nil {
+		local, err := hs.localDisk.ListWAL()
+		if err != nil {
+			return nil, err
+		}
+		lsns = append(lsns, local...)
+	}
+	lsns = append(lsns, hs.pending.walLSNs()...)
+	slices.Sort(lsns)
+	return slices.Compact(lsns), nil
+}
+
+// startUpload registers a background S3 upload
+func (hs *HybridStorage) startUpload() {
+	hs.uploads.Add(1)
+	atomic.AddInt64(&hs.pendingUploads, 1)
+}
+
+// finishUpload marks a background S3 upload as done
+func (hs *HybridStorage) finishUpload() {
+	atomic.AddInt64(&hs.pendingUploads, -1)
+	hs.uploads.Done()
+}
+
+// PendingUploads returns the number of S3 uploads still in flight
+func (hs *HybridStorage) PendingUploads() int {
+	return int(atomic.LoadInt64(&hs.pendingUploads))
+}
+
+// Flush waits for background S3 uploads to complete.
+// If ctx expires first, it returns the number of uploads still in flight.
+func (hs *HybridStorage) Flush(ctx context.Context) (int, error) {
+	done := make(chan struct{})
+	go func() {
+		hs.uploads.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return 0, nil
+	case <-ctx.Done():
+		return hs.PendingUploads(), ctx.Err()
+	}
+}
+
+// GetLatestLSN returns the highest WAL LSN stored, including WAL still
+// queued for upload. After a restart it is the higher of S3 and local disk.
 func (hs *HybridStorage) GetLatestLSN() uint64 {
-	// S3 is the source of truth for LSN
-	return hs.s3Storage.GetLatestLSN()
+	hs.lsnMu.Lock()
+	defer hs.lsnMu.Unlock()
+	return hs.latestLSN
 }
 
 // Close closes all storage tiers
@@ -194,6 +332,46 @@ func (hs *HybridStorage) Close() error {
 	return nil
 }
 
+// ListSpaces returns the tablespaces stored in S3 or queued for upload
+func (hs *HybridStorage) ListSpaces() ([]uint32, error) {
+	spaces, err := hs.s3Storage.ListSpaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, spaceID := range hs.pending.spaces() {
+		if !slices.Contains(spaces, spaceID) {
+			spaces = append(spaces, spaceID)
+		}
+	}
+	slices.Sort(spaces)
+	return spaces, nil
+}
+
+// ListPageVersions returns the page versions stored in S3 or queued for upload
+func (hs *HybridStorage) ListPageVersions(spaceID uint32) (map[uint32][]uint64, error) {
+	versions, err := hs.s3Storage.ListPageVersions(spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil {
+		versions = make(map[uint32][]uint64)
+	}
+	hs.pending.pageVersions(spaceID, versions)
+	return versions, nil
+}
+
+// DeletePageVersion deletes a page version from S3. The LFC only serves the
+// newest version at or below a requested LSN, and GC always keeps that version
+// for every LSN that can still be requested, so the LFC needs no invalidation.
+func (hs *HybridStorage) DeletePageVersion(spaceID uint32, pageNo uint32, lsn uint64) error {
+	return hs.s3Storage.DeletePageVersion(spaceID, pageNo, lsn)
+}
+
+// S3 returns the S3 tier
+func (hs *HybridStorage) S3() *S3Storage {
+	return hs.s3Storage
+}
+
 // GetStats returns tiered storage statistics
 func (hs *HybridStorage) GetStats() HybridStats {
 	hs.mu.RLock()
@@ -1,19 +1,78 @@
 package storage
 
-// StorageBackend defines the interface for persistent storage
+import (
+	"context"
+	"errors"
+)
+
+// ErrPageNotFound is returned by LoadPage when no version of the page exists at or before the LSN
+var ErrPageNotFound = errors.New("page not found")
+
+// ErrWALNotFound is returned by LoadWAL when no WAL record is stored at the LSN
+var ErrWALNotFound = errors.New("WAL record not found")
+
+// StorageBackend defines the interface for persistent storage.
+// Every implementation must pass the conformance suite in storagetest.
 type StorageBackend interface {
-	// StorePage stores a page with its LSN
+	// StorePage stores a version of a page; storing the same LSN again replaces it.
+	// The data is stored as given, whatever its length.
 	StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error
 
-	// LoadPage loads a page at or before the given LSN
+	// LoadPage returns the newest version of a page at or before the given LSN
+	// and that version's LSN, or ErrPageNotFound. It never returns a partially
+	// written version and is visible as soon as StorePage returns.
 	LoadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error)
 
 	// StoreWAL stores a WAL record
 	StoreWAL(lsn uint64, data []byte) error
 
-	// GetLatestLSN returns the highest LSN stored
+	// GetLatestLSN returns the highest LSN passed to StoreWAL, including
+	// records stored before the backend was reopened
 	GetLatestLSN() uint64
 
 	// Close closes the storage backend
 	Close() error
 }
+
+// SpaceLister is implemented by backends that can enumerate stored tablespaces
+type SpaceLister interface {
+	// ListSpaces returns the IDs of all tablespaces with stored pages
+	ListSpaces() ([]uint32, error)
+}
+
+// VersionedStorage is implemented by backends whose old page versions can be garbage collected
+type VersionedStorage interface {
+	SpaceLister
+
+	// ListPageVersions returns the stored LSNs of every page in a space, keyed by page number
+	ListPageVersions(spaceID uint32) (map[uint32][]uint64, error)
+
+	// DeletePageVersion deletes one stored version of a page
+	DeletePageVersion(spaceID uint32, pageNo uint32, lsn uint64) error
+}
+
+// Flusher is implemented by backends that write to a lower tier in the background
+type Flusher interface {
+	// Flush blocks until queued writes complete or ctx expires, and returns
+	// the number of writes that were still outstanding
+	Flush(ctx context.Context) (int, error)
+}
+
+// WALSyncer is implemented by backends whose stored WAL records are not
+// durable until synced, so many records can share one fsync
+type WALSyncer interface {
+	// SyncWAL makes every WAL record stored before the call durable
+	SyncWAL() error
+}
+
+// WALReader is implemented by backends that can read stored WAL records back
+type WALReader interface {
+	// LoadWAL returns the WAL record stored at exactly lsn
+	LoadWAL(lsn uint64) ([]byte, error)
+}
+
+// WALLister is implemented by backends that can enumerate stored WAL records
+type WALLister interface {
+	// ListWAL returns the LSNs of all stored WAL records in ascending order
+	ListWAL() ([]uint64, error)
+}
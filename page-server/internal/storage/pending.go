@@ -0,0 +1,161 @@
+package storage
+
+import "sync"
+
+// pendingWrites holds the page versions and WAL records queued for a
+// background upload, so they can be read back before the upload completes
+type pendingWrites struct {
+	mu    sync.Mutex
+	seq   uint64
+	pages map[pageKey]map[uint64]pendingEntry
+	wal   map[uint64]pendingEntry
+}
+
+type pageKey struct {
+	spaceID uint32
+	pageNo  uint32
+}
+
+// pendingEntry is one queued write; seq tells a newer write of the same key apart
+type pendingEntry struct {
+	data []byte
+	seq  uint64
+}
+
+// addPage queues a page version and returns the sequence number for donePage
+func (p *pendingWrites) addPage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pages == nil {
+		p.pages = make(map[pageKey]map[uint64]pendingEntry)
+	}
+	key := pageKey{spaceID, pageNo}
+	if p.pages[key] == nil {
+		p.pages[key] = make(map[uint64]pendingEntry)
+	}
+	p.seq++
+	p.pages[key][lsn] = pendingEntry{data: data, seq: p.seq}
+	return p.seq
+}
+
+// donePage removes a page version once its upload finished, unless it was queued again since
+func (p *pendingWrites) donePage(spaceID uint32, pageNo uint32, lsn uint64, seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := pageKey{spaceID, pageNo}
+	if entry, ok := p.pages[key][lsn]; ok && entry.seq == seq {
+		delete(p.pages[key], lsn)
+		if len(p.pages[key]) == 0 {
+			delete(p.pages, key)
+		}
+	}
+}
+
+// loadPage returns the newest queued version of a page at or before lsn
+func (p *pendingWrites) loadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best []byte
+	var bestLSN uint64
+	found := false
+	for versionLSN, entry := range p.pages[pageKey{spaceID, pageNo}] {
+		if versionLSN <= lsn && (!found || versionLSN > bestLSN) {
+			best, bestLSN, found = entry.data, versionLSN, true
+		}
+	}
+	if !found {
+		return nil, 0, false
+	}
+	data := make([]byte, len(best))
+	copy(data, best)
+	return data, bestLSN, true
+}
+
+// spaces returns the IDs of spaces with queued pages
+func (p *pendingWrites) spaces() []uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := make(map[uint32]bool)
+	var spaces []uint32
+	for key := range p.pages {
+		if !seen[key.spaceID] {
+			seen[key.spaceID] = true
+			spaces = append(spaces, key.spaceID)
+		}
+	}
+	return spaces
+}
+
+// pageVersions adds the queued versions of a space to versions
+func (p *pendingWrites) pageVersions(spaceID uint32, versions map[uint32][]uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, entries := range p.pages {
+		if key.spaceID != spaceID {
+			continue
+		}
+	next:
+		for lsn := range entries {
+			for _, known := range versions[key.pageNo] {
+				if known == lsn {
+					continue next
+				}
+			}
+			versions[key.pageNo] = append(versions[key.pageNo], lsn)
+		}
+	}
+}
+
+// addWAL queues a WAL record and returns the sequence number for doneWAL
+func (p *pendingWrites) addWAL(lsn uint64, data []byte) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.wal == nil {
+		p.wal = make(map[uint64]pendingEntry)
+	}
+	p.seq++
+	p.wal[lsn] = pendingEntry{data: data, seq: p.seq}
+	return p.seq
+}
+
+// doneWAL removes a WAL record once its upload finished, unless it was queued again since
+func (p *pendingWrites) doneWAL(lsn uint64, seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.wal[lsn]; ok && entry.seq == seq {
+		delete(p.wal, lsn)
+	}
+}
+
+// walLSNs returns the LSNs of the queued WAL records
+func (p *pendingWrites) walLSNs() []uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lsns := make([]uint64, 0, len(p.wal))
+	for lsn := range p.wal {
+		lsns = append(lsns, lsn)
+	}
+	return lsns
+}
+
+// loadWAL returns a queued WAL record
+func (p *pendingWrites) loadWAL(lsn uint64) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.wal[lsn]
+	if !ok {
+		return nil, false
+	}
+	data := make([]byte, len(entry.data))
+	copy(data, entry.data)
+	return data, true
+}
@@ -4,24 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"path/filepath"
+	"os"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/linux/projects/server/objectstore"
 )
 
-// S3Storage implements StorageBackend using S3-compatible object storage
+// S3Storage implements StorageBackend on an object store: S3-compatible
+// storage, or a local directory for file:// endpoints
 type S3Storage struct {
-	client    *s3.Client
-	bucket    string
-	prefix    string
+	store     objectstore.ObjectStore
+	prefix    string // Key prefix within the bucket, reported for uploads
 	latestLSN uint64
 	lsnMu     sync.RWMutex
 	walMu     sync.Mutex
@@ -30,7 +29,7 @@ type S3Storage struct {
 
 // S3Config holds S3 configuration
 type S3Config struct {
-	Endpoint  string // S3 endpoint (e.g., https://s3.amazonaws.com or http://minio:9000)
+	Endpoint  string // S3 endpoint (e.g., https://s3.amazonaws.com or http://minio:9000, or file:///dir for a local directory)
 	Bucket    string // S3 bucket name
 	Region    string // AWS region (e.g., us-east-1)
 	AccessKey string // Access key ID
@@ -41,224 +40,127 @@ type S3Config struct {
 
 // NewS3Storage creates a new S3 storage backend
 func NewS3Storage(cfg S3Config) (*S3Storage, error) {
-	ctx := context.Background()
-
-	// Build AWS config with custom resolver for endpoint
-	var awsCfg aws.Config
-	var err error
-
-	// If credentials provided, use them; otherwise use default chain
-	if cfg.AccessKey != "" && cfg.SecretKey != "" {
-		awsCfg, err = config.LoadDefaultConfig(ctx,
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-				cfg.AccessKey,
-				cfg.SecretKey,
-				"",
-			)),
-			config.WithRegion(cfg.Region),
-		)
-	} else {
-		awsCfg, err = config.LoadDefaultConfig(ctx,
-			config.WithRegion(cfg.Region),
-		)
-	}
+	store, err := objectstore.Open(context.Background(), objectstore.S3Config{
+		Endpoint:     cfg.Endpoint,
+		Bucket:       cfg.Bucket,
+		Region:       cfg.Region,
+		AccessKey:    cfg.AccessKey,
+		SecretKey:    cfg.SecretKey,
+		PathStyle:    true, // Required for MinIO and some S3-compatible services
+		CreateBucket: true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
-	}
-
-	// Create S3 client with custom endpoint
-	clientOptions := []func(*s3.Options){
-		func(o *s3.Options) {
-			o.UsePathStyle = true // Required for MinIO and some S3-compatible services
-		},
+		return nil, fmt.Errorf("failed to open object store: %w", err)
 	}
 
-	// Override endpoint if provided (for MinIO or custom S3)
-	if cfg.Endpoint != "" {
-		clientOptions = append(clientOptions, func(o *s3.Options) {
-			o.BaseEndpoint = aws.String(cfg.Endpoint)
-		})
-	}
-
-	client := s3.NewFromConfig(awsCfg, clientOptions...)
-
-	// Ensure bucket exists
-	if err := ensureBucketExists(ctx, client, cfg.Bucket); err != nil {
-		return nil, fmt.Errorf("failed to ensure bucket exists: %w", err)
-	}
+	storage := NewObjectStorage(objectstore.WithPrefix(store, cfg.Prefix))
+	storage.prefix = strings.Trim(cfg.Prefix, "/")
+	return storage, nil
+}
 
+// NewObjectStorage creates a storage backend on any object store, using the
+// same object layout as S3
+func NewObjectStorage(store objectstore.ObjectStore) *S3Storage {
 	storage := &S3Storage{
-		client: client,
-		bucket: cfg.Bucket,
-		prefix: strings.Trim(cfg.Prefix, "/"),
-		ctx:    ctx,
+		store: store,
+		ctx:   context.Background(),
 	}
 
-	// Load latest LSN from S3
+	// Load latest LSN from the store
 	if err := storage.loadLatestLSN(); err != nil {
 		log.Printf("Warning: Failed to load latest LSN: %v", err)
 	}
-
-	return storage, nil
+	return storage
 }
 
-// ensureBucketExists creates the bucket if it doesn't exist
-func ensureBucketExists(ctx context.Context, client *s3.Client, bucket string) error {
-	// Try to head bucket (check if exists)
-	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(bucket),
-	})
-	if err == nil {
-		return nil // Bucket exists
-	}
-
-	// Bucket doesn't exist, create it
-	_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{
-		Bucket: aws.String(bucket),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create bucket: %w", err)
-	}
-
-	log.Printf("Created S3 bucket: %s", bucket)
-	return nil
+// ObjectStore returns the object store holding the pages and WAL, scoped to the prefix
+func (s *S3Storage) ObjectStore() objectstore.ObjectStore {
+	return s.store
 }
 
-// pageObjectKey generates an S3 object key for a page
-func (s *S3Storage) pageObjectKey(spaceID uint32, pageNo uint32, lsn uint64) string {
-	key := fmt.Sprintf("pages/space_%d/page_%d_%d", spaceID, pageNo, lsn)
-	if s.prefix != "" {
-		key = filepath.Join(s.prefix, key)
-	}
-	return key
+// pageObjectKey generates an object key for a page
+func pageObjectKey(spaceID uint32, pageNo uint32, lsn uint64) string {
+	return fmt.Sprintf("pages/space_%d/page_%d_%d", spaceID, pageNo, lsn)
 }
 
-// walObjectKey generates an S3 object key for a WAL record
-func (s *S3Storage) walObjectKey(lsn uint64) string {
-	key := fmt.Sprintf("wal/wal_%d", lsn)
-	if s.prefix != "" {
-		key = filepath.Join(s.prefix, key)
-	}
-	return key
+// walObjectKey generates an object key for a WAL record
+func walObjectKey(lsn uint64) string {
+	return fmt.Sprintf("wal/wal_%d", lsn)
 }
 
 // StorePage stores a page in S3
 func (s *S3Storage) StorePage(spaceID uint32, pageNo uint32, lsn uint64, data []byte) error {
-	key := s.pageObjectKey(spaceID, pageNo, lsn)
-
 	// Prepare page data: [LSN (8 bytes)][Page Data]
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian, lsn); err != nil {
-		return fmt.Errorf("failed to write LSN: %w", err)
-	}
-	if _, err := buf.Write(data); err != nil {
-		return fmt.Errorf("failed to write page data: %w", err)
-	}
+	buf := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint64(buf, lsn)
+	copy(buf[8:], data)
 
-	// Upload to S3
-	_, err := s.client.PutObject(s.ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(buf.Bytes()),
-		ContentType: aws.String("application/octet-stream"),
-		Metadata: map[string]string{
-			"space-id": fmt.Sprintf("%d", spaceID),
-			"page-no":  fmt.Sprintf("%d", pageNo),
-			"lsn":      fmt.Sprintf("%d", lsn),
-		},
-	})
-	if err != nil {
+	if err := s.store.Put(s.ctx, pageObjectKey(spaceID, pageNo, lsn), bytes.NewReader(buf)); err != nil {
 		return fmt.Errorf("failed to upload page to S3: %w", err)
 	}
-
-	// Update latest LSN
-	s.lsnMu.Lock()
-	if lsn > s.latestLSN {
-		s.latestLSN = lsn
-	}
-	s.lsnMu.Unlock()
-
 	return nil
 }
 
 // LoadPage loads a page from S3 at or before the given LSN
 func (s *S3Storage) LoadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, error) {
-	// List objects with prefix to find matching pages
-	prefix := fmt.Sprintf("pages/space_%d/page_%d_", spaceID, pageNo)
-	if s.prefix != "" {
-		prefix = filepath.Join(s.prefix, prefix)
-	}
+	data, pageLSN, _, err := s.loadPage(spaceID, pageNo, lsn)
+	return data, pageLSN, err
+}
 
-	// List objects
-	listInput := &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(prefix),
+// loadPage is LoadPage that also reports whether the version returned is the newest stored
+func (s *S3Storage) loadPage(spaceID uint32, pageNo uint32, lsn uint64) ([]byte, uint64, bool, error) {
+	// List objects with prefix to find matching pages
+	objects, err := s.store.List(s.ctx, fmt.Sprintf("pages/space_%d/page_%d_", spaceID, pageNo))
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to list objects: %w", err)
 	}
 
 	var bestLSN uint64 = 0
 	var bestKey string
+	newest := true
 
-	paginator := s3.NewListObjectsV2Paginator(s.client, listInput)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(s.ctx)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to list objects: %w", err)
+	for _, obj := range objects {
+		// Extract LSN from key: page_<no>_<lsn>
+		var fileLSN uint64
+		if _, err := fmt.Sscanf(path.Base(obj.Key), fmt.Sprintf("page_%d_%%d", pageNo), &fileLSN); err != nil {
+			continue
 		}
 
-		for _, obj := range page.Contents {
-			// Extract LSN from key: page_<no>_<lsn>
-			var fileLSN uint64
-			key := *obj.Key
-			baseName := filepath.Base(key)
-			if _, err := fmt.Sscanf(baseName, fmt.Sprintf("page_%d_%%d", pageNo), &fileLSN); err != nil {
-				continue
-			}
-
-			// Find the highest LSN <= requested LSN
-			if fileLSN <= lsn && fileLSN > bestLSN {
-				bestLSN = fileLSN
-				bestKey = key
-			}
+		// Find the highest LSN <= requested LSN
+		if fileLSN > lsn {
+			newest = false
+		} else if bestKey == "" || fileLSN > bestLSN {
+			bestLSN = fileLSN
+			bestKey = obj.Key
 		}
 	}
 
 	if bestKey == "" {
-		return nil, 0, fmt.Errorf("page not found: space=%d page=%d lsn=%d", spaceID, pageNo, lsn)
+		return nil, 0, false, fmt.Errorf("%w: space=%d page=%d lsn=%d", ErrPageNotFound, spaceID, pageNo, lsn)
 	}
 
 	// Download the object
-	return s.downloadPage(bestKey, lsn)
+	data, pageLSN, err := s.downloadPage(bestKey, lsn)
+	return data, pageLSN, newest, err
 }
 
 // downloadPage downloads a page from S3
 func (s *S3Storage) downloadPage(key string, maxLSN uint64) ([]byte, uint64, error) {
-	result, err := s.client.GetObject(s.ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
+	data, err := s.store.Get(s.ctx, key)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to download page: %w", err)
 	}
-	defer result.Body.Close()
-
-	// Read LSN
-	var pageLSN uint64
-	if err := binary.Read(result.Body, binary.LittleEndian, &pageLSN); err != nil {
-		return nil, 0, fmt.Errorf("failed to read LSN: %w", err)
+	if len(data) < 8 {
+		return nil, 0, fmt.Errorf("page object %s is truncated: %d bytes", key, len(data))
 	}
 
 	// Check if this version is acceptable
+	pageLSN := binary.LittleEndian.Uint64(data)
 	if pageLSN > maxLSN {
 		return nil, 0, fmt.Errorf("page LSN %d exceeds requested LSN %d", pageLSN, maxLSN)
 	}
 
-	// Read page data
-	data, err := io.ReadAll(result.Body)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read page data: %w", err)
-	}
-
-	return data, pageLSN, nil
+	return data[8:], pageLSN, nil
 }
 
 // StoreWAL stores a WAL record in S3
@@ -266,31 +168,13 @@ func (s *S3Storage) StoreWAL(lsn uint64, data []byte) error {
 	s.walMu.Lock()
 	defer s.walMu.Unlock()
 
-	key := s.walObjectKey(lsn)
-
 	// Prepare WAL data: [LSN (8 bytes)][Length (4 bytes)][WAL Data]
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian, lsn); err != nil {
-		return fmt.Errorf("failed to write LSN: %w", err)
-	}
-	if err := binary.Write(buf, binary.LittleEndian, uint32(len(data))); err != nil {
-		return fmt.Errorf("failed to write WAL length: %w", err)
-	}
-	if _, err := buf.Write(data); err != nil {
-		return fmt.Errorf("failed to write WAL data: %w", err)
-	}
+	buf := make([]byte, 12+len(data))
+	binary.LittleEndian.PutUint64(buf, lsn)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(data)))
+	copy(buf[12:], data)
 
-	// Upload to S3
-	_, err := s.client.PutObject(s.ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(buf.Bytes()),
-		ContentType: aws.String("application/octet-stream"),
-		Metadata: map[string]string{
-			"lsn": fmt.Sprintf("%d", lsn),
-		},
-	})
-	if err != nil {
+	if err := s.store.Put(s.ctx, walObjectKey(lsn), bytes.NewReader(buf)); err != nil {
 		return fmt.Errorf("failed to upload WAL to S3: %w", err)
 	}
 
@@ -304,6 +188,46 @@ func (s *S3Storage) StoreWAL(lsn uint64, data []byte) error {
 	return nil
 }
 
+// LoadWAL downloads the WAL record stored at lsn
+func (s *S3Storage) LoadWAL(lsn uint64) ([]byte, error) {
+	data, err := s.store.Get(s.ctx, walObjectKey(lsn))
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return nil, fmt.Errorf("%w: lsn=%d", ErrWALNotFound, lsn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download WAL: %w", err)
+	}
+	return decodeWAL(data, lsn)
+}
+
+// ListWAL returns the LSNs of all WAL records in S3 in ascending order
+func (s *S3Storage) ListWAL() ([]uint64, error) {
+	objects, err := s.store.List(s.ctx, "wal/wal_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL objects: %w", err)
+	}
+
+	var lsns []uint64
+	for _, obj := range objects {
+		var lsn uint64
+		if _, err := fmt.Sscanf(path.Base(obj.Key), "wal_%d", &lsn); err == nil {
+			lsns = append(lsns, lsn)
+		}
+	}
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+
+	return lsns, nil
+}
+
+// UpdateCredentials replaces the S3 access key and secret key.
+// Requests issued after the call are signed with the new credentials.
+// Empty keys switch back to the default credential chain.
+func (s *S3Storage) UpdateCredentials(accessKey, secretKey string) {
+	if updater, ok := s.store.(objectstore.CredentialUpdater); ok {
+		updater.UpdateCredentials(accessKey, secretKey)
+	}
+}
+
 // GetLatestLSN returns the highest LSN stored
 func (s *S3Storage) GetLatestLSN() uint64 {
 	s.lsnMu.RLock()
@@ -313,35 +237,17 @@ func (s *S3Storage) GetLatestLSN() uint64 {
 
 // loadLatestLSN scans S3 to find the latest LSN
 func (s *S3Storage) loadLatestLSN() error {
-	prefix := "wal/wal_"
-	if s.prefix != "" {
-		prefix = filepath.Join(s.prefix, prefix)
-	}
-
-	listInput := &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(prefix),
+	objects, err := s.store.List(s.ctx, "wal/wal_")
+	if err != nil {
+		return fmt.Errorf("failed to list WAL objects: %w", err)
 	}
 
 	var maxLSN uint64 = 0
-
-	paginator := s3.NewListObjectsV2Paginator(s.client, listInput)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(s.ctx)
-		if err != nil {
-			return fmt.Errorf("failed to list WAL objects: %w", err)
-		}
-
-		for _, obj := range page.Contents {
-			// Extract LSN from key: wal_<lsn>
-			key := *obj.Key
-			baseName := filepath.Base(key)
-			var lsn uint64
-			if _, err := fmt.Sscanf(baseName, "wal_%d", &lsn); err == nil {
-				if lsn > maxLSN {
-					maxLSN = lsn
-				}
-			}
+	for _, obj := range objects {
+		// Extract LSN from key: wal_<lsn>
+		var lsn uint64
+		if _, err := fmt.Sscanf(path.Base(obj.Key), "wal_%d", &lsn); err == nil && lsn > maxLSN {
+			maxLSN = lsn
 		}
 	}
 
@@ -360,50 +266,88 @@ func (s *S3Storage) Close() error {
 
 // ListPages lists all page versions for a given space and page
 func (s *S3Storage) ListPages(spaceID uint32, pageNo uint32) ([]uint64, error) {
-	prefix := fmt.Sprintf("pages/space_%d/page_%d_", spaceID, pageNo)
-	if s.prefix != "" {
-		prefix = filepath.Join(s.prefix, prefix)
+	objects, err := s.store.List(s.ctx, fmt.Sprintf("pages/space_%d/page_%d_", spaceID, pageNo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
 	}
 
-	listInput := &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(prefix),
+	var lsns []uint64
+	for _, obj := range objects {
+		var lsn uint64
+		if _, err := fmt.Sscanf(path.Base(obj.Key), fmt.Sprintf("page_%d_%%d", pageNo), &lsn); err == nil {
+			lsns = append(lsns, lsn)
+		}
 	}
 
-	var lsns []uint64
+	return lsns, nil
+}
 
-	paginator := s3.NewListObjectsV2Paginator(s.client, listInput)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(s.ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list pages: %w", err)
+// ListSpaces returns the IDs of all tablespaces with pages in S3
+func (s *S3Storage) ListSpaces() ([]uint32, error) {
+	prefixes, err := s.store.ListPrefixes(s.ctx, "pages/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spaces: %w", err)
+	}
+
+	var spaces []uint32
+	for _, p := range prefixes {
+		var spaceID uint32
+		name := strings.TrimSuffix(strings.TrimPrefix(p, "pages/"), "/")
+		if _, err := fmt.Sscanf(name, "space_%d", &spaceID); err == nil {
+			spaces = append(spaces, spaceID)
 		}
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i] < spaces[j] })
+
+	return spaces, nil
+}
+
+// ListPageVersions returns the stored LSNs of every page in a space
+func (s *S3Storage) ListPageVersions(spaceID uint32) (map[uint32][]uint64, error) {
+	objects, err := s.store.List(s.ctx, fmt.Sprintf("pages/space_%d/", spaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page versions: %w", err)
+	}
 
-		for _, obj := range page.Contents {
-			key := *obj.Key
-			baseName := filepath.Base(key)
-			var lsn uint64
-			if _, err := fmt.Sscanf(baseName, fmt.Sprintf("page_%d_%%d", pageNo), &lsn); err == nil {
-				lsns = append(lsns, lsn)
-			}
+	versions := make(map[uint32][]uint64)
+	for _, obj := range objects {
+		var pageNo uint32
+		var lsn uint64
+		if _, err := fmt.Sscanf(path.Base(obj.Key), "page_%d_%d", &pageNo, &lsn); err == nil {
+			versions[pageNo] = append(versions[pageNo], lsn)
 		}
 	}
 
-	return lsns, nil
+	return versions, nil
 }
 
-// DeletePage deletes a specific page version from S3
-func (s *S3Storage) DeletePage(spaceID uint32, pageNo uint32, lsn uint64) error {
-	key := s.pageObjectKey(spaceID, pageNo, lsn)
+// DeletePageVersion deletes one stored version of a page
+func (s *S3Storage) DeletePageVersion(spaceID uint32, pageNo uint32, lsn uint64) error {
+	return s.DeletePage(spaceID, pageNo, lsn)
+}
 
-	_, err := s.client.DeleteObject(s.ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
+// UploadFile uploads a local file to S3 under the storage prefix and returns the object key
+func (s *S3Storage) UploadFile(ctx context.Context, key string, path string) (string, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to delete page: %w", err)
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
 	}
+	defer file.Close()
 
-	return nil
+	if err := s.store.Put(ctx, key, file); err != nil {
+		return "", fmt.Errorf("failed to upload %s to S3: %w", key, err)
+	}
+
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key, nil
 }
 
+// DeletePage deletes a specific page version from S3
+func (s *S3Storage) DeletePage(spaceID uint32, pageNo uint32, lsn uint64) error {
+	if err := s.store.Delete(s.ctx, pageObjectKey(spaceID, pageNo, lsn)); err != nil {
+		return fmt.Errorf("failed to delete page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/objectstore"
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/internal/storage/storagetest"
+)
+
+// s3TestConfig returns the bucket to run the S3 tests against, set with
+// STORAGETEST_S3_ENDPOINT, _BUCKET, _REGION, _ACCESS_KEY and _SECRET_KEY.
+// Without an endpoint the tests use a local directory through a file:// endpoint.
+// Every backend gets its own prefix so tests do not see each other's objects.
+func s3TestConfig(t *testing.T) storage.S3Config {
+	prefix := fmt.Sprintf("storagetest/%d", time.Now().UnixNano())
+	endpoint := os.Getenv("STORAGETEST_S3_ENDPOINT")
+	if endpoint == "" {
+		return storage.S3Config{Endpoint: "file://" + t.TempDir(), Bucket: "storagetest", Prefix: prefix}
+	}
+	region := os.Getenv("STORAGETEST_S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return storage.S3Config{
+		Endpoint:  endpoint,
+		Bucket:    os.Getenv("STORAGETEST_S3_BUCKET"),
+		Region:    region,
+		AccessKey: os.Getenv("STORAGETEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("STORAGETEST_S3_SECRET_KEY"),
+		Prefix:    prefix,
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	configs := make(map[storage.StorageBackend]storage.S3Config)
+	open := func(t *testing.T, cfg storage.S3Config) storage.StorageBackend {
+		s3, err := storage.NewS3Storage(cfg)
+		if err != nil {
+			t.Fatalf("NewS3Storage: %v", err)
+		}
+		configs[s3] = cfg
+		return s3
+	}
+	storagetest.Run(t, storagetest.Harness{
+		New: func(t *testing.T) storage.StorageBackend {
+			return open(t, s3TestConfig(t))
+		},
+		Reopen: func(t *testing.T, b storage.StorageBackend) storage.StorageBackend {
+			b.Close()
+			return open(t, configs[b])
+		},
+	})
+}
+
+func TestHybridStorage(t *testing.T) {
+	type location struct {
+		dir string
+		cfg storage.S3Config
+	}
+	locations := make(map[storage.StorageBackend]location)
+	open := func(t *testing.T, loc location) storage.StorageBackend {
+		hs, err := storage.NewHybridStorage(loc.dir, 0, 64*1024*1024, loc.cfg)
+		if err != nil {
+			t.Fatalf("NewHybridStorage: %v", err)
+		}
+		locations[hs] = loc
+		return hs
+	}
+	storagetest.Run(t, storagetest.Harness{
+		New: func(t *testing.T) storage.StorageBackend {
+			return open(t, location{t.TempDir(), s3TestConfig(t)})
+		},
+		Reopen: func(t *testing.T, b storage.StorageBackend) storage.StorageBackend {
+			b.Close()
+			return open(t, locations[b])
+		},
+	})
+}
+
+func TestObjectStorage(t *testing.T) {
+	storagetest.Run(t, storagetest.Harness{
+		New: func(t *testing.T) storage.StorageBackend {
+			return storage.NewObjectStorage(objectstore.NewMemoryStore())
+		},
+	})
+}
@@ -0,0 +1,511 @@
+// Package storagetest is a conformance suite for storage.StorageBackend
+// implementations. A backend's tests call Run with a Harness that creates
+// empty instances of it.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// Harness creates the backend under test
+type Harness struct {
+	// New returns an empty backend; the suite closes it
+	New func(t *testing.T) storage.StorageBackend
+
+	// Reopen closes b and opens a backend over the same data.
+	// Nil skips the persistence tests.
+	Reopen func(t *testing.T, b storage.StorageBackend) storage.StorageBackend
+}
+
+// Run runs the conformance suite against the backend created by h
+func Run(t *testing.T, h Harness) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, h Harness)
+	}{
+		{"MissingPage", testMissingPage},
+		{"AtOrBefore", testAtOrBefore},
+		{"ZeroLSN", testZeroLSN},
+		{"OutOfOrderStores", testOutOfOrderStores},
+		{"OverwriteSameLSN", testOverwriteSameLSN},
+		{"PageIndependence", testPageIndependence},
+		{"PageSizes", testPageSizes},
+		{"CopySemantics", testCopySemantics},
+		{"LatestLSN", testLatestLSN},
+		{"Reopen", testReopen},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ConcurrentReadWrite", testConcurrentReadWrite},
+		{"Versioned", testVersioned},
+		{"WALReader", testWALReader},
+		{"WALLister", testWALLister},
+		{"WALSyncer", testWALSyncer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.fn(t, h) })
+	}
+}
+
+// open creates a backend that is flushed and closed when the test ends
+func open(t *testing.T, h Harness) storage.StorageBackend {
+	t.Helper()
+	b := h.New(t)
+	t.Cleanup(func() {
+		flush(t, b)
+		b.Close()
+	})
+	return b
+}
+
+// flush waits for background writes of backends that have them
+func flush(t *testing.T, b storage.StorageBackend) {
+	t.Helper()
+	flusher, ok := b.(storage.Flusher)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if pending, err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v (%d writes pending)", err, pending)
+	}
+}
+
+// page returns a page of size bytes filled with fill
+func page(size int, fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, size)
+}
+
+func store(t *testing.T, b storage.StorageBackend, spaceID, pageNo uint32, lsn uint64, data []byte) {
+	t.Helper()
+	if err := b.StorePage(spaceID, pageNo, lsn, data); err != nil {
+		t.Fatalf("StorePage(%d, %d, %d): %v", spaceID, pageNo, lsn, err)
+	}
+}
+
+// expectPage checks that LoadPage at lsn returns want stored at wantLSN
+func expectPage(t *testing.T, b storage.StorageBackend, spaceID, pageNo uint32, lsn uint64, want []byte, wantLSN uint64) {
+	t.Helper()
+	data, gotLSN, err := b.LoadPage(spaceID, pageNo, lsn)
+	if err != nil {
+		t.Fatalf("LoadPage(%d, %d, %d): %v", spaceID, pageNo, lsn, err)
+	}
+	if gotLSN != wantLSN {
+		t.Fatalf("LoadPage(%d, %d, %d) returned LSN %d, want %d", spaceID, pageNo, lsn, gotLSN, wantLSN)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("LoadPage(%d, %d, %d) returned %d bytes that differ from the %d stored", spaceID, pageNo, lsn, len(data), len(want))
+	}
+}
+
+// expectNotFound checks that LoadPage at lsn returns ErrPageNotFound
+func expectNotFound(t *testing.T, b storage.StorageBackend, spaceID, pageNo uint32, lsn uint64) {
+	t.Helper()
+	_, gotLSN, err := b.LoadPage(spaceID, pageNo, lsn)
+	if !errors.Is(err, storage.ErrPageNotFound) {
+		t.Fatalf("LoadPage(%d, %d, %d) = LSN %d, %v; want ErrPageNotFound", spaceID, pageNo, lsn, gotLSN, err)
+	}
+}
+
+func testMissingPage(t *testing.T, h Harness) {
+	b := open(t, h)
+	expectNotFound(t, b, 1, 0, math.MaxUint64)
+
+	store(t, b, 1, 0, 100, page(16384, 1))
+	expectNotFound(t, b, 1, 0, 99)
+	expectNotFound(t, b, 1, 1, 100)
+	expectNotFound(t, b, 2, 0, 100)
+}
+
+func testAtOrBefore(t *testing.T, h Harness) {
+	b := open(t, h)
+	v100, v200 := page(16384, 1), page(16384, 2)
+	store(t, b, 1, 0, 100, v100)
+	store(t, b, 1, 0, 200, v200)
+
+	expectNotFound(t, b, 1, 0, 99)
+	expectPage(t, b, 1, 0, 100, v100, 100)
+	expectPage(t, b, 1, 0, 150, v100, 100)
+	expectPage(t, b, 1, 0, 199, v100, 100)
+	expectPage(t, b, 1, 0, 200, v200, 200)
+	expectPage(t, b, 1, 0, 250, v200, 200)
+	expectPage(t, b, 1, 0, math.MaxUint64, v200, 200)
+}
+
+func testZeroLSN(t *testing.T, h Harness) {
+	b := open(t, h)
+	v0, v10 := page(16384, 1), page(16384, 2)
+	store(t, b, 1, 0, 0, v0)
+	expectPage(t, b, 1, 0, 0, v0, 0)
+	expectPage(t, b, 1, 0, 5, v0, 0)
+
+	store(t, b, 1, 0, 10, v10)
+	expectPage(t, b, 1, 0, 0, v0, 0)
+	expectPage(t, b, 1, 0, 10, v10, 10)
+}
+
+func testOutOfOrderStores(t *testing.T, h Harness) {
+	b := open(t, h)
+	v100, v200, v300 := page(16384, 1), page(16384, 2), page(16384, 3)
+	store(t, b, 1, 0, 300, v300)
+	store(t, b, 1, 0, 100, v100)
+	store(t, b, 1, 0, 200, v200)
+
+	expectPage(t, b, 1, 0, math.MaxUint64, v300, 300)
+	expectPage(t, b, 1, 0, 299, v200, 200)
+	expectPage(t, b, 1, 0, 199, v100, 100)
+}
+
+func testOverwriteSameLSN(t *testing.T, h Harness) {
+	b := open(t, h)
+	store(t, b, 1, 0, 100, page(16384, 1))
+	flush(t, b)
+	v := page(16384, 2)
+	store(t, b, 1, 0, 100, v)
+
+	expectPage(t, b, 1, 0, 100, v, 100)
+	flush(t, b)
+	expectPage(t, b, 1, 0, 100, v, 100)
+}
+
+func testPageIndependence(t *testing.T, h Harness) {
+	b := open(t, h)
+	pages := map[[2]uint32][]byte{
+		{1, 0}:  page(16384, 1),
+		{1, 1}:  page(16384, 2),
+		{10, 0}: page(16384, 3),
+		{10, 1}: page(16384, 4),
+	}
+	for key, data := range pages {
+		store(t, b, key[0], key[1], 100, data)
+	}
+	for key, data := range pages {
+		expectPage(t, b, key[0], key[1], 100, data, 100)
+	}
+}
+
+func testPageSizes(t *testing.T, h Harness) {
+	b := open(t, h)
+	for i, size := range []int{1, 100, 4096, 8192, 16384, 32768, 65536} {
+		data := make([]byte, size)
+		for j := range data {
+			data[j] = byte(j*7 + i)
+		}
+		store(t, b, 1, uint32(i), 100, data)
+		expectPage(t, b, 1, uint32(i), 100, data, 100)
+	}
+}
+
+func testCopySemantics(t *testing.T, h Harness) {
+	b := open(t, h)
+	data := page(16384, 1)
+	store(t, b, 1, 0, 100, data)
+	data[0] = 0xff
+
+	got, _, err := b.LoadPage(1, 0, 100)
+	if err != nil {
+		t.Fatalf("LoadPage: %v", err)
+	}
+	if got[0] != 1 {
+		t.Fatal("StorePage kept a reference to the caller's buffer")
+	}
+	got[1] = 0xff
+	expectPage(t, b, 1, 0, 100, page(16384, 1), 100)
+}
+
+func testLatestLSN(t *testing.T, h Harness) {
+	b := open(t, h)
+	if lsn := b.GetLatestLSN(); lsn != 0 {
+		t.Fatalf("GetLatestLSN of an empty backend = %d, want 0", lsn)
+	}
+
+	store(t, b, 1, 0, 500, page(16384, 1))
+	if lsn := b.GetLatestLSN(); lsn != 0 {
+		t.Fatalf("GetLatestLSN after StorePage = %d, want 0 (only StoreWAL counts)", lsn)
+	}
+
+	for _, step := range []struct{ store, want uint64 }{{100, 100}, {300, 300}, {200, 300}} {
+		if err := b.StoreWAL(step.store, []byte("wal")); err != nil {
+			t.Fatalf("StoreWAL(%d): %v", step.store, err)
+		}
+		if lsn := b.GetLatestLSN(); lsn != step.want {
+			t.Fatalf("GetLatestLSN after StoreWAL(%d) = %d, want %d", step.store, lsn, step.want)
+		}
+	}
+}
+
+func testReopen(t *testing.T, h Harness) {
+	if h.Reopen == nil {
+		t.Skip("backend is not persistent")
+	}
+	b := h.New(t)
+	v100, v200 := page(16384, 1), page(16384, 2)
+	store(t, b, 1, 0, 100, v100)
+	store(t, b, 1, 0, 200, v200)
+	if err := b.StoreWAL(200, []byte("wal")); err != nil {
+		t.Fatalf("StoreWAL: %v", err)
+	}
+	flush(t, b)
+
+	b = h.Reopen(t, b)
+	defer b.Close()
+	if lsn := b.GetLatestLSN(); lsn != 200 {
+		t.Fatalf("GetLatestLSN after reopen = %d, want 200", lsn)
+	}
+	expectPage(t, b, 1, 0, 150, v100, 100)
+	expectPage(t, b, 1, 0, math.MaxUint64, v200, 200)
+}
+
+func testConcurrentWriters(t *testing.T, h Harness) {
+	b := open(t, h)
+	const writers, versions = 8, 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for v := 1; v <= versions; v++ {
+				lsn := uint64(v*writers + w)
+				// Each writer has its own page and also writes a shared one
+				if err := b.StorePage(1, uint32(w+1), lsn, page(4096, byte(v))); err != nil {
+					errs <- err
+					return
+				}
+				if err := b.StorePage(1, 0, lsn, page(4096, byte(lsn))); err != nil {
+					errs <- err
+					return
+				}
+				if err := b.StoreWAL(lsn, []byte{byte(w)}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent write: %v", err)
+	}
+
+	for w := 0; w < writers; w++ {
+		lsn := uint64(versions*writers + w)
+		expectPage(t, b, 1, uint32(w+1), math.MaxUint64, page(4096, versions), lsn)
+	}
+	newest := uint64(versions*writers + writers - 1)
+	expectPage(t, b, 1, 0, math.MaxUint64, page(4096, byte(newest)), newest)
+	expectPage(t, b, 1, 0, 100, page(4096, 100), 100)
+	if lsn := b.GetLatestLSN(); lsn != newest {
+		t.Fatalf("GetLatestLSN = %d, want %d", lsn, newest)
+	}
+}
+
+func testConcurrentReadWrite(t *testing.T, h Harness) {
+	b := open(t, h)
+	const versions, readers = 200, 4
+	store(t, b, 1, 0, 1, page(16384, 1))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, readers+1)
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				data, lsn, err := b.LoadPage(1, 0, math.MaxUint64)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Every version is filled with the low byte of its LSN
+				if len(data) != 16384 || !bytes.Equal(data, page(16384, byte(lsn))) {
+					errs <- fmt.Errorf("read a torn or mislabelled version at LSN %d", lsn)
+					return
+				}
+				if lsn < last {
+					errs <- fmt.Errorf("latest version went back from LSN %d to %d", last, lsn)
+					return
+				}
+				last = lsn
+			}
+		}()
+	}
+
+	for lsn := uint64(2); lsn <= versions; lsn++ {
+		if err := b.StorePage(1, 0, lsn, page(16384, byte(lsn))); err != nil {
+			errs <- err
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	expectPage(t, b, 1, 0, math.MaxUint64, page(16384, versions), versions)
+}
+
+func testVersioned(t *testing.T, h Harness) {
+	b := open(t, h)
+	versioned, ok := b.(storage.VersionedStorage)
+	if !ok {
+		t.Skip("backend does not implement VersionedStorage")
+	}
+
+	versions, err := versioned.ListPageVersions(7)
+	if err != nil || len(versions) != 0 {
+		t.Fatalf("ListPageVersions of an unknown space = %v, %v; want empty", versions, err)
+	}
+
+	store(t, b, 7, 0, 100, page(16384, 1))
+	store(t, b, 7, 0, 200, page(16384, 2))
+	store(t, b, 7, 3, 150, page(16384, 3))
+	store(t, b, 8, 0, 100, page(16384, 4))
+
+	spaces, err := versioned.ListSpaces()
+	if err != nil {
+		t.Fatalf("ListSpaces: %v", err)
+	}
+	for _, want := range []uint32{7, 8} {
+		if !contains(spaces, want) {
+			t.Fatalf("ListSpaces = %v, missing space %d", spaces, want)
+		}
+	}
+
+	versions, err = versioned.ListPageVersions(7)
+	if err != nil {
+		t.Fatalf("ListPageVersions: %v", err)
+	}
+	want := map[uint32][]uint64{0: {100, 200}, 3: {150}}
+	if got := sorted(versions); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ListPageVersions = %v, want %v", got, want)
+	}
+
+	// Deleting an old version leaves reads at or above the newer one unaffected
+	flush(t, b)
+	if err := versioned.DeletePageVersion(7, 0, 100); err != nil {
+		t.Fatalf("DeletePageVersion: %v", err)
+	}
+	expectNotFound(t, b, 7, 0, 150)
+	expectPage(t, b, 7, 0, 250, page(16384, 2), 200)
+	if versions, err = versioned.ListPageVersions(7); err != nil || fmt.Sprint(sorted(versions)[0]) != "[200]" {
+		t.Fatalf("ListPageVersions after delete = %v, %v; want page 0 at [200]", versions, err)
+	}
+
+	if err := versioned.DeletePageVersion(7, 0, 12345); err != nil {
+		t.Fatalf("DeletePageVersion of a missing version: %v", err)
+	}
+}
+
+func testWALReader(t *testing.T, h Harness) {
+	b := open(t, h)
+	reader, ok := b.(storage.WALReader)
+	if !ok {
+		t.Skip("backend does not implement WALReader")
+	}
+
+	record := []byte("some WAL record")
+	for lsn, data := range map[uint64][]byte{100: record, 200: {}} {
+		if err := b.StoreWAL(lsn, data); err != nil {
+			t.Fatalf("StoreWAL(%d): %v", lsn, err)
+		}
+	}
+	record[0] = 'X'
+
+	if data, err := reader.LoadWAL(100); err != nil || string(data) != "some WAL record" {
+		t.Fatalf("LoadWAL(100) = %q, %v", data, err)
+	}
+	if data, err := reader.LoadWAL(200); err != nil || len(data) != 0 {
+		t.Fatalf("LoadWAL(200) = %q, %v; want an empty record", data, err)
+	}
+	if _, err := reader.LoadWAL(150); !errors.Is(err, storage.ErrWALNotFound) {
+		t.Fatalf("LoadWAL(150) = %v, want ErrWALNotFound", err)
+	}
+}
+
+func testWALLister(t *testing.T, h Harness) {
+	b := open(t, h)
+	lister, ok := b.(storage.WALLister)
+	if !ok {
+		t.Skip("backend does not implement WALLister")
+	}
+
+	if lsns, err := lister.ListWAL(); err != nil || len(lsns) != 0 {
+		t.Fatalf("ListWAL with nothing stored = %v, %v", lsns, err)
+	}
+	for _, lsn := range []uint64{300, 100, 2000} {
+		if err := b.StoreWAL(lsn, []byte("record")); err != nil {
+			t.Fatalf("StoreWAL(%d): %v", lsn, err)
+		}
+	}
+	flush(t, b)
+	if lsns, err := lister.ListWAL(); err != nil || !slices.Equal(lsns, []uint64{100, 300, 2000}) {
+		t.Fatalf("ListWAL = %v, %v; want [100 300 2000]", lsns, err)
+	}
+}
+
+func testWALSyncer(t *testing.T, h Harness) {
+	b := open(t, h)
+	syncer, ok := b.(storage.WALSyncer)
+	if !ok {
+		t.Skip("backend does not implement WALSyncer")
+	}
+
+	if err := syncer.SyncWAL(); err != nil {
+		t.Fatalf("SyncWAL with nothing stored: %v", err)
+	}
+	for lsn := uint64(1); lsn <= 3; lsn++ {
+		if err := b.StoreWAL(lsn*100, []byte("record")); err != nil {
+			t.Fatalf("StoreWAL(%d): %v", lsn*100, err)
+		}
+	}
+	if err := syncer.SyncWAL(); err != nil {
+		t.Fatalf("SyncWAL: %v", err)
+	}
+	if err := syncer.SyncWAL(); err != nil {
+		t.Fatalf("SyncWAL again: %v", err)
+	}
+	if got := b.GetLatestLSN(); got != 300 {
+		t.Fatalf("GetLatestLSN after SyncWAL = %d, want 300", got)
+	}
+}
+
+func contains(values []uint32, want uint32) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns versions with every LSN list in ascending order
+func sorted(versions map[uint32][]uint64) map[uint32][]uint64 {
+	out := make(map[uint32][]uint64, len(versions))
+	for pageNo, lsns := range versions {
+		lsns = append([]uint64(nil), lsns...)
+		sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+		out[pageNo] = lsns
+	}
+	return out
+}
@@ -0,0 +1,255 @@
+package wal
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+const (
+	// DefaultApplyWorkers is used when ApplyOptions.Workers is not set
+	DefaultApplyWorkers = 8
+	// DefaultApplyQueue is used when ApplyOptions.QueueSize is not set
+	DefaultApplyQueue = 256
+)
+
+// ApplyOptions configure the workers applying stored WAL records to pages
+type ApplyOptions struct {
+	Workers   int // A page always goes to the same worker, so its records apply in order
+	QueueSize int // Records queued per worker before ingestion waits for it
+}
+
+// ApplyStats reports the apply workers
+type ApplyStats struct {
+	Workers    int    `json:"workers"`
+	Queued     int    `json:"queued"`      // Records stored but not applied yet
+	AppliedLSN uint64 `json:"applied_lsn"` // Every record dispatched up to here is applied
+	Stalls     int64  `json:"stalls"`      // Times ingestion waited for a full worker queue
+}
+
+// pageKey identifies a page
+type pageKey struct {
+	spaceID uint32
+	pageNo  uint32
+}
+
+// applyTask is a stored WAL record on its way to the apply hook
+type applyTask struct {
+	record  WALRecord
+	parsed  []*RedoLogRecord // Decoded once at ingestion
+	apply   bool             // Has a page to apply to; others complete at once
+	rebuild bool             // Backfilled: later versions of the page are rebuilt too
+	result  chan error       // Receives the apply error, if set
+	written bool             // A page version was stored
+	done    bool
+}
+
+// applier dispatches tasks to workers and tracks their completion
+type applier struct {
+	queues []chan *applyTask
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	hook     ApplyHook
+	order    []*applyTask         // Dispatched tasks not handed to the hook yet, in dispatch order
+	ready    []*applyTask         // Completed tasks waiting for the hook, in dispatch order
+	hooking  bool                 // A goroutine is running the hook for ready
+	inflight map[pageKey][]uint64 // LSNs dispatched and not yet applied, per page
+	applied  uint64
+	stalls   int64
+	changed  chan struct{} // Closed and replaced whenever a task completes
+}
+
+func newApplier(opts ApplyOptions) *applier {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultApplyWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultApplyQueue
+	}
+	a := &applier{
+		queues:   make([]chan *applyTask, opts.Workers),
+		inflight: make(map[pageKey][]uint64),
+		changed:  make(chan struct{}),
+	}
+	for i := range a.queues {
+		a.queues[i] = make(chan *applyTask, opts.QueueSize)
+	}
+	return a
+}
+
+// start runs the workers; apply stores the new version of a task's page
+func (a *applier) start(apply func(*applyTask) error) {
+	for _, queue := range a.queues {
+		a.wg.Add(1)
+		go func(queue chan *applyTask) {
+			defer a.wg.Done()
+			for task := range queue {
+				err := apply(task)
+				if err != nil {
+					// The WAL is stored and can be replayed later
+					log.Printf("Warning: Failed to apply WAL to page: %v", err)
+				} else {
+					task.written = true
+				}
+				a.complete(task)
+				if task.result != nil {
+					task.result <- err
+				}
+			}
+		}(queue)
+	}
+}
+
+// dispatch queues a task, waiting while its worker's queue is full.
+// Called by one goroutine at a time, in LSN order.
+func (a *applier) dispatch(task *applyTask) {
+	a.mu.Lock()
+	a.order = append(a.order, task)
+	if !task.apply {
+		task.done = true
+		a.advance()
+		a.mu.Unlock()
+		a.runHooks()
+		return
+	}
+	key := pageKey{task.record.SpaceID, task.record.PageNo}
+	a.inflight[key] = append(a.inflight[key], task.record.LSN)
+	a.mu.Unlock()
+
+	queue := a.queues[a.worker(key)]
+	select {
+	case queue <- task:
+	default:
+		a.mu.Lock()
+		a.stalls++
+		a.mu.Unlock()
+		queue <- task
+	}
+}
+
+// worker picks the worker for a page
+func (a *applier) worker(key pageKey) int {
+	h := (uint64(key.spaceID)<<32 | uint64(key.pageNo)) * 0x9e3779b97f4a7c15
+	return int((h >> 32) % uint64(len(a.queues)))
+}
+
+// complete marks an applied task done
+func (a *applier) complete(task *applyTask) {
+	a.mu.Lock()
+	task.done = true
+	key := pageKey{task.record.SpaceID, task.record.PageNo}
+	lsns := a.inflight[key]
+	for i, lsn := range lsns {
+		if lsn == task.record.LSN {
+			lsns = append(lsns[:i], lsns[i+1:]...)
+			break
+		}
+	}
+	if len(lsns) == 0 {
+		delete(a.inflight, key)
+	} else {
+		a.inflight[key] = lsns
+	}
+	a.advance()
+	a.mu.Unlock()
+	a.runHooks()
+}
+
+// advance moves completed tasks to ready in dispatch order, so the hook
+// never sees an LSN before every record dispatched ahead of it is applied.
+// a.mu must be held.
+func (a *applier) advance() {
+	for len(a.order) > 0 && a.order[0].done {
+		task := a.order[0]
+		a.order[0] = nil
+		a.order = a.order[1:]
+		if task.record.LSN > a.applied {
+			a.applied = task.record.LSN
+		}
+		a.ready = append(a.ready, task)
+	}
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// runHooks calls the hook for the ready tasks without holding a.mu, so a
+// slow hook holds up neither the workers nor readers waiting for pages.
+// One goroutine runs the hook at a time and takes over tasks that become
+// ready meanwhile, which keeps the calls in dispatch order.
+func (a *applier) runHooks() {
+	a.mu.Lock()
+	if a.hooking {
+		a.mu.Unlock()
+		return
+	}
+	a.hooking = true
+	for len(a.ready) > 0 {
+		tasks, hook := a.ready, a.hook
+		a.ready = nil
+		a.mu.Unlock()
+
+		for _, task := range tasks {
+			if hook == nil {
+				continue
+			}
+			if task.written {
+				hook(task.record.LSN, task.record.SpaceID, task.record.PageNo)
+			} else {
+				hook(task.record.LSN, 0, 0)
+			}
+		}
+		a.mu.Lock()
+	}
+	a.hooking = false
+	a.mu.Unlock()
+}
+
+// waitForPage waits until no record for the page at or below lsn is in flight
+func (a *applier) waitForPage(ctx context.Context, key pageKey, lsn uint64) error {
+	for {
+		a.mu.Lock()
+		pending := false
+		for _, l := range a.inflight[key] {
+			if l <= lsn {
+				pending = true
+				break
+			}
+		}
+		changed := a.changed
+		a.mu.Unlock()
+		if !pending {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// stop closes the queues and waits for the workers to apply what is queued
+func (a *applier) stop() {
+	for _, queue := range a.queues {
+		close(queue)
+	}
+	a.wg.Wait()
+}
+
+// stats returns the current ApplyStats
+func (a *applier) stats() ApplyStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	queued := 0
+	for _, lsns := range a.inflight {
+		queued += len(lsns)
+	}
+	return ApplyStats{
+		Workers:    len(a.queues),
+		Queued:     queued,
+		AppliedLSN: a.applied,
+		Stalls:     a.stalls,
+	}
+}
@@ -0,0 +1,119 @@
+package wal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linux/projects/server/page-server/internal/cache"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+func TestParallelApplyKeepsPageAndHookOrder(t *testing.T) {
+	backend := storage.NewMemoryStorage()
+	wp := NewWALProcessor(backend, cache.NewPageCache(100), ApplyOptions{Workers: 4, QueueSize: 2})
+
+	var mu sync.Mutex
+	var hooked []uint64
+	wp.SetApplyHook(func(lsn uint64, spaceID uint32, pageNo uint32) {
+		mu.Lock()
+		hooked = append(hooked, lsn)
+		mu.Unlock()
+	})
+
+	const pages, perPage = 16, 20
+	lsn := uint64(0)
+	for i := 0; i < perPage; i++ {
+		for page := uint32(1); page <= pages; page++ {
+			lsn++
+			if err := wp.ProcessWALRecord(WALRecord{LSN: lsn, SpaceID: 1, PageNo: page}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Every version of a page is readable once WaitForPage returns
+	for page := uint32(1); page <= pages; page++ {
+		if err := wp.WaitForPage(context.Background(), 1, page, lsn); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < perPage; i++ {
+			want := uint64(i*pages) + uint64(page)
+			if _, got, err := backend.LoadPage(1, page, want); err != nil || got != want {
+				t.Fatalf("page %d at LSN %d: version %d, %v", page, want, got, err)
+			}
+		}
+	}
+
+	wp.Close()
+	if len(hooked) != int(lsn) {
+		t.Fatalf("hook ran %d times, want %d", len(hooked), lsn)
+	}
+	for i, l := range hooked {
+		if l != uint64(i+1) {
+			t.Fatalf("hook call %d was for LSN %d, want %d", i, l, i+1)
+		}
+	}
+	if stats := wp.ApplyStats(); stats.Queued != 0 || stats.AppliedLSN != lsn {
+		t.Fatalf("after Close: %+v", stats)
+	}
+	if err := wp.ProcessWALRecord(WALRecord{LSN: lsn + 1}); err != ErrProcessorClosed {
+		t.Fatalf("record after Close: %v, want ErrProcessorClosed", err)
+	}
+}
+
+func TestSlowApplyHookDoesNotBlockApply(t *testing.T) {
+	backend := storage.NewMemoryStorage()
+	wp := NewWALProcessor(backend, cache.NewPageCache(100), ApplyOptions{Workers: 2, QueueSize: 8})
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var hooked []uint64
+	wp.SetApplyHook(func(lsn uint64, spaceID uint32, pageNo uint32) {
+		if lsn == 1 {
+			close(entered)
+			<-release
+		}
+		mu.Lock()
+		hooked = append(hooked, lsn)
+		mu.Unlock()
+	})
+
+	if err := wp.ProcessWALRecord(WALRecord{LSN: 1, SpaceID: 1, PageNo: 1}); err != nil {
+		t.Fatal(err)
+	}
+	<-entered
+
+	// While the hook for LSN 1 blocks, a page on the other worker still
+	// applies and can be waited for
+	other := uint32(2)
+	for wp.applier.worker(pageKey{1, other}) == wp.applier.worker(pageKey{1, 1}) {
+		other++
+	}
+	applied := make(chan error, 1)
+	go func() {
+		if err := wp.ProcessWALRecord(WALRecord{LSN: 2, SpaceID: 1, PageNo: other}); err != nil {
+			applied <- err
+			return
+		}
+		applied <- wp.WaitForPage(context.Background(), 1, other, 2)
+	}()
+	select {
+	case err := <-applied:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("record not applied while the hook blocks")
+	}
+	if stats := wp.ApplyStats(); stats.AppliedLSN != 2 {
+		t.Fatalf("applied LSN %d while the hook blocks, want 2", stats.AppliedLSN)
+	}
+
+	close(release)
+	wp.Close()
+	if len(hooked) != 2 || hooked[0] != 1 || hooked[1] != 2 {
+		t.Fatalf("hook calls %v, want [1 2]", hooked)
+	}
+}
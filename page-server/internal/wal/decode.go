@@ -0,0 +1,133 @@
+package wal
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/linux/projects/server/page-server/internal/storage"
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// DefaultPreviewBytes is the data shown per redo record when no preview size is given
+const DefaultPreviewBytes = 16
+
+// ErrWALListUnsupported is returned by DecodeStoredWAL for backends that cannot list stored WAL
+var ErrWALListUnsupported = errors.New("storage backend cannot list WAL records")
+
+// DecodeWAL splits a WAL record into redo records exactly as the applier
+// does, showing up to previewBytes of each record's data. Where the applier
+// stops at a record that fails to parse, DecodeWAL reports the error at its
+// offset and continues after the record if its length field could be read;
+// the records after it are marked skipped.
+func DecodeWAL(data []byte, previewBytes int) types.DecodedWAL {
+	result := types.DecodedWAL{Bytes: len(data), RedoRecords: []types.RedoRecord{}}
+	parser := NewRedoLogParser(data)
+
+	for parser.Offset() < len(data) {
+		offset := parser.Offset()
+		first := data[offset]
+		record, err := parser.ParseRecord()
+		end := parser.RecordEnd()
+
+		decoded := types.RedoRecord{Offset: offset, Skipped: result.ErrorOffset != nil}
+		if end >= 0 {
+			decoded.Length = end - offset
+		}
+		if err != nil {
+			decoded.Type = RecordTypeName(first & 0x70)
+			decoded.TypeCode = first & 0x70
+			decoded.SamePage = first&0x80 != 0
+			decoded.Error = err.Error()
+			result.RedoRecords = append(result.RedoRecords, decoded)
+			if result.ErrorOffset == nil {
+				result.ErrorOffset = &offset
+				result.Applied = len(result.RedoRecords) - 1
+			}
+			if end <= offset || end > len(data) {
+				break
+			}
+			parser.Seek(end)
+			continue
+		}
+
+		describeRecord(&decoded, record, previewBytes)
+		if end >= 0 && parser.Offset() != end {
+			decoded.Warning = fmt.Sprintf("length field gives %d bytes, parser read %d", end-offset, parser.Offset()-offset)
+		}
+		result.RedoRecords = append(result.RedoRecords, decoded)
+	}
+
+	if result.ErrorOffset == nil {
+		result.Applied = len(result.RedoRecords)
+	}
+	return result
+}
+
+// describeRecord fills the fields of a decoded record from a parsed one
+func describeRecord(decoded *types.RedoRecord, record *RedoLogRecord, previewBytes int) {
+	decoded.Type = RecordTypeName(record.Type)
+	decoded.TypeCode = record.Type
+	decoded.SamePage = record.SamePage
+	decoded.SpaceID = record.SpaceID
+	decoded.PageNo = record.PageNo
+	decoded.FileName = record.FileName
+	decoded.NewName = record.NewName
+
+	switch record.Type {
+	case MREC_INIT_PAGE, MREC_WRITE:
+		decoded.PageOffset = record.Offset
+		decoded.DataLen = len(record.Data)
+	case MREC_MEMSET:
+		decoded.PageOffset = record.Offset
+		decoded.DataLen = int(record.DataLen)
+	case MREC_MEMMOVE:
+		decoded.PageOffset = record.Offset
+		decoded.DataLen = int(record.DataLen)
+		decoded.SourceOffset = record.SourceOff
+	case MREC_EXTENDED:
+		decoded.Subtype = record.Subtype
+	}
+
+	if preview := record.Data[:min(len(record.Data), max(previewBytes, 0))]; len(preview) > 0 {
+		decoded.DataPreview = hex.EncodeToString(preview)
+	}
+}
+
+// DecodeStoredWAL decodes the stored WAL records with from <= LSN <= to, up
+// to limit of them. next is the LSN of the first record in the range left
+// out because of the limit, or 0.
+func DecodeStoredWAL(backend storage.StorageBackend, from, to uint64, limit int, previewBytes int) ([]types.DecodedWAL, uint64, error) {
+	lister, canList := backend.(storage.WALLister)
+	reader, canRead := backend.(storage.WALReader)
+	if !canList || !canRead {
+		return nil, 0, ErrWALListUnsupported
+	}
+	lsns, err := lister.ListWAL()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list WAL: %w", err)
+	}
+
+	records := []types.DecodedWAL{}
+	for _, lsn := range lsns {
+		if lsn < from || lsn > to {
+			continue
+		}
+		if len(records) == limit {
+			return records, lsn, nil
+		}
+
+		data, err := reader.LoadWAL(lsn)
+		if errors.Is(err, storage.ErrWALNotFound) {
+			continue // Removed since the listing
+		}
+		if err != nil {
+			records = append(records, types.DecodedWAL{LSN: lsn, RedoRecords: []types.RedoRecord{}, Error: err.Error()})
+			continue
+		}
+		decoded := DecodeWAL(data, previewBytes)
+		decoded.LSN = lsn
+		records = append(records, decoded)
+	}
+	return records, 0, nil
+}
@@ -0,0 +1,60 @@
+package wal
+
+import (
+	"testing"
+)
+
+func TestDecodeWALReportsWhereParsingFails(t *testing.T) {
+	data := []byte{
+		0x37, 1, 3, 0x40, 'A', 'B', 'C', 'D', // WRITE space 1 page 3 at 64
+		0xB3, 2, 'E', 'F', // Same page WRITE at 70
+		0x62, 0, 0, // Reserved type: the applier stops here
+		0x37, 1, 5, 0x10, 'W', 'X', 'Y', 'Z', // Never applied
+		0x30, // Length field missing
+	}
+	if got := len(ParseRecords(data)); got != 2 {
+		t.Fatalf("ParseRecords returned %d records, want 2", got)
+	}
+
+	decoded := DecodeWAL(data, 2)
+	if decoded.Bytes != len(data) || decoded.Applied != 2 {
+		t.Fatalf("bytes=%d applied=%d, want %d and 2", decoded.Bytes, decoded.Applied, len(data))
+	}
+	if decoded.ErrorOffset == nil || *decoded.ErrorOffset != 12 {
+		t.Fatalf("error offset %v, want 12", decoded.ErrorOffset)
+	}
+	if len(decoded.RedoRecords) != 5 {
+		t.Fatalf("%d redo records, want 5: %+v", len(decoded.RedoRecords), decoded.RedoRecords)
+	}
+
+	write, same, reserved, skipped, truncated := decoded.RedoRecords[0], decoded.RedoRecords[1],
+		decoded.RedoRecords[2], decoded.RedoRecords[3], decoded.RedoRecords[4]
+	if write.Type != "WRITE" || write.SpaceID != 1 || write.PageNo != 3 || write.PageOffset != 64 ||
+		write.DataLen != 4 || write.DataPreview != "4142" || write.Length != 8 {
+		t.Fatalf("first record: %+v", write)
+	}
+	if !same.SamePage || same.PageNo != 3 || same.PageOffset != 70 || same.Offset != 8 {
+		t.Fatalf("same page record: %+v", same)
+	}
+	if reserved.Error == "" || reserved.Type != "RESERVED" || reserved.Length != 3 || reserved.Skipped {
+		t.Fatalf("reserved record: %+v", reserved)
+	}
+	if !skipped.Skipped || skipped.PageNo != 5 || skipped.Error != "" {
+		t.Fatalf("record after the error: %+v", skipped)
+	}
+	if truncated.Offset != 23 || truncated.Error == "" || truncated.Length != 0 {
+		t.Fatalf("truncated record: %+v", truncated)
+	}
+}
+
+func TestDecodeWALWarnsOnLengthMismatch(t *testing.T) {
+	// The length field claims 15 bytes but only one data byte follows; the
+	// parser keeps going from the data byte as the applier does
+	decoded := DecodeWAL([]byte{0x3F, 1, 3, 0, 0x70}, DefaultPreviewBytes)
+	if len(decoded.RedoRecords) == 0 || decoded.RedoRecords[0].Warning == "" {
+		t.Fatalf("no warning for a truncated WRITE: %+v", decoded.RedoRecords)
+	}
+	if decoded.RedoRecords[0].Length != 16 {
+		t.Fatalf("length %d, want 16", decoded.RedoRecords[0].Length)
+	}
+}
@@ -1,6 +1,7 @@
 package wal
 
 import (
+	"bytes"
 	"fmt"
 )
 
@@ -16,6 +17,41 @@ const (
 	MREC_OPTION    = 0x70 // Optional record
 )
 
+// File-level redo record types. They carry the same_page bit but can only
+// appear where no page record precedes them in the buffer (or right after
+// another file-level record); the page number is always 0.
+const (
+	FILE_CREATE     = 0x80 // Create a tablespace file: name
+	FILE_DELETE     = 0x90 // Delete a tablespace file: name
+	FILE_RENAME     = 0xA0 // Rename a tablespace file: old name, NUL, new name
+	FILE_MODIFY     = 0xB0 // First modification of a file since the checkpoint: name
+	FILE_CHECKPOINT = 0xF0 // End of a checkpoint: 8-byte LSN
+)
+
+var recordTypeNames = map[byte]string{
+	MREC_FREE_PAGE:  "FREE_PAGE",
+	MREC_INIT_PAGE:  "INIT_PAGE",
+	MREC_EXTENDED:   "EXTENDED",
+	MREC_WRITE:      "WRITE",
+	MREC_MEMSET:     "MEMSET",
+	MREC_MEMMOVE:    "MEMMOVE",
+	MREC_RESERVED:   "RESERVED",
+	MREC_OPTION:     "OPTION",
+	FILE_CREATE:     "FILE_CREATE",
+	FILE_DELETE:     "FILE_DELETE",
+	FILE_RENAME:     "FILE_RENAME",
+	FILE_MODIFY:     "FILE_MODIFY",
+	FILE_CHECKPOINT: "FILE_CHECKPOINT",
+}
+
+// RecordTypeName returns the name of a redo record type, or TYPE_0x<n> for unknown values
+func RecordTypeName(t byte) string {
+	if name, ok := recordTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE_0x%02x", t)
+}
+
 // RedoLogRecord represents a parsed InnoDB redo log record
 type RedoLogRecord struct {
 	Type      byte   // Record type
@@ -27,16 +63,25 @@ type RedoLogRecord struct {
 	DataLen   uint32 // Length for MEMSET
 	SourceOff int32  // Source offset for MEMMOVE (signed)
 	Subtype   byte   // Subtype for EXTENDED records
+	FileName  string // File name for file-level records
+	NewName   string // New file name for FILE_RENAME
+}
+
+// IsFileOp reports whether the record is a file-level record (FILE_*)
+func (r *RedoLogRecord) IsFileOp() bool {
+	return r.Type&0x80 != 0
 }
 
 // RedoLogParser parses InnoDB redo log records
 type RedoLogParser struct {
 	pos      int    // Current position in buffer
 	buf      []byte // Buffer to parse
+	end      int    // End of the last record by its length field; -1 if unknown
 	lastPage struct {
 		spaceID uint32
 		pageNo  uint32
 		offset  uint32
+		valid   bool // A page record was parsed since the last file-level record
 	}
 }
 
@@ -48,8 +93,27 @@ func NewRedoLogParser(data []byte) *RedoLogParser {
 	}
 }
 
+// Offset returns the position in the buffer of the next record to parse
+func (p *RedoLogParser) Offset() int {
+	return p.pos
+}
+
+// RecordEnd returns where the last record passed to ParseRecord ends
+// according to its length field, or -1 if the length could not be read.
+// A record that parsed may still end elsewhere (see DecodeWAL).
+func (p *RedoLogParser) RecordEnd() int {
+	return p.end
+}
+
+// Seek moves to pos, e.g. to RecordEnd after a record failed to parse.
+// Same-page records still refer to the last page parsed before.
+func (p *RedoLogParser) Seek(pos int) {
+	p.pos = pos
+}
+
 // ParseRecord parses a single redo log record
 func (p *RedoLogParser) ParseRecord() (*RedoLogRecord, error) {
+	p.end = -1
 	if p.pos >= len(p.buf) {
 		return nil, fmt.Errorf("end of buffer")
 	}
@@ -68,6 +132,7 @@ func (p *RedoLogParser) ParseRecord() (*RedoLogRecord, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse length: %w", err)
 	}
+	p.end = p.pos + length
 
 	record := &RedoLogRecord{
 		Type:     recordType,
@@ -77,6 +142,11 @@ func (p *RedoLogParser) ParseRecord() (*RedoLogRecord, error) {
 	// Track start position for length calculation
 	recordStartPos := p.pos
 
+	// same_page without a preceding page record marks a file-level record
+	if samePage && !p.lastPage.valid {
+		return p.parseFileRecord(firstByte&0xF0, length, recordStartPos)
+	}
+
 	// Parse page identifier if not same page
 	if !samePage {
 		spaceID, err := p.parseVarLenUint32()
@@ -92,6 +162,7 @@ func (p *RedoLogParser) ParseRecord() (*RedoLogRecord, error) {
 		p.lastPage.spaceID = spaceID
 		p.lastPage.pageNo = pageNo
 		p.lastPage.offset = 0
+		p.lastPage.valid = true
 	} else {
 		record.SpaceID = p.lastPage.spaceID
 		record.PageNo = p.lastPage.pageNo
@@ -201,6 +272,63 @@ func (p *RedoLogParser) ParseRecord() (*RedoLogRecord, error) {
 	}
 }
 
+// parseFileRecord parses the body of a file-level record: space_id, page_no
+// and the type-specific payload, which fills the rest of the record
+func (p *RedoLogParser) parseFileRecord(recordType byte, length int, recordStartPos int) (*RedoLogRecord, error) {
+	spaceID, err := p.parseVarLenUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse space_id: %w", err)
+	}
+	pageNo, err := p.parseVarLenUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page_no: %w", err)
+	}
+
+	payloadLen := length - (p.pos - recordStartPos)
+	if payloadLen < 0 || p.pos+payloadLen > len(p.buf) {
+		return nil, fmt.Errorf("invalid file record 0x%02x: length=%d", recordType, length)
+	}
+	payload := p.buf[p.pos : p.pos+payloadLen]
+	p.pos += payloadLen
+
+	record := &RedoLogRecord{
+		Type:    recordType,
+		SpaceID: spaceID,
+		PageNo:  pageNo,
+	}
+
+	switch recordType {
+	case FILE_CREATE, FILE_DELETE, FILE_MODIFY:
+		record.FileName = string(payload)
+	case FILE_RENAME:
+		oldName, newName, ok := bytes.Cut(payload, []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("invalid FILE_RENAME record: missing new name")
+		}
+		record.FileName = string(oldName)
+		record.NewName = string(newName)
+	case FILE_CHECKPOINT:
+		record.Data = append([]byte(nil), payload...)
+	default:
+		return nil, fmt.Errorf("unknown file record type: 0x%02x", recordType)
+	}
+	return record, nil
+}
+
+// ParseRecords parses records from a WAL buffer until the end of the buffer
+// or the first record that cannot be parsed
+func ParseRecords(data []byte) []*RedoLogRecord {
+	var records []*RedoLogRecord
+	parser := NewRedoLogParser(data)
+	for {
+		record, err := parser.ParseRecord()
+		if err != nil {
+			return records
+		}
+		records = append(records, record)
+	}
+}
+
 // parseLength parses the length field (variable length encoding)
 func (p *RedoLogParser) parseLength(lengthBits byte) (int, error) {
 	if lengthBits == 0 {
@@ -293,7 +421,8 @@ func (p *RedoLogParser) parseVarLenUint32() (uint32, error) {
 		fourthByte := p.buf[p.pos+2]
 		fifthByte := p.buf[p.pos+3]
 		p.pos += 4
-		value := uint32(firstByte&0x07)<<32 | uint32(secondByte)<<24 | uint32(thirdByte)<<16 | uint32(fourthByte)<<8 | uint32(fifthByte)
+		// The low bits of the first byte are unused: the value is the next 4 bytes
+		value := uint32(secondByte)<<24 | uint32(thirdByte)<<16 | uint32(fourthByte)<<8 | uint32(fifthByte)
 		return 270549120 + value, nil
 	} else {
 		return 0, fmt.Errorf("reserved encoding")
@@ -1,62 +1,194 @@
 package wal
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
 
 	"github.com/linux/projects/server/page-server/internal/cache"
+	"github.com/linux/projects/server/page-server/internal/logging"
 	"github.com/linux/projects/server/page-server/internal/storage"
 )
 
 // WALRecord represents a WAL record
 type WALRecord struct {
 	LSN     uint64
+	PrevLSN uint64 // LSN of the record sent before this one; 0 if unknown (see Sequencer)
 	WALData []byte
 	SpaceID uint32
 	PageNo  uint32
 }
 
-// WALProcessor handles WAL record processing and application to pages
+// ErrProcessorClosed is returned for records received after Close
+var ErrProcessorClosed = errors.New("WAL processor is shut down")
+
+// RecordObserver is notified of the parsed redo records of every stored WAL record
+type RecordObserver interface {
+	ObserveRecords(lsn uint64, records []*RedoLogRecord) error
+}
+
+// ApplyHook is called after every WAL record, stored or skipped, in LSN
+// order; spaceID and pageNo are zero unless a page version was written for it
+type ApplyHook func(lsn uint64, spaceID uint32, pageNo uint32)
+
+// PageFilter reports whether WAL for a page is stored by this server; records
+// for other pages are only shown to the observer (see SetPageFilter)
+type PageFilter func(spaceID uint32, pageNo uint32) bool
+
+// WALProcessor handles WAL record processing and application to pages.
+// Records are stored and decoded one at a time, then applied by workers
+// (see apply.go): records for one page apply in order, other pages in parallel.
 type WALProcessor struct {
-	storage storage.StorageBackend
-	cache   *cache.PageCache
-	mu      sync.Mutex
+	storage  storage.StorageBackend
+	cache    *cache.PageCache
+	observer RecordObserver
+	filter   PageFilter
+	applier  *applier
+	mu       sync.Mutex // Serializes ingestion
+	closed   bool
 }
 
-// NewWALProcessor creates a new WAL processor
-func NewWALProcessor(storage storage.StorageBackend, cache *cache.PageCache) *WALProcessor {
-	return &WALProcessor{
+// NewWALProcessor creates a new WAL processor and starts its apply workers
+func NewWALProcessor(storage storage.StorageBackend, cache *cache.PageCache, opts ApplyOptions) *WALProcessor {
+	wp := &WALProcessor{
 		storage: storage,
 		cache:   cache,
+		applier: newApplier(opts),
 	}
+	wp.applier.start(wp.applyWALToPage)
+	return wp
 }
 
-// ProcessWALRecord processes a WAL record and applies it to pages
+// ProcessWALRecord stores a WAL record and queues it for its page's apply
+// worker. It waits while that worker is too far behind. Readers see the
+// new page version once WaitForPage returns.
 func (wp *WALProcessor) ProcessWALRecord(record WALRecord) error {
+	_, err := wp.ingest(record, false)
+	return err
+}
+
+// BackfillWALRecord stores and applies a record received after records with
+// higher LSNs (see Sequencer). The versions of its page stored at higher
+// LSNs were built without it, so they are rebuilt from their stored WAL.
+// Unlike ProcessWALRecord it waits for the page and returns the apply error.
+func (wp *WALProcessor) BackfillWALRecord(record WALRecord) error {
+	task, err := wp.ingest(record, true)
+	if err != nil || task == nil {
+		return err
+	}
+	return <-task.result
+}
+
+// ingest stores a record and dispatches it; the task is returned if it
+// applies to a page. Rebuild marks a backfilled record.
+func (wp *WALProcessor) ingest(record WALRecord, rebuild bool) (*applyTask, error) {
 	wp.mu.Lock()
 	defer wp.mu.Unlock()
 	
+	if wp.closed {
+		return nil, ErrProcessorClosed
+	}
+	
+	hasPage := record.SpaceID > 0 && record.PageNo > 0
+	if hasPage && wp.filter != nil && !wp.filter(record.SpaceID, record.PageNo) {
+		wp.skipWALRecord(record)
+		return nil, nil
+	}
+	
 	// Store WAL record first (for durability)
 	if err := wp.storage.StoreWAL(record.LSN, record.WALData); err != nil {
-		return fmt.Errorf("failed to store WAL: %w", err)
+		return nil, fmt.Errorf("failed to store WAL: %w", err)
 	}
 	
-	// If we have space_id and page_no, try to apply the WAL
-	if record.SpaceID > 0 && record.PageNo > 0 {
-		if err := wp.applyWALToPage(record); err != nil {
-			log.Printf("Warning: Failed to apply WAL to page: %v", err)
-			// Don't fail the request if WAL application fails
-			// The WAL is stored and can be replayed later
+	// Let the observer track file-level changes; the records are stored either way
+	parsed := ParseRecords(record.WALData)
+	if wp.observer != nil {
+		if err := wp.observer.ObserveRecords(record.LSN, parsed); err != nil {
+			log.Printf("Warning: Failed to track redo records at LSN %d: %v", record.LSN, err)
 		}
 	}
 	
-	return nil
+	// If we have space_id and page_no, a worker applies the WAL; a failure
+	// there is only logged since the WAL is stored and can be replayed later
+	task := &applyTask{record: record, parsed: parsed, apply: hasPage}
+	if hasPage && rebuild {
+		task.rebuild = true
+		task.result = make(chan error, 1)
+	}
+	wp.applier.dispatch(task)
+	if !hasPage {
+		return nil, nil
+	}
+	return task, nil
+}
+
+// skipWALRecord handles a record for a page another server stores: the
+// observer still tracks its file-level changes, nothing is stored
+func (wp *WALProcessor) skipWALRecord(record WALRecord) {
+	if wp.observer != nil {
+		if err := wp.observer.ObserveRecords(record.LSN, ParseRecords(record.WALData)); err != nil {
+			log.Printf("Warning: Failed to track redo records at LSN %d: %v", record.LSN, err)
+		}
+	}
+	wp.applier.dispatch(&applyTask{record: record})
+	logging.Debugf("Skipped WAL record for another shard: LSN=%d space=%d page=%d",
+		record.LSN, record.SpaceID, record.PageNo)
+}
+
+// SetPageFilter restricts the pages whose WAL is stored and applied; records
+// without a page are always stored
+func (wp *WALProcessor) SetPageFilter(filter PageFilter) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.filter = filter
+}
+
+// SetObserver registers an observer for parsed redo records
+func (wp *WALProcessor) SetObserver(observer RecordObserver) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.observer = observer
 }
 
-// applyWALToPage applies a WAL record to a specific page
-func (wp *WALProcessor) applyWALToPage(record WALRecord) error {
+// SetApplyHook registers a function called after every stored WAL record
+func (wp *WALProcessor) SetApplyHook(hook ApplyHook) {
+	wp.applier.mu.Lock()
+	defer wp.applier.mu.Unlock()
+	wp.applier.hook = hook
+}
+
+// WaitForPage waits until every record received for a page at or below
+// lsn has been applied to it
+func (wp *WALProcessor) WaitForPage(ctx context.Context, spaceID uint32, pageNo uint32, lsn uint64) error {
+	return wp.applier.waitForPage(ctx, pageKey{spaceID, pageNo}, lsn)
+}
+
+// ApplyStats returns the state of the apply workers
+func (wp *WALProcessor) ApplyStats() ApplyStats {
+	return wp.applier.stats()
+}
+
+// Close rejects all records received afterwards and waits for the workers
+// to apply the records already queued
+func (wp *WALProcessor) Close() {
+	wp.mu.Lock()
+	if wp.closed {
+		wp.mu.Unlock()
+		return
+	}
+	wp.closed = true
+	wp.mu.Unlock()
+	wp.applier.stop()
+}
+
+// applyWALToPage applies a WAL record to a specific page; run by the apply workers
+func (wp *WALProcessor) applyWALToPage(task *applyTask) error {
+	record := task.record
+
 	// Load the current page version (or create empty page)
 	pageData, pageLSN, err := wp.storage.LoadPage(record.SpaceID, record.PageNo, record.LSN)
 	if err != nil {
@@ -65,10 +197,13 @@ func (wp *WALProcessor) applyWALToPage(record WALRecord) error {
 		pageLSN = 0
 	}
 	
-	// Apply WAL record to page using full InnoDB redo log parsing
-	updatedPage, err := wp.applyRedoLogRecord(pageData, record.WALData, record.LSN)
-	if err != nil {
-		return fmt.Errorf("failed to apply redo log: %w", err)
+	// Apply the redo records decoded at ingestion
+	updatedPage := pageData
+	if len(record.WALData) > 0 {
+		updatedPage, err = wp.applyRedoLogRecords(pageData, task.parsed, record.LSN)
+		if err != nil {
+			return fmt.Errorf("failed to apply redo log: %w", err)
+		}
 	}
 	
 	// Store the updated page with new LSN
@@ -79,19 +214,77 @@ func (wp *WALProcessor) applyWALToPage(record WALRecord) error {
 	// Update cache
 	wp.cache.Put(record.SpaceID, record.PageNo, record.LSN, updatedPage)
 	
-	log.Printf("Applied WAL to page: space=%d page=%d old_lsn=%d new_lsn=%d",
+	logging.Debugf("Applied WAL to page: space=%d page=%d old_lsn=%d new_lsn=%d",
 		record.SpaceID, record.PageNo, pageLSN, record.LSN)
 	
+	if task.rebuild {
+		return wp.rebuildLaterVersions(record.SpaceID, record.PageNo, record.LSN, updatedPage)
+	}
 	return nil
 }
 
-// applyRedoLogRecord applies a redo log record to a page
-// Now uses full InnoDB redo log parsing
-func (wp *WALProcessor) applyRedoLogRecord(pageData []byte, walData []byte, lsn uint64) ([]byte, error) {
+// rebuildLaterVersions re-applies the stored WAL of each version of a page
+// above lsn, starting from page, the version just stored at lsn. A version
+// without stored WAL is a base image (e.g. imported) that the WAL at lsn did
+// not contribute to, so it and the versions after it are kept.
+func (wp *WALProcessor) rebuildLaterVersions(spaceID uint32, pageNo uint32, lsn uint64, page []byte) error {
+	versioned, ok := wp.storage.(storage.VersionedStorage)
+	reader, readable := wp.storage.(storage.WALReader)
+	if !ok || !readable {
+		return fmt.Errorf("storage cannot rebuild page %d:%d after LSN %d", spaceID, pageNo, lsn)
+	}
+	versions, err := versioned.ListPageVersions(spaceID)
+	if err != nil {
+		return fmt.Errorf("failed to list versions of page %d:%d: %w", spaceID, pageNo, err)
+	}
+	lsns := versions[pageNo]
+	slices.Sort(lsns)
+
+	rebuilt := 0
+	for _, v := range lsns {
+		if v <= lsn {
+			continue
+		}
+		walData, err := reader.LoadWAL(v)
+		if errors.Is(err, storage.ErrWALNotFound) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load WAL at LSN %d to rebuild page %d:%d: %w", v, spaceID, pageNo, err)
+		}
+		if len(walData) > 0 {
+			if page, err = wp.applyRedoLogRecords(page, ParseRecords(walData), v); err != nil {
+				return fmt.Errorf("failed to rebuild page %d:%d at LSN %d: %w", spaceID, pageNo, v, err)
+			}
+		}
+		if err := wp.storage.StorePage(spaceID, pageNo, v, page); err != nil {
+			return fmt.Errorf("failed to store rebuilt page %d:%d at LSN %d: %w", spaceID, pageNo, v, err)
+		}
+		rebuilt++
+	}
+
+	// The cache holds the newest version as it was before the rebuild
+	if rebuilt > 0 {
+		wp.cache.Remove(spaceID, pageNo)
+		logging.Debugf("Rebuilt %d versions of page %d:%d after backfilling LSN %d", rebuilt, spaceID, pageNo, lsn)
+	}
+	return nil
+}
+
+// Redo applies a WAL record to a page image exactly as ProcessWALRecord
+// does, without storing the result. A nil page starts from an empty page.
+func (wp *WALProcessor) Redo(page []byte, walData []byte, lsn uint64) ([]byte, error) {
+	if page == nil {
+		page = make([]byte, 16384)
+	}
 	if len(walData) == 0 {
-		return pageData, nil
+		return page, nil
 	}
+	return wp.applyRedoLogRecords(page, ParseRecords(walData), lsn)
+}
 
+// applyRedoLogRecords applies parsed redo log records to a copy of a page
+func (wp *WALProcessor) applyRedoLogRecords(pageData []byte, records []*RedoLogRecord, lsn uint64) ([]byte, error) {
 	// Ensure page is at least 16KB (InnoDB default page size)
 	if len(pageData) < 16384 {
 		newPage := make([]byte, 16384)
@@ -102,16 +295,7 @@ func (wp *WALProcessor) applyRedoLogRecord(pageData []byte, walData []byte, lsn
 	result := make([]byte, len(pageData))
 	copy(result, pageData)
 
-	// Parse redo log records
-	parser := NewRedoLogParser(walData)
-	
-	for {
-		record, err := parser.ParseRecord()
-		if err != nil {
-			// End of buffer or parsing error
-			break
-		}
-
+	for _, record := range records {
 		// Apply record to page
 		if err := wp.applyRecordToPage(result, record, lsn); err != nil {
 			log.Printf("Warning: Failed to apply redo log record type 0x%02x: %v", record.Type, err)
@@ -129,6 +313,11 @@ func (wp *WALProcessor) applyRedoLogRecord(pageData []byte, walData []byte, lsn
 
 // applyRecordToPage applies a parsed redo log record to a page
 func (wp *WALProcessor) applyRecordToPage(pageData []byte, record *RedoLogRecord, lsn uint64) error {
+	// File-level records do not change page contents
+	if record.IsFileOp() {
+		return nil
+	}
+
 	switch record.Type {
 	case MREC_FREE_PAGE:
 		// FREE_PAGE: Mark page as free (zero it out)
@@ -0,0 +1,79 @@
+package wal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linux/projects/server/page-server/internal/cache"
+	"github.com/linux/projects/server/page-server/internal/storage"
+)
+
+// writeByte returns WAL writing b at offset of space 1 page 3
+func writeByte(offset byte, b byte) []byte {
+	return []byte{0x34, 1, 3, offset, b}
+}
+
+// pageBytes loads page 3 at lsn and returns its LSN and bytes 64-66
+func pageBytes(t *testing.T, backend storage.StorageBackend, lsn uint64) (uint64, string) {
+	t.Helper()
+	page, version, err := backend.LoadPage(1, 3, lsn)
+	if err != nil {
+		t.Fatalf("LoadPage at %d: %v", lsn, err)
+	}
+	return version, string(page[64:67])
+}
+
+func TestBackfillRebuildsLaterVersions(t *testing.T) {
+	backend := storage.NewMemoryStorage()
+	pc := cache.NewPageCache(10)
+	wp := NewWALProcessor(backend, pc, ApplyOptions{})
+	defer wp.Close()
+
+	// LSN 20 is missing when 30 arrives
+	for _, record := range []WALRecord{
+		{LSN: 10, SpaceID: 1, PageNo: 3, WALData: writeByte(64, 'a')},
+		{LSN: 30, SpaceID: 1, PageNo: 3, WALData: writeByte(66, 'c')},
+	} {
+		if err := wp.ProcessWALRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wp.WaitForPage(context.Background(), 1, 3, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, got := pageBytes(t, backend, 30); got != "a\x00c" {
+		t.Fatalf("before the backfill: %q", got)
+	}
+
+	// A base image at 40 replaces everything before it
+	image := make([]byte, 16384)
+	copy(image[64:], "xyz")
+	if err := backend.StorePage(1, 3, 40, image); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wp.BackfillWALRecord(WALRecord{LSN: 20, SpaceID: 1, PageNo: 3, WALData: writeByte(65, 'b')}); err != nil {
+		t.Fatalf("BackfillWALRecord: %v", err)
+	}
+	tests := []struct {
+		lsn     uint64
+		version uint64
+		want    string
+	}{
+		{15, 10, "a\x00\x00"},
+		{20, 20, "ab\x00"},
+		{35, 30, "abc"},
+		{100, 40, "xyz"},
+	}
+	for _, tt := range tests {
+		version, got := pageBytes(t, backend, tt.lsn)
+		if version != tt.version || got != tt.want {
+			t.Errorf("page at LSN %d: version %d %q, want %d %q", tt.lsn, version, got, tt.version, tt.want)
+		}
+	}
+
+	// The version cached before the backfill is not served
+	if data, _, ok := pc.Get(1, 3, 35, 35); ok && string(data[64:67]) != "abc" {
+		t.Fatalf("cached page: %q", data[64:67])
+	}
+}
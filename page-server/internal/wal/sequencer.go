@@ -0,0 +1,432 @@
+package wal
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome describes what the Sequencer did with a record
+type Outcome int
+
+const (
+	// Applied records were passed to the processor in order
+	Applied Outcome = iota
+	// Buffered records wait for the records before them
+	Buffered
+	// Duplicate records were received before and are ignored
+	Duplicate
+	// Backfilled records filled part of a gap and were applied late
+	Backfilled
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Applied:
+		return "applied"
+	case Buffered:
+		return "buffered"
+	case Duplicate:
+		return "duplicate"
+	case Backfilled:
+		return "backfilled"
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// Gap is an inclusive range of LSNs that was skipped: records in it were
+// never received and can be backfilled (e.g. from a safekeeper)
+type Gap struct {
+	StartLSN   uint64    `json:"start_lsn"`
+	EndLSN     uint64    `json:"end_lsn"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// SequencerOptions configure a Sequencer
+type SequencerOptions struct {
+	StatePath   string        // Watermark and gaps, kept across restarts
+	InitialLSN  uint64        // Applied LSN if the state file does not exist
+	GapTimeout  time.Duration // How long records wait for a missing predecessor
+	MaxBuffered int           // Records buffered before the oldest gap is skipped
+
+	// Backfill applies a record inside a gap, whose page may have versions
+	// at higher LSNs to rebuild; the apply function is used if it is nil.
+	// The gap stays open if it fails.
+	Backfill func(WALRecord) error
+}
+
+// SequencerStatus reports the watermarks and gaps of a Sequencer
+type SequencerStatus struct {
+	AppliedLSN  uint64 `json:"applied_lsn"`       // Every record up to here was applied or declared missing
+	ReceivedLSN uint64 `json:"received_lsn"`      // Highest LSN received
+	Buffered    int    `json:"buffered"`          // Records waiting for a predecessor
+	Waiting     *Gap   `json:"waiting,omitempty"` // The range buffered records are waiting for
+	Gaps        []Gap  `json:"gaps"`
+	Duplicates  int64  `json:"duplicates"`
+	OutOfOrder  int64  `json:"out_of_order"` // Records that had to be buffered
+	Backfilled  int64  `json:"backfilled"`
+	GapsFound   int64  `json:"gaps_detected"`
+}
+
+// pendingRecord is a buffered record
+type pendingRecord struct {
+	record WALRecord
+	since  time.Time
+}
+
+// pendingHeap orders buffered records by LSN
+type pendingHeap []*pendingRecord
+
+func (h pendingHeap) Len() int           { return len(h) }
+func (h pendingHeap) Less(i, j int) bool { return h[i].record.LSN < h[j].record.LSN }
+func (h pendingHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pendingHeap) Push(x any)        { *h = append(*h, x.(*pendingRecord)) }
+func (h *pendingHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return p
+}
+
+// sequencerState is the state file
+type sequencerState struct {
+	AppliedLSN uint64 `json:"applied_lsn"`
+	Gaps       []Gap  `json:"gaps"`
+}
+
+// Sequencer passes WAL records to the processor in LSN order. A record
+// naming a PrevLSN that has not been applied yet is buffered until its
+// predecessor arrives or GapTimeout passes; the skipped range is then
+// recorded as a gap. Records at or below the applied LSN are duplicates
+// unless they fall in a gap.
+type Sequencer struct {
+	apply func(WALRecord) error
+	opts  SequencerOptions
+
+	mu       sync.Mutex
+	applied  uint64
+	received uint64
+	pending  map[uint64]*pendingRecord // Buffered records by LSN
+	queue    pendingHeap               // The same records, lowest LSN first
+	gaps     []Gap
+	status   SequencerStatus // Counters
+	dirty    bool            // State not saved yet
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSequencer loads the sequencer state and returns a Sequencer applying
+// records with apply
+func NewSequencer(apply func(WALRecord) error, opts SequencerOptions) (*Sequencer, error) {
+	s := &Sequencer{
+		apply:   apply,
+		opts:    opts,
+		applied: opts.InitialLSN,
+		pending: make(map[uint64]*pendingRecord),
+	}
+
+	data, err := os.ReadFile(opts.StatePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read WAL sequence state: %w", err)
+	default:
+		var state sequencerState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse WAL sequence state %s: %w", opts.StatePath, err)
+		}
+		if state.AppliedLSN > s.applied {
+			s.applied = state.AppliedLSN
+		}
+		s.gaps = state.Gaps
+	}
+	s.received = s.applied
+	return s, nil
+}
+
+// Submit sequences a record: it is applied (with any buffered records it
+// unblocks), buffered, or ignored as a duplicate. The error is the
+// processor's error for this record.
+func (s *Sequencer) Submit(record WALRecord) (Outcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.LSN > s.received {
+		s.received = record.LSN
+	}
+
+	switch {
+	case record.LSN <= s.applied:
+		i := s.gapIndex(record.LSN)
+		if i < 0 {
+			s.status.Duplicates++
+			return Duplicate, nil
+		}
+		backfill := s.opts.Backfill
+		if backfill == nil {
+			backfill = s.apply
+		}
+		if err := backfill(record); err != nil {
+			return Backfilled, err
+		}
+		s.fillGap(i, record)
+		s.status.Backfilled++
+		return Backfilled, nil
+
+	case s.pending[record.LSN] != nil:
+		s.status.Duplicates++
+		return Duplicate, nil
+
+	case record.PrevLSN > s.applied:
+		p := &pendingRecord{record: record, since: time.Now()}
+		s.pending[record.LSN] = p
+		heap.Push(&s.queue, p)
+		s.status.OutOfOrder++
+		if s.opts.MaxBuffered > 0 && len(s.pending) > s.opts.MaxBuffered {
+			log.Printf("Warning: %d WAL records buffered; skipping the oldest gap", len(s.pending))
+			s.releaseOldest()
+		}
+		if s.pending[record.LSN] != nil {
+			return Buffered, nil
+		}
+		return Applied, nil
+	}
+
+	// Buffered records below this one would be left behind for good
+	for {
+		lowest := s.lowestPending()
+		if lowest == nil || lowest.record.LSN > record.LSN {
+			break
+		}
+		s.releaseOldest()
+	}
+	if err := s.applyInOrder(record); err != nil {
+		return Applied, err
+	}
+	s.drain()
+	return Applied, nil
+}
+
+// applyInOrder applies a record and advances the applied LSN; s.mu must be held
+func (s *Sequencer) applyInOrder(record WALRecord) error {
+	if err := s.apply(record); err != nil {
+		return err
+	}
+	if record.LSN > s.applied {
+		s.applied = record.LSN
+		s.dirty = true
+	}
+	return nil
+}
+
+// drain applies buffered records whose predecessor has been applied; s.mu must be held
+func (s *Sequencer) drain() {
+	for {
+		lowest := s.lowestPending()
+		if lowest == nil || lowest.record.PrevLSN > s.applied {
+			return
+		}
+		if lowest.record.LSN <= s.applied {
+			s.status.Duplicates++
+		} else if err := s.applyInOrder(lowest.record); err != nil {
+			// Keep it buffered; the next timeout retries it
+			log.Printf("Warning: Failed to apply buffered WAL record at LSN %d: %v", lowest.record.LSN, err)
+			return
+		}
+		heap.Pop(&s.queue)
+		delete(s.pending, lowest.record.LSN)
+	}
+}
+
+// releaseOldest records the range the oldest buffered record waits for as
+// a gap and applies it; s.mu must be held
+func (s *Sequencer) releaseOldest() {
+	lowest := s.lowestPending()
+	if lowest == nil {
+		return
+	}
+	if prev := lowest.record.PrevLSN; prev > s.applied {
+		gap := Gap{StartLSN: s.applied + 1, EndLSN: prev, DetectedAt: time.Now().UTC()}
+		if prev >= lowest.record.LSN {
+			gap.EndLSN = lowest.record.LSN - 1
+		}
+		log.Printf("Warning: WAL gap: LSNs %d-%d never arrived; applying from LSN %d", gap.StartLSN, gap.EndLSN, lowest.record.LSN)
+		s.gaps = append(s.gaps, gap)
+		s.status.GapsFound++
+		s.applied = gap.EndLSN
+		s.dirty = true
+	}
+	s.drain()
+}
+
+// lowestPending returns the buffered record with the lowest LSN; s.mu must be held
+func (s *Sequencer) lowestPending() *pendingRecord {
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return s.queue[0]
+}
+
+// gapIndex returns the index of the gap containing lsn, or -1; s.mu must be held
+func (s *Sequencer) gapIndex(lsn uint64) int {
+	for i, gap := range s.gaps {
+		if lsn >= gap.StartLSN && lsn <= gap.EndLSN {
+			return i
+		}
+	}
+	return -1
+}
+
+// fillGap removes a backfilled record from gap i. The part of the gap below
+// the record is known to be empty if the record names an earlier PrevLSN.
+// s.mu must be held.
+func (s *Sequencer) fillGap(i int, record WALRecord) {
+	gap := s.gaps[i]
+	var parts []Gap
+	lowEnd := record.LSN - 1
+	if record.PrevLSN != 0 && record.PrevLSN < lowEnd {
+		lowEnd = record.PrevLSN
+	}
+	if record.LSN > gap.StartLSN && lowEnd >= gap.StartLSN {
+		parts = append(parts, Gap{StartLSN: gap.StartLSN, EndLSN: lowEnd, DetectedAt: gap.DetectedAt})
+	}
+	if record.LSN < gap.EndLSN {
+		parts = append(parts, Gap{StartLSN: record.LSN + 1, EndLSN: gap.EndLSN, DetectedAt: gap.DetectedAt})
+	}
+	s.gaps = append(s.gaps[:i], append(parts, s.gaps[i+1:]...)...)
+	s.dirty = true
+}
+
+// Advance moves the applied LSN forward to lsn, for a base image that
+// replaces the WAL before it
+func (s *Sequencer) Advance(lsn uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lsn <= s.applied {
+		return
+	}
+	s.applied = lsn
+	if lsn > s.received {
+		s.received = lsn
+	}
+	s.dirty = true
+	s.drain()
+}
+
+// AppliedLSN returns the LSN up to which every record was applied or declared missing
+func (s *Sequencer) AppliedLSN() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied
+}
+
+// Status returns the watermarks, counters and gaps
+func (s *Sequencer) Status() SequencerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.AppliedLSN = s.applied
+	status.ReceivedLSN = s.received
+	status.Buffered = len(s.pending)
+	status.Gaps = append([]Gap{}, s.gaps...)
+	sort.Slice(status.Gaps, func(i, j int) bool { return status.Gaps[i].StartLSN < status.Gaps[j].StartLSN })
+	if lowest := s.lowestPending(); lowest != nil && lowest.record.PrevLSN > s.applied {
+		status.Waiting = &Gap{StartLSN: s.applied + 1, EndLSN: lowest.record.PrevLSN, DetectedAt: lowest.since.UTC()}
+	}
+	return status
+}
+
+// expire skips gaps buffered records have waited for longer than GapTimeout
+func (s *Sequencer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		lowest := s.lowestPending()
+		if lowest == nil || time.Since(lowest.since) < s.opts.GapTimeout {
+			break
+		}
+		before := len(s.pending)
+		s.releaseOldest()
+		if len(s.pending) == before {
+			break // Could not apply it; retry next time
+		}
+	}
+	if err := s.saveLocked(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// saveLocked writes the state file if it changed; s.mu must be held
+func (s *Sequencer) saveLocked() error {
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(sequencerState{AppliedLSN: s.applied, Gaps: s.gaps})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(s.opts.StatePath), "."+filepath.Base(s.opts.StatePath)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save WAL sequence state: %w", err)
+	}
+	if err := os.Rename(tmp, s.opts.StatePath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save WAL sequence state: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Start checks for expired gaps until Close is called
+func (s *Sequencer) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	interval := s.opts.GapTimeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.expire()
+			}
+		}
+	}()
+}
+
+// Close stops the background loop, applies the buffered records (recording
+// what they wait for as gaps) and saves the state
+func (s *Sequencer) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 {
+		before := len(s.pending)
+		s.releaseOldest()
+		if len(s.pending) == before {
+			log.Printf("Warning: Dropping %d buffered WAL records", len(s.pending))
+			break
+		}
+	}
+	return s.saveLocked()
+}
@@ -0,0 +1,147 @@
+package wal
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recorder collects the LSNs a Sequencer applies
+type recorder struct {
+	applied []uint64
+}
+
+func (r *recorder) apply(record WALRecord) error {
+	r.applied = append(r.applied, record.LSN)
+	return nil
+}
+
+func newTestSequencer(t *testing.T, r *recorder, timeout time.Duration) *Sequencer {
+	s, err := NewSequencer(r.apply, SequencerOptions{
+		StatePath:  filepath.Join(t.TempDir(), "wal_sequence.json"),
+		GapTimeout: timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func submit(t *testing.T, s *Sequencer, lsn, prev uint64, want Outcome) {
+	t.Helper()
+	got, err := s.Submit(WALRecord{LSN: lsn, PrevLSN: prev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("LSN %d (prev %d): %s, want %s", lsn, prev, got, want)
+	}
+}
+
+func TestSequencerReordersAndIgnoresDuplicates(t *testing.T) {
+	r := &recorder{}
+	s := newTestSequencer(t, r, time.Hour)
+
+	submit(t, s, 10, 0, Applied)
+	submit(t, s, 30, 20, Buffered)
+	submit(t, s, 40, 30, Buffered)
+	submit(t, s, 30, 20, Duplicate)
+	submit(t, s, 20, 10, Applied)
+	submit(t, s, 20, 10, Duplicate)
+
+	if want := []uint64{10, 20, 30, 40}; !reflect.DeepEqual(r.applied, want) {
+		t.Fatalf("applied %v, want %v", r.applied, want)
+	}
+	status := s.Status()
+	if status.AppliedLSN != 40 || status.Buffered != 0 || len(status.Gaps) != 0 || status.Duplicates != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestSequencerRecordsAndBackfillsGaps(t *testing.T) {
+	r := &recorder{}
+	s := newTestSequencer(t, r, time.Hour)
+
+	submit(t, s, 10, 0, Applied)
+	submit(t, s, 40, 30, Buffered)
+	if w := s.Status().Waiting; w == nil || w.StartLSN != 11 || w.EndLSN != 30 {
+		t.Fatalf("waiting for %+v, want 11-30", w)
+	}
+
+	// Timeout: the missing range becomes a gap and the record is applied
+	s.opts.GapTimeout = 0
+	s.expire()
+	status := s.Status()
+	if status.AppliedLSN != 40 || len(status.Gaps) != 1 || status.Gaps[0].StartLSN != 11 || status.Gaps[0].EndLSN != 30 {
+		t.Fatalf("after the timeout: %+v", status)
+	}
+
+	// Backfilled records inside the gap are applied and shrink it
+	submit(t, s, 20, 10, Backfilled)
+	if gaps := s.Status().Gaps; len(gaps) != 1 || gaps[0].StartLSN != 21 || gaps[0].EndLSN != 30 {
+		t.Fatalf("after backfilling 20: %+v", gaps)
+	}
+	submit(t, s, 30, 20, Backfilled)
+	if gaps := s.Status().Gaps; len(gaps) != 0 {
+		t.Fatalf("after backfilling 30: %+v", gaps)
+	}
+	submit(t, s, 30, 20, Duplicate)
+
+	// The gaps and watermark survive a restart
+	submit(t, s, 60, 50, Buffered)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewSequencer(r.apply, s.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = reopened.Status()
+	if status.AppliedLSN != 60 || len(status.Gaps) != 1 || status.Gaps[0].StartLSN != 41 || status.Gaps[0].EndLSN != 50 {
+		t.Fatalf("after restart: %+v", status)
+	}
+}
+
+func TestSequencerBackfillKeepsGapOnFailure(t *testing.T) {
+	r := &recorder{}
+	s := newTestSequencer(t, r, 0)
+	var backfilled []uint64
+	fail := true
+	s.opts.Backfill = func(record WALRecord) error {
+		if fail {
+			return errors.New("cannot rebuild")
+		}
+		backfilled = append(backfilled, record.LSN)
+		return nil
+	}
+
+	submit(t, s, 10, 0, Applied)
+	for lsn := uint64(100); lsn > 40; lsn -= 10 {
+		submit(t, s, lsn, lsn-10, Buffered)
+	}
+	if w := s.Status().Waiting; w == nil || w.StartLSN != 11 || w.EndLSN != 40 {
+		t.Fatalf("waiting for %+v, want 11-40", w)
+	}
+	s.expire()
+	if want := []uint64{10, 50, 60, 70, 80, 90, 100}; !reflect.DeepEqual(r.applied, want) {
+		t.Fatalf("applied %v, want %v", r.applied, want)
+	}
+
+	// The gap stays open until the backfill succeeds
+	if _, err := s.Submit(WALRecord{LSN: 20, PrevLSN: 10}); err == nil {
+		t.Fatal("failed backfill returned no error")
+	}
+	if gaps := s.Status().Gaps; len(gaps) != 1 || gaps[0].StartLSN != 11 || gaps[0].EndLSN != 40 {
+		t.Fatalf("after a failed backfill: %+v", gaps)
+	}
+	fail = false
+	submit(t, s, 20, 10, Backfilled)
+	if gaps := s.Status().Gaps; len(gaps) != 1 || gaps[0].StartLSN != 21 {
+		t.Fatalf("after backfilling 20: %+v", gaps)
+	}
+	if !reflect.DeepEqual(backfilled, []uint64{20}) || len(r.applied) != 7 {
+		t.Fatalf("backfilled %v, applied %v", backfilled, r.applied)
+	}
+}
@@ -0,0 +1,68 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/linux/projects/server/page-server/pkg/types"
+)
+
+// ForwardedHeader marks a request forwarded by another shard, which must not be forwarded again
+const ForwardedHeader = "X-Page-Server-Forwarded"
+
+// credentialHeaders are copied from the client's request to forwarded requests
+var credentialHeaders = []string{"Authorization", "X-API-Key"}
+
+// Client reads pages from other shards
+type Client struct {
+	HTTP *http.Client
+}
+
+// GetPages sends a batch to the page server at baseURL. header carries the
+// credentials of the original request.
+func (c *Client) GetPages(ctx context.Context, baseURL string, header http.Header, req types.GetPagesRequest) (*types.GetPagesResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/api/v1/get_pages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(ForwardedHeader, "1")
+	for _, name := range credentialHeaders {
+		if value := header.Get(name); value != "" {
+			httpReq.Header.Set(name, value)
+		}
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach shard %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("shard %s returned %s: %s", baseURL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out types.GetPagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode response from shard %s: %w", baseURL, err)
+	}
+	if len(out.Pages) != len(req.Pages) {
+		return nil, fmt.Errorf("shard %s returned %d pages for %d requested", baseURL, len(out.Pages), len(req.Pages))
+	}
+	return &out, nil
+}
@@ -0,0 +1,152 @@
+// Package sharding assigns the pages of a tenant to page server shards.
+//
+// A Map is published by every shard (GET /api/v1/shard_map) so compute and
+// proxies can route each page request. Two schemes are supported:
+//
+//   - "hash": pages are grouped into stripes of StripePages consecutive pages
+//     of a space, and a stripe goes to shard
+//     fnv1a64(le32(space_id) || le32(page_no / stripe_pages)) % len(shards)
+//   - "range": each shard owns the keys (space_id, page_no) from its Start up
+//     to the next shard's Start
+package sharding
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+const (
+	// SchemeHash spreads stripes of pages over the shards by hash
+	SchemeHash = "hash"
+	// SchemeRange gives each shard a contiguous key range
+	SchemeRange = "range"
+
+	// DefaultStripePages is used when Map.StripePages is not set
+	DefaultStripePages = 256
+)
+
+// Key identifies a page; keys are ordered by space, then page
+type Key struct {
+	SpaceID uint32 `json:"space_id"`
+	PageNo  uint32 `json:"page_no"`
+}
+
+// Less reports whether k sorts before other
+func (k Key) Less(other Key) bool {
+	if k.SpaceID != other.SpaceID {
+		return k.SpaceID < other.SpaceID
+	}
+	return k.PageNo < other.PageNo
+}
+
+// Shard is one page server of a sharded tenant
+type Shard struct {
+	URL   string `json:"url"`             // Base URL, e.g. https://ps-1:8080
+	Start *Key   `json:"start,omitempty"` // range: first key owned
+}
+
+// Map assigns every page of a tenant to one shard
+type Map struct {
+	Version     uint64  `json:"version"` // Changes whenever the map does
+	Scheme      string  `json:"scheme"`
+	StripePages uint32  `json:"stripe_pages,omitempty"` // hash: consecutive pages kept on one shard
+	Shards      []Shard `json:"shards"`
+}
+
+// MapResponse is the body of GET /api/v1/shard_map
+type MapResponse struct {
+	Status  string `json:"status"`
+	Sharded bool   `json:"sharded"`
+	Shard   int    `json:"shard"` // Index of the answering server in Map
+	Map     *Map   `json:"map,omitempty"`
+}
+
+// Load reads and validates a JSON shard map
+func Load(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard map: %w", err)
+	}
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse shard map %s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid shard map %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Validate checks that the map assigns every key to exactly one shard
+func (m *Map) Validate() error {
+	if len(m.Shards) == 0 {
+		return fmt.Errorf("no shards")
+	}
+	for i, shard := range m.Shards {
+		if shard.URL == "" {
+			return fmt.Errorf("shard %d has no url", i)
+		}
+	}
+
+	switch m.Scheme {
+	case SchemeHash:
+		for i, shard := range m.Shards {
+			if shard.Start != nil {
+				return fmt.Errorf("shard %d: start is only valid with the range scheme", i)
+			}
+		}
+	case SchemeRange:
+		for i, shard := range m.Shards {
+			switch {
+			case shard.Start == nil:
+				return fmt.Errorf("shard %d has no start", i)
+			case i == 0 && *shard.Start != (Key{}):
+				return fmt.Errorf("shard 0 must start at space 0 page 0")
+			case i > 0 && !m.Shards[i-1].Start.Less(*shard.Start):
+				return fmt.Errorf("shard %d does not start after shard %d", i, i-1)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown scheme: %q (supported: hash, range)", m.Scheme)
+	}
+	return nil
+}
+
+// stripePages returns the stripe size, applying the default
+func (m *Map) stripePages() uint32 {
+	if m.StripePages == 0 {
+		return DefaultStripePages
+	}
+	return m.StripePages
+}
+
+// ShardFor returns the index of the shard owning a page
+func (m *Map) ShardFor(spaceID uint32, pageNo uint32) int {
+	if len(m.Shards) == 1 {
+		return 0
+	}
+
+	if m.Scheme == SchemeRange {
+		key := Key{spaceID, pageNo}
+		lo, hi := 0, len(m.Shards)-1
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if key.Less(*m.Shards[mid].Start) {
+				hi = mid - 1
+			} else {
+				lo = mid
+			}
+		}
+		return lo
+	}
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[0:4], spaceID)
+	binary.LittleEndian.PutUint32(buf[4:8], pageNo/m.stripePages())
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return int(h.Sum64() % uint64(len(m.Shards)))
+}
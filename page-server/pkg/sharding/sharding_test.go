@@ -0,0 +1,79 @@
+package sharding
+
+import (
+	"testing"
+)
+
+func shards(n int) []Shard {
+	out := make([]Shard, n)
+	for i := range out {
+		out[i] = Shard{URL: "http://shard"}
+	}
+	return out
+}
+
+func TestHashKeepsStripesTogether(t *testing.T) {
+	m := &Map{Scheme: SchemeHash, StripePages: 8, Shards: shards(4)}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	used := make(map[int]bool)
+	for stripe := uint32(0); stripe < 64; stripe++ {
+		first := m.ShardFor(5, stripe*8)
+		for pageNo := stripe * 8; pageNo < (stripe+1)*8; pageNo++ {
+			if got := m.ShardFor(5, pageNo); got != first {
+				t.Fatalf("page %d went to shard %d, the rest of its stripe to %d", pageNo, got, first)
+			}
+		}
+		used[first] = true
+	}
+	if len(used) != 4 {
+		t.Fatalf("64 stripes used %d of 4 shards", len(used))
+	}
+}
+
+func TestRangeLookup(t *testing.T) {
+	m := &Map{Scheme: SchemeRange, Shards: []Shard{
+		{URL: "http://a", Start: &Key{0, 0}},
+		{URL: "http://b", Start: &Key{5, 0}},
+		{URL: "http://c", Start: &Key{5, 1000}},
+	}}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		spaceID, pageNo uint32
+		want            int
+	}{
+		{0, 0, 0},
+		{4, 999999, 0},
+		{5, 0, 1},
+		{5, 999, 1},
+		{5, 1000, 2},
+		{9, 0, 2},
+	}
+	for _, c := range cases {
+		if got := m.ShardFor(c.spaceID, c.pageNo); got != c.want {
+			t.Errorf("space=%d page=%d: shard %d, want %d", c.spaceID, c.pageNo, got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []*Map{
+		{Scheme: SchemeHash},
+		{Scheme: "modulo", Shards: shards(2)},
+		{Scheme: SchemeHash, Shards: []Shard{{URL: ""}}},
+		{Scheme: SchemeHash, Shards: []Shard{{URL: "http://a", Start: &Key{}}}},
+		{Scheme: SchemeRange, Shards: []Shard{{URL: "http://a", Start: &Key{1, 0}}}},
+		{Scheme: SchemeRange, Shards: []Shard{{URL: "http://a", Start: &Key{}}, {URL: "http://b"}}},
+		{Scheme: SchemeRange, Shards: []Shard{{URL: "http://a", Start: &Key{}}, {URL: "http://b", Start: &Key{}}}},
+	}
+	for i, m := range bad {
+		if err := m.Validate(); err == nil {
+			t.Errorf("map %d: expected a validation error", i)
+		}
+	}
+}
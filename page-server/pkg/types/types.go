@@ -3,42 +3,91 @@ package types
 import "time"
 
 // Request/Response structures
+// GetPageRequest names the point to read the page at with one of lsn,
+// request_lsn, latest or timestamp. not_modified_since promises that the page
+// did not change between it and that point, so the server can answer without
+// waiting for newer WAL, from a cached version current at the hint, or (on a
+// replica) at its replay horizon.
 type GetPageRequest struct {
-	SpaceID uint32 `json:"space_id"`
-	PageNo  uint32 `json:"page_no"`
-	LSN     uint64 `json:"lsn"`
+	SpaceID          uint32 `json:"space_id"`
+	PageNo           uint32 `json:"page_no"`
+	LSN              uint64 `json:"lsn"`
+	RequestLSN       uint64 `json:"request_lsn,omitempty"`        // Same as lsn
+	Latest           bool   `json:"latest,omitempty"`             // The newest LSN the server has
+	NotModifiedSince uint64 `json:"not_modified_since,omitempty"` // At most the request LSN
+	Timestamp        string `json:"timestamp,omitempty"`          // RFC 3339; resolved to an LSN instead of lsn
 }
 
 type GetPageResponse struct {
 	Status   string `json:"status"`
 	PageData string `json:"page_data,omitempty"` // Base64 encoded
 	PageLSN  uint64 `json:"page_lsn,omitempty"`
+	ReadLSN  uint64 `json:"read_lsn,omitempty"` // The LSN the page was read at
 	Error    string `json:"error,omitempty"`
 }
 
 type StreamWALRequest struct {
-	LSN     uint64 `json:"lsn"`
-	WALData string `json:"wal_data"` // Base64 encoded
-	SpaceID uint32 `json:"space_id,omitempty"`
-	PageNo  uint32 `json:"page_no,omitempty"`
+	LSN        uint64 `json:"lsn"`
+	PrevLSN    uint64 `json:"prev_lsn,omitempty"` // LSN of the record sent before this one; enables gap detection
+	WALData    string `json:"wal_data"`           // Base64 encoded
+	SpaceID    uint32 `json:"space_id,omitempty"`
+	PageNo     uint32 `json:"page_no,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"` // RFC 3339; defaults to the time the record arrives
 }
 
 type StreamWALResponse struct {
 	Status         string `json:"status"`
 	LastAppliedLSN uint64 `json:"last_applied_lsn,omitempty"`
+	Skipped        bool   `json:"skipped,omitempty"`   // The page belongs to another shard; the record was not stored
+	Duplicate      bool   `json:"duplicate,omitempty"` // The LSN was received before; the record was ignored
+	Buffered       bool   `json:"buffered,omitempty"`  // Waiting for the records before prev_lsn; not applied yet
 	Error          string `json:"error,omitempty"`
 }
 
+// WALStreamAck is one line of the POST /api/v1/stream_wal/frames response
+type WALStreamAck struct {
+	Status     string `json:"status"`               // "ack", then "success" or "error" on the last line
+	AppliedLSN uint64 `json:"applied_lsn"`          // Highest LSN processed on this stream
+	DurableLSN uint64 `json:"durable_lsn"`          // Highest LSN synced to storage along with every record before it
+	Records    int64  `json:"records"`              // Records received on this stream
+	Skipped    int64  `json:"skipped,omitempty"`    // Records left to other shards
+	Duplicates int64  `json:"duplicates,omitempty"` // Records received before and ignored
+	Error      string `json:"error,omitempty"`
+}
+
+// WALGap is an inclusive range of LSNs the page server never received
+type WALGap struct {
+	StartLSN   uint64 `json:"start_lsn"`
+	EndLSN     uint64 `json:"end_lsn"`
+	DetectedAt string `json:"detected_at"` // RFC 3339
+}
+
+// WALGapsResponse is returned by GET /api/v1/wal/gaps. Gaps can be
+// backfilled by streaming their records again (with prev_lsn set).
+type WALGapsResponse struct {
+	Status      string   `json:"status"`
+	AppliedLSN  uint64   `json:"applied_lsn"`       // Every record up to here was applied or is listed in gaps
+	ReceivedLSN uint64   `json:"received_lsn"`      // Highest LSN received
+	Buffered    int      `json:"buffered"`          // Records waiting for the waiting range
+	Waiting     *WALGap  `json:"waiting,omitempty"` // Range still expected before it becomes a gap
+	Gaps        []WALGap `json:"gaps"`
+}
+
 type PingResponse struct {
 	Status  string `json:"status"`
 	Version string `json:"version"`
 }
 
 // Batch request/response structures
+// PageRequest is one page of a get_pages request (see GetPageRequest)
 type PageRequest struct {
-	SpaceID uint32 `json:"space_id"`
-	PageNo  uint32 `json:"page_no"`
-	LSN     uint64 `json:"lsn"`
+	SpaceID          uint32 `json:"space_id"`
+	PageNo           uint32 `json:"page_no"`
+	LSN              uint64 `json:"lsn"`
+	RequestLSN       uint64 `json:"request_lsn,omitempty"`
+	Latest           bool   `json:"latest,omitempty"`
+	NotModifiedSince uint64 `json:"not_modified_since,omitempty"`
+	Timestamp        string `json:"timestamp,omitempty"`
 }
 
 type GetPagesRequest struct {
@@ -51,7 +100,9 @@ type PageResponse struct {
 	Status   string `json:"status"`
 	PageData string `json:"page_data,omitempty"` // Base64 encoded
 	PageLSN  uint64 `json:"page_lsn,omitempty"`
+	ReadLSN  uint64 `json:"read_lsn,omitempty"`
 	Error    string `json:"error,omitempty"`
+	Missing  bool   `json:"missing,omitempty"` // No version at or before the LSN
 }
 
 type GetPagesResponse struct {
@@ -61,14 +112,18 @@ type GetPagesResponse struct {
 
 // Time-travel and snapshot request/response structures
 type TimeTravelRequest struct {
-	SpaceID uint32 `json:"space_id"`
-	PageNo  uint32 `json:"page_no"`
-	LSN     uint64 `json:"lsn"` // Point in time (LSN)
+	SpaceID   uint32 `json:"space_id"`
+	PageNo    uint32 `json:"page_no"`
+	LSN       uint64 `json:"lsn"`                 // Point in time (LSN)
+	Timestamp string `json:"timestamp,omitempty"` // Point in time (RFC 3339), instead of lsn
 }
 
 type CreateSnapshotRequest struct {
-	LSN         uint64 `json:"lsn,omitempty"` // If 0, uses latest LSN
-	Description string `json:"description,omitempty"`
+	LSN         uint64            `json:"lsn,omitempty"`       // If 0, uses latest LSN
+	Timestamp   string            `json:"timestamp,omitempty"` // RFC 3339, instead of lsn
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	TTL         string            `json:"ttl,omitempty"` // e.g. "72h"; without a TTL the retention policy applies
 }
 
 type CreateSnapshotResponse struct {
@@ -78,17 +133,375 @@ type CreateSnapshotResponse struct {
 }
 
 type ListSnapshotsResponse struct {
-	Status    string      `json:"status"`
-	Snapshots []*Snapshot `json:"snapshots"`
+	Status        string      `json:"status"`
+	Snapshots     []*Snapshot `json:"snapshots"`
+	Total         int         `json:"total"`                     // Matching snapshots across all pages
+	NextPageToken string      `json:"next_page_token,omitempty"` // Pass as page_token for the next page
 }
 
 type RestoreSnapshotRequest struct {
 	SnapshotID string `json:"snapshot_id"`
 }
 
+type DeleteSnapshotRequest struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+type LSNForTimestampResponse struct {
+	Status     string     `json:"status"`
+	LSN        uint64     `json:"lsn,omitempty"`
+	Timestamp  string     `json:"timestamp,omitempty"`   // The requested time
+	RecordedAt *time.Time `json:"recorded_at,omitempty"` // When the returned LSN was committed
+	Error      string     `json:"error,omitempty"`
+}
+
 type Snapshot struct {
-	ID          string    `json:"id"`
-	LSN         uint64    `json:"lsn"`
-	Timestamp   time.Time `json:"timestamp"`
-	Description string    `json:"description,omitempty"`
+	ID          string            `json:"id"`
+	LSN         uint64            `json:"lsn"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"` // Set when created with a TTL
+}
+
+// Tablespace catalog structures
+type Space struct {
+	SpaceID    uint32 `json:"space_id"`
+	Name       string `json:"name,omitempty"` // File name from FILE_CREATE/FILE_RENAME records
+	SizePages  uint32 `json:"size_pages"`
+	SizeBytes  int64  `json:"size_bytes"`
+	PageSize   int    `json:"page_size"`
+	Flags      uint32 `json:"flags"`
+	CreatedLSN uint64 `json:"created_lsn,omitempty"` // 0 if created before tracking began
+	DroppedLSN uint64 `json:"dropped_lsn,omitempty"`
+}
+
+type ListSpacesResponse struct {
+	Status string   `json:"status"`
+	LSN    uint64   `json:"lsn"` // Point in time the sizes are for
+	Spaces []*Space `json:"spaces"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type SpaceResponse struct {
+	Status string `json:"status"`
+	LSN    uint64 `json:"lsn"`
+	Space  *Space `json:"space,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Basebackup structures
+type BasebackupManifest struct {
+	LSN       uint64           `json:"lsn"`
+	CreatedAt time.Time        `json:"created_at"`
+	Pages     []BasebackupPage `json:"pages"`
+	Spaces    []*Space         `json:"spaces"` // Tablespace catalog at the LSN
+}
+
+type BasebackupPage struct {
+	SpaceID uint32 `json:"space_id"`
+	PageNo  uint32 `json:"page_no"`
+	Role    string `json:"role"` // system, dict_root, rseg_header or undo_space
+	PageLSN uint64 `json:"page_lsn,omitempty"`
+	Path    string `json:"path,omitempty"` // Tar entry holding the page
+	Size    int    `json:"size,omitempty"`
+	Missing bool   `json:"missing,omitempty"` // Referenced but never written at the LSN
+}
+
+// Page diff structures
+type PageDiffResponse struct {
+	Status        string        `json:"status"`
+	SpaceID       uint32        `json:"space_id"`
+	FromLSN       uint64        `json:"from_lsn"`
+	ToLSN         uint64        `json:"to_lsn"`
+	Pages         []*PageChange `json:"pages"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+type PageChange struct {
+	PageNo       uint32      `json:"page_no"`
+	Versions     []uint64    `json:"versions"`          // Stored LSNs in (from_lsn, to_lsn], ascending
+	Created      bool        `json:"created,omitempty"` // No version at or before from_lsn
+	Before       *FilHeader  `json:"before,omitempty"`  // Only with diff=true
+	After        *FilHeader  `json:"after,omitempty"`
+	ChangedBytes int         `json:"changed_bytes,omitempty"`
+	Diff         []ByteRange `json:"diff,omitempty"`
+}
+
+// FilHeader is the decoded FIL header and trailer of a page
+type FilHeader struct {
+	Checksum uint32 `json:"checksum"`
+	PageNo   uint32 `json:"page_no"`
+	Prev     uint32 `json:"prev"`
+	Next     uint32 `json:"next"`
+	LSN      uint64 `json:"lsn"`
+	Type     uint16 `json:"type"`
+	TypeName string `json:"type_name"`
+	FlushLSN uint64 `json:"flush_lsn,omitempty"`
+	SpaceID  uint32 `json:"space_id"`
+}
+
+type ByteRange struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Field  string `json:"field,omitempty"` // FIL header or trailer field the range starts in
+	Before string `json:"before"`          // Hex
+	After  string `json:"after"`
+}
+
+// Data directory import structures
+type StartImportRequest struct {
+	SourceDir       string `json:"source_dir"`                 // Stopped MariaDB datadir, on the page server host
+	BaseLSN         uint64 `json:"base_lsn,omitempty"`         // If 0, the flushed LSN of ibdata1
+	Parallelism     int    `json:"parallelism,omitempty"`      // Concurrent page writers
+	IgnoreChecksums bool   `json:"ignore_checksums,omitempty"` // Import pages with bad checksums instead of failing
+}
+
+type ImportResponse struct {
+	Status string        `json:"status"`
+	Import *ImportStatus `json:"import,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type ImportStatus struct {
+	State          string     `json:"state"` // running, completed or failed
+	SourceDir      string     `json:"source_dir"`
+	BaseLSN        uint64     `json:"base_lsn"`
+	Resumed        bool       `json:"resumed"` // Continued from a previous interrupted run
+	Files          int        `json:"files"`
+	Spaces         int        `json:"spaces"`
+	PagesTotal     int64      `json:"pages_total"`
+	PagesDone      int64      `json:"pages_done"`      // Includes pages done by an earlier run
+	PagesSkipped   int64      `json:"pages_skipped"`   // Never-written (all zero) pages, not stored
+	ChecksumErrors int64      `json:"checksum_errors"` // Only non-zero with ignore_checksums
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// Tablespace export structures
+type CreateExportRequest struct {
+	SnapshotID  string  `json:"snapshot_id"`
+	SpaceID     *uint32 `json:"space_id,omitempty"`    // If omitted, exports every space as a tar
+	Format      string  `json:"format,omitempty"`      // "ibd" (single space) or "tar"
+	Destination string  `json:"destination,omitempty"` // "local" (default) or "s3"
+}
+
+type ExportResponse struct {
+	Status string     `json:"status"`
+	Export *ExportJob `json:"export,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+type ListExportsResponse struct {
+	Status  string       `json:"status"`
+	Exports []*ExportJob `json:"exports"`
+}
+
+type ExportJob struct {
+	ID           string     `json:"id"`
+	SnapshotID   string     `json:"snapshot_id"`
+	LSN          uint64     `json:"lsn"`
+	SpaceIDs     []uint32   `json:"space_ids,omitempty"`
+	Format       string     `json:"format"`
+	Destination  string     `json:"destination"`
+	Location     string     `json:"location,omitempty"` // Local path or S3 key of the output
+	State        string     `json:"state"`              // pending, running, completed or failed
+	PagesTotal   int64      `json:"pages_total"`
+	PagesDone    int64      `json:"pages_done"`
+	MissingPages int64      `json:"missing_pages"` // Pages never written, exported as zeros
+	BytesWritten int64      `json:"bytes_written"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// Replication structures
+type ReplicationStatusResponse struct {
+	Status         string     `json:"status"`
+	Role           string     `json:"role"`                       // primary, replica or standalone
+	ReplicatedLSN  uint64     `json:"replicated_lsn"`             // Replicas: newest readable LSN; primaries: newest published LSN
+	PrimaryLSN     uint64     `json:"primary_lsn"`                // Latest LSN received by the primary
+	LagLSN         uint64     `json:"lag_lsn"`                    // primary_lsn - replicated_lsn
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`     // When the primary last published
+	HeartbeatAgeMs int64      `json:"heartbeat_age_ms,omitempty"` // Grows while the primary is down
+	Error          string     `json:"error,omitempty"`            // Last publish or poll error
+}
+
+// Debug structures
+// DecodeWALRequest is the body of POST /api/v1/debug/decode_wal: raw WAL
+// bytes, or a range of stored WAL records when wal_data is empty
+type DecodeWALRequest struct {
+	WALData      string `json:"wal_data,omitempty"`      // Base64 encoded
+	FromLSN      uint64 `json:"from_lsn,omitempty"`      // First stored LSN to decode
+	ToLSN        uint64 `json:"to_lsn,omitempty"`        // Last stored LSN to decode (default: the latest LSN)
+	Limit        int    `json:"limit,omitempty"`         // Stored records to decode (default and maximum: max-batch-pages)
+	PreviewBytes int    `json:"preview_bytes,omitempty"` // Data bytes shown per redo record (default 16)
+}
+
+type DecodeWALResponse struct {
+	Status  string       `json:"status"`
+	Records []DecodedWAL `json:"records"`
+	NextLSN uint64       `json:"next_lsn,omitempty"` // First stored LSN in the range not decoded because of the limit
+	Error   string       `json:"error,omitempty"`
+}
+
+// DecodedWAL is a WAL record split into redo records the way the WAL
+// applier parses it, continuing past records that fail to parse
+type DecodedWAL struct {
+	LSN         uint64       `json:"lsn,omitempty"` // Stored records only
+	Bytes       int          `json:"bytes"`
+	RedoRecords []RedoRecord `json:"redo_records"`
+	Applied     int          `json:"applied"`                // Redo records the applier uses: those before the first parse error
+	ErrorOffset *int         `json:"error_offset,omitempty"` // Byte offset of the first redo record that failed to parse
+	Error       string       `json:"error,omitempty"`        // Why a stored record could not be read
+}
+
+// RedoRecord is one decoded redo log record
+type RedoRecord struct {
+	Offset       int    `json:"offset"`           // Byte offset in the WAL record
+	Length       int    `json:"length,omitempty"` // Bytes by its length field, header included; 0 if unreadable
+	Type         string `json:"type"`
+	TypeCode     uint8  `json:"type_code"`
+	SamePage     bool   `json:"same_page,omitempty"`
+	SpaceID      uint32 `json:"space_id"`
+	PageNo       uint32 `json:"page_no"`
+	PageOffset   uint32 `json:"page_offset,omitempty"`
+	DataLen      int    `json:"data_len,omitempty"`      // Bytes written, filled or moved
+	DataPreview  string `json:"data_preview,omitempty"`  // Hex of the first preview_bytes of the data
+	SourceOffset int32  `json:"source_offset,omitempty"` // MEMMOVE: relative source offset
+	Subtype      uint8  `json:"subtype,omitempty"`       // EXTENDED
+	FileName     string `json:"file_name,omitempty"`
+	NewName      string `json:"new_name,omitempty"`
+	Warning      string `json:"warning,omitempty"` // Parsed, but not to the end its length field gives
+	Error        string `json:"error,omitempty"`
+	Skipped      bool   `json:"skipped,omitempty"` // After a parse error, so the applier never sees it
+}
+
+// DecodePageRequest is the body of POST /api/v1/debug/page: a stored page
+// read like get_page, or raw page bytes when page_data is set
+type DecodePageRequest struct {
+	SpaceID    uint32 `json:"space_id"`
+	PageNo     uint32 `json:"page_no"`
+	LSN        uint64 `json:"lsn"`
+	RequestLSN uint64 `json:"request_lsn,omitempty"`
+	Latest     bool   `json:"latest,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"`
+	PageData   string `json:"page_data,omitempty"` // Base64 encoded
+}
+
+type DecodePageResponse struct {
+	Status  string       `json:"status"`
+	PageLSN uint64       `json:"page_lsn,omitempty"`
+	ReadLSN uint64       `json:"read_lsn,omitempty"`
+	Page    *DecodedPage `json:"page,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// DecodedPage is an InnoDB page with the structures its type carries decoded
+type DecodedPage struct {
+	Bytes      int          `json:"bytes"`
+	ZeroPage   bool         `json:"zero_page,omitempty"` // Never written; nothing else is decoded
+	FilHeader  *FilHeader   `json:"fil_header,omitempty"`
+	FilTrailer *FilTrailer  `json:"fil_trailer,omitempty"`
+	Index      *IndexPage   `json:"index,omitempty"`
+	FSP        *FSPInfo     `json:"fsp,omitempty"`  // Page 0
+	XDES       []XDESExtent `json:"xdes,omitempty"` // Page 0 and XDES pages: initialized extents
+	Undo       *UndoPage    `json:"undo,omitempty"`
+	Errors     []string     `json:"errors,omitempty"` // Structures that could not be decoded
+}
+
+type FilTrailer struct {
+	Checksum   uint32 `json:"checksum"`
+	LSNLow32   uint32 `json:"lsn_low32"`
+	LSNMatches bool   `json:"lsn_matches"` // Low 32 bits of the header LSN; not written by the full_crc32 format
+}
+
+// IndexPage is the page header, directory and records of an INDEX or RTREE page
+type IndexPage struct {
+	IndexID     uint64          `json:"index_id"`
+	Level       uint16          `json:"level"`
+	NRecs       uint16          `json:"n_recs"`
+	NHeap       uint16          `json:"n_heap"`
+	Compact     bool            `json:"compact"`
+	NDirSlots   uint16          `json:"n_dir_slots"`
+	HeapTop     uint16          `json:"heap_top"`
+	Free        uint16          `json:"free"`
+	Garbage     uint16          `json:"garbage"`
+	LastInsert  uint16          `json:"last_insert"`
+	Direction   uint16          `json:"direction"`
+	NDirection  uint16          `json:"n_direction"`
+	MaxTrxID    uint64          `json:"max_trx_id"`
+	Directory   []uint16        `json:"directory"`              // Record offsets of the slots, infimum first
+	Records     []RecordSummary `json:"records"`                // Infimum to supremum in key order
+	FreeRecords []RecordSummary `json:"free_records,omitempty"` // Deleted records available for reuse
+}
+
+type RecordSummary struct {
+	Offset  uint16 `json:"offset"`
+	HeapNo  uint16 `json:"heap_no"`
+	Status  string `json:"status"` // ordinary, node_ptr, infimum or supremum
+	Deleted bool   `json:"deleted,omitempty"`
+	MinRec  bool   `json:"min_rec,omitempty"`
+	NOwned  uint8  `json:"n_owned,omitempty"`
+	Next    uint16 `json:"next"`
+	NFields uint16 `json:"n_fields,omitempty"` // Redundant format only
+}
+
+type FSPInfo struct {
+	SpaceID       uint32   `json:"space_id"`
+	Size          uint32   `json:"size"`
+	FreeLimit     uint32   `json:"free_limit"`
+	Flags         uint32   `json:"flags"`
+	PageSize      int      `json:"page_size"`
+	FullCRC32     bool     `json:"full_crc32"`
+	FragNUsed     uint32   `json:"frag_n_used"`
+	NextSegID     uint64   `json:"next_seg_id"`
+	Free          ListBase `json:"free"`
+	FreeFrag      ListBase `json:"free_frag"`
+	FullFrag      ListBase `json:"full_frag"`
+	SegInodesFull ListBase `json:"seg_inodes_full"`
+	SegInodesFree ListBase `json:"seg_inodes_free"`
+}
+
+// ListBase is a file list length and its first and last nodes as page:offset
+type ListBase struct {
+	Len   uint32 `json:"len"`
+	First string `json:"first"` // FIL_NULL for none
+	Last  string `json:"last"`
+}
+
+type XDESExtent struct {
+	FirstPage uint32 `json:"first_page"`
+	State     string `json:"state"`
+	SegID     uint64 `json:"seg_id,omitempty"`
+	FreePages int    `json:"free_pages"`
+}
+
+type UndoPage struct {
+	Type    uint16       `json:"type"`
+	Start   uint16       `json:"start"`
+	Free    uint16       `json:"free"`
+	Segment *UndoSegment `json:"segment,omitempty"` // First page of an undo segment only
+}
+
+type UndoSegment struct {
+	State     string    `json:"state"`
+	LastLog   uint16    `json:"last_log"`
+	PageCount uint32    `json:"page_count"`
+	Logs      []UndoLog `json:"logs,omitempty"` // Newest first, following prev_log
+}
+
+type UndoLog struct {
+	Offset    uint16 `json:"offset"`
+	TrxID     uint64 `json:"trx_id"`
+	TrxNo     uint64 `json:"trx_no,omitempty"`
+	DelMarks  bool   `json:"del_marks,omitempty"`
+	LogStart  uint16 `json:"log_start"`
+	XIDExists bool   `json:"xid_exists,omitempty"`
+	DictTrans bool   `json:"dict_trans,omitempty"`
+	TableID   uint64 `json:"table_id,omitempty"`
+	NextLog   uint16 `json:"next_log,omitempty"`
+	PrevLog   uint16 `json:"prev_log,omitempty"`
 }
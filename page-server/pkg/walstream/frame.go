@@ -0,0 +1,139 @@
+// Package walstream encodes WAL records for POST /api/v1/stream_wal/frames.
+//
+// The request body is a sequence of frames. Integers are little-endian:
+//
+//	u32 length       bytes after this field: 32 + len(wal_data), or 0 for a sync frame
+//	u64 lsn
+//	u64 prev_lsn     LSN of the record sent before this one; 0 if unknown
+//	u32 space_id     0 together with page_no 0 for a record without a page
+//	u32 page_no
+//	i64 commit_time  Unix nanoseconds; 0 for the time the server receives it
+//	    wal_data
+//
+// A sync frame asks the server to make every record before it durable and
+// acknowledge it right away. The response is a stream of newline-delimited
+// JSON acknowledgements (types.WALStreamAck).
+package walstream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// HeaderSize is the size of a frame after the length field, without WAL data
+	HeaderSize = 32
+	// MaxFrameSize bounds the length field of a frame
+	MaxFrameSize = 16 << 20
+)
+
+// ErrFrameTooLarge is returned for a frame longer than MaxFrameSize
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// Frame is one WAL record, or a sync request
+type Frame struct {
+	Sync       bool // A sync frame; the other fields are unset
+	LSN        uint64
+	PrevLSN    uint64 // Zero if unknown
+	SpaceID    uint32
+	PageNo     uint32
+	CommitTime time.Time // Zero for the time of receipt
+	WALData    []byte
+}
+
+// Reader decodes frames
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader returns a Reader decoding frames from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// Next returns the next frame. It returns io.EOF at the end of the stream
+// and io.ErrUnexpectedEOF for a stream that ends inside a frame. WALData
+// is only valid until the next call.
+func (r *Reader) Next() (Frame, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		return Frame{}, err
+	}
+	n := binary.LittleEndian.Uint32(length[:])
+	switch {
+	case n == 0:
+		return Frame{Sync: true}, nil
+	case n < HeaderSize:
+		return Frame{}, fmt.Errorf("frame length %d is below the %d byte header", n, HeaderSize)
+	case n > MaxFrameSize:
+		return Frame{}, fmt.Errorf("%w: %d bytes (max %d)", ErrFrameTooLarge, n, MaxFrameSize)
+	}
+
+	if cap(r.buf) < int(n) {
+		r.buf = make([]byte, n)
+	}
+	buf := r.buf[:n]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+
+	f := Frame{
+		LSN:     binary.LittleEndian.Uint64(buf[0:8]),
+		PrevLSN: binary.LittleEndian.Uint64(buf[8:16]),
+		SpaceID: binary.LittleEndian.Uint32(buf[16:20]),
+		PageNo:  binary.LittleEndian.Uint32(buf[20:24]),
+		WALData: buf[HeaderSize:],
+	}
+	if nanos := int64(binary.LittleEndian.Uint64(buf[24:32])); nanos != 0 {
+		f.CommitTime = time.Unix(0, nanos)
+	}
+	return f, nil
+}
+
+// Writer encodes frames
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer encoding frames to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write encodes a frame
+func (w *Writer) Write(f Frame) error {
+	if f.Sync {
+		return w.Sync()
+	}
+	n := HeaderSize + len(f.WALData)
+	if n > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrFrameTooLarge, n, MaxFrameSize)
+	}
+
+	buf := make([]byte, 4+n)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(n))
+	binary.LittleEndian.PutUint64(buf[4:12], f.LSN)
+	binary.LittleEndian.PutUint64(buf[12:20], f.PrevLSN)
+	binary.LittleEndian.PutUint32(buf[20:24], f.SpaceID)
+	binary.LittleEndian.PutUint32(buf[24:28], f.PageNo)
+	if !f.CommitTime.IsZero() {
+		binary.LittleEndian.PutUint64(buf[28:36], uint64(f.CommitTime.UnixNano()))
+	}
+	copy(buf[36:], f.WALData)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Sync writes a sync frame
+func (w *Writer) Sync() error {
+	_, err := w.w.Write(make([]byte, 4))
+	return err
+}
@@ -0,0 +1,61 @@
+package walstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	commit := time.Unix(1760000000, 123456789)
+	frames := []Frame{
+		{LSN: 100, PrevLSN: 90, SpaceID: 5, PageNo: 3, CommitTime: commit, WALData: []byte("redo")},
+		{Sync: true},
+		{LSN: 200, WALData: []byte{}},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range frames {
+		if err := w.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if got.Sync != want.Sync || got.LSN != want.LSN || got.PrevLSN != want.PrevLSN || got.SpaceID != want.SpaceID ||
+			got.PageNo != want.PageNo || !got.CommitTime.Equal(want.CommitTime) ||
+			!bytes.Equal(got.WALData, want.WALData) {
+			t.Fatalf("frame %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("after the last frame: %v, want io.EOF", err)
+	}
+}
+
+func TestTruncatedAndInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	NewWriter(&buf).Write(Frame{LSN: 1, WALData: []byte("redo record")})
+	truncated := buf.Bytes()[:buf.Len()-3]
+	if _, err := NewReader(bytes.NewReader(truncated)).Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated frame: %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	short := []byte{10, 0, 0, 0}
+	if _, err := NewReader(bytes.NewReader(short)).Next(); err == nil {
+		t.Fatal("frame shorter than its header: expected an error")
+	}
+
+	huge := []byte{0xff, 0xff, 0xff, 0xff}
+	if _, err := NewReader(bytes.NewReader(huge)).Next(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("oversized frame: %v, want ErrFrameTooLarge", err)
+	}
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/linux/projects/server/safekeeper/internal/auth"
+	"github.com/linux/projects/server/common/auth"
+	"github.com/linux/projects/server/common/tlsutil"
 	"github.com/linux/projects/server/safekeeper/internal/safekeeper"
-	"github.com/linux/projects/server/safekeeper/internal/server"
 )
 
 var (
@@ -21,14 +26,27 @@ var (
 	peers     = flag.String("peers", "", "Comma-separated list of peer Safekeeper endpoints (e.g., http://localhost:8091,http://localhost:8092)")
 
 	// Authentication flags
-	apiKey     = flag.String("api-key", "", "API key for authentication (optional)")
-	authTokens = flag.String("auth-tokens", "", "Comma-separated list of auth tokens")
+	apiKey      = flag.String("api-key", "", "API key for authentication (optional)")
+	authTokens  = flag.String("auth-tokens", "", "Comma-separated list of auth tokens")
+	jwtJWKS     = flag.String("jwt-jwks", "", "Path to JWKS file with EdDSA/RS256 public keys for JWT authentication (optional)")
+	jwtIssuer   = flag.String("jwt-issuer", "", "Required iss claim of JWTs (optional)")
+	jwtAudience = flag.String("jwt-audience", "", "Audience JWTs must name in their aud claim (optional)")
+	tenantID    = flag.String("tenant-id", "", "Tenant served by this instance; JWTs must name it unless they have the admin scope")
+	timelineID  = flag.String("timeline-id", "", "Timeline served by this instance; JWTs scoped to another timeline are rejected")
 
 	// TLS flags
 	tlsEnabled  = flag.Bool("tls", false, "Enable TLS/HTTPS")
 	tlsCertFile = flag.String("tls-cert", "", "Path to TLS certificate file")
 	tlsKeyFile  = flag.String("tls-key", "", "Path to TLS private key file")
 
+	// mTLS flags
+	tlsClientCA          = flag.String("tls-client-ca", "", "CA bundle for verifying client certificates (enables mTLS)")
+	tlsRequireClientCert = flag.Bool("tls-require-client-cert", false, "Reject TLS clients without a valid client certificate")
+	clientCertRoles      = flag.String("client-cert-roles", "", "Roles for client certificate SANs: pattern=role,... (roles: compute, peer, admin)")
+	peerTLSCert          = flag.String("peer-tls-cert", "", "Client certificate presented to peer Safekeepers (mTLS)")
+	peerTLSKey           = flag.String("peer-tls-key", "", "Private key for -peer-tls-cert")
+	peerTLSCA            = flag.String("peer-tls-ca", "", "CA bundle for verifying peer Safekeeper certificates (default: system roots)")
+
 	// Compression flag (Zstd - matching Neon)
 	enableCompression = flag.Bool("compression", true, "Enable Zstd compression for WAL (matching Neon's 70% reduction)")
 
@@ -36,13 +54,16 @@ var (
 	enableProtobuf = flag.Bool("protobuf", false, "Enable Protobuf encoding for WAL records (20-30% performance improvement)")
 
 	// S3 Backup flags
-	s3Endpoint  = flag.String("s3-endpoint", "", "S3 endpoint for WAL backup (e.g., https://s3.amazonaws.com)")
+	s3Endpoint  = flag.String("s3-endpoint", "", "S3 endpoint for WAL backup (e.g., https://s3.amazonaws.com, or file:///dir for a local directory)")
 	s3Bucket    = flag.String("s3-bucket", "", "S3 bucket for WAL backup")
 	s3Region    = flag.String("s3-region", "us-east-1", "AWS region for S3 backup")
 	s3AccessKey = flag.String("s3-access-key", "", "S3 access key ID")
 	s3SecretKey = flag.String("s3-secret-key", "", "S3 secret access key")
 	s3Prefix    = flag.String("s3-prefix", "", "Optional prefix for S3 objects")
 	s3UseSSL    = flag.Bool("s3-use-ssl", true, "Use SSL/TLS for S3 connections")
+
+	// Shutdown flags
+	shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "Time allowed for draining requests, replication and S3 backups on shutdown")
 )
 
 func main() {
@@ -99,6 +120,18 @@ func main() {
 		log.Printf("WAL compression enabled (Zstd - matching Neon)")
 	}
 
+	// Peer calls over TLS, presenting a client certificate for mTLS
+	var peerCertReloader *tlsutil.CertReloader
+	if *peerTLSCert != "" || *peerTLSCA != "" {
+		peerTLS, reloader, err := tlsutil.ClientTLSConfig(*peerTLSCert, *peerTLSKey, *peerTLSCA)
+		if err != nil {
+			log.Fatalf("Failed to configure peer TLS: %v", err)
+		}
+		sk.SetPeerTLSConfig(peerTLS)
+		peerCertReloader = reloader
+		log.Printf("Peer TLS enabled (client certificate: %s)", *peerTLSCert)
+	}
+
 	// Create consensus manager
 	consensus := safekeeper.NewConsensus(sk)
 	consensus.Start()
@@ -106,10 +139,32 @@ func main() {
 	// Create API handler
 	apiHandler := safekeeper.NewAPIHandler(sk, consensus)
 
-	// Setup authentication middleware
-	var authMiddleware *auth.AuthMiddleware
-	if *apiKey != "" || *authTokens != "" {
-		authMiddleware = auth.NewAuthMiddleware(*apiKey, *authTokens)
+	// Setup authentication middleware (a pass-through unless credentials,
+	// JWT keys or stored tokens are configured)
+	authMiddleware := auth.NewAuthMiddleware(*apiKey, *authTokens)
+	if *jwtJWKS != "" {
+		verifier, err := auth.NewJWTVerifier(*jwtJWKS, *jwtIssuer, *jwtAudience)
+		if err != nil {
+			log.Fatalf("Failed to load JWT keys: %v", err)
+		}
+		authMiddleware.SetJWTVerifier(verifier)
+		log.Printf("JWT authentication enabled (keys: %s)", *jwtJWKS)
+	}
+	authMiddleware.SetServedTenant(*tenantID, *timelineID)
+
+	certRoles, err := auth.ParseCertRoles(*clientCertRoles)
+	if err != nil {
+		log.Fatalf("Invalid -client-cert-roles: %v", err)
+	}
+	authMiddleware.SetCertRoles(certRoles)
+
+	tokenStore, err := auth.NewTokenStore(absDataDir)
+	if err != nil {
+		log.Fatalf("Failed to open token store: %v", err)
+	}
+	authMiddleware.SetTokenStore(tokenStore)
+
+	if authMiddleware.IsEnabled() {
 		log.Printf("Authentication enabled")
 	}
 
@@ -121,6 +176,7 @@ func main() {
 	mux.HandleFunc("/api/v1/metrics", apiHandler.HandleMetrics)
 	mux.HandleFunc("/api/v1/get_wal", apiHandler.HandleGetWAL)
 	mux.HandleFunc("/api/v1/get_latest_lsn", apiHandler.HandleGetLatestLSN)
+	mux.HandleFunc("/api/v1/lsn_for_timestamp", apiHandler.HandleLSNForTimestamp)
 	mux.HandleFunc("/api/v1/timelines", apiHandler.HandleListTimelines)
 	mux.HandleFunc("/api/v1/timelines/create", apiHandler.HandleCreateTimeline)
 	mux.HandleFunc("/api/v1/timelines/", apiHandler.HandleGetTimeline) // Must be before /api/v1/timelines
@@ -131,16 +187,24 @@ func main() {
 	mux.HandleFunc("/api/v1/get_wal_range", apiHandler.HandleGetWALRange)
 
 	// Protected endpoints (WAL streaming)
-	streamWALHandler := http.HandlerFunc(apiHandler.HandleStreamWAL)
-	if authMiddleware != nil {
-		streamWALHandler = authMiddleware.Middleware(apiHandler.HandleStreamWAL)
-	}
-	mux.HandleFunc("/api/v1/stream_wal", streamWALHandler)
+	mux.HandleFunc("/api/v1/stream_wal", authMiddleware.Middleware(authMiddleware.Require(auth.ScopeWriteWAL, apiHandler.HandleStreamWAL)))
 
-	// Internal endpoints (replication, consensus)
-	mux.HandleFunc("/api/v1/replicate_wal", apiHandler.HandleReplicateWAL)
-	mux.HandleFunc("/api/v1/request_vote", apiHandler.HandleRequestVote)
-	mux.HandleFunc("/api/v1/heartbeat", apiHandler.HandleHeartbeat)
+	// Admin endpoints (token management)
+	mux.HandleFunc("/api/v1/admin/tokens", authMiddleware.Middleware(authMiddleware.Require(auth.ScopeAdmin, authMiddleware.HandleTokens)))
+	mux.HandleFunc("/api/v1/admin/tokens/revoke", authMiddleware.Middleware(authMiddleware.Require(auth.ScopeAdmin, authMiddleware.HandleRevokeToken)))
+	mux.HandleFunc("/api/v1/admin/tokens/expire", authMiddleware.Middleware(authMiddleware.Require(auth.ScopeAdmin, authMiddleware.HandleExpireToken)))
+
+	// Internal endpoints (replication, consensus). With mTLS configured,
+	// peers must present a certificate mapped to the peer role.
+	internal := func(h http.HandlerFunc) http.HandlerFunc {
+		if *tlsClientCA == "" {
+			return h
+		}
+		return authMiddleware.Middleware(authMiddleware.Require(auth.ScopeReplicate, h))
+	}
+	mux.HandleFunc("/api/v1/replicate_wal", internal(apiHandler.HandleReplicateWAL))
+	mux.HandleFunc("/api/v1/request_vote", internal(apiHandler.HandleRequestVote))
+	mux.HandleFunc("/api/v1/heartbeat", internal(apiHandler.HandleHeartbeat))
 
 	var handler http.Handler = mux
 
@@ -151,19 +215,86 @@ func main() {
 	}
 
 	// Configure TLS if enabled
-	if err := server.ConfigureTLS(httpServer, *tlsEnabled, *tlsCertFile, *tlsKeyFile); err != nil {
+	certReloader, err := tlsutil.ConfigureTLS(httpServer, tlsutil.TLSOptions{
+		Enabled:           *tlsEnabled,
+		CertFile:          *tlsCertFile,
+		KeyFile:           *tlsKeyFile,
+		ClientCAFile:      *tlsClientCA,
+		RequireClientCert: *tlsRequireClientCert,
+	})
+	if err != nil {
 		log.Fatalf("Failed to configure TLS: %v", err)
 	}
 
-	if *tlsEnabled {
-		log.Printf("Starting Safekeeper with TLS on port %d", *port)
-		if err := httpServer.ListenAndServeTLS("", ""); err != nil {
-			log.Fatalf("Failed to start Safekeeper: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		if *tlsEnabled {
+			log.Printf("Starting Safekeeper with TLS on port %d", *port)
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting Safekeeper on port %d", *port)
+			serveErr <- httpServer.ListenAndServe()
 		}
-	} else {
-		log.Printf("Starting Safekeeper on port %d", *port)
-		if err := httpServer.ListenAndServe(); err != nil {
-			log.Fatalf("Failed to start Safekeeper: %v", err)
+	}()
+
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	// SIGHUP re-reads the JWKS file and TLS certificates (rotation)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+wait:
+	for {
+		select {
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to start Safekeeper: %v", err)
+			}
+			break wait
+		case <-hupChan:
+			if authMiddleware.JWTVerifier() != nil {
+				if err := authMiddleware.JWTVerifier().Reload(); err != nil {
+					log.Printf("Warning: failed to reload JWT keys: %v", err)
+				} else {
+					log.Printf("Reloaded JWT keys from %s", *jwtJWKS)
+				}
+			}
+			for _, reloader := range []*tlsutil.CertReloader{certReloader, peerCertReloader} {
+				if reloader != nil {
+					if err := reloader.Reload(); err != nil {
+						log.Printf("Warning: TLS certificate reload failed: %v", err)
+					}
+				}
+			}
+		case sig := <-sigChan:
+			log.Printf("Received %s, shutting down (timeout %s)...", sig, *shutdownTimeout)
+			break wait
 		}
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	// Stop elections and heartbeats so we do not take leadership while draining
+	consensus.Stop()
+
+	// Stop accepting connections and wait for in-flight handlers
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Warning: HTTP server did not drain cleanly: %v", err)
+	}
+
+	// Persist token last-used times
+	if err := tokenStore.Flush(); err != nil {
+		log.Printf("Warning: failed to save token store: %v", err)
+	}
+
+	// Wait for replication and S3 backup goroutines
+	report := sk.Shutdown(ctx)
+	if !report.Clean() {
+		log.Printf("Safekeeper stopped with unflushed work: %s", report)
+		os.Exit(1)
+	}
+	log.Printf("Safekeeper stopped: %s", report)
 }
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // APIHandler handles HTTP API requests for Safekeeper
@@ -148,6 +149,40 @@ func (h *APIHandler) HandleGetWAL(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// HandleLSNForTimestamp returns the largest LSN stored at or before ?timestamp=
+func (h *APIHandler) HandleLSNForTimestamp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	timestamp := r.URL.Query().Get("timestamp")
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		http.Error(w, "Missing or invalid timestamp (want RFC 3339)", http.StatusBadRequest)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	entry, err := h.safekeeper.LSNForTimestamp(t)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "error",
+			"timestamp": timestamp,
+			"error":     err.Error(),
+		})
+		return
+	}
+	
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "success",
+		"lsn":         entry.LSN,
+		"timestamp":   timestamp,
+		"recorded_at": entry.Time,
+	})
+}
+
 // HandleGetLatestLSN handles latest LSN retrieval
 func (h *APIHandler) HandleGetLatestLSN(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -238,6 +273,10 @@ func (h *APIHandler) HandleReplicateWAL(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
+	if err := h.safekeeper.lsnIndex.Record(time.Now(), req.LSN); err != nil {
+		log.Printf("Warning: Failed to index replicated LSN %d: %v", req.LSN, err)
+	}
+	
 	resp := StreamWALResponse{
 		Status:         "success",
 		LastAppliedLSN: req.LSN,
@@ -343,6 +382,7 @@ func (h *APIHandler) HandleCreateTimeline(w http.ResponseWriter, r *http.Request
 		TimelineID      string `json:"timeline_id"`
 		ParentLSN       uint64 `json:"parent_lsn,omitempty"`
 		ParentTimelineID string `json:"parent_timeline_id,omitempty"`
+		ParentTimestamp string `json:"parent_timestamp,omitempty"` // RFC 3339, instead of parent_lsn
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -355,6 +395,25 @@ func (h *APIHandler) HandleCreateTimeline(w http.ResponseWriter, r *http.Request
 		return
 	}
 	
+	// Branch at the last LSN committed at or before parent_timestamp
+	if req.ParentTimestamp != "" {
+		if req.ParentLSN != 0 {
+			http.Error(w, "parent_lsn and parent_timestamp are mutually exclusive", http.StatusBadRequest)
+			return
+		}
+		t, err := time.Parse(time.RFC3339Nano, req.ParentTimestamp)
+		if err != nil {
+			http.Error(w, "Invalid parent_timestamp (want RFC 3339)", http.StatusBadRequest)
+			return
+		}
+		entry, err := h.safekeeper.LSNForTimestamp(t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		req.ParentLSN = entry.LSN
+	}
+	
 	timeline, err := h.safekeeper.timelineManager.CreateTimeline(
 		req.TimelineID,
 		req.ParentLSN,
@@ -18,6 +18,10 @@ type Consensus struct {
 	// Voting
 	votesReceived map[uint64]int // term -> vote count
 	votesMu       sync.Mutex
+	
+	// Shutdown
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 // NewConsensus creates a new consensus manager
@@ -27,6 +31,7 @@ func NewConsensus(sk *Safekeeper) *Consensus {
 		electionTimeout: 5 * time.Second,
 		lastHeartbeat:   time.Now(),
 		votesReceived:   make(map[uint64]int),
+		stopCh:          make(chan struct{}),
 	}
 }
 
@@ -131,7 +136,13 @@ func (c *Consensus) sendHeartbeats() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 	
-	for range ticker.C {
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+		
 		c.safekeeper.stateMu.RLock()
 		isLeader := c.safekeeper.state == StateLeader
 		c.safekeeper.stateMu.RUnlock()
@@ -201,7 +212,13 @@ func (c *Consensus) Start() {
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 		
-		for range ticker.C {
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+			}
+			
 			c.safekeeper.stateMu.RLock()
 			state := c.safekeeper.state
 			c.safekeeper.stateMu.RUnlock()
@@ -216,3 +233,10 @@ func (c *Consensus) Start() {
 	}()
 }
 
+
+// Stop stops the election timeout checker and heartbeat sender
+func (c *Consensus) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
@@ -2,6 +2,7 @@ package safekeeper
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -26,6 +27,15 @@ func NewPeerClient() *PeerClient {
 	}
 }
 
+// SetTLSConfig makes the client use TLS (with a client certificate for mTLS)
+// for https:// peers. Must be called before the client is used.
+func (pc *PeerClient) SetTLSConfig(cfg *tls.Config) {
+	pc.client = &http.Client{
+		Timeout:   pc.timeout,
+		Transport: &http.Transport{TLSClientConfig: cfg},
+	}
+}
+
 // ReplicateWALRequest represents a WAL replication request
 type ReplicateWALRequest struct {
 	LSN     uint64 `json:"lsn"`
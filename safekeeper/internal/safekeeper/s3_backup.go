@@ -4,25 +4,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"log"
-	"path/filepath"
 	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/linux/projects/server/objectstore"
 )
 
-// S3Backup handles WAL backup to S3-compatible storage
+// S3Backup handles WAL backup to S3-compatible storage, or to a local
+// directory for file:// endpoints
 type S3Backup struct {
-	client   *s3.Client
-	bucket   string
-	prefix   string
-	enabled  bool
-	ctx      context.Context
-	mu       sync.Mutex
+	store   objectstore.ObjectStore
+	bucket  string
+	enabled bool
+	ctx     context.Context
+	mu      sync.Mutex
 }
 
 // NewS3Backup creates a new S3 backup handler
@@ -31,35 +26,29 @@ func NewS3Backup(cfg S3Config) (*S3Backup, error) {
 		return &S3Backup{enabled: false}, nil
 	}
 
-	ctx := context.Background()
-
-	// Configure AWS SDK
-	awsCfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(cfg.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			cfg.AccessKey,
-			cfg.SecretKey,
-			"",
-		)),
-	)
+	store, err := objectstore.Open(context.Background(), objectstore.S3Config{
+		Endpoint:  cfg.Endpoint,
+		Bucket:    cfg.Bucket,
+		Region:    cfg.Region,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, fmt.Errorf("failed to open object store: %w", err)
 	}
 
-	// Create S3 client
-	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		if cfg.Endpoint != "" {
-			o.BaseEndpoint = aws.String(cfg.Endpoint)
-		}
-	})
+	backup := NewObjectBackup(objectstore.WithPrefix(store, cfg.Prefix))
+	backup.bucket = cfg.Bucket
+	return backup, nil
+}
 
+// NewObjectBackup creates a backup handler that writes WAL to any object store
+func NewObjectBackup(store objectstore.ObjectStore) *S3Backup {
 	return &S3Backup{
-		client:  client,
-		bucket:  cfg.Bucket,
-		prefix:  cfg.Prefix,
+		store:   store,
 		enabled: true,
-		ctx:     ctx,
-	}, nil
+		ctx:     context.Background(),
+	}
 }
 
 // BackupWAL backs up a WAL record to S3
@@ -71,19 +60,8 @@ func (s *S3Backup) BackupWAL(lsn uint64, walData []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	key := s.walObjectKey(lsn)
-
-	_, err := s.client.PutObject(s.ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(walData),
-		ContentType: aws.String("application/octet-stream"),
-		Metadata: map[string]string{
-			"lsn": fmt.Sprintf("%d", lsn),
-		},
-	})
-
-	if err != nil {
+	key := walObjectKey(lsn)
+	if err := s.store.Put(s.ctx, key, bytes.NewReader(walData)); err != nil {
 		return fmt.Errorf("failed to backup WAL to S3: %w", err)
 	}
 
@@ -97,31 +75,18 @@ func (s *S3Backup) RestoreWAL(lsn uint64) ([]byte, error) {
 		return nil, fmt.Errorf("S3 backup not enabled")
 	}
 
-	key := s.walObjectKey(lsn)
-
-	result, err := s.client.GetObject(s.ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	})
+	key := walObjectKey(lsn)
+	walData, err := s.store.Get(s.ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to restore WAL from S3: %w", err)
 	}
-	defer result.Body.Close()
-
-	walData, err := io.ReadAll(result.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read WAL data from S3: %w", err)
-	}
 
 	log.Printf("WAL LSN %d restored from S3: %s/%s", lsn, s.bucket, key)
 	return walData, nil
 }
 
-// walObjectKey generates S3 object key for a WAL record
-func (s *S3Backup) walObjectKey(lsn uint64) string {
-	if s.prefix != "" {
-		return filepath.Join(s.prefix, fmt.Sprintf("wal_%d", lsn))
-	}
+// walObjectKey generates the object key for a WAL record
+func walObjectKey(lsn uint64) string {
 	return fmt.Sprintf("wal_%d", lsn)
 }
 
@@ -129,4 +94,3 @@ func (s *S3Backup) walObjectKey(lsn uint64) string {
 func (s *S3Backup) IsEnabled() bool {
 	return s.enabled
 }
-
@@ -0,0 +1,54 @@
+package safekeeper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/linux/projects/server/objectstore"
+)
+
+func TestS3BackupRoundTrip(t *testing.T) {
+	store := objectstore.NewMemoryStore()
+	backup := NewObjectBackup(objectstore.WithPrefix(store, "timeline-1"))
+
+	if err := backup.BackupWAL(100, []byte("record")); err != nil {
+		t.Fatalf("BackupWAL: %v", err)
+	}
+	if data, err := backup.RestoreWAL(100); err != nil || !bytes.Equal(data, []byte("record")) {
+		t.Fatalf("RestoreWAL = %q, %v", data, err)
+	}
+	if _, err := store.Get(backup.ctx, "timeline-1/wal_100"); err != nil {
+		t.Fatalf("WAL not stored under the prefix: %v", err)
+	}
+	if _, err := backup.RestoreWAL(200); !errors.Is(err, objectstore.ErrNotFound) {
+		t.Fatalf("RestoreWAL of a missing record = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3BackupFailure(t *testing.T) {
+	store := objectstore.NewMemoryStore()
+	backup := NewObjectBackup(store)
+	errDown := errors.New("bucket unavailable")
+
+	store.FailNext(objectstore.OpPut, 1, errDown)
+	if err := backup.BackupWAL(100, []byte("record")); !errors.Is(err, errDown) {
+		t.Fatalf("BackupWAL = %v, want the store error", err)
+	}
+	if err := backup.BackupWAL(100, []byte("record")); err != nil {
+		t.Fatalf("BackupWAL retry: %v", err)
+	}
+}
+
+func TestS3BackupDisabled(t *testing.T) {
+	backup, err := NewS3Backup(S3Config{})
+	if err != nil {
+		t.Fatalf("NewS3Backup: %v", err)
+	}
+	if backup.IsEnabled() {
+		t.Fatal("backup without a bucket is enabled")
+	}
+	if err := backup.BackupWAL(100, []byte("record")); err != nil {
+		t.Fatalf("BackupWAL while disabled: %v", err)
+	}
+}
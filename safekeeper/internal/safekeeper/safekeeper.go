@@ -1,13 +1,19 @@
 package safekeeper
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/linux/projects/server/common/lsnindex"
 )
 
 // Safekeeper stores WAL records with durability guarantees
@@ -17,6 +23,7 @@ type Safekeeper struct {
 	walDir    string
 	latestLSN uint64
 	lsnMu     sync.RWMutex
+	lsnIndex  *lsnindex.Index // Commit time to LSN, for timestamp branching
 
 	// Consensus
 	replicaID  string
@@ -27,6 +34,11 @@ type Safekeeper struct {
 	// Replication
 	replicationMu sync.Mutex
 	pendingWAL    map[uint64]*WALRecord // WAL waiting for replication
+	failedWAL     map[uint64]bool       // WAL that did not reach a quorum of replicas
+
+	// Background work (replication and S3 backup goroutines)
+	background     sync.WaitGroup
+	pendingBackups int64
 
 	// State
 	state   State
@@ -106,6 +118,7 @@ func NewSafekeeper(dataDir string, replicaID string, peers []string, enableCompr
 		peers:              peers,
 		quorumSize:         membership.GetQuorumSize(),
 		pendingWAL:         make(map[uint64]*WALRecord),
+		failedWAL:          make(map[uint64]bool),
 		state:              StateFollower,
 		term:               1,
 		compressionEnabled: enableCompression,
@@ -149,6 +162,13 @@ func NewSafekeeper(dataDir string, replicaID string, peers []string, enableCompr
 		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
 	}
 
+	// Open timestamp index
+	lsnIndex, err := lsnindex.Open(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LSN index: %w", err)
+	}
+	sk.lsnIndex = lsnIndex
+
 	// Create default timeline
 	if _, err := sk.timelineManager.CreateTimeline(sk.defaultTimelineID, 0, ""); err != nil {
 		log.Printf("Warning: Failed to create default timeline: %v", err)
@@ -210,7 +230,11 @@ func (sk *Safekeeper) StoreWAL(lsn uint64, walData []byte, spaceID uint32, pageN
 
 	// Backup to S3 if enabled (async)
 	if sk.s3Backup != nil && sk.s3Backup.IsEnabled() {
+		sk.background.Add(1)
+		atomic.AddInt64(&sk.pendingBackups, 1)
 		go func() {
+			defer sk.background.Done()
+			defer atomic.AddInt64(&sk.pendingBackups, -1)
 			if err := sk.s3Backup.BackupWAL(lsn, compressedData); err != nil {
 				log.Printf("Warning: S3 backup failed for LSN %d: %v", lsn, err)
 			}
@@ -223,7 +247,11 @@ func (sk *Safekeeper) StoreWAL(lsn uint64, walData []byte, spaceID uint32, pageN
 	sk.replicationMu.Unlock()
 
 	// Start replication in background
-	go sk.replicateWAL(record)
+	sk.background.Add(1)
+	go func() {
+		defer sk.background.Done()
+		sk.replicateWAL(record)
+	}()
 
 	// Wait for quorum (with timeout)
 	quorumReached := sk.waitForQuorum(record, 5*time.Second)
@@ -239,10 +267,19 @@ func (sk *Safekeeper) StoreWAL(lsn uint64, walData []byte, spaceID uint32, pageN
 	}
 	sk.lsnMu.Unlock()
 
+	if err := sk.lsnIndex.Record(time.Now(), lsn); err != nil {
+		log.Printf("Warning: Failed to index LSN %d: %v", lsn, err)
+	}
+
 	sk.walCount++
 	return nil
 }
 
+// LSNForTimestamp returns the largest LSN stored at or before t
+func (sk *Safekeeper) LSNForTimestamp(t time.Time) (lsnindex.Entry, error) {
+	return sk.lsnIndex.Lookup(t)
+}
+
 // storeWALLocal stores WAL record to local disk
 // isCompressed indicates if walData is already compressed
 func (sk *Safekeeper) storeWALLocal(lsn uint64, walData []byte, isCompressed bool) error {
@@ -286,7 +323,9 @@ func (sk *Safekeeper) storeWALLocal(lsn uint64, walData []byte, isCompressed boo
 	return nil
 }
 
-// replicateWAL replicates WAL to peer Safekeepers
+// replicateWAL replicates WAL to peer Safekeepers. The record stops being
+// pending once every peer was tried; if it did not reach a quorum it is
+// recorded as failed.
 func (sk *Safekeeper) replicateWAL(record *WALRecord) {
 	successCount := 1 // We already have it locally
 
@@ -304,6 +343,13 @@ func (sk *Safekeeper) replicateWAL(record *WALRecord) {
 	}
 
 	log.Printf("Replicated WAL LSN %d to %d/%d replicas", record.LSN, successCount, len(sk.peers)+1)
+
+	sk.replicationMu.Lock()
+	delete(sk.pendingWAL, record.LSN)
+	if successCount < sk.quorumSize {
+		sk.failedWAL[record.LSN] = true
+	}
+	sk.replicationMu.Unlock()
 }
 
 // sendWALToPeer sends WAL record to a peer Safekeeper
@@ -423,6 +469,12 @@ func (sk *Safekeeper) discoverLeader() (string, error) {
 	return "", fmt.Errorf("no leader found")
 }
 
+// SetPeerTLSConfig makes peer calls use TLS with the given client configuration.
+// Must be called before consensus is started.
+func (sk *Safekeeper) SetPeerTLSConfig(cfg *tls.Config) {
+	sk.peerClient.SetTLSConfig(cfg)
+}
+
 // SetKnownLeader sets the known leader (used when we become leader)
 func (sk *Safekeeper) SetKnownLeader(leaderEndpoint string) {
 	sk.leaderMu.Lock()
@@ -577,6 +629,83 @@ func (sk *Safekeeper) GetMetrics() map[string]interface{} {
 	return metrics
 }
 
+// ShutdownReport describes background work left unfinished at shutdown
+type ShutdownReport struct {
+	PendingReplications []uint64 // LSNs whose replication to peers had not finished
+	FailedReplications  []uint64 // LSNs that did not reach a quorum of replicas
+	PendingBackups      int      // S3 backups still in flight
+	FlushErr            error    // Set if the deadline expired before the queues drained
+}
+
+// Clean returns true if all replication and backup work completed
+func (r ShutdownReport) Clean() bool {
+	return len(r.PendingReplications) == 0 && len(r.FailedReplications) == 0 && r.PendingBackups == 0 && r.FlushErr == nil
+}
+
+// String returns a one-line summary of the report
+func (r ShutdownReport) String() string {
+	if r.Clean() {
+		return "all replication and backup work flushed"
+	}
+	s := fmt.Sprintf("pending_replications=%d pending_backups=%d", len(r.PendingReplications), r.PendingBackups)
+	if len(r.PendingReplications) > 0 {
+		s += fmt.Sprintf(" lsns=%v", r.PendingReplications)
+	}
+	if len(r.FailedReplications) > 0 {
+		s += fmt.Sprintf(" failed_replications=%d failed_lsns=%v", len(r.FailedReplications), r.FailedReplications)
+	}
+	if r.FlushErr != nil {
+		s += fmt.Sprintf(" flush_error=%q", r.FlushErr.Error())
+	}
+	return s
+}
+
+// Shutdown waits for in-flight replication and S3 backup goroutines until
+// ctx expires and reports whatever was left unflushed.
+// The HTTP server must already be shut down so no new WAL arrives.
+func (sk *Safekeeper) Shutdown(ctx context.Context) ShutdownReport {
+	var report ShutdownReport
+
+	done := make(chan struct{})
+	go func() {
+		sk.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		report.FlushErr = ctx.Err()
+	}
+
+	sk.replicationMu.Lock()
+	for lsn := range sk.pendingWAL {
+		report.PendingReplications = append(report.PendingReplications, lsn)
+	}
+	for lsn := range sk.failedWAL {
+		report.FailedReplications = append(report.FailedReplications, lsn)
+	}
+	sk.replicationMu.Unlock()
+	sort.Slice(report.PendingReplications, func(i, j int) bool {
+		return report.PendingReplications[i] < report.PendingReplications[j]
+	})
+	sort.Slice(report.FailedReplications, func(i, j int) bool {
+		return report.FailedReplications[i] < report.FailedReplications[j]
+	})
+	report.PendingBackups = int(atomic.LoadInt64(&sk.pendingBackups))
+
+	if err := sk.lsnIndex.Close(); err != nil {
+		log.Printf("Warning: Failed to close LSN index: %v", err)
+	}
+
+	// Only release the compressor once nothing can use it anymore
+	if report.Clean() && sk.compressor != nil {
+		sk.compressor.Close()
+	}
+
+	return report
+}
+
 // String returns string representation of State
 func (s State) String() string {
 	switch s {
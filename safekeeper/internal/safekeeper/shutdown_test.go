@@ -0,0 +1,46 @@
+package safekeeper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestShutdownReportsWALWithoutQuorum(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer ok.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// Two peers: a quorum is the local copy plus one peer
+	sk, err := NewSafekeeper(t.TempDir(), "sk-0", []string{ok.URL, down.URL}, false, false, nil)
+	if err != nil {
+		t.Fatalf("NewSafekeeper: %v", err)
+	}
+	replicate := func(lsn uint64) {
+		record := &WALRecord{LSN: lsn, WALData: []byte("record"), Replicas: map[string]bool{"sk-0": true}}
+		sk.pendingWAL[lsn] = record
+		sk.replicateWAL(record)
+	}
+
+	replicate(100) // One peer acknowledges
+	sk.peers = []string{down.URL, down.URL}
+	replicate(200) // Neither does
+
+	report := sk.Shutdown(context.Background())
+	if len(report.PendingReplications) != 0 {
+		t.Fatalf("pending replications %v, want none", report.PendingReplications)
+	}
+	if !slices.Equal(report.FailedReplications, []uint64{200}) {
+		t.Fatalf("failed replications %v, want [200]", report.FailedReplications)
+	}
+	if report.Clean() {
+		t.Fatal("report with a failed replication is clean")
+	}
+}